@@ -1,17 +1,23 @@
 package gbctl
 
 import (
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/inysc/GB28181/internal/pkg/app"
 	"github.com/inysc/GB28181/internal/pkg/logger"
-	"github.com/spf13/cast"
-	"github.com/spf13/viper"
 )
 
 const description = `这是一个实现了国标标准的模拟摄像头，它将实现国标的功能用于调试。
 下面是已经实现了的功能：
-	1. 注册与注销功能
+	1. 注册与注销，支持摘要认证，在注册过期前刷新注册
+	2. 定时发送心跳，心跳连续失败后重新注册
+	3. 应答设备信息、设备目录、设备状态和设备配置查询，通道在配置文件中配置
+	4. 接受点播，通过UDP或TCP以PS over RTP推送彩条测试图像或本地的H.264文件，收到BYE后停止推流
+	5. 压测模式，以连续的编码模拟大量设备，按配置的速率注册、发送带抖动的心跳、上报报警和位置，并统计延迟和失败
 
-后续还有其他功能等待支持，如果该程序有帮到你的话请去仓库给作者点一个Start吧~
+如果该程序有帮到你的话请去仓库给作者点一个Start吧~
 	https://github.com/inysc/GB28181
 `
 
@@ -28,8 +34,19 @@ func NewApp(basename string) *app.App {
 func run(opt *ctlOption) app.RunFunc {
 	return func(basename string) error {
 		logger.Init(opt.LogOption)
-		logger.Info("exec gbctl success....")
-		logger.Info(cast.ToString(viper.Get("sip.id")))
+		s, err := newSimulator(opt)
+		if err != nil {
+			return err
+		}
+		if err = s.start(); err != nil {
+			return err
+		}
+
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT)
+		<-sigCh
+		logger.Info("模拟设备退出，从平台注销...")
+		s.close()
 		return nil
 	}
 }
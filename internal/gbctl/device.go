@@ -0,0 +1,247 @@
+package gbctl
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ghettovoice/gosip"
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/gbctl/media"
+	"github.com/inysc/GB28181/internal/pkg/digest"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/option"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/pkg/errors"
+)
+
+// 注册失败后重试的间隔
+const registerRetryInterval = 10 * time.Second
+
+// 模拟的国标设备，向平台注册并保持心跳，应答平台的查询和点播
+type device struct {
+	server *option.SIPOptions
+	opt    *deviceOption
+	// 与其他模拟设备共用的sip服务
+	srv gosip.Server
+	// 为空时不统计
+	stats *stats
+	// 心跳间隔的随机抖动
+	jitter time.Duration
+	// 是否已注册到平台
+	online int32
+
+	// 注册和刷新使用相同的Call-ID
+	callId  string
+	fromTag string
+	seq     uint32
+
+	// 点播时推送的文件，为空时推送测试图像
+	file *media.FileSource
+
+	mux      sync.Mutex
+	sessions map[string]*session
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newDevice(server *option.SIPOptions, opt *deviceOption, srv gosip.Server) *device {
+	return &device{
+		server:   server,
+		opt:      opt,
+		srv:      srv,
+		callId:   digest.NewNonce(),
+		fromTag:  digest.NewNonce(),
+		sessions: make(map[string]*session),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// 开始向平台注册
+func (d *device) start() {
+	go d.run()
+}
+
+// 结束所有点播并从平台注销
+func (d *device) close() {
+	close(d.stop)
+	<-d.done
+
+	d.mux.Lock()
+	for callId, s := range d.sessions {
+		s.close()
+		delete(d.sessions, callId)
+	}
+	d.mux.Unlock()
+}
+
+// 注册成功后按心跳周期发送心跳，在注册过期前或心跳连续失败后重新注册
+func (d *device) run() {
+	defer close(d.done)
+	for {
+		start := time.Now()
+		err := d.register(d.opt.Expires)
+		d.stats.record(kindRegister, start, err)
+		if err != nil {
+			logger.Errorf("{%s}向平台%s注册失败，%s", d.opt.Id, d.server.Id, err)
+			select {
+			case <-d.stop:
+				return
+			case <-time.After(registerRetryInterval):
+				continue
+			}
+		}
+		logger.Infof("{%s}向平台%s注册成功", d.opt.Id, d.server.Id)
+		atomic.StoreInt32(&d.online, 1)
+
+		refresh := time.NewTimer(time.Duration(d.opt.Expires) * time.Second * 4 / 5)
+		stopped := d.keepalive(refresh)
+		refresh.Stop()
+		atomic.StoreInt32(&d.online, 0)
+		if stopped {
+			if err := d.register(0); err != nil {
+				logger.Errorf("{%s}从平台%s注销失败，%s", d.opt.Id, d.server.Id, err)
+			}
+			return
+		}
+	}
+}
+
+// 保持心跳直到需要重新注册，被停止时返回true
+func (d *device) keepalive(refresh *time.Timer) bool {
+	keepalive := time.NewTimer(d.keepaliveInterval())
+	defer keepalive.Stop()
+	failures := 0
+	for {
+		select {
+		case <-d.stop:
+			return true
+		case <-refresh.C:
+			return false
+		case <-keepalive.C:
+			keepalive.Reset(d.keepaliveInterval())
+			body, err := parser.CreateNotifyXML(parser.KeepaliveCmdType, d.opt.Id, parser.WithCustomKV("Status", "OK"))
+			if err != nil {
+				logger.Error(err)
+				continue
+			}
+			start := time.Now()
+			err = d.sendMessage(body)
+			d.stats.record(kindKeepalive, start, err)
+			if err != nil {
+				failures++
+				logger.Warnf("{%s}心跳失败%d次，%s", d.opt.Id, failures, err)
+				if failures >= d.opt.KeepaliveCount {
+					return false
+				}
+				continue
+			}
+			failures = 0
+		}
+	}
+}
+
+// 心跳间隔加上随机抖动，避免大量设备同时发送心跳
+func (d *device) keepaliveInterval() time.Duration {
+	interval := time.Duration(d.opt.KeepaliveInterval) * time.Second
+	if d.jitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(2*d.jitter))) - d.jitter
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return interval
+}
+
+func (d *device) isOnline() bool {
+	return atomic.LoadInt32(&d.online) == 1
+}
+
+// 向平台注册，expires为0时表示注销，平台要求认证时完成摘要认证
+func (d *device) register(expires int) error {
+	request, err := d.createRegisterRequest(expires, "", "")
+	if err != nil {
+		return err
+	}
+	response, err := d.send(request)
+	if err != nil {
+		return err
+	}
+
+	code := int(response.StatusCode())
+	if code == http.StatusUnauthorized || code == http.StatusProxyAuthRequired {
+		challengeHeader, authHeader := "WWW-Authenticate", "Authorization"
+		if code == http.StatusProxyAuthRequired {
+			challengeHeader, authHeader = "Proxy-Authenticate", "Proxy-Authorization"
+		}
+		h := response.GetHeaders(challengeHeader)
+		if len(h) == 0 {
+			return errors.Errorf("平台的认证质询中缺少%s头部", challengeHeader)
+		}
+		challenge, err := digest.ParseChallenge(h[0].Value())
+		if err != nil {
+			return errors.WithMessage(err, "解析平台的认证质询失败")
+		}
+		credentials, err := digest.Authorize(challenge, string(sip.REGISTER), request.Recipient().String(), d.opt.Id, d.server.Password)
+		if err != nil {
+			return errors.WithMessage(err, "计算注册认证信息失败")
+		}
+		if request, err = d.createRegisterRequest(expires, authHeader, credentials.String()); err != nil {
+			return err
+		}
+		if response, err = d.send(request); err != nil {
+			return err
+		}
+	}
+
+	if !response.IsSuccess() {
+		return errors.Errorf("平台拒绝了注册请求: %d %s", response.StatusCode(), response.Reason())
+	}
+	return nil
+}
+
+// 向平台发送MESSAGE请求
+func (d *device) sendMessage(body string) error {
+	response, err := d.send(d.createMessageRequest(body))
+	if err != nil {
+		return err
+	}
+	if !response.IsSuccess() {
+		return errors.Errorf("平台拒绝了消息: %d %s", response.StatusCode(), response.Reason())
+	}
+	return nil
+}
+
+func (d *device) send(request sip.Request) (sip.Response, error) {
+	logger.Debugf("向平台发送请求：\n%s", request)
+	tx, err := d.srv.Request(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "向平台发送请求失败")
+	}
+	timer := time.NewTimer(5 * time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case resp := <-tx.Responses():
+			if resp.IsProvisional() {
+				continue
+			}
+			return resp, nil
+		case <-timer.C:
+			return nil, errors.New("接收平台响应超时")
+		}
+	}
+}
+
+func (d *device) nextSeq() uint {
+	return uint(atomic.AddUint32(&d.seq, 1))
+}
+
+func respond(req sip.Request, tx sip.ServerTransaction, code int) {
+	resp := sip.NewResponseFromRequest("", req, sip.StatusCode(code), http.StatusText(code), "")
+	_ = tx.Respond(resp)
+}
@@ -0,0 +1,73 @@
+package gbctl
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ghettovoice/gosip"
+	l "github.com/ghettovoice/gosip/log"
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+// 所有模拟设备共用一个sip服务，平台的请求按目标编码分发给对应的设备
+type hub struct {
+	opt *deviceOption
+	srv gosip.Server
+
+	mux sync.RWMutex
+	// 设备编码和通道编码对应的设备
+	devices map[string]*device
+}
+
+func newHub(opt *deviceOption) *hub {
+	h := &hub{opt: opt, devices: make(map[string]*device)}
+	h.srv = gosip.NewServer(gosip.ServerConfig{Host: opt.Ip, UserAgent: opt.Name}, nil, nil, l.NewDefaultLogrusLogger())
+	_ = h.srv.OnRequest(sip.MESSAGE, h.route((*device).onMessage))
+	_ = h.srv.OnRequest(sip.INVITE, h.route((*device).onInvite))
+	_ = h.srv.OnRequest(sip.ACK, h.route((*device).onAck))
+	_ = h.srv.OnRequest(sip.BYE, h.route((*device).onBye))
+	return h
+}
+
+func (h *hub) listen() error {
+	addr := net.JoinHostPort(h.opt.Ip, h.opt.Port)
+	if err := h.srv.Listen(strings.ToLower(h.opt.Transport), addr); err != nil {
+		return errors.Wrapf(err, "监听%s失败", addr)
+	}
+	logger.Infof("模拟设备监听%s %s", h.opt.Transport, addr)
+	return nil
+}
+
+func (h *hub) add(d *device) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.devices[d.opt.Id] = d
+	for _, c := range d.opt.channels() {
+		h.devices[c.Id] = d
+	}
+}
+
+func (h *hub) route(handler func(*device, sip.Request, sip.ServerTransaction)) gosip.RequestHandler {
+	return func(req sip.Request, tx sip.ServerTransaction) {
+		id := req.Recipient().User().String()
+		h.mux.RLock()
+		d, ok := h.devices[id]
+		h.mux.RUnlock()
+		if !ok {
+			logger.Warnf("请求的目标%s不是模拟设备", id)
+			if req.Method() != sip.ACK {
+				respond(req, tx, http.StatusNotFound)
+			}
+			return
+		}
+		handler(d, req, tx)
+	}
+}
+
+func (h *hub) shutdown() {
+	h.srv.Shutdown()
+}
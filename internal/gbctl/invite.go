@@ -0,0 +1,285 @@
+package gbctl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/gbctl/media"
+	"github.com/inysc/GB28181/internal/pkg/digest"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	sdp "github.com/panjjo/gosdp"
+	"github.com/pkg/errors"
+)
+
+// 等待tcp媒体连接建立的最长时间
+const mediaConnectTimeout = 10 * time.Second
+
+// 平台点播请求中的收流信息
+type mediaOffer struct {
+	name string
+	ip   string
+	port int
+	udp  bool
+	// tcp时平台是否主动连接设备
+	active bool
+	ssrc   uint32
+}
+
+// 一次点播的媒体会话，收到ACK后开始推流，收到BYE后结束
+type session struct {
+	callId    string
+	channelId string
+	offer     mediaOffer
+	localPort int
+
+	udp *net.UDPConn
+	ln  *net.TCPListener
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (d *device) onInvite(req sip.Request, tx sip.ServerTransaction) {
+	channelId := req.Recipient().User().String()
+	if !d.hasChannel(channelId) {
+		logger.Warnf("点播的通道%s不存在", channelId)
+		respond(req, tx, http.StatusNotFound)
+		return
+	}
+	offer, err := parseOffer(req.Body())
+	if err != nil {
+		logger.Errorf("解析点播请求失败，%s", err)
+		respond(req, tx, http.StatusBadRequest)
+		return
+	}
+	callId, ok := req.CallID()
+	if !ok {
+		respond(req, tx, http.StatusBadRequest)
+		return
+	}
+
+	s := &session{callId: callId.Value(), channelId: channelId, offer: offer, done: make(chan struct{})}
+	if err = s.prepare(d.opt.Ip); err != nil {
+		logger.Errorf("准备媒体传输失败，%s", err)
+		respond(req, tx, http.StatusInternalServerError)
+		return
+	}
+	d.mux.Lock()
+	d.sessions[s.callId] = s
+	d.mux.Unlock()
+
+	resp := sip.NewResponseFromRequest("", req, http.StatusOK, http.StatusText(http.StatusOK), s.answer(d.opt.Ip))
+	if to, ok := resp.To(); ok {
+		if to.Params == nil {
+			to.Params = sip.NewParams()
+		}
+		if !to.Params.Has("tag") {
+			to.Params.Add("tag", sip.String{Str: digest.NewNonce()})
+		}
+	}
+	contentType := sip.ContentType(contentTypeSDP)
+	resp.AppendHeader(&contentType)
+	resp.AppendHeader(d.contact().AsContactHeader())
+	logger.Infof("{%s}收到%s请求，向%s:%d推流", channelId, offer.name, offer.ip, offer.port)
+	_ = tx.Respond(resp)
+}
+
+func (d *device) onAck(req sip.Request, _ sip.ServerTransaction) {
+	callId, ok := req.CallID()
+	if !ok {
+		return
+	}
+	d.mux.Lock()
+	s, ok := d.sessions[callId.Value()]
+	// 重传的ACK不重复推流
+	if !ok || s.cancel != nil {
+		d.mux.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	d.mux.Unlock()
+
+	go func() {
+		defer close(s.done)
+		if err := s.stream(ctx, d.source(), d.opt.Media.Fps); err != nil {
+			logger.Errorf("{%s}推流结束，%s", s.channelId, err)
+		}
+	}()
+}
+
+func (d *device) onBye(req sip.Request, tx sip.ServerTransaction) {
+	respond(req, tx, http.StatusOK)
+	callId, ok := req.CallID()
+	if !ok {
+		return
+	}
+	d.mux.Lock()
+	s, ok := d.sessions[callId.Value()]
+	delete(d.sessions, callId.Value())
+	d.mux.Unlock()
+	if ok {
+		logger.Infof("{%s}点播结束", s.channelId)
+		s.close()
+	}
+}
+
+func (d *device) hasChannel(id string) bool {
+	for _, c := range d.opt.channels() {
+		if c.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// 每次点播都从头推送
+func (d *device) source() media.Source {
+	if d.file == nil {
+		return media.NewTestPattern(d.opt.Media.Fps)
+	}
+	f := *d.file
+	return &f
+}
+
+// 在应答前确定本端的媒体端口，tcp被动时先开始监听
+func (s *session) prepare(ip string) error {
+	var err error
+	switch {
+	case s.offer.udp:
+		s.udp, err = net.DialUDP("udp", &net.UDPAddr{IP: net.ParseIP(ip)}, &net.UDPAddr{IP: net.ParseIP(s.offer.ip), Port: s.offer.port})
+		if err == nil {
+			s.localPort = s.udp.LocalAddr().(*net.UDPAddr).Port
+		}
+	case s.offer.active:
+		s.ln, err = net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP(ip)})
+		if err == nil {
+			s.localPort = s.ln.Addr().(*net.TCPAddr).Port
+		}
+	default:
+		// 主动连接平台时使用的本地端口，连接时再绑定
+		var ln *net.TCPListener
+		ln, err = net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP(ip)})
+		if err == nil {
+			s.localPort = ln.Addr().(*net.TCPAddr).Port
+			_ = ln.Close()
+		}
+	}
+	return errors.Wrap(err, "创建媒体连接失败")
+}
+
+func (s *session) connect(ctx context.Context) (io.WriteCloser, error) {
+	if s.udp != nil {
+		return s.udp, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, mediaConnectTimeout)
+	defer cancel()
+	if s.ln != nil {
+		_ = s.ln.SetDeadline(time.Now().Add(mediaConnectTimeout))
+		conn, err := s.ln.Accept()
+		_ = s.ln.Close()
+		return conn, errors.Wrap(err, "等待平台连接超时")
+	}
+	dialer := net.Dialer{LocalAddr: &net.TCPAddr{Port: s.localPort}}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.offer.ip, strconv.Itoa(s.offer.port)))
+	return conn, errors.Wrap(err, "连接平台失败")
+}
+
+func (s *session) stream(ctx context.Context, src media.Source, fps int) error {
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return media.Stream(ctx, src, media.NewSender(conn, !s.offer.udp, s.offer.ssrc), fps)
+}
+
+func (s *session) close() {
+	if s.cancel == nil {
+		// 还没有开始推流
+		if s.udp != nil {
+			_ = s.udp.Close()
+		}
+		if s.ln != nil {
+			_ = s.ln.Close()
+		}
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// 创建应答的sdp，本端是发送方
+func (s *session) answer(ip string) string {
+	protocol := "RTP/AVP"
+	if !s.offer.udp {
+		protocol = "TCP/RTP/AVP"
+	}
+	var b strings.Builder
+	b.WriteString("v=0\r\n")
+	b.WriteString(fmt.Sprintf("o=%s 0 0 IN IP4 %s\r\n", s.channelId, ip))
+	b.WriteString(fmt.Sprintf("s=%s\r\n", s.offer.name))
+	b.WriteString(fmt.Sprintf("c=IN IP4 %s\r\n", ip))
+	b.WriteString("t=0 0\r\n")
+	b.WriteString(fmt.Sprintf("m=video %d %s 96\r\n", s.localPort, protocol))
+	b.WriteString("a=sendonly\r\n")
+	b.WriteString("a=rtpmap:96 PS/90000\r\n")
+	if !s.offer.udp {
+		setup := "active"
+		if s.offer.active {
+			setup = "passive"
+		}
+		b.WriteString("a=setup:" + setup + "\r\n")
+		b.WriteString("a=connection:new\r\n")
+	}
+	b.WriteString(fmt.Sprintf("y=%010d\r\n", s.offer.ssrc))
+	return b.String()
+}
+
+// 解析平台点播、回放或下载请求中的sdp，回放和下载同样推送测试媒体
+func parseOffer(body string) (mediaOffer, error) {
+	msg, err := sdp.Decode([]byte(body))
+	if err != nil {
+		return mediaOffer{}, errors.WithMessage(err, "解析sdp失败")
+	}
+	if len(msg.Medias) == 0 {
+		return mediaOffer{}, errors.New("sdp中没有媒体描述")
+	}
+	m := msg.Medias[0]
+	offer := mediaOffer{
+		name: msg.Name,
+		port: m.Description.Port,
+		udp:  !strings.HasPrefix(strings.ToUpper(m.Description.Protocol), "TCP"),
+	}
+	if !offer.udp {
+		offer.active = m.Attributes.Value("setup") == "active"
+	}
+	ip := m.Connection.IP
+	if ip == nil {
+		ip = msg.Connection.IP
+	}
+	if ip == nil {
+		return mediaOffer{}, errors.New("sdp中没有收流地址")
+	}
+	offer.ip = ip.String()
+
+	// sdp解析库不解析GB28181扩展的y字段
+	for _, line := range strings.Split(body, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "y=") {
+			ssrc, _ := strconv.ParseUint(strings.TrimPrefix(line, "y="), 10, 32)
+			offer.ssrc = uint32(ssrc)
+		}
+	}
+	if offer.ssrc == 0 {
+		offer.ssrc = rand.Uint32()
+	}
+	return offer, nil
+}
@@ -0,0 +1,335 @@
+package media
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// H.264的nal单元类型
+const (
+	nalSlice = 1
+	nalIDR   = 5
+	nalSEI   = 6
+	nalSPS   = 7
+	nalPPS   = 8
+	nalAUD   = 9
+)
+
+var startCode = []byte{0, 0, 0, 1}
+
+// Source 按帧提供H.264访问单元，每一帧都是带起始码的Annex-B格式
+type Source interface {
+	// Next 返回下一帧以及该帧是否为关键帧
+	Next() ([]byte, bool)
+}
+
+// 测试图像的宽高，以16×16的宏块为单位
+const (
+	patternMbWidth  = 20
+	patternMbHeight = 15
+)
+
+// 八条标准彩条的YCbCr取值：白、黄、青、绿、品红、红、蓝、黑
+var colorBars = [8][3]byte{
+	{235, 128, 128},
+	{210, 16, 146},
+	{170, 166, 16},
+	{145, 54, 34},
+	{106, 202, 222},
+	{81, 90, 240},
+	{41, 240, 110},
+	{16, 128, 128},
+}
+
+// TestPattern 320×240的彩条测试图像，不依赖编码器
+//
+// 关键帧的宏块全部以I_PCM方式直接携带像素，其余帧全部跳过宏块，每个关键帧彩条移动一格
+type TestPattern struct {
+	gop   int
+	frame int
+	idr   int
+}
+
+// NewTestPattern 创建测试图像，gop为关键帧间隔
+func NewTestPattern(gop int) *TestPattern {
+	if gop <= 0 {
+		gop = 25
+	}
+	return &TestPattern{gop: gop}
+}
+
+func (p *TestPattern) Next() ([]byte, bool) {
+	defer func() {
+		p.frame++
+	}()
+	n := p.frame % p.gop
+	if n != 0 {
+		return nal(nalSlice, 2, p.skipSlice(n)), false
+	}
+
+	frame := append(nal(nalSPS, 3, sps()), nal(nalPPS, 3, pps())...)
+	frame = append(frame, nal(nalIDR, 3, p.idrSlice())...)
+	p.idr++
+	return frame, true
+}
+
+// Baseline档次，帧号4位，图像顺序号类型2，只参考一帧
+func sps() []byte {
+	w := &bitWriter{}
+	w.u(8, 66)
+	w.u(8, 0xC0)
+	w.u(8, 30)
+	w.ue(0)
+	w.ue(0)
+	w.ue(2)
+	w.ue(1)
+	w.u(1, 0)
+	w.ue(patternMbWidth - 1)
+	w.ue(patternMbHeight - 1)
+	w.u(1, 1)
+	w.u(1, 1)
+	w.u(1, 0)
+	w.u(1, 0)
+	w.trailing()
+	return w.bytes()
+}
+
+// CAVLC，开启去块滤波控制以便在条带中关闭滤波
+func pps() []byte {
+	w := &bitWriter{}
+	w.ue(0)
+	w.ue(0)
+	w.u(1, 0)
+	w.u(1, 0)
+	w.ue(0)
+	w.ue(0)
+	w.ue(0)
+	w.u(1, 0)
+	w.u(2, 0)
+	w.se(0)
+	w.se(0)
+	w.se(0)
+	w.u(1, 1)
+	w.u(1, 0)
+	w.u(1, 0)
+	w.trailing()
+	return w.bytes()
+}
+
+func (p *TestPattern) idrSlice() []byte {
+	w := &bitWriter{}
+	w.ue(0)
+	w.ue(7)
+	w.ue(0)
+	w.u(4, 0)
+	// 相邻IDR的idr_pic_id不能相同
+	w.ue(uint32(p.idr % 2))
+	w.u(1, 0)
+	w.u(1, 0)
+	w.se(0)
+	w.ue(1)
+
+	shift := p.idr % len(colorBars)
+	for mby := 0; mby < patternMbHeight; mby++ {
+		for mbx := 0; mbx < patternMbWidth; mbx++ {
+			// I_PCM
+			w.ue(25)
+			w.align()
+			for y := 0; y < 16; y++ {
+				for x := 0; x < 16; x++ {
+					w.u(8, uint32(bar(mbx*16+x, patternMbWidth*16, shift)[0]))
+				}
+			}
+			for c := 1; c <= 2; c++ {
+				for y := 0; y < 8; y++ {
+					for x := 0; x < 8; x++ {
+						w.u(8, uint32(bar(mbx*8+x, patternMbWidth*8, shift)[c]))
+					}
+				}
+			}
+		}
+	}
+	w.trailing()
+	return w.bytes()
+}
+
+// 所有宏块都跳过，画面与参考帧相同
+func (p *TestPattern) skipSlice(n int) []byte {
+	w := &bitWriter{}
+	w.ue(0)
+	w.ue(5)
+	w.ue(0)
+	w.u(4, uint32(n%16))
+	w.u(1, 0)
+	w.u(1, 0)
+	w.u(1, 0)
+	w.se(0)
+	w.ue(1)
+	w.ue(patternMbWidth * patternMbHeight)
+	w.trailing()
+	return w.bytes()
+}
+
+func bar(x, width, shift int) [3]byte {
+	return colorBars[(x*len(colorBars)/width+shift)%len(colorBars)]
+}
+
+// FileSource 循环读取H.264裸流文件
+type FileSource struct {
+	frames [][]byte
+	keys   []bool
+	i      int
+}
+
+// NewFileSource 读取Annex-B格式的H.264文件并按访问单元切分
+func NewFileSource(path string) (*FileSource, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取媒体文件失败")
+	}
+	s := &FileSource{}
+	var (
+		cur      []byte
+		key, vcl bool
+	)
+	flush := func() {
+		if len(cur) > 0 {
+			s.frames = append(s.frames, cur)
+			s.keys = append(s.keys, key)
+		}
+		cur, key, vcl = nil, false, false
+	}
+	for _, n := range splitNAL(b) {
+		t := n[0] & 0x1F
+		isVCL := t == nalSlice || t == nalIDR
+		// 参数集、SEI和AUD在条带之后出现，或者新图像的第一个条带，都表示新的访问单元开始
+		if vcl && ((t >= nalSEI && t <= nalAUD) || (isVCL && len(n) > 1 && n[1]&0x80 != 0)) {
+			flush()
+		}
+		cur = append(cur, startCode...)
+		cur = append(cur, n...)
+		if isVCL {
+			vcl = true
+		}
+		if t == nalIDR {
+			key = true
+		}
+	}
+	flush()
+	if len(s.frames) == 0 {
+		return nil, errors.Errorf("%s中没有H.264数据", path)
+	}
+	return s, nil
+}
+
+func (s *FileSource) Next() ([]byte, bool) {
+	i := s.i
+	s.i = (s.i + 1) % len(s.frames)
+	return s.frames[i], s.keys[i]
+}
+
+// 按起始码切分nal单元，返回的nal不包含起始码
+func splitNAL(b []byte) [][]byte {
+	var nals [][]byte
+	start := -1
+	for i := 0; i+2 < len(b); i++ {
+		if b[i] != 0 || b[i+1] != 0 || b[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			nals = appendNAL(nals, b[start:i])
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 {
+		nals = appendNAL(nals, b[start:])
+	}
+	return nals
+}
+
+func appendNAL(nals [][]byte, n []byte) [][]byte {
+	// 四字节起始码多出的0属于前一个nal的末尾
+	n = bytes.TrimRight(n, "\x00")
+	if len(n) == 0 {
+		return nals
+	}
+	return append(nals, n)
+}
+
+// 加上起始码和nal头，并插入防竞争字节
+func nal(unitType, refIdc byte, rbsp []byte) []byte {
+	out := make([]byte, 0, len(rbsp)+len(rbsp)/64+5)
+	out = append(out, startCode...)
+	out = append(out, refIdc<<5|unitType)
+	zeros := 0
+	for _, b := range rbsp {
+		if zeros == 2 && b <= 3 {
+			out = append(out, 3)
+			zeros = 0
+		}
+		out = append(out, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// 按位写入码流
+type bitWriter struct {
+	buf []byte
+	cur byte
+	n   uint8
+}
+
+func (w *bitWriter) u(bits int, v uint32) {
+	for i := bits - 1; i >= 0; i-- {
+		w.cur = w.cur<<1 | byte(v>>uint(i)&1)
+		w.n++
+		if w.n == 8 {
+			w.buf = append(w.buf, w.cur)
+			w.cur, w.n = 0, 0
+		}
+	}
+}
+
+// 无符号指数哥伦布编码
+func (w *bitWriter) ue(v uint32) {
+	v++
+	bits := 0
+	for t := v; t > 0; t >>= 1 {
+		bits++
+	}
+	w.u(bits-1, 0)
+	w.u(bits, v)
+}
+
+// 有符号指数哥伦布编码
+func (w *bitWriter) se(v int32) {
+	if v > 0 {
+		w.ue(uint32(2*v - 1))
+		return
+	}
+	w.ue(uint32(-2 * v))
+}
+
+func (w *bitWriter) align() {
+	if w.n > 0 {
+		w.u(int(8-w.n), 0)
+	}
+}
+
+// rbsp结尾的停止位和对齐
+func (w *bitWriter) trailing() {
+	w.u(1, 1)
+	w.align()
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.buf
+}
@@ -0,0 +1,133 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// 按位读取码流，用于校验生成的语法元素
+type bitReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *bitReader) u(bits int) uint32 {
+	var v uint32
+	for i := 0; i < bits; i++ {
+		v = v<<1 | uint32(r.b[r.pos/8]>>(7-uint(r.pos%8))&1)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) ue() uint32 {
+	zeros := 0
+	for r.u(1) == 0 {
+		zeros++
+	}
+	return 1<<uint(zeros) - 1 + r.u(zeros)
+}
+
+// 去掉起始码、nal头和防竞争字节
+func rbsp(n []byte) []byte {
+	n = bytes.TrimPrefix(n, startCode)[1:]
+	return bytes.ReplaceAll(n, []byte{0, 0, 3}, []byte{0, 0})
+}
+
+func TestTestPattern(t *testing.T) {
+	convey.Convey("TestTestPattern", t, func() {
+		p := NewTestPattern(3)
+		frame, key := p.Next()
+		convey.So(key, convey.ShouldBeTrue)
+
+		nals := splitNAL(frame)
+		convey.So(len(nals), convey.ShouldEqual, 3)
+		convey.So(nals[0][0]&0x1F, convey.ShouldEqual, nalSPS)
+		convey.So(nals[2][0]&0x1F, convey.ShouldEqual, nalIDR)
+
+		r := &bitReader{b: rbsp(append(startCode, nals[0]...))}
+		convey.So(r.u(8), convey.ShouldEqual, 66)
+		r.u(16)
+		convey.So(r.ue(), convey.ShouldEqual, 0)
+		convey.So(r.ue(), convey.ShouldEqual, 0)
+		convey.So(r.ue(), convey.ShouldEqual, 2)
+		convey.So(r.ue(), convey.ShouldEqual, 1)
+		r.u(1)
+		convey.So(r.ue(), convey.ShouldEqual, patternMbWidth-1)
+		convey.So(r.ue(), convey.ShouldEqual, patternMbHeight-1)
+
+		// 每个宏块在mb_type和对齐之后携带384字节像素
+		r = &bitReader{b: rbsp(append(startCode, nals[2]...))}
+		convey.So([]uint32{r.ue(), r.ue(), r.ue(), r.u(4), r.ue(), r.u(2)}, convey.ShouldResemble, []uint32{0, 7, 0, 0, 0, 0})
+		r.ue()
+		r.ue()
+		for i := 0; i < patternMbWidth*patternMbHeight; i++ {
+			convey.So(r.ue(), convey.ShouldEqual, 25)
+			if r.pos%8 != 0 {
+				convey.So(r.u(8-r.pos%8), convey.ShouldEqual, 0)
+			}
+			r.pos += 384 * 8
+		}
+		convey.So(r.u(1), convey.ShouldEqual, 1)
+		convey.So(len(r.b)*8-r.pos, convey.ShouldBeLessThan, 8)
+
+		frame, key = p.Next()
+		convey.So(key, convey.ShouldBeFalse)
+		r = &bitReader{b: rbsp(frame)}
+		convey.So([]uint32{r.ue(), r.ue(), r.ue(), r.u(4)}, convey.ShouldResemble, []uint32{0, 5, 0, 1})
+		r.u(3)
+		r.ue()
+		r.ue()
+		convey.So(r.ue(), convey.ShouldEqual, patternMbWidth*patternMbHeight)
+
+		p.Next()
+		_, key = p.Next()
+		convey.So(key, convey.ShouldBeTrue)
+	})
+}
+
+func TestMuxPS(t *testing.T) {
+	convey.Convey("TestMuxPS", t, func() {
+		convey.So(crc32MPEG2([]byte("123456789")), convey.ShouldEqual, 0x0376E6E7)
+
+		frame := bytes.Repeat([]byte{1}, maxPESPayload+100)
+		ps := MuxPS(frame, 3600, true)
+		convey.So(ps[:4], convey.ShouldResemble, []byte{0, 0, 1, 0xBA})
+		convey.So(ps[14:18], convey.ShouldResemble, []byte{0, 0, 1, 0xBB})
+
+		// 跳过系统头和节目流映射后，两个PES包的负载之和等于原始帧
+		pos := 14 + 6 + int(binary.BigEndian.Uint16(ps[18:]))
+		convey.So(ps[pos:pos+4], convey.ShouldResemble, []byte{0, 0, 1, 0xBC})
+		pos += 6 + int(binary.BigEndian.Uint16(ps[pos+4:]))
+		var payload []byte
+		for pos < len(ps) {
+			convey.So(ps[pos:pos+4], convey.ShouldResemble, []byte{0, 0, 1, videoStreamId})
+			length := int(binary.BigEndian.Uint16(ps[pos+4:]))
+			header := 9 + int(ps[pos+8])
+			payload = append(payload, ps[pos+header:pos+6+length]...)
+			pos += 6 + length
+		}
+		convey.So(payload, convey.ShouldResemble, frame)
+	})
+}
+
+func TestSender(t *testing.T) {
+	convey.Convey("TestSender", t, func() {
+		var buf bytes.Buffer
+		s := NewSender(&buf, true, 0x01020304)
+		convey.So(s.Send(make([]byte, maxRTPPayload+1), 90000), convey.ShouldBeNil)
+
+		b := buf.Bytes()
+		first := int(binary.BigEndian.Uint16(b))
+		convey.So(first, convey.ShouldEqual, 12+maxRTPPayload)
+		convey.So(b[3]&0x80, convey.ShouldEqual, 0)
+		second := b[2+first:]
+		convey.So(binary.BigEndian.Uint16(second), convey.ShouldEqual, 13)
+		convey.So(second[3], convey.ShouldEqual, 0x80|payloadTypePS)
+		convey.So(binary.BigEndian.Uint32(second[6:]), convey.ShouldEqual, 90000)
+		convey.So(binary.BigEndian.Uint32(second[10:]), convey.ShouldEqual, 0x01020304)
+	})
+}
@@ -0,0 +1,115 @@
+package media
+
+import "encoding/binary"
+
+const (
+	// PS流中视频的流id
+	videoStreamId = 0xE0
+	// 节目流映射中H.264的流类型
+	streamTypeH264 = 0x1B
+	// 每个PES包最多携带的负载，PES长度字段只有16位
+	maxPESPayload = 0xFFFF - 8
+	// 以50字节/秒为单位的复用码率，只用于填写头部
+	muxRate = 6106
+)
+
+// MuxPS 把一帧H.264封装为PS，关键帧前加系统头和节目流映射，pts以90kHz为单位
+func MuxPS(frame []byte, pts uint64, key bool) []byte {
+	out := make([]byte, 0, len(frame)+64+len(frame)/maxPESPayload*14)
+	out = packHeader(out, pts)
+	if key {
+		out = systemHeader(out)
+		out = programStreamMap(out)
+	}
+	for first := true; len(frame) > 0 || first; first = false {
+		n := len(frame)
+		if n > maxPESPayload {
+			n = maxPESPayload
+		}
+		out = pes(out, frame[:n], pts, first)
+		frame = frame[n:]
+	}
+	return out
+}
+
+func packHeader(out []byte, scr uint64) []byte {
+	return append(out,
+		0x00, 0x00, 0x01, 0xBA,
+		0x44|byte(scr>>27&0x38)|byte(scr>>28&0x03),
+		byte(scr>>20),
+		0x04|byte(scr>>12&0xF8)|byte(scr>>13&0x03),
+		byte(scr>>5),
+		0x04|byte(scr<<3&0xF8),
+		0x01,
+		byte(muxRate>>14),
+		byte(muxRate>>6),
+		byte(muxRate<<2&0xFC)|0x03,
+		0xF8,
+	)
+}
+
+func systemHeader(out []byte) []byte {
+	return append(out,
+		0x00, 0x00, 0x01, 0xBB,
+		0x00, 0x09,
+		0x80|byte(muxRate>>15),
+		byte(muxRate>>7&0xFF),
+		byte(muxRate<<1&0xFE)|0x01,
+		0x04,
+		0xE1,
+		0xFF,
+		videoStreamId, 0xE8, 0x00,
+	)
+}
+
+func programStreamMap(out []byte) []byte {
+	start := len(out)
+	out = append(out,
+		0x00, 0x00, 0x01, 0xBC,
+		0x00, 0x0E,
+		0xE0, 0xFF,
+		0x00, 0x00,
+		0x00, 0x04,
+		streamTypeH264, videoStreamId, 0x00, 0x00,
+	)
+	return binary.BigEndian.AppendUint32(out, crc32MPEG2(out[start:]))
+}
+
+// 一帧拆成多个PES包时只在第一个包中携带pts
+func pes(out, payload []byte, pts uint64, withPTS bool) []byte {
+	headerLen := 0
+	if withPTS {
+		headerLen = 5
+	}
+	length := 3 + headerLen + len(payload)
+	out = append(out, 0x00, 0x00, 0x01, videoStreamId, byte(length>>8), byte(length), 0x80)
+	if !withPTS {
+		out = append(out, 0x00, 0x00)
+		return append(out, payload...)
+	}
+	out = append(out,
+		0x80, 0x05,
+		0x21|byte(pts>>29&0x0E),
+		byte(pts>>22),
+		0x01|byte(pts>>14&0xFE),
+		byte(pts>>7),
+		0x01|byte(pts<<1&0xFE),
+	)
+	return append(out, payload...)
+}
+
+// MPEG-2使用的CRC32，多项式0x04C11DB7，不反转
+func crc32MPEG2(b []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, v := range b {
+		crc ^= uint32(v) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,93 @@
+package media
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// PS流的rtp负载类型
+	payloadTypePS = 96
+	// 每个rtp包最多携带的负载，避免超过以太网MTU
+	maxRTPPayload = 1400
+	// rtp时间戳的时钟频率
+	clockRate = 90000
+)
+
+// Sender 以RTP发送PS流，tcp传输时按RFC 4571在每个包前加两字节长度
+type Sender struct {
+	w    io.Writer
+	tcp  bool
+	ssrc uint32
+	seq  uint16
+}
+
+func NewSender(w io.Writer, tcp bool, ssrc uint32) *Sender {
+	return &Sender{
+		w:    w,
+		tcp:  tcp,
+		ssrc: ssrc,
+		seq:  uint16(rand.Intn(0xFFFF)),
+	}
+}
+
+// Send 把一帧的PS数据分成多个rtp包发送，最后一个包设置marker
+func (s *Sender) Send(ps []byte, timestamp uint32) error {
+	packet := make([]byte, 0, 2+12+maxRTPPayload)
+	for len(ps) > 0 {
+		n := len(ps)
+		if n > maxRTPPayload {
+			n = maxRTPPayload
+		}
+		marker := byte(0)
+		if n == len(ps) {
+			marker = 0x80
+		}
+
+		packet = packet[:0]
+		if s.tcp {
+			packet = binary.BigEndian.AppendUint16(packet, uint16(12+n))
+		}
+		packet = append(packet, 0x80, marker|payloadTypePS)
+		packet = binary.BigEndian.AppendUint16(packet, s.seq)
+		packet = binary.BigEndian.AppendUint32(packet, timestamp)
+		packet = binary.BigEndian.AppendUint32(packet, s.ssrc)
+		packet = append(packet, ps[:n]...)
+		if _, err := s.w.Write(packet); err != nil {
+			return errors.Wrap(err, "发送rtp包失败")
+		}
+		s.seq++
+		ps = ps[n:]
+	}
+	return nil
+}
+
+// Stream 按帧率从源读取帧，封装为PS后发送，直到ctx结束或发送失败
+func Stream(ctx context.Context, src Source, s *Sender, fps int) error {
+	if fps <= 0 {
+		fps = 25
+	}
+	interval := time.Second / time.Duration(fps)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pts uint64
+	for {
+		frame, key := src.Next()
+		if err := s.Send(MuxPS(frame, pts, key), uint32(pts)); err != nil {
+			return err
+		}
+		pts += clockRate / uint64(fps)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package gbctl
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+)
+
+// 目录应答每个分包携带的通道数，避免UDP报文过大
+const catalogItemsPerPacket = 5
+
+// 平台发来的查询、控制和配置请求
+type request struct {
+	CmdType    string `xml:"CmdType"`
+	SN         string `xml:"SN"`
+	DeviceID   string `xml:"DeviceID"`
+	ConfigType string `xml:"ConfigType"`
+}
+
+func (d *device) onMessage(req sip.Request, tx sip.ServerTransaction) {
+	key, err := parser.GetCmdTypeFromXML(req.Body())
+	if err != nil {
+		logger.Error(err)
+		respond(req, tx, http.StatusBadRequest)
+		return
+	}
+	r := request{}
+	if err = parser.XmlStringDecode(req.Body(), &r); err != nil {
+		logger.Error(err)
+		respond(req, tx, http.StatusBadRequest)
+		return
+	}
+	logger.Debugf("收到平台的%s请求\n%s", key, req.Body())
+	respond(req, tx, http.StatusOK)
+
+	// 应答在新的MESSAGE中发送，不能阻塞当前事务
+	go func() {
+		if err := d.answer(key, r); err != nil {
+			logger.Errorf("应答%s失败，%s", key, err)
+		}
+	}()
+}
+
+func (d *device) answer(key string, r request) error {
+	switch key {
+	case "Query:DeviceInfo":
+		return d.reply(parser.DeviceInfoCmdType, r.SN,
+			parser.WithCustomKV("Result", "OK"),
+			parser.WithCustomKV("DeviceName", d.opt.Name),
+			parser.WithCustomKV("Manufacturer", d.opt.Manufacturer),
+			parser.WithCustomKV("Model", d.opt.Model),
+			parser.WithCustomKV("Firmware", d.opt.Firmware),
+			parser.WithCustomKV("Channel", strconv.Itoa(len(d.opt.channels()))))
+	case "Query:Catalog":
+		return d.catalog(r.SN)
+	case "Query:DeviceStatus":
+		return d.reply(parser.DeviceStatusCmdType, r.SN,
+			parser.WithCustomKV("Result", "OK"),
+			parser.WithCustomKV("Online", "ONLINE"),
+			parser.WithCustomKV("Status", "OK"),
+			parser.WithCustomKV("DeviceTime", time.Now().Format(model.GBTimeLayout)),
+			parser.WithCustomKV("Encode", "ON"),
+			parser.WithCustomKV("Record", "OFF"))
+	case "Query:ConfigDownload":
+		return d.reply(parser.ConfigDownloadCmdType, r.SN, parser.WithCustomKV("Result", "OK"), d.withConfig(r.ConfigType))
+	case "Control:DeviceControl", "Control:DeviceConfig":
+		// 模拟设备不执行控制命令，只返回成功
+		return d.reply(parser.QueryType(r.CmdType), r.SN, parser.WithCustomKV("Result", "OK"))
+	default:
+		logger.Debugf("不支持的请求%s，已忽略", key)
+		return nil
+	}
+}
+
+func (d *device) reply(cmd parser.QueryType, sn string, kvs ...parser.WithKeyValue) error {
+	body, err := parser.CreateResponseXML(cmd, sn, d.opt.Id, kvs...)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = d.sendMessage(body)
+	d.stats.record(kindResponse, start, err)
+	return err
+}
+
+// 以随机的报警方式上报一条通道报警
+func (d *device) notifyAlarm() error {
+	channels := d.opt.channels()
+	channel := channels[rand.Intn(len(channels))]
+	body, err := parser.CreateNotifyXML(parser.AlarmCmdType, channel.Id,
+		parser.WithCustomKV("AlarmPriority", strconv.Itoa(rand.Intn(4)+1)),
+		parser.WithCustomKV("AlarmMethod", strconv.Itoa(rand.Intn(7)+1)),
+		parser.WithCustomKV("AlarmTime", time.Now().Format(model.GBTimeLayout)),
+		parser.WithCustomKV("AlarmDescription", "simulated alarm"))
+	if err != nil {
+		return err
+	}
+	return d.sendMessage(body)
+}
+
+// 在初始位置附近随机上报移动设备位置
+func (d *device) notifyPosition() error {
+	body, err := parser.CreateNotifyXML(parser.MobilePositionCmdType, d.opt.Id,
+		parser.WithCustomKV("Time", time.Now().Format(model.GBTimeLayout)),
+		parser.WithCustomKV("Longitude", strconv.FormatFloat(113.26+rand.Float64()/100, 'f', 6, 64)),
+		parser.WithCustomKV("Latitude", strconv.FormatFloat(23.13+rand.Float64()/100, 'f', 6, 64)),
+		parser.WithCustomKV("Speed", strconv.Itoa(rand.Intn(120))),
+		parser.WithCustomKV("Direction", strconv.Itoa(rand.Intn(360))),
+		parser.WithCustomKV("Altitude", strconv.Itoa(rand.Intn(100))))
+	if err != nil {
+		return err
+	}
+	return d.sendMessage(body)
+}
+
+// 以分包的方式返回通道目录
+func (d *device) catalog(sn string) error {
+	channels := d.opt.channels()
+	sum := strconv.Itoa(len(channels))
+	for start := 0; start < len(channels); start += catalogItemsPerPacket {
+		end := start + catalogItemsPerPacket
+		if end > len(channels) {
+			end = len(channels)
+		}
+		if err := d.reply(parser.CatalogCmdType, sn, parser.WithCustomKV("SumNum", sum), d.withCatalogItems(channels[start:end])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *device) withCatalogItems(channels []channelOption) parser.WithKeyValue {
+	return func(element *etree.Element) {
+		list := element.CreateElement("DeviceList")
+		list.CreateAttr("Num", strconv.Itoa(len(channels)))
+		for _, ch := range channels {
+			item := list.CreateElement("Item")
+			item.CreateElement("DeviceID").CreateText(ch.Id)
+			item.CreateElement("Name").CreateText(ch.Name)
+			item.CreateElement("Manufacturer").CreateText(d.opt.Manufacturer)
+			item.CreateElement("Model").CreateText(d.opt.Model)
+			item.CreateElement("Owner").CreateText("Owner")
+			item.CreateElement("CivilCode").CreateText(civilCode(ch.Id))
+			item.CreateElement("Address").CreateText("Address")
+			item.CreateElement("Parental").CreateText("0")
+			item.CreateElement("ParentID").CreateText(d.opt.Id)
+			item.CreateElement("SafetyWay").CreateText("0")
+			item.CreateElement("RegisterWay").CreateText("1")
+			item.CreateElement("Secrecy").CreateText("0")
+			item.CreateElement("Status").CreateText("ON")
+		}
+	}
+}
+
+// 只支持基本参数配置，其余配置类型只返回成功
+func (d *device) withConfig(configType string) parser.WithKeyValue {
+	return func(element *etree.Element) {
+		for _, t := range strings.Split(configType, "/") {
+			if t != "BasicParam" {
+				continue
+			}
+			basic := element.CreateElement("BasicParam")
+			basic.CreateElement("Name").CreateText(d.opt.Name)
+			basic.CreateElement("DeviceID").CreateText(d.opt.Id)
+			basic.CreateElement("SIPServerID").CreateText(d.server.Id)
+			basic.CreateElement("SIPServerIP").CreateText(d.server.Ip)
+			basic.CreateElement("SIPServerPort").CreateText(d.server.Port)
+			basic.CreateElement("DomainName").CreateText(d.server.Domain)
+			basic.CreateElement("Expiration").CreateText(strconv.Itoa(d.opt.Expires))
+			basic.CreateElement("HeartBeatInterval").CreateText(strconv.Itoa(d.opt.KeepaliveInterval))
+			basic.CreateElement("HeartBeatCount").CreateText(strconv.Itoa(d.opt.KeepaliveCount))
+		}
+	}
+}
+
+// 行政区划取编码的前6位
+func civilCode(id string) string {
+	if len(id) < 6 {
+		return id
+	}
+	return id[:6]
+}
@@ -6,20 +6,138 @@ import (
 )
 
 type ctlOption struct {
+	// 注册的目标国标平台
 	Sip       *option.SIPOptions `json:"sip" mapstructure:"sip"`
+	Device    *deviceOption      `json:"device" mapstructure:"device"`
+	Load      *loadOption        `json:"load" mapstructure:"load"`
 	LogOption *option.LogOptions `json:"log" mapstructure:"log"`
 }
 
+// 模拟设备的配置
+type deviceOption struct {
+	Id string `json:"id" mapstructure:"id"`
+	// 设备本机的ip和sip端口
+	Ip        string `json:"ip" mapstructure:"ip"`
+	Port      string `json:"port" mapstructure:"port"`
+	Transport string `json:"transport" mapstructure:"transport"`
+
+	Name         string `json:"name" mapstructure:"name"`
+	Manufacturer string `json:"manufacturer" mapstructure:"manufacturer"`
+	Model        string `json:"model" mapstructure:"model"`
+	Firmware     string `json:"firmware" mapstructure:"firmware"`
+
+	// 注册有效期，单位秒
+	Expires int `json:"expires" mapstructure:"expires"`
+	// 心跳间隔，单位秒
+	KeepaliveInterval int `json:"keepaliveInterval" mapstructure:"keepaliveInterval"`
+	// 心跳连续失败多少次后重新注册
+	KeepaliveCount int `json:"keepaliveCount" mapstructure:"keepaliveCount"`
+
+	// 设备下的通道，为空时以设备编码生成一个摄像机通道
+	Channels []channelOption `json:"channels" mapstructure:"channels"`
+	Media    *mediaOption    `json:"media" mapstructure:"media"`
+}
+
+type channelOption struct {
+	Id   string `json:"id" mapstructure:"id"`
+	Name string `json:"name" mapstructure:"name"`
+}
+
+// 点播时推送的媒体
+type mediaOption struct {
+	// H.264裸流文件，为空时推送彩条测试图像
+	File string `json:"file" mapstructure:"file"`
+	Fps  int    `json:"fps" mapstructure:"fps"`
+}
+
+// 压测模式的配置，设备数大于1时以设备编码的序号为起点生成连续编码的设备
+type loadOption struct {
+	// 模拟的设备数
+	Count int `json:"count" mapstructure:"count"`
+	// 每秒发起注册的设备数，为0时不限制
+	RegisterRate int `json:"registerRate" mapstructure:"registerRate"`
+	// 心跳间隔的最大随机抖动，单位秒
+	KeepaliveJitter int `json:"keepaliveJitter" mapstructure:"keepaliveJitter"`
+	// 所有设备合计每秒上报的报警数
+	AlarmRate float64 `json:"alarmRate" mapstructure:"alarmRate"`
+	// 所有设备合计每秒上报的移动设备位置数
+	PositionRate float64 `json:"positionRate" mapstructure:"positionRate"`
+	// 输出统计报告的间隔，单位秒
+	ReportInterval int `json:"reportInterval" mapstructure:"reportInterval"`
+}
+
 func newCTLOption() *ctlOption {
 	return &ctlOption{
 		Sip:       option.NewSIPOptions(),
+		Device:    newDeviceOption(),
+		Load:      newLoadOption(),
 		LogOption: option.NewLogOptions(),
 	}
 }
 
+func newDeviceOption() *deviceOption {
+	return &deviceOption{
+		Id:                "44010200491320000001",
+		Ip:                "127.0.0.1",
+		Port:              "5061",
+		Transport:         "UDP",
+		Name:              "gbctl",
+		Manufacturer:      "inysc",
+		Model:             "gbctl",
+		Firmware:          "1.0",
+		Expires:           3600,
+		KeepaliveInterval: 60,
+		KeepaliveCount:    3,
+		Media:             &mediaOption{Fps: 25},
+	}
+}
+
+func (d *deviceOption) AddFlags(fss *pflag.FlagSet) {
+	fss.StringVar(&d.Id, "device.id", d.Id, "模拟设备的国标编码")
+	fss.StringVar(&d.Ip, "device.ip", d.Ip, "模拟设备本机的ip")
+	fss.StringVar(&d.Port, "device.port", d.Port, "模拟设备监听的sip端口")
+	fss.StringVar(&d.Transport, "device.transport", d.Transport, "sip信令的传输方式，取值UDP、TCP")
+	fss.IntVar(&d.Expires, "device.expires", d.Expires, "注册有效期，单位秒")
+	fss.IntVar(&d.KeepaliveInterval, "device.keepaliveInterval", d.KeepaliveInterval, "心跳间隔，单位秒")
+	fss.IntVar(&d.KeepaliveCount, "device.keepaliveCount", d.KeepaliveCount, "心跳连续失败多少次后重新注册")
+	fss.StringVar(&d.Media.File, "device.media.file", d.Media.File, "点播时推送的H.264裸流文件，为空时推送彩条测试图像")
+	fss.IntVar(&d.Media.Fps, "device.media.fps", d.Media.Fps, "推流的帧率")
+}
+
+// 未配置通道时，把设备编码的类型改为摄像机作为唯一的通道
+func (d *deviceOption) channels() []channelOption {
+	if len(d.Channels) > 0 {
+		return d.Channels
+	}
+	id := d.Id
+	if len(id) == 20 {
+		id = id[:10] + "131" + id[13:]
+	}
+	return []channelOption{{Id: id, Name: d.Name}}
+}
+
+func newLoadOption() *loadOption {
+	return &loadOption{
+		Count:          1,
+		RegisterRate:   50,
+		ReportInterval: 10,
+	}
+}
+
+func (l *loadOption) AddFlags(fss *pflag.FlagSet) {
+	fss.IntVar(&l.Count, "load.count", l.Count, "模拟的设备数，大于1时进入压测模式")
+	fss.IntVar(&l.RegisterRate, "load.registerRate", l.RegisterRate, "每秒发起注册的设备数，为0时不限制")
+	fss.IntVar(&l.KeepaliveJitter, "load.keepaliveJitter", l.KeepaliveJitter, "心跳间隔的最大随机抖动，单位秒")
+	fss.Float64Var(&l.AlarmRate, "load.alarmRate", l.AlarmRate, "所有设备合计每秒上报的报警数")
+	fss.Float64Var(&l.PositionRate, "load.positionRate", l.PositionRate, "所有设备合计每秒上报的移动设备位置数")
+	fss.IntVar(&l.ReportInterval, "load.reportInterval", l.ReportInterval, "压测时输出统计报告的间隔，单位秒")
+}
+
 func (c *ctlOption) Flags() (fss *pflag.FlagSet) {
 	fss = pflag.NewFlagSet("gbctl", pflag.ExitOnError)
 	c.Sip.AddFlags(fss)
+	c.Device.AddFlags(fss)
+	c.Load.AddFlags(fss)
 	c.LogOption.AddFlags(fss)
 	return
 }
@@ -0,0 +1,110 @@
+package gbctl
+
+import (
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/pkg/digest"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+const (
+	contentTypeXML = "Application/MANSCDP+xml"
+	contentTypeSDP = "APPLICATION/SDP"
+)
+
+// createRegisterRequest 创建注册请求，authHeader不为空时携带认证信息
+func (d *device) createRegisterRequest(expires int, authHeader, authorization string) (sip.Request, error) {
+	// 注册请求的From和To都是设备在平台域中的地址
+	aor := &sip.SipUri{
+		FUser: sip.String{Str: d.opt.Id},
+		FHost: d.server.Domain,
+	}
+	callID := sip.CallID(d.callId)
+	e := sip.Expires(expires)
+	userAgent := sip.UserAgentHeader(d.opt.Name)
+	builder := sip.NewRequestBuilder().
+		SetMethod(sip.REGISTER).
+		SetRecipient(d.serverUri()).
+		SetFrom(&sip.Address{Uri: aor, Params: newParams(map[string]string{"tag": d.fromTag})}).
+		SetTo(&sip.Address{Uri: aor.Clone()}).
+		AddVia(d.newVia()).
+		SetContact(d.contact()).
+		SetCallID(&callID).
+		SetSeqNo(d.nextSeq()).
+		SetExpires(&e).
+		SetUserAgent(&userAgent)
+	if authHeader != "" {
+		builder.AddHeader(&sip.GenericHeader{HeaderName: authHeader, Contents: authorization})
+	}
+
+	request, err := builder.Build()
+	if err != nil {
+		return nil, errors.WithMessage(err, "generate register request fail")
+	}
+	return request, nil
+}
+
+// createMessageRequest 创建发给平台的MESSAGE请求
+func (d *device) createMessageRequest(body string) sip.Request {
+	from := &sip.Address{
+		Uri:    &sip.SipUri{FUser: sip.String{Str: d.opt.Id}, FHost: d.server.Domain},
+		Params: newParams(map[string]string{"tag": digest.NewNonce()}),
+	}
+	to := &sip.Address{Uri: &sip.SipUri{FUser: sip.String{Str: d.server.Id}, FHost: d.server.Domain}}
+	callID := sip.CallID(digest.NewNonce())
+	contentType := sip.ContentType(contentTypeXML)
+	userAgent := sip.UserAgentHeader(d.opt.Name)
+	request, _ := sip.NewRequestBuilder().
+		SetMethod(sip.MESSAGE).
+		SetRecipient(d.serverUri()).
+		SetFrom(from).
+		SetTo(to).
+		AddVia(d.newVia()).
+		SetCallID(&callID).
+		SetSeqNo(d.nextSeq()).
+		SetContentType(&contentType).
+		SetUserAgent(&userAgent).
+		SetBody(body).
+		Build()
+	return request
+}
+
+func (d *device) serverUri() *sip.SipUri {
+	port := sip.Port(cast.ToUint16(d.server.Port))
+	return &sip.SipUri{
+		FUser: sip.String{Str: d.server.Id},
+		FHost: d.server.Ip,
+		FPort: &port,
+	}
+}
+
+func (d *device) contact() *sip.Address {
+	port := sip.Port(cast.ToUint16(d.opt.Port))
+	return &sip.Address{
+		Uri: &sip.SipUri{
+			FUser: sip.String{Str: d.opt.Id},
+			FHost: d.opt.Ip,
+			FPort: &port,
+		},
+	}
+}
+
+func (d *device) newVia() *sip.ViaHop {
+	port := sip.Port(cast.ToUint16(d.opt.Port))
+	return &sip.ViaHop{
+		ProtocolName:    "SIP",
+		ProtocolVersion: "2.0",
+		Transport:       d.opt.Transport,
+		Host:            d.opt.Ip,
+		Port:            &port,
+		Params:          newParams(map[string]string{"branch": sip.GenerateBranch()}),
+	}
+}
+
+func newParams(m map[string]string) sip.Params {
+	params := sip.NewParams()
+	for k, v := range m {
+		params.Add(k, sip.String{Str: v})
+	}
+	return params
+}
@@ -0,0 +1,204 @@
+package gbctl
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/inysc/GB28181/internal/gbctl/media"
+	"github.com/inysc/GB28181/internal/pkg/gbid"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+// 所有模拟设备的集合，设备数大于1时为压测模式，按配置的速率注册、上报报警和位置并定时输出统计报告
+type simulator struct {
+	opt     *ctlOption
+	hub     *hub
+	devices []*device
+	// 为空时不统计
+	stats *stats
+
+	// 已开始注册的设备数，只在停止后读取
+	started int
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newSimulator(opt *ctlOption) (*simulator, error) {
+	ids, err := deviceIds(opt.Device.Id, opt.Load.Count)
+	if err != nil {
+		return nil, err
+	}
+	var file *media.FileSource
+	if opt.Device.Media.File != "" {
+		if file, err = media.NewFileSource(opt.Device.Media.File); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &simulator{opt: opt, hub: newHub(opt.Device), stop: make(chan struct{})}
+	if len(ids) > 1 {
+		s.stats = newStats()
+	}
+	for _, id := range ids {
+		o := *opt.Device
+		if len(ids) > 1 {
+			// 压测时每个设备只有一个由设备编码生成的通道
+			o.Id, o.Channels = id, nil
+		}
+		d := newDevice(opt.Sip, &o, s.hub.srv)
+		d.file = file
+		d.stats = s.stats
+		d.jitter = time.Duration(opt.Load.KeepaliveJitter) * time.Second
+		s.hub.add(d)
+		s.devices = append(s.devices, d)
+	}
+	return s, nil
+}
+
+func (s *simulator) start() error {
+	if err := s.hub.listen(); err != nil {
+		return err
+	}
+	logger.Infof("开始模拟%d个设备", len(s.devices))
+
+	s.wg.Add(1)
+	go s.register()
+	if s.opt.Load.AlarmRate > 0 {
+		s.wg.Add(1)
+		go s.generate(kindAlarm, s.opt.Load.AlarmRate, (*device).notifyAlarm)
+	}
+	if s.opt.Load.PositionRate > 0 {
+		s.wg.Add(1)
+		go s.generate(kindPosition, s.opt.Load.PositionRate, (*device).notifyPosition)
+	}
+	if s.stats != nil && s.opt.Load.ReportInterval > 0 {
+		s.wg.Add(1)
+		go s.report()
+	}
+	return nil
+}
+
+// 停止上报，注销所有设备，压测时输出最终报告
+func (s *simulator) close() {
+	close(s.stop)
+	s.wg.Wait()
+
+	var wg sync.WaitGroup
+	for _, d := range s.devices[:s.started] {
+		wg.Add(1)
+		go func(d *device) {
+			defer wg.Done()
+			d.close()
+		}(d)
+	}
+	wg.Wait()
+	s.hub.shutdown()
+
+	if s.stats != nil {
+		logger.Infof("压测结束\n%s", s.stats.report(s.online(), len(s.devices), false))
+	}
+}
+
+// 按注册速率依次启动设备
+func (s *simulator) register() {
+	defer s.wg.Done()
+	var limiter <-chan time.Time
+	if rate := s.opt.Load.RegisterRate; rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+	for _, d := range s.devices {
+		if s.started > 0 && limiter != nil {
+			select {
+			case <-s.stop:
+				return
+			case <-limiter:
+			}
+		}
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		d.start()
+		s.started++
+	}
+}
+
+// 按速率随机挑选在线的设备上报
+func (s *simulator) generate(kind string, rate float64, notify func(*device) error) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			d := s.devices[rand.Intn(len(s.devices))]
+			if !d.isOnline() {
+				continue
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				start := time.Now()
+				err := notify(d)
+				s.stats.record(kind, start, err)
+				if err != nil {
+					logger.Warnf("{%s}上报%s失败，%s", d.opt.Id, kind, err)
+				}
+			}()
+		}
+	}
+}
+
+func (s *simulator) report() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Duration(s.opt.Load.ReportInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			logger.Infof("压测报告\n%s", s.stats.report(s.online(), len(s.devices), true))
+		}
+	}
+}
+
+func (s *simulator) online() int {
+	n := 0
+	for _, d := range s.devices {
+		if d.isOnline() {
+			n++
+		}
+	}
+	return n
+}
+
+// 以模板编码的序号为起点生成count个连续的设备编码
+func deviceIds(template string, count int) ([]string, error) {
+	if count <= 1 {
+		return []string{template}, nil
+	}
+	id, err := gbid.Parse(template)
+	if err != nil {
+		return nil, errors.WithMessage(err, "模拟设备编码不合法")
+	}
+	serial, _ := strconv.Atoi(id.Serial)
+	if serial+count > 1000000 {
+		return nil, errors.Errorf("从序号%s开始不足以生成%d个设备编码", id.Serial, count)
+	}
+	prefix := template[:len(template)-len(id.Serial)]
+	ids := make([]string, count)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%s%06d", prefix, serial+i)
+	}
+	return ids, nil
+}
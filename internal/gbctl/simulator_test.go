@@ -0,0 +1,24 @@
+package gbctl
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestDeviceIds(t *testing.T) {
+	convey.Convey("TestDeviceIds", t, func() {
+		ids, err := deviceIds("44010200491320000001", 1)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, []string{"44010200491320000001"})
+
+		ids, err = deviceIds("44010200491320999998", 2)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, []string{"44010200491320999998", "44010200491320999999"})
+
+		_, err = deviceIds("44010200491320999998", 3)
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = deviceIds("4401020049", 3)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
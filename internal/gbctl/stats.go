@@ -0,0 +1,128 @@
+package gbctl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// 统计的请求类型
+const (
+	kindRegister  = "register"
+	kindKeepalive = "keepalive"
+	kindAlarm     = "alarm"
+	kindPosition  = "position"
+	kindResponse  = "response"
+)
+
+var kinds = []string{kindRegister, kindKeepalive, kindAlarm, kindPosition, kindResponse}
+
+// 一类请求的统计，延迟样本在每次报告后清空，总数和失败原因一直累计
+type metric struct {
+	total    int
+	failures int
+	sum      time.Duration
+	max      time.Duration
+	errors   map[string]int
+	samples  []time.Duration
+}
+
+// 压测时各类请求的数量、失败和延迟统计
+type stats struct {
+	mux     sync.Mutex
+	begin   time.Time
+	metrics map[string]*metric
+}
+
+func newStats() *stats {
+	s := &stats{begin: time.Now(), metrics: make(map[string]*metric, len(kinds))}
+	for _, kind := range kinds {
+		s.metrics[kind] = &metric{errors: make(map[string]int)}
+	}
+	return s
+}
+
+// 记录一次请求，失败的请求不计入延迟
+func (s *stats) record(kind string, start time.Time, err error) {
+	if s == nil {
+		return
+	}
+	latency := time.Since(start)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	m := s.metrics[kind]
+	m.total++
+	if err != nil {
+		m.failures++
+		m.errors[err.Error()]++
+		return
+	}
+	m.sum += latency
+	if latency > m.max {
+		m.max = latency
+	}
+	m.samples = append(m.samples, latency)
+}
+
+// 生成报告，interval为true时输出本期的延迟分位数并清空样本，否则输出全程的累计结果
+func (s *stats) report(online, count int, interval bool) string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "运行%s，在线设备%d/%d\n", time.Since(s.begin).Round(time.Second), online, count)
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	if interval {
+		fmt.Fprintln(w, "类型\t总数\t失败\t本期\tP50\tP95\tP99\t最大\t")
+	} else {
+		fmt.Fprintln(w, "类型\t总数\t失败\t失败率\t平均\t最大\t")
+	}
+	for _, kind := range kinds {
+		m := s.metrics[kind]
+		if m.total == 0 {
+			continue
+		}
+		if interval {
+			sort.Slice(m.samples, func(i, j int) bool { return m.samples[i] < m.samples[j] })
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t\n", kind, m.total, m.failures, len(m.samples),
+				percentile(m.samples, 50), percentile(m.samples, 95), percentile(m.samples, 99), percentile(m.samples, 100))
+			m.samples = m.samples[:0]
+			continue
+		}
+		var avg time.Duration
+		if succeeded := m.total - m.failures; succeeded > 0 {
+			avg = m.sum / time.Duration(succeeded)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f%%\t%s\t%s\t\n", kind, m.total, m.failures,
+			float64(m.failures)*100/float64(m.total), round(avg), round(m.max))
+	}
+	_ = w.Flush()
+
+	if !interval {
+		for _, kind := range kinds {
+			for reason, n := range s.metrics[kind].errors {
+				fmt.Fprintf(&b, "%s失败%d次：%s\n", kind, n, reason)
+			}
+		}
+	}
+	return b.String()
+}
+
+// 已排序样本的p分位数
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return round(sorted[i])
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(100 * time.Microsecond)
+}
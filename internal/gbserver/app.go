@@ -29,6 +29,7 @@ func NewApp(basename string) *app.App {
 		app.WithBanner(banner),
 		app.WithDescription(description),
 		app.WithRunFunc(run(option)),
+		app.WithCommand(migrateCommand(option)),
 	)
 }
 
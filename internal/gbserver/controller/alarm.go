@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	srv "github.com/inysc/GB28181/internal/gbserver/service"
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/pkg/errors"
+)
+
+var (
+	errAlarmQuery = errors.New("查询报警记录失败")
+)
+
+// AlarmController 报警控制器
+type AlarmController struct {
+	srv srv.Service
+}
+
+// NewAlarmController 新建报警控制器
+func NewAlarmController(store storage.Factory) *AlarmController {
+	return &AlarmController{
+		srv: srv.NewService(store),
+	}
+}
+
+// 报警记录分页列表
+type alarmPage struct {
+	Total int64         `json:"total"`
+	List  []model.Alarm `json:"list"`
+}
+
+// List 查询报警记录
+//
+//	@Summary      查询报警记录
+//	@Description  根据设备、报警级别、报警方式、报警类型以及时间段分页查询报警记录，按报警时间倒序排列
+//	@Tags         报警
+//	@Produce      json
+//	@Param        deviceId	query	string	false	"设备id"
+//	@Param        channelId	query	string	false	"报警源id"
+//	@Param        priority	query	int	false	"报警级别，1-4"
+//	@Param        method	query	int	false	"报警方式，1-7"
+//	@Param        type	query	int	false	"报警类型"
+//	@Param        start	query	string	false	"起始时间，格式为2006-01-02T15:04:05"
+//	@Param        end	query	string	false	"终止时间，格式为2006-01-02T15:04:05"
+//	@Param        page	query	int	false	"页码，从1开始"
+//	@Param        size	query	int	false	"每页数量，默认为20"
+//	@Success      200  {object}  alarmPage
+//	@Router       /alarm/list [get]
+func (a *AlarmController) List(ctx *gin.Context) {
+	var q model.AlarmQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	var err error
+	if s := ctx.Query("start"); s != "" {
+		if q.StartTime, err = time.ParseInLocation(model.GBTimeLayout, s, time.Local); err != nil {
+			newResponse(ctx).fail(errRecordTimeFormat.Error())
+			return
+		}
+	}
+	if e := ctx.Query("end"); e != "" {
+		if q.EndTime, err = time.ParseInLocation(model.GBTimeLayout, e, time.Local); err != nil {
+			newResponse(ctx).fail(errRecordTimeFormat.Error())
+			return
+		}
+	}
+
+	list, total, err := a.srv.Alarm().List(q)
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errAlarmQuery.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(alarmPage{Total: total, List: list})
+}
+
+// Reset 报警复位
+//
+//	@Summary      报警复位
+//	@Description  向设备发送报警复位命令，设备确认后将对应的报警记录标记为已复位
+//	@Tags         报警
+//	@Accept       json
+//	@Produce      json
+//	@Param        报警复位对象 body model.AlarmReset  true  "报警复位对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /alarm/reset [post]
+func (a *AlarmController) Reset(ctx *gin.Context) {
+	var data model.AlarmReset
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	if err := validateIDs(data.DeviceId, data.ChannelId); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	if err := a.srv.Alarm().Reset(data); err != nil {
+		logger.Errorf("%+v", err)
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	newResponse(ctx).success()
+}
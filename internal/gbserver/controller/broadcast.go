@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	srv "github.com/inysc/GB28181/internal/gbserver/service"
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+)
+
+// BroadcastController 语音广播控制器
+type BroadcastController struct {
+	srv srv.Service
+}
+
+// NewBroadcastController 新建语音广播控制器
+func NewBroadcastController(store storage.Factory) *BroadcastController {
+	return &BroadcastController{
+		srv: srv.NewService(store),
+	}
+}
+
+// Start 开始语音广播或对讲
+//
+//	@Summary      开始语音广播或对讲
+//	@Description  先将语音流推送到流媒体（默认为broadcast/{deviceId}_{channelId}），再通知设备发起语音广播，会话建立后返回。
+//	@Description  流媒体不做转码，推送的语音编码需要与返回的codec一致；对讲时设备的语音以talkStream发布到流媒体
+//	@Tags         语音广播
+//	@Accept       json
+//	@Produce      json
+//	@Param        语音广播对象 body model.BroadcastRequest  true  "语音广播对象"
+//	@Success      200  {object}  model.BroadcastSession
+//	@Router       /broadcast/start [post]
+func (b *BroadcastController) Start(ctx *gin.Context) {
+	var data model.BroadcastRequest
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	if err := validateIDs(data.DeviceId, data.ChannelId); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	session, err := b.srv.Broadcast().Start(data)
+	if err != nil {
+		logger.Errorf("%+v", err)
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(session)
+}
+
+// Stop 停止语音广播或对讲
+//
+//	@Summary      停止语音广播或对讲
+//	@Tags         语音广播
+//	@Accept       json
+//	@Produce      json
+//	@Param        停止语音广播对象 body model.BroadcastStop  true  "停止语音广播对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /broadcast/stop [post]
+func (b *BroadcastController) Stop(ctx *gin.Context) {
+	var data model.BroadcastStop
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	if err := validateIDs(data.DeviceId, data.ChannelId); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	if err := b.srv.Broadcast().Stop(data); err != nil {
+		logger.Errorf("%+v", err)
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	newResponse(ctx).success()
+}
+
+// List 查询语音广播会话
+//
+//	@Summary      查询正在进行的语音广播
+//	@Tags         语音广播
+//	@Produce      json
+//	@Success      200  {object}  []model.BroadcastSession
+//	@Router       /broadcast/list [get]
+func (b *BroadcastController) List(ctx *gin.Context) {
+	newResponse(ctx).successWithAny(b.srv.Broadcast().List())
+}
@@ -1,9 +1,19 @@
 package controller
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	srv "github.com/inysc/GB28181/internal/gbserver/service"
 	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/pkg/errors"
+)
+
+var (
+	errChangeQuery = errors.New("查询通道变更记录失败")
+	errTreeQuery   = errors.New("查询通道树失败")
 )
 
 type ChannelController struct {
@@ -38,3 +48,97 @@ func (c *ChannelController) List(ctx *gin.Context) {
 	}
 	newResponse(ctx).successWithAny(list)
 }
+
+// 通道变更记录分页列表
+type channelChangePage struct {
+	Total int64                 `json:"total"`
+	List  []model.ChannelChange `json:"list"`
+}
+
+// Changes 查询通道变更记录
+//
+//	@Summary      查询通道变更记录
+//	@Description  查询设备通过目录订阅上报的通道新增、删除、更新、上下线等变化记录，按变化时间倒序排列
+//	@Tags         设备通道
+//	@Produce      json
+//	@Param        deviceId	query	string	false	"设备id"
+//	@Param        channelId	query	string	false	"通道id"
+//	@Param        event	query	string	false	"变化事件，ADD、DEL、UPDATE、ON、OFF、VLOST、DEFECT"
+//	@Param        start	query	string	false	"起始时间，格式为2006-01-02T15:04:05"
+//	@Param        end	query	string	false	"终止时间，格式为2006-01-02T15:04:05"
+//	@Param        page	query	int	false	"页码，从1开始"
+//	@Param        size	query	int	false	"每页数量，默认为20"
+//	@Success      200  {object}  channelChangePage
+//	@Router       /channel/changes [get]
+func (c *ChannelController) Changes(ctx *gin.Context) {
+	var q model.ChannelChangeQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	var err error
+	if s := ctx.Query("start"); s != "" {
+		if q.StartTime, err = time.ParseInLocation(model.GBTimeLayout, s, time.Local); err != nil {
+			newResponse(ctx).fail(errRecordTimeFormat.Error())
+			return
+		}
+	}
+	if e := ctx.Query("end"); e != "" {
+		if q.EndTime, err = time.ParseInLocation(model.GBTimeLayout, e, time.Local); err != nil {
+			newResponse(ctx).fail(errRecordTimeFormat.Error())
+			return
+		}
+	}
+
+	list, total, err := c.srv.Channel().Changes(q)
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errChangeQuery.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(channelChangePage{Total: total, List: list})
+}
+
+// Tree 懒加载通道树
+//
+//	@Summary      查询通道树的子节点
+//	@Description  通道树由行政区划、业务分组、虚拟组织、设备和通道组成，每次返回一个节点的直接子节点，parentId为空时返回根节点
+//	@Tags         设备通道
+//	@Produce      json
+//	@Param        parentId	query	string	false	"父节点id"
+//	@Success      200  {array}   model.TreeNode
+//	@Router       /channel/tree [get]
+func (c *ChannelController) Tree(ctx *gin.Context) {
+	list, err := c.srv.Channel().Tree(ctx.Query("parentId"))
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errTreeQuery.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(list)
+}
+
+// SearchTree 按名称搜索通道树
+//
+//	@Summary      按名称搜索通道树
+//	@Description  按名称模糊搜索通道树中的节点，返回的path为从根节点到该节点父节点的id，用于逐级展开
+//	@Tags         设备通道
+//	@Produce      json
+//	@Param        name	query	string	true	"节点名称"
+//	@Success      200  {array}   model.TreeNode
+//	@Router       /channel/tree/search [get]
+func (c *ChannelController) SearchTree(ctx *gin.Context) {
+	name := ctx.Query("name")
+	if name == "" {
+		newResponse(ctx).fail("name 参数是必须的")
+		return
+	}
+	list, err := c.srv.Channel().SearchTree(name)
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errTreeQuery.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(list)
+}
@@ -1,19 +1,29 @@
 package controller
 
 import (
+	"context"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/inysc/GB28181/internal/gbserver/service"
 	"github.com/inysc/GB28181/internal/pkg/gbsip"
 	"github.com/inysc/GB28181/internal/pkg/logger"
 	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/syn"
 	"github.com/pkg/errors"
 )
 
 var (
 	errDataBindStructFail = errors.New("传入参数失败，请检查传参")
 	errDeviceNotFound     = errors.New("设备未找到")
+	errPresetQuery        = errors.New("查询预置位失败")
+	errPresetQueryTimeOut = errors.New("查询预置位超时")
+	errControlTimeOut     = errors.New("等待设备返回执行结果超时")
 )
 
+// 等待设备返回控制命令执行结果的最长时间
+const controlResultTimeout = 5 * time.Second
+
 type ControlController struct {
 }
 
@@ -38,6 +48,10 @@ func (c ControlController) ControlPTZ(ctx *gin.Context) {
 		newResponse(ctx).fail(errDataBindStructFail.Error())
 		return
 	}
+	if err := validateIDs(data.DeviceId, data.ChannelId); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
 	device, ok := service.Device().GetByDeviceId(data.DeviceId)
 	if !ok {
 		newResponse(ctx).fail(errDeviceNotFound.Error())
@@ -51,3 +65,301 @@ func (c ControlController) ControlPTZ(ctx *gin.Context) {
 	}
 	newResponse(ctx).success()
 }
+
+// ControlFI 控制聚焦和光圈
+//
+//	@Summary      控制摄像头的聚焦和光圈
+//	@Tags         设备控制
+//	@Accept       json
+//	@Produce      json
+//	@Param        聚焦和光圈控制对象 body model.FIControl  true  "聚焦和光圈控制对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /control/fi [post]
+func (c ControlController) ControlFI(ctx *gin.Context) {
+	var data model.FIControl
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	c.control(ctx, data.DeviceId, data.ChannelId, func(d model.Device) error {
+		return gbsip.ControlFI(d, data)
+	})
+}
+
+// ControlPreset 预置位控制
+//
+//	@Summary      设置、调用和删除预置位
+//	@Tags         设备控制
+//	@Accept       json
+//	@Produce      json
+//	@Param        预置位控制对象 body model.PresetControl  true  "预置位控制对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /control/preset [post]
+func (c ControlController) ControlPreset(ctx *gin.Context) {
+	var data model.PresetControl
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	c.control(ctx, data.DeviceId, data.ChannelId, func(d model.Device) error {
+		return gbsip.ControlPreset(d, data)
+	})
+}
+
+// ControlCruise 巡航控制
+//
+//	@Summary      设置和启停巡航
+//	@Description  先通过add将预置位加入巡航组，再设置巡航速度和停留时间，最后通过start开始巡航
+//	@Tags         设备控制
+//	@Accept       json
+//	@Produce      json
+//	@Param        巡航控制对象 body model.CruiseControl  true  "巡航控制对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /control/cruise [post]
+func (c ControlController) ControlCruise(ctx *gin.Context) {
+	var data model.CruiseControl
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	c.control(ctx, data.DeviceId, data.ChannelId, func(d model.Device) error {
+		return gbsip.ControlCruise(d, data)
+	})
+}
+
+// ControlScan 自动扫描控制
+//
+//	@Summary      设置和启停自动扫描
+//	@Description  转动云台后分别通过left和right设置扫描的左右边界，再通过start开始扫描
+//	@Tags         设备控制
+//	@Accept       json
+//	@Produce      json
+//	@Param        自动扫描控制对象 body model.ScanControl  true  "自动扫描控制对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /control/scan [post]
+func (c ControlController) ControlScan(ctx *gin.Context) {
+	var data model.ScanControl
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	c.control(ctx, data.DeviceId, data.ChannelId, func(d model.Device) error {
+		return gbsip.ControlScan(d, data)
+	})
+}
+
+// PresetQuery 查询预置位
+//
+//	@Summary      查询通道的预置位
+//	@Tags         设备控制
+//	@Produce      json
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Param        channelId	path	string	true	"通道id"
+//	@Success      200  {object}  []gbsip.PresetItem
+//	@Router       /control/preset/{deviceId}/{channelId} [get]
+func (c ControlController) PresetQuery(ctx *gin.Context) {
+	device, ok := service.Device().GetByDeviceId(ctx.Param("deviceId"))
+	if !ok {
+		newResponse(ctx).fail(errDeviceNotFound.Error())
+		return
+	}
+	channelId := ctx.Param("channelId")
+
+	task, err := gbsip.PresetQuery(device, channelId)
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errPresetQuery.Error())
+		return
+	}
+	waitCtx, cancel := context.WithTimeout(ctx.Request.Context(), 5*time.Second)
+	defer cancel()
+	data, err := task.Wait(waitCtx)
+	if err != nil {
+		if errors.Is(err, syn.ErrTimeOut) {
+			newResponse(ctx).fail(errPresetQueryTimeOut.Error())
+			return
+		}
+		logger.Error(err)
+		newResponse(ctx).fail(errPresetQuery.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(data)
+}
+
+// TeleBoot 远程启动
+//
+//	@Summary      远程重启设备
+//	@Description  设备收到命令后直接重启，不返回执行结果
+//	@Tags         设备控制
+//	@Accept       json
+//	@Produce      json
+//	@Param        远程启动对象 body model.TeleBootControl  true  "远程启动对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /control/teleboot [post]
+func (c ControlController) TeleBoot(ctx *gin.Context) {
+	var data model.TeleBootControl
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	c.control(ctx, data.DeviceId, "", gbsip.TeleBoot)
+}
+
+// ControlRecord 手动录像控制
+//
+//	@Summary      开始或停止设备端录像
+//	@Tags         设备控制
+//	@Accept       json
+//	@Produce      json
+//	@Param        录像控制对象 body model.RecordControl  true  "录像控制对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /control/record [post]
+func (c ControlController) ControlRecord(ctx *gin.Context) {
+	var data model.RecordControl
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	c.controlWithResult(ctx, data.DeviceId, data.ChannelId, func(d model.Device) (*syn.Entity, error) {
+		return gbsip.RecordCmd(d, data)
+	})
+}
+
+// ControlGuard 布防和撤防
+//
+//	@Summary      设备或报警通道布防和撤防
+//	@Tags         设备控制
+//	@Accept       json
+//	@Produce      json
+//	@Param        布撤防对象 body model.GuardControl  true  "布撤防对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /control/guard [post]
+func (c ControlController) ControlGuard(ctx *gin.Context) {
+	var data model.GuardControl
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	c.controlWithResult(ctx, data.DeviceId, data.ChannelId, func(d model.Device) (*syn.Entity, error) {
+		return gbsip.GuardCmd(d, data)
+	})
+}
+
+// ControlIFrame 强制关键帧
+//
+//	@Summary      要求通道立即发送关键帧
+//	@Tags         设备控制
+//	@Accept       json
+//	@Produce      json
+//	@Param        强制关键帧对象 body model.IFrameControl  true  "强制关键帧对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /control/iframe [post]
+func (c ControlController) ControlIFrame(ctx *gin.Context) {
+	var data model.IFrameControl
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	c.control(ctx, data.DeviceId, data.ChannelId, func(d model.Device) error {
+		return gbsip.IFrameCmd(d, data)
+	})
+}
+
+// ControlDragZoom 拉框放大和缩小
+//
+//	@Summary      拉框放大和缩小
+//	@Description  以播放窗口的像素为坐标，将拉框区域放大到整个窗口或将整个窗口缩小到拉框区域
+//	@Tags         设备控制
+//	@Accept       json
+//	@Produce      json
+//	@Param        拉框缩放对象 body model.DragZoomControl  true  "拉框缩放对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /control/dragzoom [post]
+func (c ControlController) ControlDragZoom(ctx *gin.Context) {
+	var data model.DragZoomControl
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	c.control(ctx, data.DeviceId, data.ChannelId, func(d model.Device) error {
+		return gbsip.DragZoomCmd(d, data)
+	})
+}
+
+// ControlHomePosition 看守位控制
+//
+//	@Summary      启用或关闭看守位
+//	@Description  启用后通道空闲resetTime秒会自动调用presetIndex预置位
+//	@Tags         设备控制
+//	@Accept       json
+//	@Produce      json
+//	@Param        看守位控制对象 body model.HomePositionControl  true  "看守位控制对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /control/homeposition [post]
+func (c ControlController) ControlHomePosition(ctx *gin.Context) {
+	var data model.HomePositionControl
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	c.controlWithResult(ctx, data.DeviceId, data.ChannelId, func(d model.Device) (*syn.Entity, error) {
+		return gbsip.HomePosition(d, data)
+	})
+}
+
+// 校验设备后发送控制命令，设备确认收到即返回
+func (c ControlController) control(ctx *gin.Context, deviceId, channelId string, send func(d model.Device) error) {
+	c.controlWithResult(ctx, deviceId, channelId, func(d model.Device) (*syn.Entity, error) {
+		return nil, send(d)
+	})
+}
+
+// 校验设备后发送控制命令，send返回等待的任务时等待设备返回执行结果
+func (c ControlController) controlWithResult(ctx *gin.Context, deviceId, channelId string, send func(d model.Device) (*syn.Entity, error)) {
+	if err := validateIDs(deviceId, channelId); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	device, ok := service.Device().GetByDeviceId(deviceId)
+	if !ok {
+		newResponse(ctx).fail(errDeviceNotFound.Error())
+		return
+	}
+
+	task, err := send(device)
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	if task != nil {
+		if err = waitResult(ctx, task); err != nil {
+			newResponse(ctx).fail(err.Error())
+			return
+		}
+	}
+	newResponse(ctx).success()
+}
+
+// 等待设备返回控制或配置命令的执行结果
+func waitResult(ctx *gin.Context, task *syn.Entity) error {
+	c, cancel := context.WithTimeout(ctx.Request.Context(), controlResultTimeout)
+	defer cancel()
+	if _, err := task.Wait(c); err != nil {
+		if errors.Is(err, syn.ErrTimeOut) {
+			return errControlTimeOut
+		}
+		return err
+	}
+	return nil
+}
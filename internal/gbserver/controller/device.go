@@ -1,7 +1,8 @@
 package controller
 
 import (
-	"fmt"
+	"context"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,15 +13,22 @@ import (
 	"github.com/inysc/GB28181/internal/pkg/model"
 	"github.com/inysc/GB28181/internal/pkg/syn"
 	"github.com/pkg/errors"
+	"github.com/spf13/cast"
 )
 
 var (
 	errDeviceBasicConfig             = errors.New("修改设备基本配置失败")
-	errDeviceBasicConfigQuery        = errors.New("获取设备基本配置失败")
-	errDeviceBasicConfigQueryTimeOut = errors.New("获取设备基本配置超时")
+	errDeviceBasicConfigQuery        = errors.New("获取设备配置失败")
+	errDeviceBasicConfigQueryTimeOut = errors.New("获取设备配置超时")
 
 	errDeviceStatusQuery        = errors.New("获取设备状态失败")
 	errDeviceStatusQueryTimeOut = errors.New("获取设备状态失败")
+
+	errCatalogSync         = errors.New("同步设备目录失败")
+	errCatalogSyncNotFound = errors.New("设备没有目录同步记录")
+
+	errSubscribe   = errors.New("订阅失败")
+	errUnsubscribe = errors.New("取消订阅失败")
 )
 
 // DeviceController 设备控制器
@@ -66,15 +74,18 @@ func (d *DeviceController) BasicParamsConfig(ctx *gin.Context) {
 	if !ok {
 		newResponse(ctx).fail(errDeviceNotFound.Error())
 		return
-	} else if device.Offline == 0 {
-
 	}
 
-	err := gbsip.DeviceBasicConfig(&model.DeviceBasicConfigDto{DeviceBasicConfigReq: *cfg, Device: device})
+	task, err := gbsip.DeviceBasicConfig(&model.DeviceBasicConfigDto{DeviceBasicConfigReq: *cfg, Device: device})
 	if err != nil {
+		logger.Error(err)
 		newResponse(ctx).fail(errDeviceBasicConfig.Error())
 		return
 	}
+	if err = waitResult(ctx, task); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
 
 	newResponse(ctx).success()
 }
@@ -86,24 +97,116 @@ func (d *DeviceController) BasicParamsQuery(ctx *gin.Context) {
 		newResponse(ctx).fail(errDeviceNotFound.Error())
 		return
 	}
-	if err := gbsip.DeviceBasicConfigQuery(device); err != nil {
+	cfg, ok := d.queryConfig(ctx, device, deviceId, []string{gbsip.ConfigBasicParam})
+	if !ok {
+		return
+	}
+	if cfg.BasicParam == nil {
+		newResponse(ctx).fail(errDeviceBasicConfigQuery.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(gbsip.DeviceBasicConfigResp{Mata: cfg.Mata, R: cfg.R, BasicParam: *cfg.BasicParam})
+}
+
+// ConfigQuery 查询设备配置
+//
+//	@Summary      查询设备或通道的配置
+//	@Description  支持VideoParamOpt、SVACEncodeConfig、SVACDecodeConfig、VideoParamAttribute、VideoRecordPlan、VideoAlarmRecord、PictureMask、FrameMirror、AlarmReport和OSDConfig，多个类型以逗号分隔
+//	@Tags         设备配置
+//	@Produce      json
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Param        configType	query	string	true	"配置类型"
+//	@Param        channelId	query	string	false	"通道id，为空时查询设备的配置"
+//	@Success      200  {object}  gbsip.DeviceConfigResp
+//	@Router       /device/config/{deviceId} [get]
+func (d *DeviceController) ConfigQuery(ctx *gin.Context) {
+	deviceId := ctx.Param("deviceId")
+	channelId := ctx.Query("channelId")
+	if err := validateIDs(channelId); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	types := strings.Split(ctx.Query("configType"), ",")
+	if err := gbsip.CheckConfigTypes(types); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	device, ok := d.srv.Devices().GetByDeviceId(deviceId)
+	if !ok {
+		newResponse(ctx).fail(errDeviceNotFound.Error())
+		return
+	}
+	targetId := channelId
+	if targetId == "" {
+		targetId = deviceId
+	}
+	if cfg, ok := d.queryConfig(ctx, device, targetId, types); ok {
+		newResponse(ctx).successWithAny(cfg)
+	}
+}
+
+// Config 修改设备配置
+//
+//	@Summary      修改设备或通道的配置
+//	@Description  请求中携带的每种配置都会下发给设备，设备返回执行结果后才会应答
+//	@Tags         设备配置
+//	@Accept       json
+//	@Produce      json
+//	@Param        设备配置对象 body gbsip.DeviceConfigRequest  true  "设备配置对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /device/config [post]
+func (d *DeviceController) Config(ctx *gin.Context) {
+	var req gbsip.DeviceConfigRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
 		logger.Error(err)
-		newResponse(ctx).fail("fail")
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	if err := validateIDs(req.DeviceId, req.ChannelId); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	device, ok := d.srv.Devices().GetByDeviceId(req.DeviceId)
+	if !ok {
+		newResponse(ctx).fail(errDeviceNotFound.Error())
 		return
 	}
 
-	entity := syn.NewDelayTask(fmt.Sprintf("%s_%s", syn.KeyControlDeviceConfigQuery, deviceId), 3*time.Second)
-	data, err := entity.Wait()
+	task, err := gbsip.DeviceConfig(device, req)
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	if err = waitResult(ctx, task); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	newResponse(ctx).success()
+}
+
+// 查询配置并等待设备通过ConfigDownload返回，失败时已应答请求
+func (d *DeviceController) queryConfig(ctx *gin.Context, device model.Device, targetId string, types []string) (*gbsip.DeviceConfigResp, bool) {
+	task, err := gbsip.DeviceConfigQuery(device, targetId, types...)
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDeviceBasicConfigQuery.Error())
+		return nil, false
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx.Request.Context(), 3*time.Second)
+	defer cancel()
+	data, err := task.Wait(waitCtx)
 	if err != nil {
 		if errors.Is(err, syn.ErrTimeOut) {
 			newResponse(ctx).fail(errDeviceBasicConfigQueryTimeOut.Error())
-			return
+			return nil, false
 		}
 		logger.Error(err)
 		newResponse(ctx).fail(errDeviceBasicConfigQuery.Error())
-		return
+		return nil, false
 	}
-	newResponse(ctx).successWithAny(data)
+	return data.(*gbsip.DeviceConfigResp), true
 }
 
 func (d *DeviceController) StatusQuery(ctx *gin.Context) {
@@ -114,13 +217,15 @@ func (d *DeviceController) StatusQuery(ctx *gin.Context) {
 		return
 	}
 
-	entity := syn.NewDelayTask(fmt.Sprintf("%s_%s", syn.KeyQueryDeviceStatus, deviceId), 3*time.Second)
-	if err := gbsip.DeviceStatusQuery(device); err != nil {
+	task, err := gbsip.DeviceStatusQuery(device)
+	if err != nil {
 		logger.Error(err)
 		newResponse(ctx).fail(errDeviceStatusQuery.Error())
 		return
 	}
-	data, err := entity.Wait()
+	waitCtx, cancel := context.WithTimeout(ctx.Request.Context(), 3*time.Second)
+	defer cancel()
+	data, err := task.Wait(waitCtx)
 	if err != nil {
 		if errors.Is(err, syn.ErrTimeOut) {
 			newResponse(ctx).fail(errDeviceStatusQueryTimeOut.Error())
@@ -134,43 +239,145 @@ func (d *DeviceController) StatusQuery(ctx *gin.Context) {
 	newResponse(ctx).successWithAny(data)
 }
 
-func (d *DeviceController) AlarmSubscribe(ctx *gin.Context) {
+// CatalogSync 同步设备目录
+//
+//	@Summary      同步设备目录
+//	@Description  向设备发送目录查询，收到完整目录后以其为准新增、更新和删除通道，同步进度通过目录同步状态接口查询
+//	@Tags         设备
+//	@Produce      json
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Success      200  {object}  model.CatalogSync
+//	@Router       /device/catalog/sync/{deviceId} [post]
+func (d *DeviceController) CatalogSync(ctx *gin.Context) {
 	deviceId := ctx.Param("deviceId")
 	device, ok := d.srv.Devices().GetByDeviceId(deviceId)
 	if !ok {
 		newResponse(ctx).fail(errDeviceNotFound.Error())
 		return
 	}
-	if err := gbsip.AlarmSubscribe(device); err != nil {
-		newResponse(ctx).fail("订阅失败")
+
+	status, err := gbsip.SyncCatalog(device)
+	if err != nil {
+		logger.Error(err)
+		if errors.Is(err, gbsip.ErrCatalogSyncing) {
+			newResponse(ctx).fail(err.Error())
+			return
+		}
+		newResponse(ctx).fail(errCatalogSync.Error())
 		return
 	}
-	newResponse(ctx).success()
+	newResponse(ctx).successWithAny(status)
 }
 
-func (d *DeviceController) CatalogSubscribe(ctx *gin.Context) {
-	deviceId := ctx.Param("deviceId")
-	device, ok := d.srv.Devices().GetByDeviceId(deviceId)
+// CatalogSyncStatus 查询设备目录同步进度
+//
+//	@Summary      查询设备目录同步进度
+//	@Description  返回设备最近一次目录同步的状态、通道总数和已收到的通道数
+//	@Tags         设备
+//	@Produce      json
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Success      200  {object}  model.CatalogSync
+//	@Router       /device/catalog/status/{deviceId} [get]
+func (d *DeviceController) CatalogSyncStatus(ctx *gin.Context) {
+	status, ok := gbsip.CatalogSyncStatus(ctx.Param("deviceId"))
 	if !ok {
-		newResponse(ctx).fail(errDeviceNotFound.Error())
+		newResponse(ctx).fail(errCatalogSyncNotFound.Error())
 		return
 	}
-	if err := gbsip.CatalogSubscribe(device); err != nil {
-		newResponse(ctx).fail("订阅失败")
-		return
-	}
-	newResponse(ctx).success()
+	newResponse(ctx).successWithAny(status)
+}
+
+// AlarmSubscribe 报警订阅
+//
+//	@Summary      订阅设备的报警信息
+//	@Description  向设备发起报警订阅，订阅会在过期前自动刷新，设备重新上线后会重新订阅
+//	@Tags         订阅
+//	@Produce      json
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Param        expires	query	int	false	"订阅有效期，单位秒，默认为3600"
+//	@Success      200  {string}   "ok"
+//	@Router       /device/subscribe/alarm/{deviceId} [post]
+func (d *DeviceController) AlarmSubscribe(ctx *gin.Context) {
+	d.subscribe(ctx, gbsip.SubscribeAlarm)
 }
 
+// CatalogSubscribe 目录订阅
+//
+//	@Summary      订阅设备的目录变化
+//	@Description  向设备发起目录订阅，订阅会在过期前自动刷新，设备重新上线后会重新订阅
+//	@Tags         订阅
+//	@Produce      json
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Param        expires	query	int	false	"订阅有效期，单位秒，默认为3600"
+//	@Success      200  {string}   "ok"
+//	@Router       /device/subscribe/catalog/{deviceId} [post]
+func (d *DeviceController) CatalogSubscribe(ctx *gin.Context) {
+	d.subscribe(ctx, gbsip.SubscribeCatalog)
+}
+
+// MobilePositionSubscribe 移动设备位置订阅
+//
+//	@Summary      订阅移动设备的位置信息
+//	@Description  向设备发起移动设备位置订阅，订阅会在过期前自动刷新，设备重新上线后会重新订阅
+//	@Tags         订阅
+//	@Produce      json
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Param        expires	query	int	false	"订阅有效期，单位秒，默认为3600"
+//	@Param        interval	query	int	false	"位置上报间隔，单位秒，默认为5"
+//	@Success      200  {string}   "ok"
+//	@Router       /device/subscribe/mobilePosition/{deviceId} [post]
 func (d *DeviceController) MobilePositionSubscribe(ctx *gin.Context) {
+	d.subscribe(ctx, gbsip.SubscribeMobilePosition)
+}
+
+func (d *DeviceController) subscribe(ctx *gin.Context, cmdType string) {
 	deviceId := ctx.Param("deviceId")
 	device, ok := d.srv.Devices().GetByDeviceId(deviceId)
 	if !ok {
 		newResponse(ctx).fail(errDeviceNotFound.Error())
 		return
 	}
-	if err := gbsip.MobilePositionSubscribe(device); err != nil {
-		newResponse(ctx).fail("订阅失败")
+	expires := cast.ToInt(ctx.Query("expires"))
+	interval := cast.ToInt(ctx.Query("interval"))
+	if err := gbsip.Subscribe(device, cmdType, expires, interval); err != nil {
+		logger.Errorf("%+v", err)
+		newResponse(ctx).fail(errSubscribe.Error())
+		return
+	}
+	newResponse(ctx).success()
+}
+
+// SubscriptionList 查询订阅
+//
+//	@Summary      查询订阅列表
+//	@Description  返回所有订阅的状态，可以按设备过滤
+//	@Tags         订阅
+//	@Produce      json
+//	@Param        deviceId	query	string	false	"设备id"
+//	@Success      200  {array}   gbsip.Subscription
+//	@Router       /device/subscribe/list [get]
+func (d *DeviceController) SubscriptionList(ctx *gin.Context) {
+	newResponse(ctx).successWithAny(gbsip.Subscriptions(ctx.Query("deviceId")))
+}
+
+// Unsubscribe 取消订阅
+//
+//	@Summary      取消订阅
+//	@Description  向设备发送有效期为0的订阅请求，取消对应类型的订阅
+//	@Tags         订阅
+//	@Produce      json
+//	@Param        cmdType	path	string	true	"订阅类型，取值为：Catalog、Alarm、MobilePosition"
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Success      200  {string}   "ok"
+//	@Router       /device/subscribe/cancel/{cmdType}/{deviceId} [post]
+func (d *DeviceController) Unsubscribe(ctx *gin.Context) {
+	if err := gbsip.Unsubscribe(ctx.Param("deviceId"), ctx.Param("cmdType")); err != nil {
+		logger.Errorf("%+v", err)
+		if errors.Is(err, gbsip.ErrSubscriptionNotFound) {
+			newResponse(ctx).fail(err.Error())
+			return
+		}
+		newResponse(ctx).fail(errUnsubscribe.Error())
 		return
 	}
 	newResponse(ctx).success()
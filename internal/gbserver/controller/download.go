@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	srv "github.com/inysc/GB28181/internal/gbserver/service"
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/pkg/errors"
+)
+
+var (
+	errDownloadSpeed = errors.New("下载倍速必须为正整数")
+)
+
+// 未指定下载倍速时使用的默认倍速
+const defaultDownloadSpeed = 4
+
+// DownloadController 录像下载控制器
+type DownloadController struct {
+	srv srv.Service
+}
+
+// NewDownloadController 新建录像下载控制器
+func NewDownloadController(store storage.Factory) *DownloadController {
+	return &DownloadController{
+		srv: srv.NewService(store),
+	}
+}
+
+// Start 开始下载录像
+//
+//	@Summary      下载设备通道的历史录像
+//	@Description  根据设备id、通道id以及时间段，让设备以指定的倍速推送录像，并由流媒体服务录制成mp4文件
+//	@Tags         下载
+//	@Produce      json
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Param        channelId	path	string	true	"通道id"
+//	@Param        start	query	string	true	"起始时间，格式为2006-01-02T15:04:05"
+//	@Param        end	query	string	true	"终止时间，格式为2006-01-02T15:04:05"
+//	@Param        speed	query	int	false	"下载倍速，默认为4"
+//	@Success      200  {object}  model.DownloadInfo
+//	@Router       /download/start/{deviceId}/{channelId} [post]
+func (d *DownloadController) Start(ctx *gin.Context) {
+	start, err := time.ParseInLocation(model.GBTimeLayout, ctx.Query("start"), time.Local)
+	if err != nil {
+		newResponse(ctx).fail(errRecordTimeFormat.Error())
+		return
+	}
+	end, err := time.ParseInLocation(model.GBTimeLayout, ctx.Query("end"), time.Local)
+	if err != nil {
+		newResponse(ctx).fail(errRecordTimeFormat.Error())
+		return
+	}
+	if !start.Before(end) {
+		newResponse(ctx).fail(errRecordTimeRange.Error())
+		return
+	}
+	speed := defaultDownloadSpeed
+	if s := ctx.Query("speed"); s != "" {
+		speed, err = strconv.Atoi(s)
+		if err != nil || speed <= 0 {
+			newResponse(ctx).fail(errDownloadSpeed.Error())
+			return
+		}
+	}
+
+	info, err := d.srv.Download().Start(ctx.Param("deviceId"), ctx.Param("channelId"), start, end, speed)
+	if err != nil {
+		logger.Errorf("%+v", err)
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(info)
+}
+
+// Status 查询录像下载状态
+//
+//	@Summary      查询录像下载状态
+//	@Description  根据流id查询下载状态和下载进度
+//	@Tags         下载
+//	@Produce      json
+//	@Param        streamId	path	string	true	"下载的流id"
+//	@Success      200  {object}  model.DownloadInfo
+//	@Router       /download/status/{streamId} [get]
+func (d *DownloadController) Status(ctx *gin.Context) {
+	info, err := d.srv.Download().Status(ctx.Param("streamId"))
+	if err != nil {
+		logger.Errorf("%+v", err)
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(info)
+}
+
+// Stop 停止下载录像
+//
+//	@Summary      停止录像下载
+//	@Description  根据流id结束下载会话，已录制的文件会保留
+//	@Tags         下载
+//	@Produce      json
+//	@Param        streamId	path	string	true	"下载的流id"
+//	@Success      200  {string}   "ok"
+//	@Router       /download/stop/{streamId} [post]
+func (d *DownloadController) Stop(ctx *gin.Context) {
+	if err := d.srv.Download().Stop(ctx.Param("streamId")); err != nil {
+		logger.Errorf("%+v", err)
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	newResponse(ctx).success()
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/inysc/GB28181/internal/pkg/event"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+)
+
+const (
+	// 每个订阅者缓存的事件数
+	eventBuffer = 256
+	// 连接空闲时发送心跳，避免被代理断开
+	eventHeartbeat = 30 * time.Second
+)
+
+// EventController 平台事件推送控制器
+type EventController struct{}
+
+func NewEventController() *EventController {
+	return &EventController{}
+}
+
+// Subscribe 订阅平台事件
+//
+//	@Summary      订阅平台事件
+//	@Description  以WebSocket或Server-Sent Events推送设备上下线、通道变化、报警、媒体会话和流媒体服务等事件；请求携带WebSocket升级头时使用WebSocket，否则使用SSE
+//	@Tags         事件
+//	@Produce      text/event-stream
+//	@Param        deviceId	query	string	false	"设备id，多个以逗号分隔"
+//	@Param        type	query	string	false	"事件类型，多个以逗号分隔，可以只填类别，如device、channel、alarm、stream、media"
+//	@Success      200  {object}  event.Event
+//	@Router       /events [get]
+func (e *EventController) Subscribe(ctx *gin.Context) {
+	filter := event.Filter{
+		DeviceIds: splitQuery(ctx.Query("deviceId")),
+		Types:     splitQuery(ctx.Query("type")),
+	}
+	if strings.EqualFold(ctx.GetHeader("Upgrade"), "websocket") {
+		e.websocket(ctx, filter)
+		return
+	}
+	e.sse(ctx, filter)
+}
+
+func (e *EventController) sse(ctx *gin.Context, filter event.Filter) {
+	events, cancel := event.Subscribe(eventBuffer, filter)
+	defer cancel()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Writer.WriteHeaderNow()
+	ctx.Writer.Flush()
+	ticker := time.NewTicker(eventHeartbeat)
+	defer ticker.Stop()
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case ev := <-events:
+			ctx.SSEvent(string(ev.Type), ev)
+			ticker.Reset(eventHeartbeat)
+			return true
+		case <-ticker.C:
+			_, err := w.Write([]byte(":\n\n"))
+			return err == nil
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func (e *EventController) websocket(ctx *gin.Context, filter event.Filter) {
+	conn, _, _, err := ws.UpgradeHTTP(ctx.Request, ctx.Writer)
+	if err != nil {
+		logger.Error(err)
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
+
+	events, cancel := event.Subscribe(eventBuffer, filter)
+	defer cancel()
+
+	// 读取在单独的goroutine中应答ping和close，同样会写连接，写一个帧时需要与事件推送互斥
+	var mux sync.Mutex
+	write := func(op ws.OpCode, p []byte) error {
+		mux.Lock()
+		defer mux.Unlock()
+		return wsutil.WriteServerMessage(conn, op, p)
+	}
+	controlHandler := wsutil.ControlFrameHandler(conn, ws.StateServerSide)
+	handleControl := func(h ws.Header, r io.Reader) error {
+		mux.Lock()
+		defer mux.Unlock()
+		return controlHandler(h, r)
+	}
+
+	// 客户端只需要接收事件，读取仅用于应答ping和感知连接关闭，其他数据直接丢弃
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		rd := wsutil.Reader{Source: conn, State: ws.StateServerSide, OnIntermediate: handleControl}
+		for {
+			h, err := rd.NextFrame()
+			if err != nil {
+				return
+			}
+			if h.OpCode.IsControl() {
+				err = handleControl(h, &rd)
+			} else {
+				err = rd.Discard()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(eventHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev := <-events:
+			b, err := json.Marshal(ev)
+			if err != nil {
+				logger.Error(err)
+				continue
+			}
+			if err = write(ws.OpText, b); err != nil {
+				return
+			}
+			ticker.Reset(eventHeartbeat)
+		case <-ticker.C:
+			if err := write(ws.OpPing, nil); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// 拆分以逗号分隔的查询参数，忽略空项
+func splitQuery(q string) []string {
+	var s []string
+	for _, v := range strings.Split(q, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			s = append(s, v)
+		}
+	}
+	return s
+}
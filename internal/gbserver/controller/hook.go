@@ -5,7 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/inysc/GB28181/internal/gbserver/service"
-	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/event"
 	"github.com/inysc/GB28181/internal/pkg/logger"
 	"github.com/inysc/GB28181/internal/pkg/model"
 )
@@ -34,6 +34,7 @@ func (m MediaHookController) OnServerStarted(c *gin.Context) {
 	logger.Info("收到zlm上线事件,media_server_id:", conf.GeneralMediaServerId, "ip:", conf.RemoteIp, "port:", conf.HttpPort)
 	conf.RemoteIp = c.RemoteIP()
 	go service.Media().Online(conf)
+	event.Publish(event.MediaServerStarted, "", conf)
 	replyAllowMsg(c)
 }
 
@@ -77,9 +78,13 @@ func (m MediaHookController) OnPublish(c *gin.Context) {
 		})
 		return
 	}
-	// do something
-
-	c.JSON(200, model.NewOnPublishDefaultReply())
+	reply := model.NewOnPublishDefaultReply()
+	// 录像下载的流需要录制成mp4文件，同时录制也计入观看人数，避免下载过程中因无人观看被关闭
+	if hookParam.App == "rtp" && service.Download().IsDownload(hookParam.Stream) {
+		reply.EnableMp4 = true
+		reply.Mp4AsPlayer = true
+	}
+	c.JSON(200, reply)
 }
 
 func (m MediaHookController) OnStreamChanged(c *gin.Context) {
@@ -94,6 +99,7 @@ func (m MediaHookController) OnStreamChanged(c *gin.Context) {
 	}
 	// do something
 	logger.Info("收到流改变事件,stream_id:", hookParam.Stream, "register: ", hookParam.Register, "protocol:", hookParam.Schema)
+	event.Publish(event.MediaStreamChanged, streamDeviceId(hookParam.App, hookParam.Stream), hookParam)
 	replyAllowMsg(c)
 }
 
@@ -115,25 +121,18 @@ func (m MediaHookController) OnStreamNOneReader(c *gin.Context) {
 		return
 	}
 
-	// do something
 	logger.Info("收到流无人观看事件,stream_id:", hookParam.Stream, "media_server_id:", hookParam.MediaServerId)
 
-	s := strings.Split(hookParam.Stream, "_")
-	if len(s) == 0 {
-		logger.Error("stream split by '_' fail")
-		closeStream()
-		return
-	}
-
-	device, exist := service.Device().GetByDeviceId(s[0])
-	if !exist {
-		logger.Error("not found device by deviceId query")
-		closeStream()
+	// 下载的流在录制完成之前不能关闭
+	if service.Download().IsDownload(hookParam.Stream) {
+		c.JSON(200, model.OnStreamNoneReaderReply{
+			Code:  0,
+			Close: false,
+		})
 		return
 	}
 
-	err := gbsip.StopPlay(hookParam.Stream, s[1], device)
-	if err != nil {
+	if err := service.Play().StopStream(hookParam.Stream); err != nil {
 		logger.Errorf("%+v", err)
 	}
 	closeStream()
@@ -171,6 +170,14 @@ func (m MediaHookController) OnShellLogin(c *gin.Context) {
 
 }
 
+// 国标流的流id以设备id开头，据此找到流所属的设备
+func streamDeviceId(app, stream string) string {
+	if app != "rtp" {
+		return ""
+	}
+	return strings.SplitN(stream, "_", 2)[0]
+}
+
 func replyAllowMsg(c *gin.Context) {
 	c.JSON(200, model.HookReply{
 		Code: model.RespondSuccess,
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	srv "github.com/inysc/GB28181/internal/gbserver/service"
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+var (
+	errPlatformQuery    = errors.New("查询上级平台失败")
+	errPlatformSave     = errors.New("保存上级平台失败")
+	errPlatformDelete   = errors.New("删除上级平台失败")
+	errPlatformId       = errors.New("上级平台id不合法")
+	errPlatformChannels = errors.New("设置共享通道失败")
+)
+
+// PlatformController 上级平台控制器
+type PlatformController struct {
+	srv srv.Service
+}
+
+// NewPlatformController 新建上级平台控制器
+func NewPlatformController(store storage.Factory) *PlatformController {
+	return &PlatformController{
+		srv: srv.NewService(store),
+	}
+}
+
+// List 查询上级平台
+//
+//	@Summary      查询上级平台列表
+//	@Description  返回所有上级平台及注册状态，不返回注册密码
+//	@Tags         级联
+//	@Produce      json
+//	@Success      200  {object}  []model.Platform
+//	@Router       /platform/list [get]
+func (p *PlatformController) List(ctx *gin.Context) {
+	list, err := p.srv.Cascade().List()
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errPlatformQuery.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(list)
+}
+
+// Add 添加上级平台
+//
+//	@Summary      添加上级平台
+//	@Description  添加上级平台，启用的平台会立即开始注册
+//	@Tags         级联
+//	@Accept       json
+//	@Produce      json
+//	@Param        上级平台对象 body model.Platform  true  "上级平台对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /platform/add [post]
+func (p *PlatformController) Add(ctx *gin.Context) {
+	var data model.Platform
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	if err := validateIDs(data.ServerId); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	if err := p.srv.Cascade().Add(data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errPlatformSave.Error())
+		return
+	}
+	newResponse(ctx).success()
+}
+
+// Update 修改上级平台
+//
+//	@Summary      修改上级平台
+//	@Description  修改上级平台配置，密码为空时保留原密码，修改后以新的配置重新注册
+//	@Tags         级联
+//	@Accept       json
+//	@Produce      json
+//	@Param        上级平台对象 body model.Platform  true  "上级平台对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /platform/update [post]
+func (p *PlatformController) Update(ctx *gin.Context) {
+	var data model.Platform
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	if err := validateIDs(data.ServerId); err != nil {
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	if err := p.srv.Cascade().Update(data); err != nil {
+		logger.Error(err)
+		if errors.Is(err, srv.ErrPlatformNotFound) {
+			newResponse(ctx).fail(err.Error())
+			return
+		}
+		newResponse(ctx).fail(errPlatformSave.Error())
+		return
+	}
+	newResponse(ctx).success()
+}
+
+// Delete 删除上级平台
+//
+//	@Summary      删除上级平台
+//	@Description  从上级平台注销后删除平台及其共享通道
+//	@Tags         级联
+//	@Produce      json
+//	@Param        id	path	int	true	"上级平台id"
+//	@Success      200  {string}   "ok"
+//	@Router       /platform/delete/{id} [post]
+func (p *PlatformController) Delete(ctx *gin.Context) {
+	id, err := cast.ToUintE(ctx.Param("id"))
+	if err != nil {
+		newResponse(ctx).fail(errPlatformId.Error())
+		return
+	}
+	if err = p.srv.Cascade().Delete(id); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errPlatformDelete.Error())
+		return
+	}
+	newResponse(ctx).success()
+}
+
+// Channels 查询共享通道
+//
+//	@Summary      查询共享给上级平台的通道
+//	@Tags         级联
+//	@Produce      json
+//	@Param        id	path	int	true	"上级平台id"
+//	@Success      200  {object}  []model.PlatformChannel
+//	@Router       /platform/channels/{id} [get]
+func (p *PlatformController) Channels(ctx *gin.Context) {
+	id, err := cast.ToUintE(ctx.Param("id"))
+	if err != nil {
+		newResponse(ctx).fail(errPlatformId.Error())
+		return
+	}
+	list, err := p.srv.Cascade().Channels(id)
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errPlatformQuery.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(list)
+}
+
+// SetChannels 设置共享通道
+//
+//	@Summary      设置共享给上级平台的通道
+//	@Description  以传入的通道列表替换平台原有的共享通道，平台设置为共享全部通道时不使用该列表
+//	@Tags         级联
+//	@Accept       json
+//	@Produce      json
+//	@Param        id	path	int	true	"上级平台id"
+//	@Param        共享通道列表 body []model.PlatformChannel  true  "共享通道列表"
+//	@Success      200  {string}   "ok"
+//	@Router       /platform/channels/{id} [post]
+func (p *PlatformController) SetChannels(ctx *gin.Context) {
+	id, err := cast.ToUintE(ctx.Param("id"))
+	if err != nil {
+		newResponse(ctx).fail(errPlatformId.Error())
+		return
+	}
+	var data []model.PlatformChannel
+	if err = ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	if err = p.srv.Cascade().SetChannels(id, data); err != nil {
+		logger.Error(err)
+		if errors.Is(err, srv.ErrPlatformNotFound) {
+			newResponse(ctx).fail(err.Error())
+			return
+		}
+		newResponse(ctx).fail(errPlatformChannels.Error())
+		return
+	}
+	newResponse(ctx).success()
+}
@@ -40,3 +40,24 @@ func (p *PlayController) Play(c *gin.Context) {
 	}
 	newResponse(c).successWithAny(streamInfo)
 }
+
+// Stop 停止播放视频
+//
+// @Summary      停止播放设备的通道视频
+// @Description  观看者停止观看，所有观看者都停止后才会结束设备的点播
+// @Tags         播放
+// @Produce      json
+// @Param       deviceId	path	string	true	"设备id"
+// @Param       channelId	path	string	true	"通道id"
+// @Success      200  {string}   "ok"
+// @Router       /play/stop/{deviceId}/{channelId} [post]
+func (p *PlayController) Stop(c *gin.Context) {
+	deviceId := c.Param("deviceId")
+	channelId := c.Param("channelId")
+	if err := p.srv.Play().Stop(deviceId, channelId); err != nil {
+		logger.Errorf("%+v", err)
+		newResponse(c).fail(err.Error())
+		return
+	}
+	newResponse(c).success()
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	srv "github.com/inysc/GB28181/internal/gbserver/service"
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+)
+
+// PlaybackController 历史回放控制器
+type PlaybackController struct {
+	srv srv.Service
+}
+
+// NewPlaybackController 新建历史回放控制器
+func NewPlaybackController(store storage.Factory) *PlaybackController {
+	return &PlaybackController{
+		srv: srv.NewService(store),
+	}
+}
+
+// Start 开始历史回放
+//
+//	@Summary      回放设备通道的历史录像
+//	@Description  根据设备id、通道id以及时间段回放设备上存储的录像，每次调用都会建立独立的回放会话
+//	@Tags         回放
+//	@Produce      json
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Param        channelId	path	string	true	"通道id"
+//	@Param        start	query	string	true	"起始时间，格式为2006-01-02T15:04:05"
+//	@Param        end	query	string	true	"终止时间，格式为2006-01-02T15:04:05"
+//	@Success      200  {object}  model.StreamInfo
+//	@Router       /playback/start/{deviceId}/{channelId} [post]
+func (p *PlaybackController) Start(ctx *gin.Context) {
+	start, err := time.ParseInLocation(model.GBTimeLayout, ctx.Query("start"), time.Local)
+	if err != nil {
+		newResponse(ctx).fail(errRecordTimeFormat.Error())
+		return
+	}
+	end, err := time.ParseInLocation(model.GBTimeLayout, ctx.Query("end"), time.Local)
+	if err != nil {
+		newResponse(ctx).fail(errRecordTimeFormat.Error())
+		return
+	}
+	if !start.Before(end) {
+		newResponse(ctx).fail(errRecordTimeRange.Error())
+		return
+	}
+
+	streamInfo, err := p.srv.Playback().Start(ctx.Param("deviceId"), ctx.Param("channelId"), start, end)
+	if err != nil {
+		logger.Errorf("%+v", err)
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	newResponse(ctx).successWithAny(streamInfo)
+}
+
+// Control 控制历史回放
+//
+//	@Summary      控制历史回放
+//	@Description  对回放会话进行播放、暂停、倍速以及拖动等控制
+//	@Tags         回放
+//	@Accept       json
+//	@Produce      json
+//	@Param        回放控制对象 body model.PlaybackControl  true  "回放控制对象"
+//	@Success      200  {string}   "ok"
+//	@Router       /playback/control [post]
+func (p *PlaybackController) Control(ctx *gin.Context) {
+	var data model.PlaybackControl
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errDataBindStructFail.Error())
+		return
+	}
+	if err := p.srv.Playback().Control(data); err != nil {
+		logger.Errorf("%+v", err)
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	newResponse(ctx).success()
+}
+
+// Stop 停止历史回放
+//
+//	@Summary      停止历史回放
+//	@Description  根据流id结束回放会话
+//	@Tags         回放
+//	@Produce      json
+//	@Param        streamId	path	string	true	"回放的流id"
+//	@Success      200  {string}   "ok"
+//	@Router       /playback/stop/{streamId} [post]
+func (p *PlaybackController) Stop(ctx *gin.Context) {
+	if err := p.srv.Playback().Stop(ctx.Param("streamId")); err != nil {
+		logger.Errorf("%+v", err)
+		newResponse(ctx).fail(err.Error())
+		return
+	}
+	newResponse(ctx).success()
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	srv "github.com/inysc/GB28181/internal/gbserver/service"
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/pkg/errors"
+)
+
+var (
+	errPositionQuery = errors.New("查询设备轨迹失败")
+	errTrackRange    = errors.New("轨迹查询的时间段不能超过7天，且起始时间要早于终止时间")
+)
+
+const (
+	// 轨迹的输出格式
+	formatGeoJSON = "geojson"
+
+	// 没有指定起始时间时查询终止时间前一天的轨迹
+	defaultTrackWindow = 24 * time.Hour
+	// 单次查询的最大时间段
+	maxTrackWindow = 7 * 24 * time.Hour
+)
+
+// PositionController 移动设备位置控制器
+type PositionController struct {
+	srv srv.Service
+}
+
+// NewPositionController 新建移动设备位置控制器
+func NewPositionController(store storage.Factory) *PositionController {
+	return &PositionController{
+		srv: srv.NewService(store),
+	}
+}
+
+// Track 查询设备轨迹
+//
+//	@Summary      查询移动设备的轨迹
+//	@Description  根据设备id和时间段查询设备上报的位置记录，按定位时间升序排列，format为geojson时返回GeoJSON格式的轨迹
+//	@Description  终止时间默认为当前时间，起始时间默认为终止时间前一天，时间段最长7天，最多返回10000个点
+//	@Tags         移动设备位置
+//	@Produce      json
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Param        channelId	query	string	false	"位置所属的通道id"
+//	@Param        start	query	string	false	"起始时间，格式为2006-01-02T15:04:05"
+//	@Param        end	query	string	false	"终止时间，格式为2006-01-02T15:04:05"
+//	@Param        format	query	string	false	"输出格式，取值为geojson时返回GeoJSON"
+//	@Success      200  {array}   model.MobilePosition
+//	@Router       /position/{deviceId} [get]
+func (p *PositionController) Track(ctx *gin.Context) {
+	q := model.PositionQuery{
+		DeviceId:  ctx.Param("deviceId"),
+		ChannelId: ctx.Query("channelId"),
+	}
+	var err error
+	if s := ctx.Query("start"); s != "" {
+		if q.StartTime, err = time.ParseInLocation(model.GBTimeLayout, s, time.Local); err != nil {
+			newResponse(ctx).fail(errRecordTimeFormat.Error())
+			return
+		}
+	}
+	if e := ctx.Query("end"); e != "" {
+		if q.EndTime, err = time.ParseInLocation(model.GBTimeLayout, e, time.Local); err != nil {
+			newResponse(ctx).fail(errRecordTimeFormat.Error())
+			return
+		}
+	}
+
+	if q.EndTime.IsZero() {
+		q.EndTime = time.Now()
+	}
+	if q.StartTime.IsZero() {
+		q.StartTime = q.EndTime.Add(-defaultTrackWindow)
+	}
+	if !q.StartTime.Before(q.EndTime) || q.EndTime.Sub(q.StartTime) > maxTrackWindow {
+		newResponse(ctx).fail(errTrackRange.Error())
+		return
+	}
+
+	list, err := p.srv.Position().Track(q)
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errPositionQuery.Error())
+		return
+	}
+	if ctx.Query("format") == formatGeoJSON {
+		newResponse(ctx).successWithAny(model.NewTrackGeoJSON(list))
+		return
+	}
+	newResponse(ctx).successWithAny(list)
+}
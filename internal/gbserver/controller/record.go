@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	srv "github.com/inysc/GB28181/internal/gbserver/service"
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/syn"
+	"github.com/pkg/errors"
+)
+
+var (
+	errRecordTimeFormat   = errors.New("时间格式错误，格式为：2006-01-02T15:04:05")
+	errRecordQuery        = errors.New("检索录像文件失败")
+	errRecordQueryTimeOut = errors.New("检索录像文件超时")
+	errRecordTimeRange    = errors.New("录像起始时间必须早于终止时间")
+)
+
+// RecordController 录像控制器
+type RecordController struct {
+	srv srv.Service
+}
+
+// NewRecordController 新建录像控制器
+func NewRecordController(store storage.Factory) *RecordController {
+	return &RecordController{
+		srv: srv.NewService(store),
+	}
+}
+
+// Query 检索录像文件
+//
+//	@Summary      检索设备通道的录像文件
+//	@Description  根据设备id、通道id以及时间段，检索设备上存储的录像文件，设备分多包返回时会合并后再返回
+//	@Tags         录像
+//	@Produce      json
+//	@Param        deviceId	path	string	true	"设备id"
+//	@Param        channelId	path	string	true	"通道id"
+//	@Param        start	query	string	true	"起始时间，格式为2006-01-02T15:04:05"
+//	@Param        end	query	string	true	"终止时间，格式为2006-01-02T15:04:05"
+//	@Param        type	query	string	false	"录像产生类型，取值为：time、alarm、manual、all"
+//	@Success      200  {object}  gbsip.RecordInfo
+//	@Router       /record/{deviceId}/{channelId} [get]
+func (r *RecordController) Query(ctx *gin.Context) {
+	q := model.RecordQuery{
+		DeviceId:  ctx.Param("deviceId"),
+		ChannelId: ctx.Param("channelId"),
+		Type:      ctx.Query("type"),
+	}
+	start, err := time.ParseInLocation(model.GBTimeLayout, ctx.Query("start"), time.Local)
+	if err != nil {
+		newResponse(ctx).fail(errRecordTimeFormat.Error())
+		return
+	}
+	end, err := time.ParseInLocation(model.GBTimeLayout, ctx.Query("end"), time.Local)
+	if err != nil {
+		newResponse(ctx).fail(errRecordTimeFormat.Error())
+		return
+	}
+	if !start.Before(end) {
+		newResponse(ctx).fail(errRecordTimeRange.Error())
+		return
+	}
+	q.StartTime = start.Format(model.GBTimeLayout)
+	q.EndTime = end.Format(model.GBTimeLayout)
+
+	device, ok := r.srv.Devices().GetByDeviceId(q.DeviceId)
+	if !ok {
+		newResponse(ctx).fail(errDeviceNotFound.Error())
+		return
+	}
+
+	task, err := gbsip.RecordInfoQuery(device, q)
+	if err != nil {
+		logger.Error(err)
+		newResponse(ctx).fail(errRecordQuery.Error())
+		return
+	}
+	waitCtx, cancel := context.WithTimeout(ctx.Request.Context(), 10*time.Second)
+	defer cancel()
+	data, err := gbsip.CollectRecordInfo(waitCtx, task)
+	if err != nil {
+		if errors.Is(err, syn.ErrTimeOut) {
+			newResponse(ctx).fail(errRecordQueryTimeOut.Error())
+			return
+		}
+		logger.Error(err)
+		newResponse(ctx).fail(errRecordQuery.Error())
+		return
+	}
+
+	newResponse(ctx).successWithAny(data)
+}
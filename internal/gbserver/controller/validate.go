@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/inysc/GB28181/internal/pkg/gbid"
+)
+
+// 路径中需要校验为统一编码的参数
+var idParams = []string{"deviceId", "channelId", "device"}
+
+// ValidateID 校验路径参数中的设备id和通道id是否为合法的统一编码
+func ValidateID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		for _, name := range idParams {
+			v := ctx.Param(name)
+			if v == "" {
+				continue
+			}
+			if err := gbid.Validate(v); err != nil {
+				newResponse(ctx).fail(err.Error())
+				ctx.Abort()
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
+
+// 校验请求体中的设备id和通道id，为空的id不校验
+func validateIDs(ids ...string) error {
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		if err := gbid.Validate(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package gb
+
+import (
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghettovoice/gosip/sip"
+	sipparser "github.com/ghettovoice/gosip/sip/parser"
+	"github.com/inysc/GB28181/internal/pkg/digest"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/option"
+)
+
+const (
+	AuthorizationHeader = "Authorization"
+
+	// nonce的有效期，超过有效期的nonce需要重新质询
+	nonceExpires = 5 * time.Minute
+	// 同时保留的nonce数量上限，超过后最早签发的nonce会被提前丢弃
+	maxNonces = 10000
+)
+
+// 认证结果
+type authResult int
+
+const (
+	authOK authResult = iota
+	// 没有携带认证信息，或nonce不是本服务签发的
+	authChallenge
+	// nonce已过期，需要重新质询并设置stale
+	authStale
+	// 密码错误
+	authForbidden
+)
+
+// 签发的nonce，nc记录该nonce已经使用过的最大请求计数，用于拒绝重放的认证信息
+type nonceState struct {
+	expire time.Time
+	nc     uint64
+	elem   *list.Element
+}
+
+type digestAuth struct {
+	opt *option.SIPOptions
+
+	mux    sync.Mutex
+	nonces map[string]*nonceState
+	// 按签发顺序排列的nonce，有效期固定，所以队首总是最先过期的
+	queue *list.List
+}
+
+var auth = newDigestAuth(option.NewSIPOptions())
+
+func newDigestAuth(opt *option.SIPOptions) *digestAuth {
+	return &digestAuth{
+		opt:    opt,
+		nonces: make(map[string]*nonceState),
+		queue:  list.New(),
+	}
+}
+
+// 生成一个新的认证质询，并记录其中nonce的过期时间
+func (a *digestAuth) challenge(stale bool) digest.Challenge {
+	nonce := digest.NewNonce()
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	now := time.Now()
+	// 从队首清理掉已经过期的nonce，数量超过上限时也丢弃最早签发的
+	for e := a.queue.Front(); e != nil; e = a.queue.Front() {
+		n := e.Value.(string)
+		if a.queue.Len() < maxNonces && !now.After(a.nonces[n].expire) {
+			break
+		}
+		a.remove(n)
+	}
+	a.nonces[nonce] = &nonceState{
+		expire: now.Add(nonceExpires),
+		elem:   a.queue.PushBack(nonce),
+	}
+
+	return digest.Challenge{
+		Realm:     a.opt.Domain,
+		Nonce:     nonce,
+		Algorithm: a.algorithm(),
+		Qop:       digest.QopAuth,
+		Stale:     stale,
+	}
+}
+
+// 删除nonce，调用方需持有锁
+func (a *digestAuth) remove(nonce string) {
+	if state, ok := a.nonces[nonce]; ok {
+		a.queue.Remove(state.elem)
+		delete(a.nonces, nonce)
+	}
+}
+
+func (a *digestAuth) algorithm() string {
+	if a.opt.Algorithm == "" {
+		return DefaultAlgorithm
+	}
+	return a.opt.Algorithm
+}
+
+// 检查nonce是否由本服务签发，以及是否过期
+func (a *digestAuth) checkNonce(nonce string) authResult {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	state, ok := a.nonces[nonce]
+	if !ok {
+		return authChallenge
+	}
+	if time.Now().After(state.expire) {
+		a.remove(nonce)
+		return authStale
+	}
+	return authOK
+}
+
+// 认证通过后登记nonce的使用，带qop时nc必须比该nonce上次使用的更大，
+// 不带qop时没有nc可以区分重放，nonce只能使用一次
+func (a *digestAuth) useNonce(cred digest.Credentials) authResult {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	state, ok := a.nonces[cred.Nonce]
+	if !ok {
+		return authChallenge
+	}
+	if cred.Qop == "" {
+		a.remove(cred.Nonce)
+		return authOK
+	}
+	nc, err := strconv.ParseUint(cred.Nc, 16, 64)
+	if err != nil || nc <= state.nc {
+		return authChallenge
+	}
+	state.nc = nc
+	return authOK
+}
+
+// 设备单独配置的密码优先，否则使用全局密码
+func (a *digestAuth) password(device model.Device) string {
+	if device.Password != "" {
+		return device.Password
+	}
+	return a.opt.Password
+}
+
+// 校验REGISTER请求中的Authorization头部，uri为请求的Request-URI
+func (a *digestAuth) verify(device model.Device, method string, uri sip.Uri, authorization string) authResult {
+	password := a.password(device)
+	// 没有配置密码则不进行校验
+	if password == "" {
+		return authOK
+	}
+	if authorization == "" {
+		return authChallenge
+	}
+
+	cred, err := digest.ParseCredentials(authorization)
+	if err != nil {
+		return authChallenge
+	}
+	if r := a.checkNonce(cred.Nonce); r != authOK {
+		return r
+	}
+	// 只接受质询时指定的算法，防止配置了SHA-256时被降级为MD5
+	algorithm := cred.Algorithm
+	if algorithm == "" {
+		algorithm = digest.AlgorithmMD5
+	}
+	if !strings.EqualFold(algorithm, a.algorithm()) {
+		return authForbidden
+	}
+	if cred.Realm != a.opt.Domain || cred.Username != device.DeviceId {
+		return authForbidden
+	}
+	// 认证信息中的uri必须是本次请求的Request-URI，防止截获其它请求的认证信息后重用
+	if credURI, err := sipparser.ParseUri(cred.URI); err != nil || !credURI.Equals(uri) {
+		return authForbidden
+	}
+	if !cred.Verify(method, password) {
+		return authForbidden
+	}
+	return a.useNonce(cred)
+}
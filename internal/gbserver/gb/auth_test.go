@@ -0,0 +1,64 @@
+package gb
+
+import (
+	"testing"
+
+	sipparser "github.com/ghettovoice/gosip/sip/parser"
+	"github.com/inysc/GB28181/internal/pkg/digest"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/option"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestDigestAuth(t *testing.T) {
+	convey.Convey("TestDigestAuth", t, func() {
+		opt := option.NewSIPOptions()
+		opt.Password = "12345678"
+		opt.Algorithm = digest.AlgorithmSHA256
+		a := newDigestAuth(opt)
+		device := model.Device{DeviceId: "44010200491320000001"}
+		uri := "sip:" + opt.Id + "@" + opt.Domain
+		requestURI, err := sipparser.ParseUri(uri)
+		convey.So(err, convey.ShouldBeNil)
+		verify := func(authorization string) authResult {
+			return a.verify(device, "REGISTER", requestURI, authorization)
+		}
+
+		authorize := func(ch digest.Challenge) string {
+			cred, err := digest.Authorize(ch, "REGISTER", uri, device.DeviceId, opt.Password)
+			convey.So(err, convey.ShouldBeNil)
+			return cred.String()
+		}
+
+		ch := a.challenge(false)
+		authorization := authorize(ch)
+		convey.So(verify(authorization), convey.ShouldEqual, authOK)
+		// 相同nc的认证信息被重放
+		convey.So(verify(authorization), convey.ShouldEqual, authChallenge)
+
+		// 质询SHA-256时使用MD5应答
+		ch = a.challenge(false)
+		ch.Algorithm = digest.AlgorithmMD5
+		convey.So(verify(authorize(ch)), convey.ShouldEqual, authForbidden)
+
+		// 不带qop时nonce只能使用一次
+		ch = a.challenge(false)
+		ch.Qop = ""
+		authorization = authorize(ch)
+		convey.So(verify(authorization), convey.ShouldEqual, authOK)
+		convey.So(verify(authorization), convey.ShouldEqual, authChallenge)
+
+		// 认证信息中的uri与请求不一致
+		cred, err := digest.Authorize(a.challenge(false), "REGISTER", "sip:"+opt.Id+"@127.0.0.1", device.DeviceId, opt.Password)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(verify(cred.String()), convey.ShouldEqual, authForbidden)
+
+		// 超过数量上限时最早签发的nonce被丢弃
+		first := a.challenge(false)
+		for i := 0; i < maxNonces; i++ {
+			a.challenge(false)
+		}
+		convey.So(a.queue.Len(), convey.ShouldEqual, maxNonces)
+		convey.So(verify(authorize(first)), convey.ShouldEqual, authChallenge)
+	})
+}
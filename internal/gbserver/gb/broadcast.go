@@ -0,0 +1,64 @@
+package gb
+
+import (
+	"encoding/xml"
+	"net/http"
+
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/gbserver/service"
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/pkg/errors"
+)
+
+// 设备对语音广播通知的应答
+type broadcastResponse struct {
+	XMLName  xml.Name `xml:"Response"`
+	SN       string   `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+	Result   string   `xml:"Result"`
+}
+
+// 设备拒绝语音广播时不会再发起INVITE，需要立即结束等待
+func broadcastResponseHandler(req sip.Request, tx sip.ServerTransaction) {
+	_ = responseAck(tx, req)
+	r := broadcastResponse{}
+	if err := parser.XmlStringDecode(req.Body(), &r); err != nil {
+		logger.Error("解析语音广播应答出错", err)
+		return
+	}
+	if r.Result != resultOK {
+		logger.Errorf("{%s}设备拒绝了语音广播，结果为%s", r.DeviceID, r.Result)
+		gbsip.BroadcastFailed(r.DeviceID, errors.Errorf("设备拒绝了语音广播: %s", r.Result))
+	}
+}
+
+// 设备请求语音广播的语音流，本级应答后由流媒体向设备推送语音
+func broadcastInviteHandler(req sip.Request, tx sip.ServerTransaction, session model.BroadcastSession) {
+	inv, err := gbsip.ParseBroadcastInvite(req.Body())
+	if err != nil {
+		respondStatus(tx, req, 488)
+		logger.Errorf("{%s}设备的语音广播请求不支持，%s", session.ChannelId, err)
+		gbsip.BroadcastFailed(session.ChannelId, err)
+		return
+	}
+	respondStatus(tx, req, 100)
+
+	answer, session, err := service.Broadcast().Invite(session, inv)
+	if err != nil {
+		logger.Errorf("{%s}应答设备的语音广播请求失败，%s", session.ChannelId, err)
+		respondStatus(tx, req, http.StatusInternalServerError)
+		return
+	}
+
+	resp := gbsip.CreateInviteOkResponse(req, answer)
+	logger.Debugf("应答设备的语音广播请求\n%s", resp)
+	if err = tx.Respond(resp); err != nil {
+		logger.Error(err)
+		service.Broadcast().Fail(session, err)
+		return
+	}
+	gbsip.BroadcastEstablished(session, req, resp)
+}
@@ -0,0 +1,54 @@
+package gb
+
+import (
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/gbserver/service"
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+)
+
+// ByeHandler 处理设备或上级平台主动发送的BYE请求，清理缓存中的会话信息
+func ByeHandler(req sip.Request, tx sip.ServerTransaction) {
+	logger.Debugf("收到BYE请求\n%s", printRequest(req))
+	_ = responseAck(tx, req)
+
+	callId, ok := req.CallID()
+	if !ok {
+		logger.Error("BYE请求中没有Call-ID")
+		return
+	}
+	streamId, err := gbsip.StreamIdByCallId(callId.Value())
+	if err != nil {
+		// 上级平台结束级联点播
+		if ok, err := service.Cascade().Bye(callId.Value()); ok {
+			if err != nil {
+				logger.Errorf("结束级联点播失败：%v", err)
+			}
+			return
+		}
+		// 设备结束语音广播
+		if ok, err := service.Broadcast().Bye(callId.Value()); ok {
+			if err != nil {
+				logger.Errorf("结束语音广播失败：%v", err)
+			}
+			return
+		}
+		// 平台已经主动结束了会话，缓存已被清理
+		logger.Debugf("找不到Call-ID为%s的会话", callId.Value())
+		return
+	}
+
+	// 设备在录像发送完毕后可能直接结束会话，而不发送媒体通知
+	if info, err := gbsip.GetDownloadInfo(streamId); err == nil && info.Status == model.DownloadStatusDownloading {
+		if err := gbsip.FinishDownload(streamId); err != nil {
+			logger.Errorf("{%s}更新下载状态失败：%v", streamId, err)
+		}
+	}
+
+	if err := service.Play().Bye(streamId); err != nil {
+		logger.Errorf("{%s}清理会话信息失败：%v", streamId, err)
+		return
+	}
+	logger.Infof("{%s}设备结束了会话", streamId)
+}
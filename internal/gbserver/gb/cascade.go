@@ -0,0 +1,184 @@
+package gb
+
+import (
+	"encoding/xml"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/gbserver/service"
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/pkg/errors"
+)
+
+// 上级平台发送的查询请求
+type platformQuery struct {
+	XMLName  xml.Name `xml:"Query"`
+	CmdType  string   `xml:"CmdType"`
+	SN       string   `xml:"SN"`
+	DeviceID string   `xml:"DeviceID"`
+}
+
+// 根据请求的From头部查找发起请求的上级平台，From头部可以伪造，请求来源还必须是平台配置的地址
+func platformFromRequest(req sip.Request) (model.Platform, bool) {
+	from, ok := req.From()
+	if !ok || from.Address == nil || from.Address.User() == nil {
+		return model.Platform{}, false
+	}
+	p, ok := storage.s.Platform().GetByServerId(from.Address.User().String())
+	if !ok {
+		return model.Platform{}, false
+	}
+	if !fromPlatform(p, service.Cascade().Addrs(p.ID), req.Source()) {
+		logger.Warnf("{%s}请求来源%s与上级平台地址%s:%s不一致", p.ServerId, req.Source(), p.ServerIp, p.ServerPort)
+		return model.Platform{}, false
+	}
+	return p, true
+}
+
+// 请求来源的ip必须是上级平台地址解析后的ip之一，UDP请求的来源端口还必须是平台的SIP端口，TCP连接的来源端口是随机的
+func fromPlatform(p model.Platform, addrs []string, source string) bool {
+	host, port, err := net.SplitHostPort(source)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(p.WithDefault().Transport, "UDP") && port != p.ServerPort {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ip.Equal(net.ParseIP(addr)) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodePlatformQuery(req sip.Request) (platformQuery, error) {
+	q := platformQuery{}
+	if err := parser.XmlStringDecode(req.Body(), &q); err != nil {
+		b, err := gbkToUtf8([]byte(req.Body()))
+		if err != nil {
+			return q, err
+		}
+		if err = parser.XmlStringDecode(string(b), &q); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// 处理上级平台的查询请求，先应答再异步发送查询结果
+func platformQueryHandler(req sip.Request, tx sip.ServerTransaction, reply func(p model.Platform, q platformQuery) error) {
+	p, ok := platformFromRequest(req)
+	if !ok {
+		resp := sip.NewResponseFromRequest("", req, http.StatusForbidden, http.StatusText(http.StatusForbidden), "")
+		logger.Warnf("收到未知平台的查询请求\n%s", resp)
+		_ = tx.Respond(resp)
+		return
+	}
+	_ = responseAck(tx, req)
+
+	q, err := decodePlatformQuery(req)
+	if err != nil {
+		logger.Errorf("解析上级平台%s的查询请求失败，%s", p.ServerId, err)
+		return
+	}
+	go func() {
+		if err := reply(p, q); err != nil {
+			logger.Errorf("{%s}应答上级平台的%s查询失败，%s", p.ServerId, q.CmdType, err)
+		}
+	}()
+}
+
+func platformCatalogQueryHandler(req sip.Request, tx sip.ServerTransaction) {
+	platformQueryHandler(req, tx, func(p model.Platform, q platformQuery) error {
+		channels, err := service.Cascade().SharedChannels(p)
+		if err != nil {
+			return err
+		}
+		return gbsip.CatalogResponse(p, q.SN, channels)
+	})
+}
+
+func platformDeviceInfoQueryHandler(req sip.Request, tx sip.ServerTransaction) {
+	platformQueryHandler(req, tx, func(p model.Platform, q platformQuery) error {
+		channels, err := service.Cascade().SharedChannels(p)
+		if err != nil {
+			return err
+		}
+		return gbsip.DeviceInfoResponse(p, q.SN, len(channels))
+	})
+}
+
+func platformDeviceStatusQueryHandler(req sip.Request, tx sip.ServerTransaction) {
+	platformQueryHandler(req, tx, func(p model.Platform, q platformQuery) error {
+		return gbsip.DeviceStatusResponse(p, q.SN)
+	})
+}
+
+// 处理上级平台对共享通道的点播请求
+func platformInviteHandler(req sip.Request, tx sip.ServerTransaction) {
+	p, ok := platformFromRequest(req)
+	if !ok {
+		respondStatus(tx, req, http.StatusForbidden)
+		logger.Warn("收到未知平台的INVITE请求")
+		return
+	}
+	callId, ok := req.CallID()
+	if !ok {
+		respondStatus(tx, req, http.StatusBadRequest)
+		return
+	}
+
+	inv, err := gbsip.ParseCascadeInvite(req.Body())
+	if err != nil {
+		respondStatus(tx, req, 488)
+		logger.Errorf("{%s}上级平台的点播请求不支持，%s", p.ServerId, err)
+		return
+	}
+	inv.ChannelId = inviteChannelId(req)
+	respondStatus(tx, req, 100)
+
+	answer, err := service.Cascade().Invite(p, callId.Value(), inv)
+	if err != nil {
+		logger.Errorf("{%s}上级平台点播通道%s失败，%s", p.ServerId, inv.ChannelId, err)
+		if errors.Is(err, service.ErrChannelNotShared) {
+			respondStatus(tx, req, http.StatusNotFound)
+		} else {
+			respondStatus(tx, req, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := gbsip.CreateInviteOkResponse(req, answer)
+	logger.Debugf("应答上级平台的点播请求\n%s", resp)
+	if err = tx.Respond(resp); err != nil {
+		logger.Error(err)
+	}
+}
+
+// 通道id取自请求地址，部分平台只在Subject头部中携带
+func inviteChannelId(req sip.Request) string {
+	if u := req.Recipient().User(); u != nil && u.String() != "" {
+		return u.String()
+	}
+	if h := req.GetHeaders("Subject"); len(h) > 0 {
+		// Subject: 媒体流发送者设备编码:发送端媒体流序列号,媒体流接收者设备编码:接收端媒体流序列号
+		v := h[0].Value()
+		for i := 0; i < len(v); i++ {
+			if v[i] == ':' {
+				return v[:i]
+			}
+		}
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,26 @@
+package gb
+
+import (
+	"testing"
+
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFromPlatform(t *testing.T) {
+	convey.Convey("TestFromPlatform", t, func() {
+		p := model.Platform{ServerIp: "192.168.1.10", ServerPort: "5060"}
+		addrs := []string{"192.168.1.10"}
+		convey.So(fromPlatform(p, addrs, "192.168.1.10:5060"), convey.ShouldBeTrue)
+		convey.So(fromPlatform(p, addrs, "192.168.1.10:5061"), convey.ShouldBeFalse)
+		convey.So(fromPlatform(p, addrs, "192.168.1.11:5060"), convey.ShouldBeFalse)
+		convey.So(fromPlatform(p, addrs, ""), convey.ShouldBeFalse)
+		// 平台没有启用或地址解析失败
+		convey.So(fromPlatform(p, nil, "192.168.1.10:5060"), convey.ShouldBeFalse)
+
+		// TCP连接的来源端口是随机的
+		p.Transport = "TCP"
+		convey.So(fromPlatform(p, addrs, "192.168.1.10:40312"), convey.ShouldBeTrue)
+		convey.So(fromPlatform(p, addrs, "192.168.1.11:40312"), convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,102 @@
+package gb
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+)
+
+// 超过该时间没有收到后续分包，按已收到的通道保存并结束本次同步
+const catalogFragmentTimeout = 30 * time.Second
+
+type catalogFragment struct {
+	deviceId string
+	sn       string
+	total    int
+	// 已收到的目录项数，包括编码不合法而被忽略的目录项，达到total时目录接收完整
+	received int
+	items    []CatalogItem
+	// 按通道id去重，UDP重传时设备可能重复发送同一个分包
+	seen  map[string]struct{}
+	timer *time.Timer
+}
+
+// 目录查询结果聚合，设备通道较多时会按SN分多个包返回
+type catalogAggregator struct {
+	mux sync.Mutex
+	m   map[string]*catalogFragment
+}
+
+var catalogs = &catalogAggregator{m: make(map[string]*catalogFragment)}
+
+// 合并一个分包，收到的目录项数达到SumNum后以完整目录同步通道
+func (c *catalogAggregator) add(catalog DeviceCatalogResponse) {
+	deviceId := catalog.DeviceID.DeviceID
+	key := fmt.Sprintf("%s_%s", deviceId, catalog.SN.SN)
+
+	c.mux.Lock()
+	f, ok := c.m[key]
+	if !ok {
+		f = &catalogFragment{
+			deviceId: deviceId,
+			sn:       catalog.SN.SN,
+			seen:     make(map[string]struct{}),
+		}
+		f.timer = time.AfterFunc(catalogFragmentTimeout, func() { c.expire(key) })
+		c.m[key] = f
+	} else {
+		f.timer.Reset(catalogFragmentTimeout)
+	}
+	f.total = catalog.SumNum
+	for _, item := range catalog.DeviceList.Items {
+		if _, ok := f.seen[item.DeviceID.DeviceID]; ok {
+			continue
+		}
+		f.seen[item.DeviceID.DeviceID] = struct{}{}
+		f.received++
+		if !item.valid() {
+			logger.Warnf("{%s}目录项编码不合法，已忽略：%s", deviceId, item.DeviceID.DeviceID)
+			continue
+		}
+		f.items = append(f.items, item)
+	}
+	gbsip.CatalogSyncProgress(deviceId, f.sn, f.total, f.received)
+
+	if f.received < f.total {
+		c.mux.Unlock()
+		return
+	}
+	f.timer.Stop()
+	delete(c.m, key)
+	c.mux.Unlock()
+
+	logger.Infof("{%s}目录接收完整，共%d个通道，忽略%d个不合法的目录项", deviceId, len(f.items), f.received-len(f.items))
+	if err := storage.syncChannel(deviceId, f.items); err != nil {
+		logger.Errorf("{%s}同步设备目录失败，%s", deviceId, err)
+		gbsip.FinishCatalogSync(deviceId, model.CatalogSyncFailed, err.Error())
+		return
+	}
+	gbsip.FinishCatalogSync(deviceId, model.CatalogSyncFinished, "")
+}
+
+// 分包接收超时，已收到的通道仍然保存，但目录不完整，不能据此删除通道
+func (c *catalogAggregator) expire(key string) {
+	c.mux.Lock()
+	f, ok := c.m[key]
+	if ok {
+		delete(c.m, key)
+	}
+	c.mux.Unlock()
+	if !ok {
+		return
+	}
+
+	logger.Warnf("{%s}目录未接收完整，已收到%d/%d个目录项", f.deviceId, f.received, f.total)
+	storage.saveChannels(f.deviceId, f.items)
+	gbsip.FinishCatalogSync(f.deviceId, model.CatalogSyncTimeout,
+		fmt.Sprintf("只收到%d/%d个目录项", f.received, f.total))
+}
@@ -0,0 +1,42 @@
+package gb
+
+import (
+	"testing"
+
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestCatalogAggregator(t *testing.T) {
+	convey.Convey("TestCatalogAggregator", t, func() {
+		setupStorage(t)
+		const deviceId = "44010200491320000001"
+		catalog := func(ids ...string) DeviceCatalogResponse {
+			var c DeviceCatalogResponse
+			c.DeviceID.DeviceID = deviceId
+			c.SN.SN = "1"
+			c.SumNum = 3
+			for _, id := range ids {
+				var item CatalogItem
+				item.DeviceID.DeviceID = id
+				c.DeviceList.Items = append(c.DeviceList.Items, item)
+			}
+			return c
+		}
+
+		// 不合法的目录项被忽略，但仍然计入已收到的目录项数
+		catalogs.add(catalog("44010200491310000001", "bad"))
+		catalogs.add(catalog("44010200491310000001"))
+		s, ok := gbsip.CatalogSyncStatus(deviceId)
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(s.Received, convey.ShouldEqual, 2)
+
+		catalogs.add(catalog("44010200491310000002"))
+		s, _ = gbsip.CatalogSyncStatus(deviceId)
+		convey.So(s.Status, convey.ShouldEqual, model.CatalogSyncFinished)
+		list, err := storage.s.Channel().List(deviceId)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(list), convey.ShouldEqual, 2)
+	})
+}
@@ -2,7 +2,6 @@ package gb
 
 import (
 	"encoding/xml"
-	"fmt"
 
 	"github.com/ghettovoice/gosip/sip"
 	"github.com/inysc/GB28181/internal/pkg/gbsip"
@@ -17,7 +16,7 @@ func deviceConfigQueryHandler(req sip.Request, tx sip.ServerTransaction) {
 		_ = responseAck(tx, req)
 	}()
 
-	cfg := &gbsip.DeviceBasicConfigResp{}
+	cfg := &gbsip.DeviceConfigResp{}
 
 	if err := xml.Unmarshal([]byte(req.Body()), cfg); err != nil {
 		b, err := gbkToUtf8([]byte(req.Body()))
@@ -33,26 +32,68 @@ func deviceConfigQueryHandler(req sip.Request, tx sip.ServerTransaction) {
 	}
 
 	if cfg.R.Result != "OK" {
+		syn.Fail(cfg.CmdType.CmdType, cfg.SN.SN, gbsip.ErrControlFailed)
 		return
 	}
+	syn.Deliver(cfg.CmdType.CmdType, cfg.SN.SN, cfg)
 
-	syn.HasSyncTask(fmt.Sprintf("%s_%s", syn.KeyControlDeviceConfigQuery, cfg.DeviceID.DeviceID), func(e *syn.Entity) {
-		e.Ok(cfg)
-	})
-
-	_ = storage.updateDeviceBasicConfig(*cfg)
+	if cfg.BasicParam != nil {
+		_ = storage.updateDeviceBasicConfig(gbsip.DeviceBasicConfigResp{Mata: cfg.Mata, R: cfg.R, BasicParam: *cfg.BasicParam})
+	}
 }
 
+// 设备配置和设备控制的应答，将执行结果交给等待的调用方
 func deviceConfigResponseHandler(req sip.Request, tx sip.ServerTransaction) {
-	r := parser.GetResultFromXML(req.Body())
-	if r == "" {
+	defer func() {
+		_ = responseAck(tx, req)
+	}()
+
+	r := gbsip.ControlResult{}
+	if err := parser.XmlStringDecode(req.Body(), &r); err != nil {
+		b, err := gbkToUtf8([]byte(req.Body()))
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		if err = parser.XmlStringDecode(string(b), &r); err != nil {
+			logger.Error(err)
+			return
+		}
+	}
+	if r.Result == "" {
 		logger.Error("获取不到响应信息中的Result字段")
 		return
 	}
 
-	if r == "ERROR" {
-		logger.Error("发送修改配置请求失败，请检查")
+	var found bool
+	if r.Result == "OK" {
+		found = syn.Deliver(r.CmdType.CmdType, r.SN.SN, r.Result)
 	} else {
-		logger.Debug("发送修改配置请求成功")
+		found = syn.Fail(r.CmdType.CmdType, r.SN.SN, gbsip.ErrControlFailed)
+	}
+	if !found {
+		logger.Debugf("{%s}%s的执行结果：%s", r.DeviceID.DeviceID, r.CmdType.CmdType, r.Result)
 	}
 }
+
+// 预置位查询响应
+func presetQueryHandler(req sip.Request, tx sip.ServerTransaction) {
+	defer func() {
+		_ = responseAck(tx, req)
+	}()
+
+	info := gbsip.PresetInfo{}
+	if err := parser.XmlStringDecode(req.Body(), &info); err != nil {
+		b, err := gbkToUtf8([]byte(req.Body()))
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		if err = parser.XmlStringDecode(string(b), &info); err != nil {
+			logger.Error(err)
+			return
+		}
+	}
+
+	syn.Deliver(info.CmdType.CmdType, info.SN.SN, info.PresetList.Items)
+}
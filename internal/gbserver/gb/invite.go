@@ -0,0 +1,43 @@
+package gb
+
+import (
+	"net/http"
+
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+)
+
+// InviteHandler 处理收到的INVITE请求，设备收到语音广播通知后发起的INVITE和上级平台的点播请求
+func InviteHandler(req sip.Request, tx sip.ServerTransaction) {
+	logger.Debugf("收到INVITE请求\n%s", printRequest(req))
+	if from, ok := req.From(); ok && from.Address != nil && from.Address.User() != nil {
+		if session, ok := gbsip.PendingBroadcast(from.Address.User().String()); ok {
+			broadcastInviteHandler(req, tx, session)
+			return
+		}
+	}
+	platformInviteHandler(req, tx)
+}
+
+// AckHandler 对方确认了本级对INVITE的应答
+func AckHandler(req sip.Request, tx sip.ServerTransaction) {
+	logger.Debugf("收到ACK请求\n%s", printRequest(req))
+}
+
+func respondStatus(tx sip.ServerTransaction, req sip.Request, code int) {
+	resp := sip.NewResponseFromRequest("", req, sip.StatusCode(code), sipStatusText(code), "")
+	if err := tx.Respond(resp); err != nil {
+		logger.Error(err)
+	}
+}
+
+func sipStatusText(code int) string {
+	switch code {
+	case 100:
+		return "Trying"
+	case 488:
+		return "Not Acceptable Here"
+	}
+	return http.StatusText(code)
+}
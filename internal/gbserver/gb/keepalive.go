@@ -0,0 +1,156 @@
+package gb
+
+import (
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/cron"
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/spf13/cast"
+)
+
+const (
+	// 设备未上报心跳参数时使用的默认值
+	defaultHeartBeatInterval = 60
+	defaultHeartBeatCount    = 3
+)
+
+const (
+	reasonRegister   = "设备注册"
+	reasonUnregister = "设备注销"
+	reasonKeepalive  = "心跳恢复"
+	reasonTimeout    = "心跳超时"
+	reasonExpired    = "注册过期"
+)
+
+// 设备在线状态监测，心跳超过 心跳间隔×超时次数 未收到，或注册有效期内未刷新注册时设备离线
+type deviceMonitor struct {
+	wheel *cron.TimingWheel
+}
+
+var monitor = &deviceMonitor{wheel: cron.NewTimingWheel(time.Second, 3600)}
+
+func keepaliveKey(deviceId string) string {
+	return deviceId + ":keepalive"
+}
+
+func registerKey(deviceId string) string {
+	return deviceId + ":register"
+}
+
+// 心跳超时时长
+func keepaliveTimeout(d model.Device) time.Duration {
+	interval, count := d.HeartBeatInterval, d.HeartBeatCount
+	if interval <= 0 {
+		interval = defaultHeartBeatInterval
+	}
+	if count <= 0 {
+		count = defaultHeartBeatCount
+	}
+	return time.Duration(interval*count) * time.Second
+}
+
+// 注册有效期，为0时不检测注册过期
+func registerExpires(d model.Device) time.Duration {
+	return time.Duration(cast.ToInt(d.Expires)) * time.Second
+}
+
+// 注册是否仍在有效期内
+func registerValid(d model.Device) bool {
+	expires := registerExpires(d)
+	return expires == 0 || time.Since(d.RegisterTime) < expires
+}
+
+// 启动监测，并从存储中恢复在线设备的监测
+func (m *deviceMonitor) start() {
+	m.wheel.Start()
+	m.rebuild()
+}
+
+func (m *deviceMonitor) stop() {
+	m.wheel.Stop()
+}
+
+// 服务重启后，以存储中最近一次注册和心跳的时间继续监测在线设备，期间已超时的设备直接离线
+func (m *deviceMonitor) rebuild() {
+	devices, err := storage.s.Devices().List()
+	if err != nil {
+		logger.Errorf("恢复设备在线状态监测失败，%s", err)
+		return
+	}
+	for _, d := range devices {
+		if d.Offline != 1 {
+			continue
+		}
+		if !m.watch(d) {
+			logger.Warnf("{%s}服务停止期间设备已超时", d.DeviceId)
+			_ = storage.deviceOffline(d, reasonTimeout)
+		}
+	}
+}
+
+// 按最近一次注册和心跳的时间开始监测，已经超时返回false
+func (m *deviceMonitor) watch(d model.Device) bool {
+	now := time.Now()
+	last := d.Keepalive
+	if last.Before(d.RegisterTime) {
+		last = d.RegisterTime
+	}
+	keepaliveLeft := keepaliveTimeout(d) - now.Sub(last)
+	if keepaliveLeft <= 0 {
+		return false
+	}
+	expires := registerExpires(d)
+	registerLeft := expires - now.Sub(d.RegisterTime)
+	if expires > 0 && registerLeft <= 0 {
+		return false
+	}
+
+	deviceId := d.DeviceId
+	m.wheel.Add(keepaliveKey(deviceId), keepaliveLeft, func() {
+		go m.expire(deviceId, reasonTimeout)
+	})
+	if expires > 0 {
+		m.wheel.Add(registerKey(deviceId), registerLeft, func() {
+			go m.expire(deviceId, reasonExpired)
+		})
+	} else {
+		m.wheel.Remove(registerKey(deviceId))
+	}
+	return true
+}
+
+// 收到心跳，重新计算心跳超时
+func (m *deviceMonitor) keepalive(d model.Device) {
+	deviceId := d.DeviceId
+	m.wheel.Add(keepaliveKey(deviceId), keepaliveTimeout(d), func() {
+		go m.expire(deviceId, reasonTimeout)
+	})
+}
+
+// 停止监测
+func (m *deviceMonitor) unwatch(deviceId string) {
+	m.wheel.Remove(keepaliveKey(deviceId))
+	m.wheel.Remove(registerKey(deviceId))
+}
+
+func (m *deviceMonitor) expire(deviceId, reason string) {
+	device, ok := storage.getDeviceById(deviceId)
+	if !ok || device.Offline != 1 {
+		m.unwatch(deviceId)
+		return
+	}
+	logger.Warnf("{%s}设备离线，原因：%s，上次心跳时间：%s", deviceId, reason, device.Keepalive.Format(time.RFC3339))
+	_ = storage.deviceOffline(device, reason)
+}
+
+// 查询设备的心跳参数，应答由deviceConfigQueryHandler保存，下次心跳时按新的参数计算超时
+func queryHeartBeatConfig(d model.Device) {
+	task, err := gbsip.DeviceBasicConfigQuery(d)
+	if err != nil {
+		logger.Errorf("{%s}查询设备心跳参数失败，%s", d.DeviceId, err)
+		return
+	}
+	task.Cancel()
+}
@@ -2,17 +2,21 @@ package gb
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
-	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/ghettovoice/gosip"
 	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/pkg/gbid"
 	"github.com/inysc/GB28181/internal/pkg/gbsip"
 	"github.com/inysc/GB28181/internal/pkg/logger"
 	"github.com/inysc/GB28181/internal/pkg/model"
 	"github.com/inysc/GB28181/internal/pkg/parser"
 	"github.com/inysc/GB28181/internal/pkg/syn"
+	"github.com/pkg/errors"
 	"golang.org/x/text/encoding/simplifiedchinese"
 	"golang.org/x/text/transform"
 )
@@ -23,6 +27,13 @@ var (
 		"Notify:Keepalive":      keepaliveNotifyHandler,
 		"Notify:Alarm":          alarmNotifyHandler,
 		"Notify:MobilePosition": mobilePositionNotifyHandler,
+		"Notify:MediaStatus":    mediaStatusNotifyHandler,
+		"Notify:Catalog":        catalogNotifyHandler,
+
+		// 上级平台的查询请求
+		"Query:Catalog":      platformCatalogQueryHandler,
+		"Query:DeviceInfo":   platformDeviceInfoQueryHandler,
+		"Query:DeviceStatus": platformDeviceStatusQueryHandler,
 
 		// 响应
 		// 查询设备信息响应
@@ -31,6 +42,9 @@ var (
 		// 设备配置请求应答
 		"Response:DeviceConfig": deviceConfigResponseHandler,
 
+		// 设备控制请求应答
+		"Response:DeviceControl": deviceConfigResponseHandler,
+
 		// 查询设备目录信息响应
 		"Response:Catalog": catalogHandler,
 
@@ -40,11 +54,20 @@ var (
 		// 查询设备配置信息响应
 		"Response:ConfigDownload": deviceConfigQueryHandler,
 
+		// 预置位查询响应
+		"Response:PresetQuery": presetQueryHandler,
+
+		// 录像文件检索响应
+		"Response:RecordInfo": recordInfoHandler,
+
 		// 发起报警订阅信息响应
 		"Response:Alarm": subscribeAlarmResponseHandler,
 
 		// 发起设备移动位置订阅响应
 		"Response:MobilePosition": subscribeMobilePositionResponseHandler,
+
+		// 语音广播通知响应
+		"Response:Broadcast": broadcastResponseHandler,
 	}
 )
 
@@ -63,14 +86,61 @@ func MessageHandler(req sip.Request, tx sip.ServerTransaction) {
 	}
 	handler, ok := messageHandler[cmdType]
 	if !ok {
+		if strings.HasPrefix(cmdType, "Response:") {
+			unhandledResponse(req, tx)
+			return
+		}
 		logger.Warn("不支持的Message方法实现")
 		return
 	}
 	handler(req, tx)
 }
 
+// 没有专门处理的应答按SN交给等待的请求，请求方自行解析原始消息体
+func unhandledResponse(req sip.Request, tx sip.ServerTransaction) {
+	_ = responseAck(tx, req)
+	m := gbsip.Mata{}
+	if err := parser.XmlStringDecode(req.Body(), &m); err != nil {
+		logger.Error(err)
+		return
+	}
+	if !syn.Deliver(m.CmdType.CmdType, m.SN.SN, req.Body()) {
+		logger.Warnf("{%s}不支持的应答%s", m.DeviceID.DeviceID, m.CmdType.CmdType)
+	}
+}
+
+// NotifyHandler 处理设备在订阅会话中发送的NOTIFY请求，消息体与MESSAGE相同，按CmdType分发
+func NotifyHandler(req sip.Request, tx sip.ServerTransaction) {
+	logger.Debugf("收到NOTIFY请求\n%s", printRequest(req))
+	if h := req.GetHeaders(SubscriptionStateHeader); len(h) > 0 &&
+		strings.HasPrefix(strings.ToLower(h[0].Value()), subscriptionTerminated) {
+		if callId, ok := req.CallID(); ok {
+			gbsip.SubscriptionTerminated(callId.Value())
+		}
+	}
+
+	cmdType, err := parser.GetCmdTypeFromXML(req.Body())
+	if err != nil {
+		_ = responseAck(tx, req)
+		return
+	}
+	handler, ok := messageHandler[cmdType]
+	if !ok {
+		logger.Warnf("不支持的Notify方法实现：%s", cmdType)
+		_ = responseAck(tx, req)
+		return
+	}
+	handler(req, tx)
+}
+
 const (
 	resultOK = "OK"
+
+	// 等待设备信息查询应答的时间
+	deviceInfoTimeout = 5 * time.Second
+
+	SubscriptionStateHeader = "Subscription-State"
+	subscriptionTerminated  = "terminated"
 )
 
 type (
@@ -98,34 +168,60 @@ type deviceInfo struct {
 	Firmware     string `xml:"Firmware"`
 }
 
+// 设备信息查询应答，按SN交给注册后发起查询的任务，由查询方更新设备信息
 func deviceInfoHandler(req sip.Request, tx sip.ServerTransaction) {
-	d := &deviceInfo{}
-	if err := parser.XmlStringDecode(req.Body(), d); err != nil {
+	defer func() {
+		_ = responseAck(tx, req)
+	}()
+
+	d := deviceInfo{}
+	if err := parser.XmlStringDecode(req.Body(), &d); err != nil {
 		logger.Error("解析deviceInfo响应包出错", err)
 		return
 	}
 
+	var delivered bool
 	if d.Result != resultOK {
-		logger.Errorf("查询设备信息请求结果为%s，请检查", d.Result)
-		return
+		delivered = syn.Fail(d.CmdType, d.SN, errors.Errorf("查询设备信息请求结果为%s", d.Result))
+	} else {
+		delivered = syn.Deliver(d.CmdType, d.SN, d)
+	}
+	if !delivered {
+		logger.Warnf("{%s}设备信息应答%s没有等待的请求，已丢弃", d.DeviceID, d.SN)
+	}
+}
+
+// 注册后查询设备信息并同步设备目录
+func syncDeviceInfo(device model.Device) {
+	if err := queryDeviceInfo(device); err != nil {
+		logger.Errorf("{%s}%s", device.DeviceId, err)
+	}
+	if _, err := gbsip.SyncCatalog(device); err != nil {
+		logger.Errorf("{%s}设备目录同步失败，%s", device.DeviceId, err)
+	}
+}
+
+func queryDeviceInfo(device model.Device) error {
+	task, err := gbsip.DeviceInfoQuery(device)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deviceInfoTimeout)
+	defer cancel()
+	data, err := task.Wait(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "接收设备信息应答失败")
 	}
 
+	d := data.(deviceInfo)
 	dev := model.Device{
 		Name:         d.DeviceName,
 		Manufacturer: d.Manufacturer,
 		Model:        d.Model,
 		Firmware:     d.Firmware,
-		DeviceId:     d.DeviceID,
-	}
-
-	if err := storage.updateDeviceInfo(dev); err != nil {
-		logger.Error("更新设备信息失败", err)
-		return
-	}
-	err := responseAck(tx, req)
-	if err != nil {
-		return
+		DeviceId:     device.DeviceId,
 	}
+	return errors.WithMessage(storage.updateDeviceInfo(dev), "更新设备信息失败")
 }
 
 type DeviceCatalogResponse struct {
@@ -133,7 +229,7 @@ type DeviceCatalogResponse struct {
 	CmdType
 	SN
 	DeviceID
-	SunNum        string     `xml:"SunNum"`
+	SumNum        int        `xml:"SumNum"`
 	DeviceListNum string     `xml:"Num,attr"`
 	DeviceList    DeviceList `xml:"DeviceList"`
 }
@@ -158,10 +254,15 @@ type CatalogItem struct {
 	RegisterWay  string `xml:"RegisterWay"`
 	Secrecy      string `xml:"Secrecy"`
 	Status       string `xml:"Status"`
+	// 目录变化通知中的事件类型，目录查询应答中不携带
+	Event string `xml:"Event"`
 }
 
-func (i CatalogItem) ConvertToChannel() model.Channel {
+// ConvertToChannel 转换为上报目录的设备下的通道，目录项没有父节点时挂在该设备下
+func (i CatalogItem) ConvertToChannel(deviceId string) model.Channel {
 	c := model.NewChannelMust(i.DeviceID.DeviceID)
+	c.OwnerId = deviceId
+	c.TypeCode = gbid.TypeCode(i.DeviceID.DeviceID)
 	c.Name = i.Name
 	c.Manufacturer = i.Manufacturer
 	c.Model = i.Model
@@ -170,6 +271,9 @@ func (i CatalogItem) ConvertToChannel() model.Channel {
 	c.Address = i.Address
 	c.Parental = i.Parental
 	c.ParentID = i.ParentID
+	if c.ParentID == "" {
+		c.ParentID = deviceId
+	}
 	c.SafetyWay = i.SafetyWay
 	c.RegisterWay = i.RegisterWay
 	c.Secrecy = i.Secrecy
@@ -177,6 +281,11 @@ func (i CatalogItem) ConvertToChannel() model.Channel {
 	return c
 }
 
+// 目录项的id应为统一编码，行政区划节点为行政区划代码
+func (i CatalogItem) valid() bool {
+	return gbid.IsValid(i.DeviceID.DeviceID) || gbid.IsCivilCode(i.DeviceID.DeviceID)
+}
+
 func gbkToUtf8(s []byte) ([]byte, error) {
 	reader := transform.NewReader(bytes.NewReader(s), simplifiedchinese.GBK.NewDecoder())
 	buffer := bytes.Buffer{}
@@ -187,6 +296,9 @@ func gbkToUtf8(s []byte) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+// 目录查询应答，不经过SN任务关联而是交给目录聚合：目录同步由注册或接口触发后立即返回，
+// 进度通过同步状态查询，分包的合并、去重和超时都以设备id和SN为key在聚合中完成，
+// 部分设备注册后也会不经查询主动上报目录，这些分包同样需要保存
 func catalogHandler(req sip.Request, tx sip.ServerTransaction) {
 	defer func() {
 		_ = responseAck(tx, req)
@@ -210,8 +322,29 @@ func catalogHandler(req sip.Request, tx sip.ServerTransaction) {
 			return
 		}
 	}
-	// save catalog object to database
-	storage.syncChannel(catalog)
+	catalogs.add(catalog)
+}
+
+// 目录订阅后设备发送的目录变化通知
+func catalogNotifyHandler(req sip.Request, tx sip.ServerTransaction) {
+	defer func() {
+		_ = responseAck(tx, req)
+	}()
+
+	catalog := DeviceCatalogResponse{}
+	if err := parser.XmlStringDecode(req.Body(), &catalog); err != nil {
+		b, err := gbkToUtf8([]byte(req.Body()))
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		if err = parser.XmlStringDecode(string(b), &catalog); err != nil {
+			logger.Error("解析目录变化通知出错", err)
+			return
+		}
+	}
+	logger.Infof("{%s}收到目录变化通知，共%d项", catalog.DeviceID.DeviceID, len(catalog.DeviceList.Items))
+	storage.applyCatalogChanges(catalog)
 }
 
 func deviceStatusHandler(req sip.Request, tx sip.ServerTransaction) {
@@ -224,9 +357,7 @@ func deviceStatusHandler(req sip.Request, tx sip.ServerTransaction) {
 		logger.Error(err)
 		return
 	}
-	syn.HasSyncTask(fmt.Sprintf("%s_%s", syn.KeyQueryDeviceStatus, status.DeviceID.DeviceID), func(e *syn.Entity) {
-		e.Ok(*status)
-	})
+	syn.Deliver(status.CmdType.CmdType, status.SN.SN, *status)
 }
 
 func printRequest(req sip.Request) string {
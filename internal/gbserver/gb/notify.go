@@ -8,9 +8,9 @@ import (
 	"time"
 
 	"github.com/ghettovoice/gosip/sip"
-	"github.com/inysc/GB28181/internal/pkg/cron"
+	"github.com/inysc/GB28181/internal/gbserver/service"
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
 	"github.com/inysc/GB28181/internal/pkg/logger"
-	"github.com/inysc/GB28181/internal/pkg/model"
 	"github.com/inysc/GB28181/internal/pkg/parser"
 	"golang.org/x/text/encoding/simplifiedchinese"
 	"golang.org/x/text/transform"
@@ -54,18 +54,14 @@ func keepaliveNotifyHandler(req sip.Request, tx sip.ServerTransaction) {
 	if err := storage.deviceKeepalive(device.ID); err != nil {
 		logger.Debugf("{%d,%s}更新心跳失败：%v", device.ID, device.DeviceId, err.Error())
 	}
-	err := cron.ResetTime(device.DeviceId, cron.TaskKeepLive)
-	switch err {
-	case nil:
-	case cron.ErrNotFoud:
-		err = cron.StartTask(device.DeviceId, cron.TaskKeepLive, 10*time.Second, func() {
-			storage.s.Devices().Update(model.Device{DeviceId: device.DeviceId, Offline: 0})
-		})
-		if err != nil {
-			logger.Errorf("启动定时任务失败：%s", err)
-		}
+	device.Keepalive = time.Now()
+	switch {
+	case device.Offline == 1:
+		monitor.keepalive(device)
+	case registerValid(device):
+		_ = storage.deviceRecover(device)
 	default:
-		logger.Errorf("{%d,%s}更新心跳失败：%v", device.ID, device.DeviceId, err.Error())
+		logger.Warnf("{%s}设备注册已过期，等待设备重新注册", device.DeviceId)
 	}
 
 	resp := sip.NewResponseFromRequest("", req, http.StatusOK, http.StatusText(http.StatusOK), "")
@@ -74,16 +70,101 @@ func keepaliveNotifyHandler(req sip.Request, tx sip.ServerTransaction) {
 }
 
 func alarmNotifyHandler(req sip.Request, tx sip.ServerTransaction) {
-	// 使用 gbsip.AlarmNotify 结构体解析
-	// 自行扩展
+	defer func() {
+		_ = responseAck(tx, req)
+	}()
 
-	_ = responseAck(tx, req)
+	notify := gbsip.AlarmNotify{}
+	if err := parser.XmlStringDecode(req.Body(), &notify); err != nil {
+		// 报警描述中可能包含GBK编码的中文
+		b, err := gbkToUtf8([]byte(req.Body()))
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		if err = parser.XmlStringDecode(string(b), &notify); err != nil {
+			logger.Error("解析报警通知出错", err)
+			return
+		}
+	}
+
+	device, ok := parser.DeviceFromRequest(req)
+	if !ok {
+		return
+	}
+	logger.Infof("{%s}收到报警通知：%+v", device.DeviceId, notify)
+	if err := storage.saveAlarm(device.DeviceId, notify); err != nil {
+		logger.Errorf("{%s}保存报警信息失败：%v", device.DeviceId, err)
+	}
 }
 
 func mobilePositionNotifyHandler(req sip.Request, tx sip.ServerTransaction) {
-	// 自行扩展
+	defer func() {
+		_ = responseAck(tx, req)
+	}()
+
+	notify := gbsip.MobilePositionNotify{}
+	if err := parser.XmlStringDecode(req.Body(), &notify); err != nil {
+		logger.Error("解析移动设备位置通知出错", err)
+		return
+	}
 
+	device, ok := parser.DeviceFromRequest(req)
+	if !ok {
+		return
+	}
+	if err := storage.saveMobilePosition(device.DeviceId, notify); err != nil {
+		logger.Errorf("{%s}保存移动设备位置失败：%v", device.DeviceId, err)
+	}
+}
+
+// 媒体通知类型，121表示历史媒体文件发送结束
+const notifyTypeFileEnd = "121"
+
+type mediaStatus struct {
+	CmdType    string `xml:"CmdType"`
+	SN         int    `xml:"SN"`
+	DeviceID   string `xml:"DeviceID"`
+	NotifyType string `xml:"NotifyType"`
+}
+
+// 设备在回放或下载的录像发送完毕后会在会话内发送媒体通知，收到后需要主动结束会话
+func mediaStatusNotifyHandler(req sip.Request, tx sip.ServerTransaction) {
 	_ = responseAck(tx, req)
+
+	status := &mediaStatus{}
+	if err := parser.XmlStringDecode(req.Body(), status); err != nil {
+		logger.Error("解析媒体通知出错", err)
+		return
+	}
+	if status.NotifyType != notifyTypeFileEnd {
+		logger.Warnf("{%s}不支持的媒体通知类型：%s", status.DeviceID, status.NotifyType)
+		return
+	}
+
+	callId, ok := req.CallID()
+	if !ok {
+		logger.Error("媒体通知中没有Call-ID")
+		return
+	}
+	streamId, err := gbsip.StreamIdByCallId(callId.Value())
+	if err != nil {
+		logger.Errorf("{%s}找不到媒体通知对应的会话：%v", status.DeviceID, err)
+		return
+	}
+	if _, err := gbsip.GetDownloadInfo(streamId); err == nil {
+		if err := gbsip.FinishDownload(streamId); err != nil {
+			logger.Errorf("{%s}更新下载状态失败：%v", streamId, err)
+		}
+	}
+
+	logger.Infof("{%s}录像文件发送结束，结束会话", streamId)
+	// 发送BYE需要等待设备响应，不能阻塞当前请求的处理
+	go func() {
+		if err := service.Play().StopStream(streamId); err != nil {
+			logger.Errorf("%+v", err)
+		}
+	}()
 }
 
 func subscribeAlarmResponseHandler(req sip.Request, tx sip.ServerTransaction) {
@@ -0,0 +1,33 @@
+package gb
+
+import (
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/inysc/GB28181/internal/pkg/syn"
+)
+
+// 录像检索结果，设备录像文件较多时会按SN分多个包返回，每个分包都交给等待的请求合并
+func recordInfoHandler(req sip.Request, tx sip.ServerTransaction) {
+	defer func() {
+		_ = responseAck(tx, req)
+	}()
+
+	info := gbsip.RecordInfo{}
+	if err := parser.XmlStringDecode(req.Body(), &info); err != nil {
+		b, err := gbkToUtf8([]byte(req.Body()))
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		if err = parser.XmlStringDecode(string(b), &info); err != nil {
+			logger.Error(err)
+			return
+		}
+	}
+
+	if !syn.Deliver(info.CmdType.CmdType, info.SN.SN, info) {
+		logger.Warnf("{%s}录像检索结果%s没有等待的请求，已丢弃", info.DeviceID.DeviceID, info.SN.SN)
+	}
+}
@@ -1,12 +1,10 @@
 package gb
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/ghettovoice/gosip/sip"
-	"github.com/inysc/GB28181/internal/pkg/cron"
-	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/gbid"
 	"github.com/inysc/GB28181/internal/pkg/logger"
 	"github.com/inysc/GB28181/internal/pkg/parser"
 )
@@ -19,68 +17,81 @@ const (
 
 func RegisterHandler(req sip.Request, tx sip.ServerTransaction) {
 	logger.Debugf("收到register请求\n%s", printRequest(req))
-	// 判断是否存在 Authorization 字段
-	if headers := req.GetHeaders("Authorization"); len(headers) > 0 {
-		// 存在 Authorization 头部字段
-		//authHeader := headers[0].(*SipOption.GenericHeader)
-		fromRequest, ok := parser.DeviceFromRequest(req)
-		if !ok {
-			return
-		}
-		offlineFlag := false
-		device, ok := storage.getDeviceById(fromRequest.DeviceId)
+	fromRequest, ok := parser.DeviceFromRequest(req)
+	if !ok {
+		return
+	}
+	if err := gbid.ValidateRegister(fromRequest.DeviceId); err != nil {
+		resp := sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), "")
+		logger.Warnf("设备编码不合法，拒绝注册，%s", err)
+		_ = tx.Respond(resp)
+		return
+	}
+	offlineFlag := false
+	device, ok := storage.getDeviceById(fromRequest.DeviceId)
 
-		if !ok {
-			logger.Debug("not found from device from database")
-			device = fromRequest
-		}
+	if !ok {
+		logger.Debug("not found from device from database")
+		device = fromRequest
+	}
 
-		h := req.GetHeaders(ExpiresHeader)
-		if len(h) != 1 {
-			logger.Error("not found expires header from request", req)
-			return
-		}
-		expires := h[0].(*sip.Expires)
-		// 如果v=0，则代表该请求是注销请求
-		if expires.Equals(new(sip.Expires)) {
-			logger.Debug("expires值为0,该请求是注销请求")
-			offlineFlag = true
-		}
-		device.Expires = expires.Value()
-		logger.Infof("设备信息:  %+v\n")
-		// 发送OK信息
-		resp := sip.NewResponseFromRequest("", req, http.StatusOK, "ok", "")
-		logger.Debugf("发送OK信息\n%s", resp)
+	var authorization string
+	if headers := req.GetHeaders(AuthorizationHeader); len(headers) > 0 {
+		authorization = headers[0].Value()
+	}
+	switch auth.verify(device, string(req.Method()), req.Recipient(), authorization) {
+	case authChallenge:
+		responseChallenge(req, tx, false)
+		return
+	case authStale:
+		responseChallenge(req, tx, true)
+		return
+	case authForbidden:
+		resp := sip.NewResponseFromRequest("", req, http.StatusForbidden, http.StatusText(http.StatusForbidden), "")
+		logger.Warnf("设备%s认证失败，密码错误\n%s", device.DeviceId, resp)
 		_ = tx.Respond(resp)
+		return
+	}
 
-		if offlineFlag {
-			// 注销请求
-			_ = storage.deviceOffline(device)
-			if err := cron.StopTask(device.DeviceId, cron.TaskKeepLive); err != nil {
-				logger.Errorf("停止心跳检测任务失败: %s", device.DeviceId)
-			}
-		} else {
-			// 注册请求
-			if err := storage.deviceOnline(device); err != nil {
-				logger.Errorf("设备上线失败请检查,%s", err)
-			}
-			go gbsip.DeviceInfoQuery(device)
-		}
+	h := req.GetHeaders(ExpiresHeader)
+	if len(h) != 1 {
+		logger.Error("not found expires header from request", req)
 		return
 	}
+	expires := h[0].(*sip.Expires)
+	// 如果v=0，则代表该请求是注销请求
+	if expires.Equals(new(sip.Expires)) {
+		logger.Debug("expires值为0,该请求是注销请求")
+		offlineFlag = true
+	}
+	device.Expires = expires.Value()
+	logger.Infof("设备信息:  %+v\n", device)
+	// 发送OK信息
+	resp := sip.NewResponseFromRequest("", req, http.StatusOK, "ok", "")
+	logger.Debugf("发送OK信息\n%s", resp)
+	_ = tx.Respond(resp)
+
+	if offlineFlag {
+		// 注销请求
+		_ = storage.deviceOffline(device, reasonUnregister)
+	} else {
+		// 注册请求
+		if err := storage.deviceOnline(device); err != nil {
+			logger.Errorf("设备上线失败请检查,%s", err)
+		}
+		go syncDeviceInfo(device)
+		go queryHeartBeatConfig(device)
+	}
+}
 
-	// 没有存在 Authorization 头部字段
+// 返回401，并添加 WWW-Authenticate 头
+func responseChallenge(req sip.Request, tx sip.ServerTransaction, stale bool) {
 	resp := sip.NewResponseFromRequest("", req, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), "")
-	// 添加 WWW-Authenticate 头
 	wwwHeader := &sip.GenericHeader{
 		HeaderName: WWWHeader,
-		Contents: fmt.Sprintf("Digest nonce=\"%s\", algorithm=%s, realm=\"%s\", qop=\"auth\"",
-			"44010200491118000001",
-			DefaultAlgorithm,
-			randString(32),
-		),
+		Contents:   auth.challenge(stale).String(),
 	}
 	resp.AppendHeader(wwwHeader)
-	logger.Debugf("没有Authorization头部信息，生成WWW-Authenticate头部返回：\n%s", resp)
+	logger.Debugf("没有通过认证，生成WWW-Authenticate头部返回：\n%s", resp)
 	_ = tx.Respond(resp)
 }
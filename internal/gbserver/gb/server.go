@@ -2,7 +2,7 @@ package gb
 
 import (
 	"github.com/ghettovoice/gosip/sip"
-	"github.com/inysc/GB28181/internal/gbserver/storage/mysql"
+	st "github.com/inysc/GB28181/internal/gbserver/storage"
 	"github.com/inysc/GB28181/internal/pkg/gbsip"
 	"github.com/inysc/GB28181/internal/pkg/logger"
 	"github.com/inysc/GB28181/internal/pkg/option"
@@ -13,20 +13,22 @@ type Server struct {
 }
 
 type SipConfig struct {
-	SipOption   *option.SIPOptions
-	MysqlOption *option.MySQLOptions
+	SipOption *option.SIPOptions
+	Store     st.Factory
 }
 
 func NewServer(c *SipConfig) *Server {
 	s := &Server{
 		gbsip.NewServer(
 			&gbsip.SipConfig{
-				SipOption:   c.SipOption,
-				MysqlOption: c.MysqlOption,
-				HandlerMap:  createHandlerMap(),
+				SipOption:  c.SipOption,
+				HandlerMap: createHandlerMap(),
 			}),
 	}
-	storage.s = mysql.GetMySQLFactory()
+	storage.s = c.Store
+	auth = newDigestAuth(c.SipOption)
+	// 设备在线状态监测，从存储中恢复在线设备
+	monitor.start()
 	return s
 }
 
@@ -38,7 +40,22 @@ func (s *Server) ListenUDP() error {
 	return s.server.ListenUDP()
 }
 
+// Resubscribe 服务重启后重新建立在线设备的订阅，开始监听后才能调用
+func (s *Server) Resubscribe() {
+	devices, err := storage.s.Devices().List()
+	if err != nil {
+		logger.Errorf("恢复设备订阅失败，%s", err)
+		return
+	}
+	for _, d := range devices {
+		if d.Offline == 1 {
+			go gbsip.Resubscribe(d)
+		}
+	}
+}
+
 func (s *Server) Close() error {
+	monitor.stop()
 	_ = s.server.Shutdown()
 	logger.Info("gb server shutdown...")
 	return nil
@@ -48,5 +65,9 @@ func createHandlerMap() gbsip.RequestHandlerMap {
 	m := make(map[sip.RequestMethod]func(req sip.Request, tx sip.ServerTransaction))
 	m[sip.REGISTER] = RegisterHandler
 	m[sip.MESSAGE] = MessageHandler
+	m[sip.BYE] = ByeHandler
+	m[sip.NOTIFY] = NotifyHandler
+	m[sip.INVITE] = InviteHandler
+	m[sip.ACK] = AckHandler
 	return m
 }
@@ -1,13 +1,15 @@
 package gb
 
 import (
+	"strings"
 	"time"
 
 	st "github.com/inysc/GB28181/internal/gbserver/storage"
-	"github.com/inysc/GB28181/internal/pkg/cron"
+	"github.com/inysc/GB28181/internal/pkg/event"
 	"github.com/inysc/GB28181/internal/pkg/gbsip"
 	"github.com/inysc/GB28181/internal/pkg/logger"
 	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/spf13/cast"
 )
 
 type data struct {
@@ -16,42 +18,68 @@ type data struct {
 
 var storage = new(data)
 
-// 设备离线
-func (d *data) deviceOffline(device model.Device) error {
-	logger.Infof("%s设备离线,设备信息：%+v", device.DeviceId, device)
+// 设备离线，停止在线状态监测
+func (d *data) deviceOffline(device model.Device, reason string) error {
+	monitor.unwatch(device.DeviceId)
+	logger.Infof("%s设备离线,原因：%s,设备信息：%+v", device.DeviceId, reason, device)
+	online := device.Offline == 1
 	device.Offline = 0
 	err := d.s.Devices().Update(device)
 	if err != nil {
 		logger.Errorf("设备离线发生错误，请检查。%s", err)
 		return err
 	}
+	if online {
+		event.Publish(event.DeviceOffline, device.DeviceId, event.DeviceStatus{DeviceId: device.DeviceId, Reason: reason})
+	}
 	return nil
 }
 
-// 设备上线
+// 设备注册上线，注册刷新时更新注册时间并重新计算注册有效期
 func (d *data) deviceOnline(device model.Device) error {
 	var err error
+	online := device.Offline == 1
+	now := time.Now()
 	if device.RegisterTime.Equal(time.Time{}) {
 		logger.Infof("%s设备第一次注册，发送设备查询请求", device.DeviceId)
-		device.RegisterTime = time.Now()
-		device.Keepalive = time.Now()
+		device.RegisterTime = now
+		device.Keepalive = now
 		device.Offline = 1
 		err = d.s.Devices().Save(device)
 	} else {
-		logger.Infof("%s设备离线状态下重新上线，", device.DeviceId)
+		if !online {
+			logger.Infof("%s设备离线状态下重新上线，", device.DeviceId)
+		}
+		device.RegisterTime = now
 		device.Offline = 1
 		err = d.s.Devices().Update(device)
 	}
 	if err != nil {
 		logger.Errorf("设备上线发生错误，请检查。%s", err)
+		return err
 	}
 
-	err = cron.StartTask(device.DeviceId, cron.TaskKeepLive, 10*time.Second, func() {
-		device.Offline = 0
-		d.s.Devices().Save(device)
-	})
+	monitor.watch(device)
+	if !online {
+		event.Publish(event.DeviceOnline, device.DeviceId, event.DeviceStatus{DeviceId: device.DeviceId, Reason: reasonRegister})
+		// 设备离线期间订阅会话已经失效，需要重新建立订阅
+		go gbsip.Resubscribe(device)
+	}
+	return nil
+}
 
-	return err
+// 心跳超时离线的设备在注册有效期内恢复心跳，重新上线
+func (d *data) deviceRecover(device model.Device) error {
+	device.Offline = 1
+	if err := d.s.Devices().Update(device); err != nil {
+		logger.Errorf("设备上线发生错误，请检查。%s", err)
+		return err
+	}
+	logger.Infof("%s设备恢复心跳，重新上线", device.DeviceId)
+	monitor.watch(device)
+	event.Publish(event.DeviceOnline, device.DeviceId, event.DeviceStatus{DeviceId: device.DeviceId, Reason: reasonKeepalive})
+	go gbsip.Resubscribe(device)
+	return nil
 }
 
 func (d *data) deviceKeepalive(deviceId uint) error {
@@ -78,11 +106,146 @@ func (d *data) updateDeviceBasicConfig(device gbsip.DeviceBasicConfigResp) error
 	return d.s.Devices().UpdateBasicConfig(dev)
 }
 
-func (d *data) syncChannel(c DeviceCatalogResponse) {
-	var channels []model.Channel
-	for _, item := range c.DeviceList.Items {
-		c := item.ConvertToChannel()
+// 以完整目录为准同步设备的通道，并记录新增和删除的通道
+func (d *data) syncChannel(deviceId string, items []CatalogItem) error {
+	exists, err := d.s.Channel().List(deviceId)
+	if err != nil {
+		return err
+	}
+	old := make(map[string]model.Channel, len(exists))
+	for _, e := range exists {
+		old[e.DeviceId] = e
+	}
+
+	now := time.Now()
+	var (
+		channels []model.Channel
+		changes  []model.ChannelChange
+	)
+	for _, item := range items {
+		c := item.ConvertToChannel(deviceId)
 		channels = append(channels, c)
+		if _, ok := old[c.DeviceId]; ok {
+			delete(old, c.DeviceId)
+			continue
+		}
+		changes = append(changes, model.ChannelChange{DeviceId: deviceId, ChannelId: c.DeviceId, Name: c.Name, Event: model.CatalogEventAdd, Time: now})
+	}
+	for _, e := range old {
+		changes = append(changes, model.ChannelChange{DeviceId: deviceId, ChannelId: e.DeviceId, Name: e.Name, Event: model.CatalogEventDel, Time: now})
+	}
+
+	if err = d.s.Channel().SaveBatch(channels, deviceId); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		d.saveChannelChange(change)
+	}
+	return nil
+}
+
+// 记录通道变更并发布通道变化事件
+func (d *data) saveChannelChange(change model.ChannelChange) {
+	if err := d.s.Channel().SaveChange(change); err != nil {
+		logger.Errorf("{%s}保存通道变更记录失败，%s", change.DeviceId, err)
+	}
+	event.Publish(event.ChannelChange, change.DeviceId, change)
+}
+
+// 保存未接收完整的目录，只新增或更新已收到的通道，不删除通道
+func (d *data) saveChannels(deviceId string, items []CatalogItem) {
+	for _, item := range items {
+		c := item.ConvertToChannel(deviceId)
+		if err := d.s.Channel().Save(c); err != nil {
+			logger.Errorf("{%s}保存通道%s失败，%s", deviceId, c.DeviceId, err)
+		}
+	}
+}
+
+// 逐项应用目录变化通知，并记录通道变更，通道按发送通知的设备查找，目录项的父节点可能是分组或组织
+func (d *data) applyCatalogChanges(c DeviceCatalogResponse) {
+	deviceId := c.DeviceID.DeviceID
+	now := time.Now()
+	for _, item := range c.DeviceList.Items {
+		if !item.valid() {
+			logger.Warnf("{%s}目录项编码不合法，已忽略：%s", deviceId, item.DeviceID.DeviceID)
+			continue
+		}
+		channel := item.ConvertToChannel(deviceId)
+		// 未携带事件的通知按新增或更新处理
+		event := strings.ToUpper(item.Event)
+		if event == "" {
+			event = model.CatalogEventUpdate
+		}
+
+		var err error
+		switch event {
+		case model.CatalogEventAdd, model.CatalogEventUpdate:
+			err = d.s.Channel().Save(channel)
+		case model.CatalogEventDel:
+			err = d.s.Channel().Delete(deviceId, channel.DeviceId)
+		case model.CatalogEventOn, model.CatalogEventOff:
+			err = d.s.Channel().UpdateStatus(deviceId, channel.DeviceId, event)
+		case model.CatalogEventVLost, model.CatalogEventDefect:
+			// 视频丢失和故障只记录变更，不修改通道信息
+		default:
+			logger.Warnf("{%s}不支持的目录变化事件：%s", deviceId, item.Event)
+			continue
+		}
+		if err != nil {
+			logger.Errorf("{%s}应用通道%s的%s事件失败，%s", deviceId, channel.DeviceId, event, err)
+			continue
+		}
+
+		change := model.ChannelChange{
+			DeviceId:  deviceId,
+			ChannelId: channel.DeviceId,
+			Name:      channel.Name,
+			Event:     event,
+			Time:      now,
+		}
+		d.saveChannelChange(change)
+	}
+}
+
+func (d *data) saveAlarm(deviceId string, n gbsip.AlarmNotify) error {
+	alarmTime, err := time.ParseInLocation(model.GBTimeLayout, n.AlarmTime, time.Local)
+	if err != nil {
+		alarmTime = time.Now()
+	}
+	alarm := model.Alarm{
+		DeviceId:      deviceId,
+		ChannelId:     n.DeviceID.DeviceID,
+		AlarmPriority: cast.ToInt(n.AlarmPriority),
+		AlarmMethod:   cast.ToInt(n.AlarmMethod),
+		AlarmType:     cast.ToInt(n.Info.AlarmType),
+		EventType:     cast.ToInt(n.Info.AlarmTypeParam.EventType),
+		AlarmTime:     alarmTime,
+		Description:   n.AlarmDescription,
+		Longitude:     cast.ToFloat64(n.Longitude),
+		Latitude:      cast.ToFloat64(n.Latitude),
+	}
+	if err = d.s.Alarm().Save(alarm); err != nil {
+		return err
+	}
+	event.Publish(event.Alarm, deviceId, alarm)
+	return nil
+}
+
+func (d *data) saveMobilePosition(deviceId string, n gbsip.MobilePositionNotify) error {
+	t, err := time.ParseInLocation(model.GBTimeLayout, n.Time, time.Local)
+	if err != nil {
+		t = time.Now()
+	}
+	position := model.MobilePosition{
+		DeviceId:  deviceId,
+		ChannelId: n.DeviceID.DeviceID,
+		Time:      t,
+		Longitude: cast.ToFloat64(n.Longitude),
+		Latitude:  cast.ToFloat64(n.Latitude),
+		Speed:     cast.ToFloat64(n.Speed),
+		Direction: cast.ToFloat64(n.Direction),
+		Altitude:  cast.ToFloat64(n.Altitude),
 	}
-	_ = d.s.Channel().SaveBatch(channels, c.DeviceID.DeviceID)
+	return d.s.Position().Save(position)
 }
@@ -0,0 +1,55 @@
+package gb
+
+import (
+	"testing"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage/sqlite"
+	"github.com/inysc/GB28181/internal/gbserver/storage/sqlstore"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/option"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func setupStorage(t *testing.T) {
+	db, err := sqlite.New(&option.SQLiteOptions{Path: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sqlstore.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	storage.s = sqlstore.New(db)
+}
+
+func TestApplyCatalogChanges(t *testing.T) {
+	convey.Convey("TestApplyCatalogChanges", t, func() {
+		setupStorage(t)
+
+		notify := func(event string, items ...CatalogItem) {
+			var c DeviceCatalogResponse
+			c.DeviceID.DeviceID = "44010200491320000001"
+			for _, item := range items {
+				item.Event = event
+				c.DeviceList.Items = append(c.DeviceList.Items, item)
+			}
+			storage.applyCatalogChanges(c)
+		}
+		// 父节点是虚拟组织的通道
+		var item CatalogItem
+		item.DeviceID.DeviceID = "44010200491310000001"
+		item.ParentID = "44010200492160000001"
+
+		notify(model.CatalogEventAdd, item)
+		channel, ok := storage.s.Channel().Get("44010200491320000001", "44010200491310000001")
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(channel.OwnerId, convey.ShouldEqual, "44010200491320000001")
+
+		notify(model.CatalogEventOff, item)
+		channel, _ = storage.s.Channel().Get("44010200491320000001", "44010200491310000001")
+		convey.So(channel.Status, convey.ShouldEqual, model.CatalogEventOff)
+
+		notify(model.CatalogEventDel, item)
+		_, ok = storage.s.Channel().Get("44010200491320000001", "44010200491310000001")
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,101 @@
+package gbserver
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage/sqlstore"
+	"github.com/inysc/GB28181/internal/pkg/app"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+const migrateDescription = `迁移数据库表结构，使用与服务相同的配置文件连接数据库。
+
+  up [version]    升级到指定版本，不指定时升级到最新版本
+  down [version]  回滚到指定版本，不指定时回滚最近的一个版本，0表示回滚全部
+  status          列出所有版本及执行时间
+`
+
+func migrateCommand(opt *GbOption) app.Command {
+	return app.Command{
+		Use:   "migrate up|down|status [version]",
+		Short: "迁移数据库表结构",
+		Long:  migrateDescription,
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(args []string) error {
+			logger.Init(opt.LogOption)
+			db, err := openDatabase(opt)
+			if err != nil {
+				return errors.WithMessagef(err, "failed to connect %s database", opt.DatabaseOption.Type)
+			}
+			return migrate(db, args)
+		},
+	}
+}
+
+func migrate(db *gorm.DB, args []string) error {
+	action := args[0]
+	if action == "status" {
+		if len(args) > 1 {
+			return errors.New("status不需要指定版本")
+		}
+		return printMigrateStatus(db)
+	}
+
+	current, err := sqlstore.Version(db)
+	if err != nil {
+		return err
+	}
+	var target int
+	switch action {
+	case "up":
+		target = sqlstore.LatestVersion()
+	case "down":
+		target = current - 1
+	default:
+		return errors.Errorf("未知的操作%s，可选up、down、status", action)
+	}
+	if len(args) > 1 {
+		if target, err = strconv.Atoi(args[1]); err != nil {
+			return errors.Errorf("版本号%s不是数字", args[1])
+		}
+	}
+	if action == "up" && target < current {
+		return errors.Errorf("目标版本%d低于当前版本%d，回滚请使用down", target, current)
+	}
+	if action == "down" && target > current {
+		return errors.Errorf("目标版本%d高于当前版本%d，升级请使用up", target, current)
+	}
+	if target == current || target < 0 {
+		fmt.Printf("当前版本为%d，无需迁移\n", current)
+		return nil
+	}
+
+	if err = sqlstore.MigrateTo(db, target); err != nil {
+		return err
+	}
+	fmt.Printf("表结构已从版本%d迁移到版本%d\n", current, target)
+	return nil
+}
+
+func printMigrateStatus(db *gorm.DB) error {
+	list, err := sqlstore.Status(db)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "版本\t名称\t执行时间")
+	for _, s := range list {
+		applied := "未执行"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return w.Flush()
+}
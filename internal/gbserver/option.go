@@ -6,30 +6,42 @@ import (
 )
 
 type GbOption struct {
-	ServerOption *option.ServerOptions `json:"server,omitempty" mapstructure:"server"`
-	MediaOption  *option.MediaOptions  `json:"media,omitempty"  mapstructure:"media"`
-	MysqlOption  *option.MySQLOptions  `json:"mysql,omitempty"  mapstructure:"mysql"`
-	RedisOption  *option.RedisOptions  `json:"redis,omitempty"  mapstructure:"redis"`
-	LogOption    *option.LogOptions    `json:"log,omitempty"    mapstructure:"log"`
-	Sip          *option.SIPOptions    `json:"sip"              mapstructure:"sip"`
+	ServerOption   *option.ServerOptions   `json:"server,omitempty"   mapstructure:"server"`
+	MediaOption    *option.MediaOptions    `json:"media,omitempty"    mapstructure:"media"`
+	DatabaseOption *option.DatabaseOptions `json:"database,omitempty" mapstructure:"database"`
+	MysqlOption    *option.MySQLOptions    `json:"mysql,omitempty"    mapstructure:"mysql"`
+	SqliteOption   *option.SQLiteOptions   `json:"sqlite,omitempty"   mapstructure:"sqlite"`
+	PostgresOption *option.PostgresOptions `json:"postgres,omitempty" mapstructure:"postgres"`
+	CacheOption    *option.CacheOptions    `json:"cache,omitempty"    mapstructure:"cache"`
+	RedisOption    *option.RedisOptions    `json:"redis,omitempty"    mapstructure:"redis"`
+	LogOption      *option.LogOptions      `json:"log,omitempty"      mapstructure:"log"`
+	Sip            *option.SIPOptions      `json:"sip"                mapstructure:"sip"`
 }
 
 func newGbOption() *GbOption {
 	return &GbOption{
-		ServerOption: option.NewServerOptions(),
-		MediaOption:  option.NewMediaOption(),
-		MysqlOption:  option.NewMySQLOptions(),
-		RedisOption:  option.NewRedisOptions(),
-		LogOption:    option.NewLogOptions(),
-		Sip:          option.NewSIPOptions(),
+		ServerOption:   option.NewServerOptions(),
+		MediaOption:    option.NewMediaOption(),
+		DatabaseOption: option.NewDatabaseOptions(),
+		MysqlOption:    option.NewMySQLOptions(),
+		SqliteOption:   option.NewSQLiteOptions(),
+		PostgresOption: option.NewPostgresOptions(),
+		CacheOption:    option.NewCacheOptions(),
+		RedisOption:    option.NewRedisOptions(),
+		LogOption:      option.NewLogOptions(),
+		Sip:            option.NewSIPOptions(),
 	}
 }
 
 func (c *GbOption) Flags() (fss *pflag.FlagSet) {
 	fss = pflag.NewFlagSet("gbserver", pflag.ExitOnError)
 	c.ServerOption.AddFlags(fss)
+	c.DatabaseOption.AddFlags(fss)
 	c.MysqlOption.AddFlags(fss)
+	c.SqliteOption.AddFlags(fss)
+	c.PostgresOption.AddFlags(fss)
 	c.MediaOption.AddFlags(fss)
+	c.CacheOption.AddFlags(fss)
 	c.RedisOption.AddFlags(fss)
 	c.LogOption.AddFlags(fss)
 	c.Sip.AddFlags(fss)
@@ -10,7 +10,6 @@ import (
 	"github.com/inysc/GB28181/internal/gbserver/controller"
 	"github.com/inysc/GB28181/internal/gbserver/service"
 	"github.com/inysc/GB28181/internal/gbserver/storage"
-	"github.com/inysc/GB28181/internal/gbserver/storage/mysql"
 	"github.com/inysc/GB28181/internal/pkg/logger"
 	"github.com/inysc/GB28181/internal/pkg/option"
 	swaggerFiles "github.com/swaggo/files"
@@ -26,7 +25,7 @@ type apiServer struct {
 type apiConfig struct {
 	mediaOption  *option.MediaOptions
 	serverOption *option.ServerOptions
-	mysqlOption  *option.MySQLOptions
+	store        storage.Factory
 }
 
 func newApiServer(config *apiConfig) *apiServer {
@@ -57,13 +56,21 @@ func (a *apiServer) Close() error {
 }
 
 func (a *apiServer) installController() {
-	store := mysql.GetMySQLFactory()
+	store := a.c.store
 	service.InitService(store)
 	initMediaHookRoute(a.engine.Group("/index/hook"))
 	initDeviceRoute(a.engine.Group("/device"), store)
 	initChannelRoute(a.engine.Group("/channel"), store)
 	initControlRoute(a.engine.Group("/control"))
 	initPlayRoute(a.engine.Group("/play"), store)
+	initPlaybackRoute(a.engine.Group("/playback"), store)
+	initDownloadRoute(a.engine.Group("/download"), store)
+	initRecordRoute(a.engine.Group("/record"), store)
+	initAlarmRoute(a.engine.Group("/alarm"), store)
+	initPositionRoute(a.engine.Group("/position"), store)
+	initPlatformRoute(a.engine.Group("/platform"), store)
+	initBroadcastRoute(a.engine.Group("/broadcast"), store)
+	initEventRoute(a.engine.Group("/events"))
 	initSwaggerRoute(a.engine.Group("/"))
 }
 
@@ -72,13 +79,83 @@ func initSwaggerRoute(group *gin.RouterGroup) {
 }
 
 func initPlayRoute(group *gin.RouterGroup, store storage.Factory) {
+	group.Use(controller.ValidateID())
 	playController := controller.NewPlayController(store)
 	group.POST("/start/:deviceId/:channelId", playController.Play)
+	group.POST("/stop/:deviceId/:channelId", playController.Stop)
+}
+
+func initPlaybackRoute(group *gin.RouterGroup, store storage.Factory) {
+	group.Use(controller.ValidateID())
+	p := controller.NewPlaybackController(store)
+	group.POST("/start/:deviceId/:channelId", p.Start)
+	group.POST("/control", p.Control)
+	group.POST("/stop/:streamId", p.Stop)
+}
+
+func initDownloadRoute(group *gin.RouterGroup, store storage.Factory) {
+	group.Use(controller.ValidateID())
+	d := controller.NewDownloadController(store)
+	group.POST("/start/:deviceId/:channelId", d.Start)
+	group.GET("/status/:streamId", d.Status)
+	group.POST("/stop/:streamId", d.Stop)
+}
+
+func initRecordRoute(group *gin.RouterGroup, store storage.Factory) {
+	group.Use(controller.ValidateID())
+	r := controller.NewRecordController(store)
+	group.GET("/:deviceId/:channelId", r.Query)
+}
+
+func initAlarmRoute(group *gin.RouterGroup, store storage.Factory) {
+	a := controller.NewAlarmController(store)
+	group.GET("/list", a.List)
+	group.POST("/reset", a.Reset)
+}
+
+func initPositionRoute(group *gin.RouterGroup, store storage.Factory) {
+	group.Use(controller.ValidateID())
+	p := controller.NewPositionController(store)
+	group.GET("/:deviceId", p.Track)
+}
+
+func initPlatformRoute(group *gin.RouterGroup, store storage.Factory) {
+	p := controller.NewPlatformController(store)
+	group.GET("/list", p.List)
+	group.POST("/add", p.Add)
+	group.POST("/update", p.Update)
+	group.POST("/delete/:id", p.Delete)
+	group.GET("/channels/:id", p.Channels)
+	group.POST("/channels/:id", p.SetChannels)
+}
+
+func initBroadcastRoute(group *gin.RouterGroup, store storage.Factory) {
+	b := controller.NewBroadcastController(store)
+	group.POST("/start", b.Start)
+	group.POST("/stop", b.Stop)
+	group.GET("/list", b.List)
+}
+
+func initEventRoute(group *gin.RouterGroup) {
+	e := controller.NewEventController()
+	group.GET("", e.Subscribe)
 }
 
 func initControlRoute(group *gin.RouterGroup) {
+	group.Use(controller.ValidateID())
 	c := controller.NewControlController()
 	group.POST("ptz", c.ControlPTZ)
+	group.POST("fi", c.ControlFI)
+	group.POST("preset", c.ControlPreset)
+	group.POST("cruise", c.ControlCruise)
+	group.POST("scan", c.ControlScan)
+	group.GET("preset/:deviceId/:channelId", c.PresetQuery)
+	group.POST("teleboot", c.TeleBoot)
+	group.POST("record", c.ControlRecord)
+	group.POST("guard", c.ControlGuard)
+	group.POST("iframe", c.ControlIFrame)
+	group.POST("dragzoom", c.ControlDragZoom)
+	group.POST("homeposition", c.ControlHomePosition)
 }
 
 func initMediaHookRoute(group *gin.RouterGroup) {
@@ -100,25 +177,37 @@ func initMediaHookRoute(group *gin.RouterGroup) {
 }
 
 func initDeviceRoute(group *gin.RouterGroup, factory storage.Factory) {
+	group.Use(controller.ValidateID())
 	d := controller.NewDeviceController(factory)
 	group.GET("/list", d.List)
 
 	// 设备的基本配置
 	group.POST("/config/basic", d.BasicParamsConfig)
 	group.GET("/config/basic/:deviceId", d.BasicParamsQuery)
+
+	// 设备的扩展配置
+	group.POST("/config", d.Config)
+	group.GET("/config/:deviceId", d.ConfigQuery)
 	// 查询设备状态
 	group.GET("/status/:deviceId", d.StatusQuery)
-	// 查询设备文件目录
-	//group.GET("/catalog",)
+	// 同步设备目录
+	group.POST("/catalog/sync/:deviceId", d.CatalogSync)
+	group.GET("/catalog/status/:deviceId", d.CatalogSyncStatus)
 
 	// 订阅
-	group.POST("/subscribe/alarm:deviceId", d.AlarmSubscribe)
-	group.POST("/subscribe/catalog:deviceId", d.CatalogSubscribe)
-	group.POST("/subscribe/mobilePosition:deviceId", d.MobilePositionSubscribe)
+	group.POST("/subscribe/alarm/:deviceId", d.AlarmSubscribe)
+	group.POST("/subscribe/catalog/:deviceId", d.CatalogSubscribe)
+	group.POST("/subscribe/mobilePosition/:deviceId", d.MobilePositionSubscribe)
+	group.GET("/subscribe/list", d.SubscriptionList)
+	group.POST("/subscribe/cancel/:cmdType/:deviceId", d.Unsubscribe)
 
 }
 
 func initChannelRoute(group *gin.RouterGroup, factory storage.Factory) {
+	group.Use(controller.ValidateID())
 	c := controller.NewChannelController(factory)
 	group.GET("/list/:device", c.List)
+	group.GET("/changes", c.Changes)
+	group.GET("/tree", c.Tree)
+	group.GET("/tree/search", c.SearchTree)
 }
@@ -8,6 +8,7 @@ import (
 	"syscall"
 
 	"github.com/inysc/GB28181/internal/gbserver/gb"
+	"github.com/inysc/GB28181/internal/gbserver/service"
 	"github.com/inysc/GB28181/internal/gbserver/storage/cache"
 	"github.com/inysc/GB28181/internal/pkg/logger"
 	"golang.org/x/sync/errgroup"
@@ -23,14 +24,15 @@ type Server struct {
 
 func NewServer(opt *GbOption) *Server {
 	ctx, cancelFunc := context.WithCancel(context.Background())
+	store := newStore(opt)
 	apiConfig := &apiConfig{
 		mediaOption:  opt.MediaOption,
 		serverOption: opt.ServerOption,
-		mysqlOption:  opt.MysqlOption,
+		store:        store,
 	}
 	gbConfig := &gb.SipConfig{
-		SipOption:   opt.Sip,
-		MysqlOption: opt.MysqlOption,
+		SipOption: opt.Sip,
+		Store:     store,
 	}
 	return &Server{
 		sip:       gb.NewServer(gbConfig),
@@ -50,7 +52,7 @@ func (s *Server) Run() error {
 		}
 		logger.Info("gbserver shutdown....")
 	}()
-	cache.InitCache(s.opt.RedisOption)
+	cache.InitCache(s.opt.CacheOption, s.opt.RedisOption)
 	s.apiServer.initRoute()
 	eg, ctx := errgroup.WithContext(s.ctx)
 	defer ctx.Done()
@@ -64,12 +66,19 @@ func (s *Server) Run() error {
 	})
 
 	eg.Go(func() error {
-		return s.sip.ListenTCP()
+		if err := s.sip.ListenTCP(); err != nil {
+			return err
+		}
+		if err := s.sip.ListenUDP(); err != nil {
+			return err
+		}
+		// 开始监听后才能向设备发送请求
+		s.sip.Resubscribe()
+		return nil
 	})
 
-	eg.Go(func() error {
-		return s.sip.ListenUDP()
-	})
+	// 向上级平台注册，注册失败时会定期重试
+	go service.Cascade().Start()
 
 	if err := eg.Wait(); err != nil {
 		return err
@@ -78,6 +87,7 @@ func (s *Server) Run() error {
 }
 
 func (s *Server) Close() error {
+	service.Cascade().Stop()
 	if err := s.sip.Close(); err != nil {
 		return err
 	}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"time"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/model"
+)
+
+// 报警记录分页的默认值和最大值
+const (
+	defaultAlarmPageSize = 20
+	maxAlarmPageSize     = 500
+)
+
+type IAlarm interface {
+	List(q model.AlarmQuery) ([]model.Alarm, int64, error)
+	Reset(r model.AlarmReset) error
+}
+
+type alarmService struct {
+	store storage.Factory
+}
+
+var aService = new(alarmService)
+
+func Alarm() IAlarm {
+	return aService
+}
+
+// List 分页查询报警记录，按报警时间倒序排列
+func (a *alarmService) List(q model.AlarmQuery) ([]model.Alarm, int64, error) {
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.Size <= 0 {
+		q.Size = defaultAlarmPageSize
+	}
+	if q.Size > maxAlarmPageSize {
+		q.Size = maxAlarmPageSize
+	}
+	return a.store.Alarm().List(q)
+}
+
+// Reset 向设备发送报警复位命令，设备确认后将对应的报警记录标记为已复位
+func (a *alarmService) Reset(r model.AlarmReset) error {
+	device, ok := Device().GetByDeviceId(r.DeviceId)
+	if !ok {
+		return deviceNotFound
+	}
+	channelId := r.ChannelId
+	if channelId == "" {
+		channelId = r.DeviceId
+	}
+	if err := gbsip.ResetAlarm(device, channelId, r.AlarmMethod, r.AlarmType); err != nil {
+		return err
+	}
+	return a.store.Alarm().Reset(r.DeviceId, r.ChannelId, time.Now())
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/util"
+	"github.com/pkg/errors"
+)
+
+// 等待设备收到广播通知后发起INVITE的最长时间
+const broadcastInviteTimeout = 10 * time.Second
+
+var (
+	ErrBroadcastStreamOffline = errors.New("语音流不在线，请先将语音流推送到流媒体")
+	errBroadcastMode          = errors.New("不支持的语音广播模式")
+	errBroadcastCodec         = errors.New("不支持的语音编码")
+	errDeviceOffline          = errors.New("设备不在线")
+)
+
+type IBroadcast interface {
+	Start(r model.BroadcastRequest) (model.BroadcastSession, error)
+	Stop(r model.BroadcastStop) error
+	List() []model.BroadcastSession
+	Invite(session model.BroadcastSession, inv model.BroadcastInvite) (string, model.BroadcastSession, error)
+	Fail(session model.BroadcastSession, err error)
+	Bye(callId string) (bool, error)
+}
+
+type broadcastService struct {
+	store storage.Factory
+}
+
+var bService = new(broadcastService)
+
+func Broadcast() IBroadcast {
+	return bService
+}
+
+// Start 通知设备开始语音广播，并等待设备发起INVITE建立会话
+//
+// 语音流需要事先推送到流媒体，流媒体不做转码，推送的语音编码需要与协商的编码一致
+func (b *broadcastService) Start(r model.BroadcastRequest) (model.BroadcastSession, error) {
+	if r.Mode == "" {
+		r.Mode = model.BroadcastModeBroadcast
+	}
+	if r.Mode != model.BroadcastModeBroadcast && r.Mode != model.BroadcastModeTalk {
+		return model.BroadcastSession{}, errBroadcastMode
+	}
+	if r.Codec == "" {
+		r.Codec = model.AudioCodecPCMA
+	}
+	switch r.Codec {
+	case model.AudioCodecPCMA, model.AudioCodecPCMU, model.AudioCodecAAC:
+	default:
+		return model.BroadcastSession{}, errBroadcastCodec
+	}
+	if r.App == "" {
+		r.App = model.BroadcastApp
+	}
+	if r.Stream == "" {
+		r.Stream = fmt.Sprintf("%s_%s", r.DeviceId, r.ChannelId)
+	}
+
+	device, ok := Device().GetByDeviceId(r.DeviceId)
+	if !ok {
+		return model.BroadcastSession{}, deviceNotFound
+	}
+	if device.Offline != 1 {
+		return model.BroadcastSession{}, errDeviceOffline
+	}
+
+	detail, err := Media().GetDefaultMedia()
+	if err != nil {
+		return model.BroadcastSession{}, err
+	}
+	if resp, err := Media().GetMediaInfo(r.App, r.Stream, detail); err != nil || !resp.Online {
+		return model.BroadcastSession{}, ErrBroadcastStreamOffline
+	}
+
+	session := model.BroadcastSession{
+		DeviceId:      r.DeviceId,
+		ChannelId:     r.ChannelId,
+		Mode:          r.Mode,
+		Codec:         r.Codec,
+		MediaServerId: detail.ID,
+		App:           r.App,
+		Stream:        r.Stream,
+		StartTime:     time.Now(),
+	}
+	if r.Mode == model.BroadcastModeTalk {
+		session.TalkStream = fmt.Sprintf("%s_talk", r.Stream)
+	}
+	if err = gbsip.Broadcast(device, session); err != nil {
+		return model.BroadcastSession{}, err
+	}
+	return gbsip.WaitBroadcast(r.ChannelId, broadcastInviteTimeout)
+}
+
+// Stop 向设备发送BYE结束语音广播
+func (b *broadcastService) Stop(r model.BroadcastStop) error {
+	session, err := gbsip.EndBroadcast(r.ChannelId, true)
+	b.stopSendRtp(session)
+	return err
+}
+
+func (b *broadcastService) List() []model.BroadcastSession {
+	return gbsip.Broadcasts()
+}
+
+// Invite 应答设备的语音广播INVITE，协商语音编码后由流媒体向设备推送语音，返回应答的sdp
+func (b *broadcastService) Invite(session model.BroadcastSession, inv model.BroadcastInvite) (string, model.BroadcastSession, error) {
+	answer, session, err := b.invite(session, inv)
+	if err != nil {
+		gbsip.BroadcastFailed(session.ChannelId, err)
+	}
+	return answer, session, err
+}
+
+func (b *broadcastService) invite(session model.BroadcastSession, inv model.BroadcastInvite) (string, model.BroadcastSession, error) {
+	// 设备支持期望的编码时使用期望的编码，否则使用设备首选的编码
+	codec := inv.Codecs[0]
+	for _, c := range inv.Codecs {
+		if c == session.Codec {
+			codec = c
+			break
+		}
+	}
+	session.Codec = codec
+	session.SSRC = inv.SSRC
+	if session.SSRC == "" {
+		ssrc, err := util.GetSSRC(util.RealTime)
+		if err != nil {
+			return "", session, err
+		}
+		session.SSRC = ssrc
+	}
+
+	detail, err := Media().GetMedia(session.MediaServerId)
+	if err != nil {
+		return "", session, err
+	}
+	localPort, err := Media().StartSendRtp(detail, model.SendRtpParam{
+		App:         session.App,
+		Stream:      session.Stream,
+		SSRC:        session.SSRC,
+		DstIp:       inv.Ip,
+		DstPort:     inv.Port,
+		UDP:         inv.UDP,
+		Passive:     !inv.UDP && inv.Active,
+		OnlyAudio:   true,
+		PayloadType: gbsip.BroadcastPayloadType(codec, inv),
+		RecvStream:  session.TalkStream,
+	})
+	if err != nil {
+		return "", session, errors.WithMessage(err, "向设备推送语音流失败")
+	}
+	logger.Infof("{%s}开始向设备推送语音流%s/%s，编码%s，目标地址%s:%d", session.ChannelId, session.App, session.Stream, codec, inv.Ip, inv.Port)
+
+	talk := session.Mode == model.BroadcastModeTalk
+	return gbsip.CreateBroadcastAnswerSdp(detail.Ip, session.SSRC, codec, localPort, talk, inv), session, nil
+}
+
+// Fail 应答设备失败时停止推送语音
+func (b *broadcastService) Fail(session model.BroadcastSession, err error) {
+	b.stopSendRtp(session)
+	gbsip.BroadcastFailed(session.ChannelId, err)
+}
+
+// Bye 设备结束语音广播，Call-ID不属于语音广播时返回false
+func (b *broadcastService) Bye(callId string) (bool, error) {
+	session, ok := gbsip.BroadcastByCallId(callId)
+	if !ok {
+		return false, nil
+	}
+	session, err := gbsip.EndBroadcast(session.ChannelId, false)
+	b.stopSendRtp(session)
+	return true, err
+}
+
+// 停止推送语音并归还平台分配的ssrc，设备在sdp中指定的ssrc不在ssrc池中，归还时会被忽略
+func (b *broadcastService) stopSendRtp(session model.BroadcastSession) {
+	if session.SSRC == "" {
+		return
+	}
+	util.ReleaseSSRC(session.SSRC)
+	detail, err := Media().GetMedia(session.MediaServerId)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	if err = Media().StopSendRtp(detail, session.App, session.Stream, session.SSRC); err != nil {
+		logger.Errorf("{%s}停止推送语音流失败，%s", session.ChannelId, err)
+	}
+}
@@ -0,0 +1,443 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/gbserver/storage/cache"
+	"github.com/inysc/GB28181/internal/pkg/gbid"
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/model/constant"
+	"github.com/pkg/errors"
+)
+
+const (
+	// 注册失败后重试的间隔
+	cascadeRetryInterval = 30 * time.Second
+	// 等待本级点播的流在流媒体上线的最长时间
+	cascadeStreamTimeout = 10 * time.Second
+)
+
+var (
+	ErrPlatformNotFound = errors.New("上级平台不存在")
+	ErrChannelNotShared = errors.New("通道没有共享给该上级平台")
+)
+
+type ICascade interface {
+	Start()
+	Stop()
+	List() ([]model.Platform, error)
+	Add(p model.Platform) error
+	Update(p model.Platform) error
+	Delete(id uint) error
+	Channels(id uint) ([]model.PlatformChannel, error)
+	SetChannels(id uint, channels []model.PlatformChannel) error
+	SharedChannels(p model.Platform) ([]model.Channel, error)
+	Invite(p model.Platform, callId string, inv model.CascadeInvite) (string, error)
+	Bye(callId string) (bool, error)
+	Addrs(id uint) []string
+}
+
+// 与一个上级平台保持注册和心跳
+type cascadeClient struct {
+	p    model.Platform
+	stop chan struct{}
+	done chan struct{}
+
+	mux sync.Mutex
+	// 上级平台地址解析后的ip，每次注册前更新，用于校验上级平台请求的来源
+	addrs []string
+}
+
+// 解析上级平台地址，平台地址可能配置为域名，解析失败时保留上一次的结果
+func (cl *cascadeClient) resolve() {
+	addrs, err := net.LookupHost(cl.p.ServerIp)
+	if err != nil {
+		logger.Errorf("{%s}解析上级平台地址%s失败，%s", cl.p.ServerId, cl.p.ServerIp, err)
+		return
+	}
+	cl.mux.Lock()
+	cl.addrs = addrs
+	cl.mux.Unlock()
+}
+
+type cascadeService struct {
+	store storage.Factory
+
+	mux     sync.Mutex
+	clients map[uint]*cascadeClient
+}
+
+var csService = &cascadeService{clients: make(map[uint]*cascadeClient)}
+
+func Cascade() ICascade {
+	return csService
+}
+
+// Start 向所有启用的上级平台注册
+func (c *cascadeService) Start() {
+	list, err := c.store.Platform().List()
+	if err != nil {
+		logger.Errorf("获取上级平台失败，%s", err)
+		return
+	}
+	for _, p := range list {
+		if p.Enable {
+			c.startClient(p)
+		}
+	}
+}
+
+// Stop 从所有上级平台注销
+func (c *cascadeService) Stop() {
+	c.mux.Lock()
+	ids := make([]uint, 0, len(c.clients))
+	for id := range c.clients {
+		ids = append(ids, id)
+	}
+	c.mux.Unlock()
+
+	for _, id := range ids {
+		c.stopClient(id)
+	}
+}
+
+func (c *cascadeService) startClient(p model.Platform) {
+	cl := &cascadeClient{
+		p:    p.WithDefault(),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	c.mux.Lock()
+	c.clients[p.ID] = cl
+	c.mux.Unlock()
+	go c.run(cl)
+}
+
+func (c *cascadeService) stopClient(id uint) {
+	c.mux.Lock()
+	cl, ok := c.clients[id]
+	delete(c.clients, id)
+	c.mux.Unlock()
+	if !ok {
+		return
+	}
+	close(cl.stop)
+	<-cl.done
+}
+
+// 注册成功后按心跳周期发送心跳，在注册过期前或心跳连续超时后重新注册
+func (c *cascadeService) run(cl *cascadeClient) {
+	defer close(cl.done)
+	p := cl.p
+
+	for {
+		cl.resolve()
+		if err := gbsip.PlatformRegister(p, p.Expires); err != nil {
+			logger.Errorf("{%s}向上级平台注册失败，%s", p.ServerId, err)
+			c.setStatus(p, false)
+			select {
+			case <-cl.stop:
+				return
+			case <-time.After(cascadeRetryInterval):
+				continue
+			}
+		}
+		logger.Infof("{%s}向上级平台注册成功", p.ServerId)
+		c.setStatus(p, true)
+
+		refresh := time.NewTimer(time.Duration(p.Expires) * time.Second * 4 / 5)
+		keepalive := time.NewTicker(time.Duration(p.KeepaliveInterval) * time.Second)
+		if stopped := c.keepalive(cl, refresh, keepalive); stopped {
+			refresh.Stop()
+			keepalive.Stop()
+			if err := gbsip.PlatformRegister(p, 0); err != nil {
+				logger.Errorf("{%s}从上级平台注销失败，%s", p.ServerId, err)
+			}
+			c.setStatus(p, false)
+			return
+		}
+		refresh.Stop()
+		keepalive.Stop()
+	}
+}
+
+// 保持心跳直到需要重新注册，被停止时返回true
+func (c *cascadeService) keepalive(cl *cascadeClient, refresh *time.Timer, keepalive *time.Ticker) bool {
+	p := cl.p
+	failures := 0
+	for {
+		select {
+		case <-cl.stop:
+			return true
+		case <-refresh.C:
+			return false
+		case <-keepalive.C:
+			if err := gbsip.PlatformKeepalive(p); err != nil {
+				failures++
+				logger.Warnf("{%s}上级平台心跳失败%d次，%s", p.ServerId, failures, err)
+				if failures >= p.KeepaliveTimeout {
+					c.setStatus(p, false)
+					return false
+				}
+				continue
+			}
+			failures = 0
+		}
+	}
+}
+
+// Addrs 返回上级平台地址解析后的ip，平台没有启用时返回空
+func (c *cascadeService) Addrs(id uint) []string {
+	c.mux.Lock()
+	cl, ok := c.clients[id]
+	c.mux.Unlock()
+	if !ok {
+		return nil
+	}
+	cl.mux.Lock()
+	defer cl.mux.Unlock()
+	return cl.addrs
+}
+
+func (c *cascadeService) setStatus(p model.Platform, online bool) {
+	var registerTime *time.Time
+	if online {
+		now := time.Now()
+		registerTime = &now
+	}
+	if err := c.store.Platform().UpdateStatus(p.ID, online, registerTime); err != nil {
+		logger.Errorf("{%s}更新上级平台状态失败，%s", p.ServerId, err)
+	}
+}
+
+// List 返回所有上级平台，不返回密码
+func (c *cascadeService) List() ([]model.Platform, error) {
+	list, err := c.store.Platform().List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Password = ""
+	}
+	return list, nil
+}
+
+func (c *cascadeService) Add(p model.Platform) error {
+	if err := gbid.Validate(p.ServerId); err != nil {
+		return err
+	}
+	p = p.WithDefault()
+	p.Online = false
+	if err := c.store.Platform().Save(p); err != nil {
+		return err
+	}
+	saved, ok := c.store.Platform().GetByServerId(p.ServerId)
+	if ok && saved.Enable {
+		c.startClient(saved)
+	}
+	return nil
+}
+
+// Update 修改上级平台配置，正在注册的平台会以新的配置重新注册
+func (c *cascadeService) Update(p model.Platform) error {
+	if err := gbid.Validate(p.ServerId); err != nil {
+		return err
+	}
+	old, err := c.store.Platform().Get(p.ID)
+	if err != nil {
+		return ErrPlatformNotFound
+	}
+	// 未传入密码时保留原来的密码
+	if p.Password == "" {
+		p.Password = old.Password
+	}
+	p = p.WithDefault()
+	if err = c.store.Platform().Update(p); err != nil {
+		return err
+	}
+	c.stopClient(p.ID)
+	if p.Enable {
+		c.startClient(p)
+	}
+	return nil
+}
+
+func (c *cascadeService) Delete(id uint) error {
+	c.stopClient(id)
+	return c.store.Platform().Delete(id)
+}
+
+func (c *cascadeService) Channels(id uint) ([]model.PlatformChannel, error) {
+	return c.store.Platform().Channels(id)
+}
+
+func (c *cascadeService) SetChannels(id uint, channels []model.PlatformChannel) error {
+	if _, err := c.store.Platform().Get(id); err != nil {
+		return ErrPlatformNotFound
+	}
+	for _, ch := range channels {
+		if err := gbid.Validate(ch.ChannelId); err != nil {
+			return err
+		}
+	}
+	return c.store.Platform().SetChannels(id, channels)
+}
+
+// SharedChannels 返回共享给上级平台的通道，共享全部时不包括行政区划、业务分组和虚拟组织，
+// 多个设备下有相同的通道id时只共享最先保存的一个，与sharedDevice一致
+func (c *cascadeService) SharedChannels(p model.Platform) ([]model.Channel, error) {
+	if p.ShareAll {
+		all, err := c.store.Channel().ListAll()
+		if err != nil {
+			return nil, err
+		}
+		var list []model.Channel
+		index := make(map[string]int)
+		for _, ch := range all {
+			if id, err := gbid.Parse(ch.DeviceId); err != nil || !(id.IsDevice() || id.IsPeripheral()) {
+				continue
+			}
+			if i, ok := index[ch.DeviceId]; ok {
+				if ch.ID < list[i].ID {
+					list[i] = ch
+				}
+				continue
+			}
+			index[ch.DeviceId] = len(list)
+			list = append(list, ch)
+		}
+		return list, nil
+	}
+
+	shared, err := c.store.Platform().Channels(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]model.Channel, 0, len(shared))
+	for _, s := range shared {
+		if ch, ok := c.store.Channel().Get(s.DeviceId, s.ChannelId); ok {
+			list = append(list, ch)
+		}
+	}
+	return list, nil
+}
+
+// 获取共享通道所属的设备id
+func (c *cascadeService) sharedDevice(p model.Platform, channelId string) (string, bool) {
+	if p.ShareAll {
+		owners, err := c.store.Channel().Owners(channelId)
+		if err != nil {
+			logger.Error(err)
+			return "", false
+		}
+		if len(owners) == 0 {
+			return "", false
+		}
+		if len(owners) > 1 {
+			logger.Warnf("通道%s属于多个设备%v，使用%s", channelId, owners, owners[0])
+		}
+		return owners[0], true
+	}
+	shared, err := c.store.Platform().Channels(p.ID)
+	if err != nil {
+		logger.Error(err)
+		return "", false
+	}
+	for _, s := range shared {
+		if s.ChannelId == channelId {
+			return s.DeviceId, true
+		}
+	}
+	return "", false
+}
+
+// Invite 上级平台点播共享的通道，本级点播后由流媒体将流推送到上级平台，返回应答的sdp
+func (c *cascadeService) Invite(p model.Platform, callId string, inv model.CascadeInvite) (string, error) {
+	deviceId, ok := c.sharedDevice(p, inv.ChannelId)
+	if !ok {
+		return "", ErrChannelNotShared
+	}
+
+	info, err := Play().Play(deviceId, inv.ChannelId)
+	if err != nil {
+		return "", errors.WithMessage(err, "本级点播失败")
+	}
+	detail, err := Media().GetMedia(info.MediaServerId)
+	if err != nil {
+		_ = Play().Stop(deviceId, inv.ChannelId)
+		return "", err
+	}
+	if err = c.waitStream(detail, info); err != nil {
+		_ = Play().Stop(deviceId, inv.ChannelId)
+		return "", err
+	}
+
+	localPort, err := Media().StartSendRtp(detail, model.SendRtpParam{
+		App:     info.App,
+		Stream:  info.Stream,
+		SSRC:    inv.SSRC,
+		DstIp:   inv.Ip,
+		DstPort: inv.Port,
+		UDP:     inv.UDP,
+	})
+	if err != nil {
+		_ = Play().Stop(deviceId, inv.ChannelId)
+		return "", errors.WithMessage(err, "向上级平台推流失败")
+	}
+
+	session := model.CascadeSession{
+		PlatformId:    p.ID,
+		DeviceId:      deviceId,
+		ChannelId:     inv.ChannelId,
+		StreamId:      info.Stream,
+		MediaServerId: info.MediaServerId,
+		SSRC:          inv.SSRC,
+		LocalPort:     localPort,
+	}
+	cache.Set(fmt.Sprintf("%s:%s", constant.CascadeSessionPrefix, callId), session)
+	logger.Infof("{%s}开始向上级平台%s推流，目标地址%s:%d", info.Stream, p.ServerId, inv.Ip, inv.Port)
+	return gbsip.CreateAnswerSdp(detail.Ip, inv.SSRC, localPort), nil
+}
+
+// 设备收到点播后需要一段时间才会推流，流上线后才能转推
+func (c *cascadeService) waitStream(detail model.MediaDetail, info model.StreamInfo) error {
+	deadline := time.Now().Add(cascadeStreamTimeout)
+	for time.Now().Before(deadline) {
+		if resp, err := Media().GetMediaInfo(info.App, info.Stream, detail); err == nil && resp.Online {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return errors.Errorf("等待流%s上线超时", info.Stream)
+}
+
+// Bye 上级平台结束点播，Call-ID不属于级联会话时返回false
+func (c *cascadeService) Bye(callId string) (bool, error) {
+	key := fmt.Sprintf("%s:%s", constant.CascadeSessionPrefix, callId)
+	j, _ := cache.Get(key)
+	if j == nil || j == "" {
+		return false, nil
+	}
+	var session model.CascadeSession
+	if err := json.Unmarshal([]byte(j.(string)), &session); err != nil {
+		return true, errors.WithMessage(err, "unmarshal json data to struct fail")
+	}
+	if err := cache.Del(key); err != nil {
+		logger.Error(err)
+	}
+
+	// 在点播时使用的流媒体上停止推流
+	if detail, err := Media().GetMedia(session.MediaServerId); err != nil {
+		logger.Errorf("{%s}获取流媒体%s失败，%s", session.StreamId, session.MediaServerId, err)
+	} else if err = Media().StopSendRtp(detail, "rtp", session.StreamId, session.SSRC); err != nil {
+		logger.Errorf("{%s}停止向上级平台推流失败，%s", session.StreamId, err)
+	}
+	return true, Play().Stop(session.DeviceId, session.ChannelId)
+}
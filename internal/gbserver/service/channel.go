@@ -5,8 +5,17 @@ import (
 	"github.com/inysc/GB28181/internal/pkg/model"
 )
 
+// 通道变更记录分页的默认值和最大值
+const (
+	defaultChangePageSize = 20
+	maxChangePageSize     = 500
+)
+
 type IChannel interface {
 	List(deviceId string) ([]model.Channel, error)
+	Changes(q model.ChannelChangeQuery) ([]model.ChannelChange, int64, error)
+	Tree(parentId string) ([]model.TreeNode, error)
+	SearchTree(name string) ([]model.TreeNode, error)
 }
 
 type channelService struct {
@@ -26,3 +35,27 @@ func (c channelService) List(deviceId string) ([]model.Channel, error) {
 	}
 	return list, nil
 }
+
+// Changes 分页查询通道变更记录，按变化时间倒序排列
+func (c channelService) Changes(q model.ChannelChangeQuery) ([]model.ChannelChange, int64, error) {
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.Size <= 0 {
+		q.Size = defaultChangePageSize
+	}
+	if q.Size > maxChangePageSize {
+		q.Size = maxChangePageSize
+	}
+	return c.store.Channel().Changes(q)
+}
+
+// Tree 返回通道树中一个节点的直接子节点，parentId为空时返回根节点
+func (c channelService) Tree(parentId string) ([]model.TreeNode, error) {
+	return channelTree{store: c.store}.childrenOf(parentId)
+}
+
+// SearchTree 按名称在通道树中模糊搜索节点
+func (c channelService) SearchTree(name string) ([]model.TreeNode, error) {
+	return channelTree{store: c.store}.search(name)
+}
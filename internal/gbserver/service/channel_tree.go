@@ -0,0 +1,237 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/gbid"
+	"github.com/inysc/GB28181/internal/pkg/model"
+)
+
+// 防止错误的ParentID形成环时无限查找
+const maxTreeDepth = 32
+
+// 同级节点按类型排序
+var treeNodeOrder = map[string]int{
+	model.TreeNodeRegion:        0,
+	model.TreeNodeBusinessGroup: 1,
+	model.TreeNodeVirtualOrg:    2,
+	model.TreeNodeDevice:        3,
+	model.TreeNodeChannel:       4,
+}
+
+// 通道树，目录项在树中的父节点在保存时计算，见model.Channel.TreeParent，每次只查询一个节点的子节点
+type channelTree struct {
+	store storage.Factory
+}
+
+// 返回一个节点的直接子节点，parentId为空时返回根节点
+func (t channelTree) childrenOf(parentId string) ([]model.TreeNode, error) {
+	channels, err := t.store.Channel().Children(parentId)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]model.TreeNode, 0, len(channels))
+	for _, c := range channels {
+		list = append(list, channelNode(c))
+	}
+	if err = t.markChildren(list); err != nil {
+		return nil, err
+	}
+	if parentId != "" && !gbid.IsCivilCode(parentId) {
+		sortTreeNodes(list)
+		return list, nil
+	}
+
+	// 根节点和行政区划下补全目录中没有上报的下级行政区划
+	parents, err := t.store.Channel().TreeParents(parentId)
+	if err != nil {
+		return nil, err
+	}
+	regions := subRegions(parentId, parents)
+	for i, n := range list {
+		if n.Type == model.TreeNodeRegion {
+			list[i].HasChildren = regions[n.Id]
+			delete(regions, n.Id)
+		}
+	}
+	for code := range regions {
+		list = append(list, model.TreeNode{Id: code, Name: code, Type: model.TreeNodeRegion, ParentId: parentId, HasChildren: true})
+	}
+
+	// 设备都在根节点下，没有子节点的设备不展示
+	if parentId == "" {
+		devices, err := t.store.Devices().List()
+		if err != nil {
+			return nil, err
+		}
+		has := make(map[string]bool, len(parents))
+		for _, p := range parents {
+			has[p] = true
+		}
+		for _, d := range devices {
+			if has[d.DeviceId] {
+				list = append(list, deviceNode(d))
+			}
+		}
+	}
+	sortTreeNodes(list)
+	return list, nil
+}
+
+// 按名称模糊搜索节点，并返回每个节点从根节点开始的路径
+func (t channelTree) search(name string) ([]model.TreeNode, error) {
+	channels, err := t.store.Channel().Search(name)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]model.TreeNode, 0, len(channels))
+	// 节点所属的设备，用于沿父节点查找路径，设备节点属于自身
+	owners := make([]string, 0, len(channels))
+	for _, c := range channels {
+		list = append(list, channelNode(c))
+		owners = append(owners, c.OwnerId)
+	}
+	if err = t.markChildren(list); err != nil {
+		return nil, err
+	}
+	for i, n := range list {
+		if n.Type != model.TreeNodeRegion {
+			continue
+		}
+		parents, err := t.store.Channel().TreeParents(n.Id)
+		if err != nil {
+			return nil, err
+		}
+		list[i].HasChildren = len(parents) > 0
+	}
+
+	devices, err := t.store.Devices().List()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	byId := make(map[string]model.Device)
+	for _, d := range devices {
+		if strings.Contains(strings.ToLower(d.Name), strings.ToLower(name)) {
+			ids = append(ids, d.DeviceId)
+			byId[d.DeviceId] = d
+		}
+	}
+	ids, err = t.store.Channel().WithChildren(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		list = append(list, deviceNode(byId[id]))
+		owners = append(owners, id)
+	}
+
+	parentOf := make(map[[2]string]string)
+	for i := range list {
+		list[i].Path = t.pathOf(owners[i], list[i].ParentId, parentOf)
+	}
+	sortTreeNodes(list)
+	return list, nil
+}
+
+// 设置业务分组、虚拟组织和通道节点是否有子节点，行政区划由调用方处理
+func (t channelTree) markChildren(list []model.TreeNode) error {
+	var ids []string
+	for _, n := range list {
+		if n.Type != model.TreeNodeRegion {
+			ids = append(ids, n.Id)
+		}
+	}
+	ids, err := t.store.Channel().WithChildren(ids)
+	if err != nil {
+		return err
+	}
+	has := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		has[id] = true
+	}
+	for i, n := range list {
+		if n.Type != model.TreeNodeRegion {
+			list[i].HasChildren = has[n.Id]
+		}
+	}
+	return nil
+}
+
+// 沿父节点向上查找从根节点到parentId的路径，业务分组、虚拟组织和上级通道都在ownerId的目录中，
+// parentOf按所属设备和id缓存已查询过的父节点
+func (t channelTree) pathOf(ownerId, parentId string, parentOf map[[2]string]string) []string {
+	var path []string
+	for id, i := parentId, 0; id != "" && i < maxTreeDepth; i++ {
+		path = append([]string{id}, path...)
+		if gbid.IsCivilCode(id) {
+			id = id[:len(id)-2]
+			continue
+		}
+		key := [2]string{ownerId, id}
+		p, ok := parentOf[key]
+		if !ok {
+			// 设备节点没有对应的目录项，是根节点
+			if c, found := t.store.Channel().Get(ownerId, id); found {
+				p = c.TreeParentId
+			}
+			parentOf[key] = p
+		}
+		id = p
+	}
+	return path
+}
+
+// 返回parent下一级的行政区划，parents为通道树中以parent开头的父节点
+func subRegions(parent string, parents []string) map[string]bool {
+	regions := make(map[string]bool)
+	for _, p := range parents {
+		if len(p) > len(parent) && strings.HasPrefix(p, parent) && gbid.IsCivilCode(p) {
+			regions[p[:len(parent)+2]] = true
+		}
+	}
+	return regions
+}
+
+func channelNode(c model.Channel) model.TreeNode {
+	n := model.TreeNode{Id: c.DeviceId, Name: c.Name, ParentId: c.TreeParentId}
+	switch {
+	case gbid.IsCivilCode(c.DeviceId):
+		n.Type = model.TreeNodeRegion
+	case gbid.TypeCode(c.DeviceId) == gbid.TypeBusinessGroup:
+		n.Type = model.TreeNodeBusinessGroup
+	case gbid.TypeCode(c.DeviceId) == gbid.TypeVirtualOrg:
+		n.Type = model.TreeNodeVirtualOrg
+	default:
+		n.Type = model.TreeNodeChannel
+		n.DeviceId = c.OwnerId
+		n.Status = c.Status
+	}
+	return n
+}
+
+func deviceNode(d model.Device) model.TreeNode {
+	status := "OFF"
+	if d.Offline == 1 {
+		status = "ON"
+	}
+	name := d.Name
+	if name == "" {
+		name = d.DeviceId
+	}
+	return model.TreeNode{Id: d.DeviceId, Name: name, Type: model.TreeNodeDevice, Status: status, HasChildren: true}
+}
+
+func sortTreeNodes(list []model.TreeNode) {
+	sort.Slice(list, func(i, j int) bool {
+		if treeNodeOrder[list[i].Type] != treeNodeOrder[list[j].Type] {
+			return treeNodeOrder[list[i].Type] < treeNodeOrder[list[j].Type]
+		}
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Id < list[j].Id
+	})
+}
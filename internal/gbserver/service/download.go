@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/util"
+	"github.com/pkg/errors"
+)
+
+type IDownload interface {
+	Start(deviceId, channelId string, start, end time.Time, speed int) (model.DownloadInfo, error)
+	Status(streamId string) (model.DownloadInfo, error)
+	Stop(streamId string) error
+	IsDownload(streamId string) bool
+}
+
+type downloadService struct{}
+
+func Download() IDownload {
+	return downloadService{}
+}
+
+// Start 开始下载录像，录像由流媒体服务录制成mp4文件
+func (d downloadService) Start(deviceId, channelId string, start, end time.Time, speed int) (model.DownloadInfo, error) {
+	device, ok := Device().GetByDeviceId(deviceId)
+	if !ok {
+		return model.DownloadInfo{}, deviceNotFound
+	}
+
+	mediaDetail, err := Media().GetDefaultMedia()
+	if err != nil {
+		return model.DownloadInfo{}, err
+	}
+
+	ssrc, err := util.GetSSRC(util.History)
+	if err != nil {
+		return model.DownloadInfo{}, err
+	}
+	streamId := fmt.Sprintf("%s_%s_%s", deviceId, channelId, ssrc)
+	rtpPort, err := Media().OpenRtpServer(mediaDetail, streamId)
+	if err != nil {
+		util.ReleaseSSRC(ssrc)
+		return model.DownloadInfo{}, errors.WithMessage(err, "create rtp server fail")
+	}
+	info, err := gbsip.Download(device, mediaDetail, streamId, ssrc, channelId, rtpPort, start, end, speed)
+	if err != nil {
+		releaseStream(mediaDetail, streamId, ssrc)
+	}
+	return info, err
+}
+
+// Status 查询下载状态，下载中时根据流媒体服务统计的轨道时长计算下载进度
+func (d downloadService) Status(streamId string) (model.DownloadInfo, error) {
+	info, err := gbsip.GetDownloadInfo(streamId)
+	if err != nil {
+		return model.DownloadInfo{}, err
+	}
+	if info.Status != model.DownloadStatusDownloading {
+		return info, nil
+	}
+
+	start, err1 := time.ParseInLocation(model.GBTimeLayout, info.StartTime, time.Local)
+	end, err2 := time.ParseInLocation(model.GBTimeLayout, info.EndTime, time.Local)
+	if err1 != nil || err2 != nil || !start.Before(end) {
+		return info, nil
+	}
+
+	mediaDetail, err := Media().GetMedia(info.MediaServerId)
+	if err != nil {
+		logger.Errorf("%+v", err)
+		return info, nil
+	}
+	mediaInfo, err := Media().GetMediaInfo(info.App, streamId, mediaDetail)
+	if err != nil || !mediaInfo.Online {
+		// 设备可能还没有开始推流
+		return info, nil
+	}
+
+	var duration int64
+	for _, t := range mediaInfo.Tracks {
+		if t.Duration > duration {
+			duration = t.Duration
+		}
+	}
+	progress := float64(duration) / float64(end.Sub(start).Milliseconds())
+	// 收到设备的文件发送结束通知之前，进度不会达到100%
+	if progress > 0.99 {
+		progress = 0.99
+	}
+	if progress > info.Progress {
+		info.Progress = progress
+		gbsip.SaveDownloadInfo(info)
+	}
+	return info, nil
+}
+
+// Stop 停止下载
+func (d downloadService) Stop(streamId string) error {
+	info, err := gbsip.GetDownloadInfo(streamId)
+	if err != nil {
+		return err
+	}
+	if info.Status != model.DownloadStatusDownloading {
+		return nil
+	}
+
+	tx, err := gbsip.StreamSession(streamId)
+	if err != nil {
+		return err
+	}
+	device, ok := Device().GetByDeviceId(tx.DeviceId)
+	if !ok {
+		return deviceNotFound
+	}
+	err = gbsip.StopPlay(streamId, tx.ChannelId, device)
+	closeStream(streamId, tx.SSRC)
+	if err != nil {
+		return err
+	}
+
+	info.Status = model.DownloadStatusStopped
+	gbsip.SaveDownloadInfo(info)
+	return nil
+}
+
+// IsDownload 判断流是否属于录像下载会话
+func (d downloadService) IsDownload(streamId string) bool {
+	info, err := gbsip.GetDownloadInfo(streamId)
+	return err == nil && info.Status == model.DownloadStatusDownloading
+}
@@ -19,9 +19,13 @@ import (
 type IMedia interface {
 	Online(config model.MediaConfig)
 	GetRtpServerInfo(stream string, mediaDetail model.MediaDetail) (model.GetRtpInfoResp, error)
-	OpenRtpServer(detail model.MediaDetail, stream string) (rtpPort int, ssrc string, err error)
+	GetMediaInfo(app, stream string, mediaDetail model.MediaDetail) (model.GetMediaInfoResp, error)
+	OpenRtpServer(detail model.MediaDetail, stream string) (rtpPort int, err error)
+	CloseRtpServer(detail model.MediaDetail, stream string) error
 	GetMedia(serverId string) (model.MediaDetail, error)
 	GetDefaultMedia() (model.MediaDetail, error)
+	StartSendRtp(detail model.MediaDetail, p model.SendRtpParam) (localPort int, err error)
+	StopSendRtp(detail model.MediaDetail, app, stream, ssrc string) error
 }
 
 type mediaService struct {
@@ -85,10 +89,37 @@ func (m *mediaService) GetRtpServerInfo(stream string, mediaDetail model.MediaDe
 	return resp, err
 }
 
-// OpenRtpServer 创建rtp服务
-func (m *mediaService) OpenRtpServer(detail model.MediaDetail, stream string) (rtpPort int, ssrc string, err error) {
-	ssrc = util2.GetSSRC(util2.RealTime)
+// GetMediaInfo 从流媒体服务获取流的统计信息
+func (m *mediaService) GetMediaInfo(app, stream string, mediaDetail model.MediaDetail) (model.GetMediaInfoResp, error) {
+	params := map[string]interface{}{
+		"secret": mediaDetail.Secret,
+		"schema": "rtsp",
+		"vhost":  "__defaultVhost__",
+		"app":    app,
+		"stream": stream,
+	}
+
+	url := fmt.Sprintf(constant.MediaGetMediaInfoUrl, mediaDetail.Ip, mediaDetail.HttpPort)
+
+	result, err := util2.SendPost(url, params)
+	if err != nil {
+		return model.GetMediaInfoResp{}, errors.WithMessage(err, "query media info fail")
+	}
+
+	resp := model.GetMediaInfoResp{}
+	err = json.Unmarshal([]byte(result), &resp)
+	if err != nil {
+		return model.GetMediaInfoResp{}, errors.WithMessage(err, "unmarshal data to struct fail")
+	}
+	if resp.Code != model.RespondSuccess {
+		return model.GetMediaInfoResp{}, errors.New(resp.Msg)
+	}
 
+	return resp, nil
+}
+
+// OpenRtpServer 创建rtp服务
+func (m *mediaService) OpenRtpServer(detail model.MediaDetail, stream string) (rtpPort int, err error) {
 	url := fmt.Sprintf(constant.MediaCreateRtpApiUrl, detail.Ip, detail.HttpPort)
 	params := map[string]interface{}{
 		"secret":     detail.Secret,
@@ -98,26 +129,115 @@ func (m *mediaService) OpenRtpServer(detail model.MediaDetail, stream string) (r
 	}
 	body, err := util2.SendPost(url, params)
 	if err != nil {
-		return 0, "", errors.WithMessage(err, "create rtp server fail")
+		return 0, errors.WithMessage(err, "create rtp server fail")
 	}
 
 	resp := model.CreateRtpServerResp{}
 	err = json.Unmarshal([]byte(body), &resp)
 	if err != nil {
-		return 0, "", errors.WithMessage(err, "unmarshal data to struct fail")
+		return 0, errors.WithMessage(err, "unmarshal data to struct fail")
 	}
 
 	if resp.Code != model.RespondSuccess {
-		return 0, "", errors.New(resp.Msg)
+		return 0, errors.New(resp.Msg)
 	}
 
 	rtpPort = resp.Port
 	return
 }
 
+// CloseRtpServer 关闭rtp服务，会话结束后释放流媒体上的收流端口
+func (m *mediaService) CloseRtpServer(detail model.MediaDetail, stream string) error {
+	url := fmt.Sprintf(constant.MediaCloseRtpApiUrl, detail.Ip, detail.HttpPort)
+	params := map[string]interface{}{
+		"secret":    detail.Secret,
+		"stream_id": stream,
+	}
+	body, err := util2.SendPost(url, params)
+	if err != nil {
+		return errors.WithMessage(err, "close rtp server fail")
+	}
+
+	resp := model.CodeMessage{}
+	if err = json.Unmarshal([]byte(body), &resp); err != nil {
+		return errors.WithMessage(err, "unmarshal data to struct fail")
+	}
+	if resp.Code != model.RespondSuccess {
+		return errors.New(resp.Msg)
+	}
+	return nil
+}
+
+// StartSendRtp 让流媒体将流以rtp的方式推送到指定地址，udp为false时主动以tcp连接目标地址，被动推流时等待对方连接返回的端口
+func (m *mediaService) StartSendRtp(detail model.MediaDetail, p model.SendRtpParam) (localPort int, err error) {
+	url := fmt.Sprintf(constant.MediaStartSendRtpUrl, detail.Ip, detail.HttpPort)
+	params := map[string]interface{}{
+		"secret": detail.Secret,
+		"vhost":  "__defaultVhost__",
+		"app":    p.App,
+		"stream": p.Stream,
+		"ssrc":   p.SSRC,
+	}
+	if p.Passive {
+		url = fmt.Sprintf(constant.MediaStartSendRtpPassiveUrl, detail.Ip, detail.HttpPort)
+	} else {
+		params["dst_url"] = p.DstIp
+		params["dst_port"] = p.DstPort
+		params["is_udp"] = p.UDP
+	}
+	if p.OnlyAudio {
+		params["only_audio"] = true
+		params["use_ps"] = 0
+	}
+	if p.PayloadType > 0 {
+		params["pt"] = p.PayloadType
+	}
+	if p.RecvStream != "" {
+		params["recv_stream_id"] = p.RecvStream
+	}
+	body, err := util2.SendPost(url, params)
+	if err != nil {
+		return 0, errors.WithMessage(err, "start send rtp fail")
+	}
+
+	resp := model.StartSendRtpResp{}
+	if err = json.Unmarshal([]byte(body), &resp); err != nil {
+		return 0, errors.WithMessage(err, "unmarshal data to struct fail")
+	}
+	if resp.Code != model.RespondSuccess {
+		return 0, errors.New(resp.Msg)
+	}
+	return resp.LocalPort, nil
+}
+
+// StopSendRtp 停止推送rtp
+func (m *mediaService) StopSendRtp(detail model.MediaDetail, app, stream, ssrc string) error {
+	url := fmt.Sprintf(constant.MediaStopSendRtpUrl, detail.Ip, detail.HttpPort)
+	params := map[string]interface{}{
+		"secret": detail.Secret,
+		"vhost":  "__defaultVhost__",
+		"app":    app,
+		"stream": stream,
+		"ssrc":   ssrc,
+	}
+	body, err := util2.SendPost(url, params)
+	if err != nil {
+		return errors.WithMessage(err, "stop send rtp fail")
+	}
+
+	resp := model.CodeMessage{}
+	if err = json.Unmarshal([]byte(body), &resp); err != nil {
+		return errors.WithMessage(err, "unmarshal data to struct fail")
+	}
+	if resp.Code != model.RespondSuccess {
+		return errors.New(resp.Msg)
+	}
+	return nil
+}
+
 // GetMedia 从缓存里面获取一个流媒体明细
 func (m *mediaService) GetMedia(serverId string) (model.MediaDetail, error) {
-	j, err := cache.Get(serverId)
+	j, err := cache.Get(fmt.Sprintf("%s:%s", constant.MediaServerPrefix, serverId))
 	if err != nil {
 		return model.MediaDetail{}, errors.WithMessage(err, "GetMedia function happen error")
 	}
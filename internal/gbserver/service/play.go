@@ -3,17 +3,22 @@ package service
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
 
 	"github.com/inysc/GB28181/internal/gbserver/storage/cache"
 	"github.com/inysc/GB28181/internal/pkg/gbsip"
 	"github.com/inysc/GB28181/internal/pkg/logger"
 	"github.com/inysc/GB28181/internal/pkg/model"
 	"github.com/inysc/GB28181/internal/pkg/model/constant"
+	"github.com/inysc/GB28181/internal/pkg/util"
 	"github.com/pkg/errors"
 )
 
 type IPlay interface {
 	Play(deviceId, channelId string) (model.StreamInfo, error)
+	Stop(deviceId, channelId string) error
+	StopStream(streamId string) error
+	Bye(streamId string) error
 }
 
 type playService struct{}
@@ -22,6 +27,40 @@ var (
 	deviceNotFound = errors.New("device not found")
 )
 
+// 实时点播的观看者计数，多个观看者共用同一路流，计数归零时才真正结束点播
+type viewerCounter struct {
+	mux    sync.Mutex
+	counts map[string]int
+}
+
+var viewers = &viewerCounter{counts: make(map[string]int)}
+
+func (v *viewerCounter) add(streamId string) int {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	v.counts[streamId]++
+	return v.counts[streamId]
+}
+
+// 减少一个观看者，返回剩余的观看者数量
+func (v *viewerCounter) done(streamId string) int {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	n := v.counts[streamId] - 1
+	if n <= 0 {
+		delete(v.counts, streamId)
+		return 0
+	}
+	v.counts[streamId] = n
+	return n
+}
+
+func (v *viewerCounter) reset(streamId string) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	delete(v.counts, streamId)
+}
+
 func Play() IPlay {
 	return playService{}
 }
@@ -57,6 +96,7 @@ func (p playService) Play(deviceId, channelId string) (model.StreamInfo, error)
 
 		if rtpServerInfo.Code == model.RespondSuccess {
 			if rtpServerInfo.Exist == true {
+				viewers.add(streamId)
 				return streamInfo, nil
 			} else {
 				streamInfo = model.StreamInfo{}
@@ -66,18 +106,90 @@ func (p playService) Play(deviceId, channelId string) (model.StreamInfo, error)
 			logger.Errorf("media api response: %+v\n", rtpServerInfo.Msg)
 			streamInfo = model.StreamInfo{}
 		}
+		// 旧会话的收流已经失效，归还它占用的ssrc后重新点播
+		if tx, err := gbsip.StreamSession(streamId); err == nil {
+			util.ReleaseSSRC(tx.SSRC)
+		}
 	}
 
 	// 判断流信息对象是否是默认值，是默认值的话代表没有这个流信息或者rtp服务连接失败
 	if streamInfo == (model.StreamInfo{}) {
-		rtpPort, ssrc, err := Media().OpenRtpServer(mediaDetail, streamId)
-		streamInfo.Ssrc = ssrc
-
+		ssrc, err := util.GetSSRC(util.RealTime)
+		if err != nil {
+			return model.StreamInfo{}, err
+		}
+		rtpPort, err := Media().OpenRtpServer(mediaDetail, streamId)
 		if err != nil {
+			util.ReleaseSSRC(ssrc)
 			return model.StreamInfo{}, errors.WithMessage(err, "create rtp server fail")
 		}
-		return gbsip.Play(device, mediaDetail, streamId, ssrc, channelId, rtpPort)
+		streamInfo, err = gbsip.Play(device, mediaDetail, streamId, ssrc, channelId, rtpPort)
+		if err != nil {
+			releaseStream(mediaDetail, streamId, ssrc)
+			return model.StreamInfo{}, err
+		}
+		// 重新点播的流，之前的计数已经失效
+		viewers.reset(streamId)
+		viewers.add(streamId)
+		return streamInfo, nil
 	}
 
 	return model.StreamInfo{}, nil
 }
+
+// Stop 观看者停止观看，所有观看者都停止后向设备发送BYE结束点播
+func (p playService) Stop(deviceId, channelId string) error {
+	streamId := fmt.Sprintf("%s_%s", deviceId, channelId)
+	if n := viewers.done(streamId); n > 0 {
+		logger.Debugf("{%s}还有%d个观看者，不结束点播", streamId, n)
+		return nil
+	}
+	return p.StopStream(streamId)
+}
+
+// StopStream 不论观看者数量，直接结束流对应的会话
+func (p playService) StopStream(streamId string) error {
+	viewers.reset(streamId)
+	tx, err := gbsip.StreamSession(streamId)
+	if err != nil {
+		return err
+	}
+	device, ok := Device().GetByDeviceId(tx.DeviceId)
+	if !ok {
+		return deviceNotFound
+	}
+	err = gbsip.StopPlay(streamId, tx.ChannelId, device)
+	closeStream(streamId, tx.SSRC)
+	return err
+}
+
+// Bye 设备主动结束了会话，清理会话信息并释放收流资源
+func (p playService) Bye(streamId string) error {
+	viewers.reset(streamId)
+	tx, err := gbsip.StreamSession(streamId)
+	if err != nil {
+		return err
+	}
+	err = gbsip.ClearStreamSession(streamId)
+	closeStream(streamId, tx.SSRC)
+	return err
+}
+
+// 会话结束后关闭默认流媒体上的rtp服务，点播、回放和下载的rtp服务都创建在默认流媒体上
+func closeStream(streamId, ssrc string) {
+	detail, err := Media().GetDefaultMedia()
+	if err != nil {
+		util.ReleaseSSRC(ssrc)
+		logger.Errorf("{%s}获取流媒体失败，无法关闭rtp服务，%s", streamId, err)
+		return
+	}
+	releaseStream(detail, streamId, ssrc)
+}
+
+// 归还ssrc并关闭rtp服务，会话结束或设备拒绝点播时调用
+func releaseStream(detail model.MediaDetail, streamId, ssrc string) {
+	util.ReleaseSSRC(ssrc)
+	if err := Media().CloseRtpServer(detail, streamId); err != nil {
+		logger.Warnf("{%s}关闭rtp服务失败，%s", streamId, err)
+	}
+}
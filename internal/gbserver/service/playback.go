@@ -0,0 +1,80 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/gbsip"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/util"
+	"github.com/pkg/errors"
+)
+
+type IPlayback interface {
+	Start(deviceId, channelId string, start, end time.Time) (model.StreamInfo, error)
+	Control(ctl model.PlaybackControl) error
+	Stop(streamId string) error
+}
+
+type playbackService struct{}
+
+func Playback() IPlayback {
+	return playbackService{}
+}
+
+// Start 开始回放，每次回放都会建立新的会话，以便各自独立控制
+func (p playbackService) Start(deviceId, channelId string, start, end time.Time) (model.StreamInfo, error) {
+	device, ok := Device().GetByDeviceId(deviceId)
+	if !ok {
+		return model.StreamInfo{}, deviceNotFound
+	}
+
+	mediaDetail, err := Media().GetDefaultMedia()
+	if err != nil {
+		return model.StreamInfo{}, err
+	}
+
+	ssrc, err := util.GetSSRC(util.History)
+	if err != nil {
+		return model.StreamInfo{}, err
+	}
+	streamId := fmt.Sprintf("%s_%s_%s", deviceId, channelId, ssrc)
+	rtpPort, err := Media().OpenRtpServer(mediaDetail, streamId)
+	if err != nil {
+		util.ReleaseSSRC(ssrc)
+		return model.StreamInfo{}, errors.WithMessage(err, "create rtp server fail")
+	}
+	info, err := gbsip.Playback(device, mediaDetail, streamId, ssrc, channelId, rtpPort, start, end)
+	if err != nil {
+		releaseStream(mediaDetail, streamId, ssrc)
+	}
+	return info, err
+}
+
+// Control 控制回放
+func (p playbackService) Control(ctl model.PlaybackControl) error {
+	tx, err := gbsip.StreamSession(ctl.StreamId)
+	if err != nil {
+		return err
+	}
+	device, ok := Device().GetByDeviceId(tx.DeviceId)
+	if !ok {
+		return deviceNotFound
+	}
+	return gbsip.PlaybackControl(device, tx, ctl)
+}
+
+// Stop 停止回放
+func (p playbackService) Stop(streamId string) error {
+	tx, err := gbsip.StreamSession(streamId)
+	if err != nil {
+		return err
+	}
+	device, ok := Device().GetByDeviceId(tx.DeviceId)
+	if !ok {
+		return deviceNotFound
+	}
+	err = gbsip.StopPlay(streamId, tx.ChannelId, device)
+	closeStream(streamId, tx.SSRC)
+	return err
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/pkg/model"
+)
+
+type IPosition interface {
+	Track(q model.PositionQuery) ([]model.MobilePosition, error)
+}
+
+type positionService struct {
+	store storage.Factory
+}
+
+var pService = new(positionService)
+
+// 单次查询最多返回的轨迹点数
+const maxTrackPoints = 10000
+
+func Position() IPosition {
+	return pService
+}
+
+// Track 查询设备在一段时间内的轨迹，按定位时间升序排列，超过最大点数时只返回前面的部分
+func (p *positionService) Track(q model.PositionQuery) ([]model.MobilePosition, error) {
+	q.Limit = maxTrackPoints
+	return p.store.Position().List(q)
+}
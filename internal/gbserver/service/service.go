@@ -7,8 +7,14 @@ import (
 type Service interface {
 	Devices() IDevice
 	Play() IPlay
+	Playback() IPlayback
+	Download() IDownload
 	Media() IMedia
 	Channel() IChannel
+	Alarm() IAlarm
+	Position() IPosition
+	Cascade() ICascade
+	Broadcast() IBroadcast
 }
 
 type service struct {
@@ -29,6 +35,14 @@ func (s *service) Play() IPlay {
 	return Play()
 }
 
+func (s *service) Playback() IPlayback {
+	return Playback()
+}
+
+func (s *service) Download() IDownload {
+	return Download()
+}
+
 func (s *service) Media() IMedia {
 	return Media()
 }
@@ -37,8 +51,28 @@ func (s *service) Channel() IChannel {
 	return Channel()
 }
 
+func (s *service) Alarm() IAlarm {
+	return Alarm()
+}
+
+func (s *service) Position() IPosition {
+	return Position()
+}
+
+func (s *service) Cascade() ICascade {
+	return Cascade()
+}
+
+func (s *service) Broadcast() IBroadcast {
+	return Broadcast()
+}
+
 func InitService(factory storage.Factory) {
 	dService.store = factory
 	mService.store = factory
 	cService.store = factory
+	aService.store = factory
+	pService.store = factory
+	csService.store = factory
+	bService.store = factory
 }
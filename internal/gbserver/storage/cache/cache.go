@@ -1,19 +1,43 @@
 package cache
 
-import "github.com/inysc/GB28181/internal/pkg/option"
+import (
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/option"
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound 缓存中没有该key或已过期
+var ErrNotFound = errors.New("cache key not found")
 
 // Cache cache interface
 type Cache interface {
+	// Get 返回值的json字符串，不存在时返回空字符串和ErrNotFound
 	Get(key string) (any, error)
+	// Set 保存值，覆盖已有的值时保留原来的过期时间
 	Set(key string, val any)
+	// SetEx 保存值，超过ttl后过期
+	SetEx(key string, val any, ttl time.Duration)
 	Del(key string) error
+	// GetCeq 返回全局递增的sip请求序号
 	GetCeq() (int64, error)
 }
 
 var cache Cache
 
-func InitCache(opt *option.RedisOptions) {
-	cache = newRedis(opt)
+// InitCache 根据配置的缓存类型初始化缓存，redis只有在使用时才需要配置
+func InitCache(opt *option.CacheOptions, redisOpt *option.RedisOptions) {
+	switch opt.Type {
+	case option.CacheMemory:
+		cache = newMemory()
+		logger.Warn("内存缓存不会持久化，服务重启后无法恢复设备订阅，需要恢复订阅时请使用redis缓存")
+	case option.CacheRedis, "":
+		cache = newRedis(redisOpt)
+	default:
+		panic(errors.Errorf("不支持的缓存类型%s", opt.Type))
+	}
+	logger.Infof("使用%s缓存", opt.Type)
 }
 
 // Get get value in cache by key
@@ -25,6 +49,10 @@ func Set(key string, val any) {
 	cache.Set(key, val)
 }
 
+func SetEx(key string, val any, ttl time.Duration) {
+	cache.SetEx(key, val, ttl)
+}
+
 func Del(key string) error {
 	return cache.Del(key)
 }
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 清理过期缓存的间隔
+const memoryCleanInterval = time.Minute
+
+type memoryItem struct {
+	val string
+	// 为零时不过期
+	expire time.Time
+}
+
+func (m memoryItem) expired(now time.Time) bool {
+	return !m.expire.IsZero() && now.After(m.expire)
+}
+
+// 进程内缓存，单机部署和单元测试时代替redis，与redis一样以json字符串保存值
+type memoryCache struct {
+	mux   sync.RWMutex
+	items map[string]memoryItem
+	ceq   int64
+}
+
+func newMemory() *memoryCache {
+	m := &memoryCache{items: make(map[string]memoryItem)}
+	go m.clean()
+	return m
+}
+
+func (m *memoryCache) Get(key string) (any, error) {
+	m.mux.RLock()
+	item, ok := m.items[key]
+	m.mux.RUnlock()
+	if !ok || item.expired(time.Now()) {
+		return "", ErrNotFound
+	}
+	return item.val, nil
+}
+
+// Set 与redis的KEEPTTL一致，覆盖已有的值时保留原来的过期时间
+func (m *memoryCache) Set(key string, val any) {
+	b, _ := json.MarshalIndent(val, "", "  ")
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	item := m.items[key]
+	if item.expired(time.Now()) {
+		item.expire = time.Time{}
+	}
+	item.val = string(b)
+	m.items[key] = item
+}
+
+func (m *memoryCache) SetEx(key string, val any, ttl time.Duration) {
+	b, _ := json.MarshalIndent(val, "", "  ")
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.items[key] = memoryItem{val: string(b), expire: time.Now().Add(ttl)}
+}
+
+func (m *memoryCache) Del(key string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.items, key)
+	return nil
+}
+
+func (m *memoryCache) GetCeq() (int64, error) {
+	return atomic.AddInt64(&m.ceq, 1), nil
+}
+
+// 定期删除过期的缓存，避免不再读取的key一直占用内存
+func (m *memoryCache) clean() {
+	ticker := time.NewTicker(memoryCleanInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		m.mux.Lock()
+		for key, item := range m.items {
+			if item.expired(now) {
+				delete(m.items, key)
+			}
+		}
+		m.mux.Unlock()
+	}
+}
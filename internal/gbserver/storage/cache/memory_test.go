@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestMemory(t *testing.T) {
+	convey.Convey("TestMemory", t, func() {
+		m := newMemory()
+		_, err := m.Get("none")
+		convey.So(err, convey.ShouldEqual, ErrNotFound)
+
+		m.Set("key", map[string]int{"a": 1})
+		val, err := m.Get("key")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(val, convey.ShouldEqual, "{\n  \"a\": 1\n}")
+
+		// 覆盖时保留过期时间
+		m.SetEx("ttl", 1, 20*time.Millisecond)
+		m.Set("ttl", 2)
+		val, _ = m.Get("ttl")
+		convey.So(val, convey.ShouldEqual, "2")
+		time.Sleep(30 * time.Millisecond)
+		_, err = m.Get("ttl")
+		convey.So(err, convey.ShouldEqual, ErrNotFound)
+
+		convey.So(m.Del("key"), convey.ShouldBeNil)
+		_, err = m.Get("key")
+		convey.So(err, convey.ShouldEqual, ErrNotFound)
+	})
+}
+
+func TestMemoryCeq(t *testing.T) {
+	convey.Convey("TestMemoryCeq", t, func() {
+		m := newMemory()
+		var wg sync.WaitGroup
+		var mux sync.Mutex
+		seen := make(map[int64]bool)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ceq, _ := m.GetCeq()
+				mux.Lock()
+				seen[ceq] = true
+				mux.Unlock()
+			}()
+		}
+		wg.Wait()
+		convey.So(len(seen), convey.ShouldEqual, 100)
+		convey.So(seen[1] && seen[100], convey.ShouldBeTrue)
+	})
+}
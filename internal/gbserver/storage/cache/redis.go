@@ -55,6 +55,9 @@ func newRedis(opt *option.RedisOptions) *redisClient {
 
 func (r *redisClient) Get(key string) (any, error) {
 	result, err := r.rdb.Get(context.Background(), key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
 	if err != nil {
 		logger.Error(err)
 	}
@@ -68,6 +71,13 @@ func (r *redisClient) Set(key string, val any) {
 	}
 }
 
+func (r *redisClient) SetEx(key string, val any, ttl time.Duration) {
+	b, _ := json.MarshalIndent(val, "", "  ")
+	if err := r.rdb.Set(context.Background(), key, b, ttl).Err(); err != nil {
+		logger.Error(err)
+	}
+}
+
 func (r *redisClient) Del(key string) error {
 	_, err := r.rdb.Del(context.Background(), key).Result()
 	if err != nil {
@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage/sqlstore"
+	"github.com/inysc/GB28181/internal/pkg/option"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// New 根据PostgreSQL选项去构建gorm对象
+func New(opts *option.PostgresOptions) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		opts.Host,
+		opts.Port,
+		opts.Username,
+		opts.Password,
+		opts.Database,
+		opts.SSLMode)
+	db, err := gorm.Open(postgres.Open(dsn), sqlstore.Config())
+	if err != nil {
+		return nil, err
+	}
+
+	err = sqlstore.SetPool(db, opts.MaxOpenConnections, opts.MaxIdleConnections, time.Duration(opts.MaxConnectionLifeTime)*time.Second)
+	return db, err
+}
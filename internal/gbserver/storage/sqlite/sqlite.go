@@ -0,0 +1,22 @@
+package sqlite
+
+import (
+	"github.com/glebarez/sqlite"
+	"github.com/inysc/GB28181/internal/gbserver/storage/sqlstore"
+	"github.com/inysc/GB28181/internal/pkg/option"
+	"gorm.io/gorm"
+)
+
+// New 根据SQLite选项去构建gorm对象，使用纯Go实现的驱动，不依赖cgo
+func New(opts *option.SQLiteOptions) (*gorm.DB, error) {
+	// 写入冲突时等待而不是立即返回database is locked
+	dsn := opts.Path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := gorm.Open(sqlite.Open(dsn), sqlstore.Config())
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite同一时间只允许一个写连接
+	err = sqlstore.SetPool(db, 1, 1, 0)
+	return db, err
+}
@@ -0,0 +1,66 @@
+package sqlstore
+
+import (
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type alarmStorage struct {
+	db *gorm.DB
+}
+
+func newAlarmStorage(ds *datastore) *alarmStorage {
+	return &alarmStorage{db: ds.db}
+}
+
+func (a alarmStorage) Save(entity model.Alarm) error {
+	return a.db.Create(&entity).Error
+}
+
+func (a alarmStorage) List(q model.AlarmQuery) ([]model.Alarm, int64, error) {
+	db := a.db.Model(&model.Alarm{})
+	if q.DeviceId != "" {
+		db = db.Where(map[string]any{"deviceId": q.DeviceId})
+	}
+	if q.ChannelId != "" {
+		db = db.Where(map[string]any{"channelId": q.ChannelId})
+	}
+	if q.AlarmPriority != 0 {
+		db = db.Where(map[string]any{"alarmPriority": q.AlarmPriority})
+	}
+	if q.AlarmMethod != 0 {
+		db = db.Where(map[string]any{"alarmMethod": q.AlarmMethod})
+	}
+	if q.AlarmType != 0 {
+		db = db.Where(map[string]any{"alarmType": q.AlarmType})
+	}
+	if !q.StartTime.IsZero() {
+		db = db.Where(clause.Gte{Column: "alarmTime", Value: q.StartTime})
+	}
+	if !q.EndTime.IsZero() {
+		db = db.Where(clause.Lte{Column: "alarmTime", Value: q.EndTime})
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var list []model.Alarm
+	err := db.Order(clause.OrderByColumn{Column: clause.Column{Name: "alarmTime"}, Desc: true}).Offset((q.Page - 1) * q.Size).Limit(q.Size).Find(&list).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+func (a alarmStorage) Reset(deviceId, channelId string, resetTime time.Time) error {
+	db := a.db.Model(&model.Alarm{}).Where(map[string]any{"deviceId": deviceId, "reset": false})
+	if channelId != "" {
+		db = db.Where(map[string]any{"channelId": channelId})
+	}
+	return db.Updates(map[string]any{"reset": true, "resetTime": resetTime}).Error
+}
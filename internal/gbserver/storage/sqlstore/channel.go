@@ -0,0 +1,227 @@
+package sqlstore
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 查询通道时使用的列和条件
+var (
+	owner      = clause.Column{Name: "ownerId"}
+	treeParent = clause.Column{Name: "treeParentId"}
+	// 通道树中不展示设备把自身上报的目录项，以及找不到所属设备的目录项
+	treeVisible = clause.And(
+		clause.Neq{Column: clause.Column{Name: "deviceId"}, Value: owner},
+		clause.Neq{Column: owner, Value: ""},
+	)
+)
+
+type channelStorage struct {
+	db *gorm.DB
+}
+
+func newChannelStorage(ds *datastore) *channelStorage {
+	return &channelStorage{db: ds.db}
+}
+
+// SaveBatch 以设备上报的完整目录为准同步通道，已存在的通道更新，新通道新增，不再上报的通道删除
+func (c channelStorage) SaveBatch(channels []model.Channel, deviceId string) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		var exists []model.Channel
+		if err := tx.Where(map[string]any{"ownerId": deviceId}).Find(&exists).Error; err != nil {
+			return err
+		}
+		old := make(map[string]model.Channel, len(exists))
+		for _, e := range exists {
+			old[e.DeviceId] = e
+		}
+
+		for _, ch := range channels {
+			ch.OwnerId = deviceId
+			ch.TreeParentId = ch.TreeParent()
+			if e, ok := old[ch.DeviceId]; ok {
+				ch.ID = e.ID
+				ch.CreatedAt = e.CreatedAt
+				ch.Longitude = e.Longitude
+				ch.Latitude = e.Latitude
+				ch.PositionTime = e.PositionTime
+				delete(old, ch.DeviceId)
+			}
+			if err := tx.Save(&ch).Error; err != nil {
+				return err
+			}
+		}
+
+		var removed []uint
+		for _, e := range old {
+			removed = append(removed, e.ID)
+		}
+		if len(removed) > 0 {
+			if err := tx.Delete(&model.Channel{}, removed).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (c channelStorage) List(deviceId string) ([]model.Channel, error) {
+	var list []model.Channel
+	err := c.db.Model(&model.Channel{}).Where(map[string]any{"ownerId": deviceId}).Find(&list).Error
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c channelStorage) ListAll() ([]model.Channel, error) {
+	var list []model.Channel
+	if err := c.db.Model(&model.Channel{}).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c channelStorage) Get(deviceId, channelId string) (model.Channel, bool) {
+	var channel model.Channel
+	if err := c.db.Where(map[string]any{"ownerId": deviceId, "deviceId": channelId}).First(&channel).Error; err != nil {
+		return model.Channel{}, false
+	}
+	return channel, true
+}
+
+func (c channelStorage) Owners(channelId string) ([]string, error) {
+	var owners []string
+	err := c.db.Model(&model.Channel{}).Where(map[string]any{"deviceId": channelId}).Where(clause.Neq{Column: owner, Value: ""}).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}}).Pluck("ownerId", &owners).Error
+	if err != nil {
+		return nil, err
+	}
+	return owners, nil
+}
+
+func (c channelStorage) Save(entity model.Channel) error {
+	entity.TreeParentId = entity.TreeParent()
+	var old model.Channel
+	err := c.db.Where(map[string]any{"ownerId": entity.OwnerId, "deviceId": entity.DeviceId}).First(&old).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.db.Create(&entity).Error
+	}
+	if err != nil {
+		return err
+	}
+	entity.ID = old.ID
+	entity.CreatedAt = old.CreatedAt
+	entity.Longitude = old.Longitude
+	entity.Latitude = old.Latitude
+	entity.PositionTime = old.PositionTime
+	return c.db.Save(&entity).Error
+}
+
+func (c channelStorage) Delete(deviceId, channelId string) error {
+	return c.db.Where(map[string]any{"ownerId": deviceId, "deviceId": channelId}).Delete(&model.Channel{}).Error
+}
+
+func (c channelStorage) UpdateStatus(deviceId, channelId, status string) error {
+	return c.db.Model(&model.Channel{}).
+		Where(map[string]any{"ownerId": deviceId, "deviceId": channelId}).
+		Update("status", status).Error
+}
+
+func (c channelStorage) Children(parentId string) ([]model.Channel, error) {
+	var list []model.Channel
+	db := c.db.Model(&model.Channel{}).Where(treeVisible)
+	if parentId == "" {
+		db = db.Where("? IS NULL OR ? = ''", treeParent, treeParent)
+	} else {
+		db = db.Where(map[string]any{"treeParentId": parentId})
+	}
+	if err := db.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// TreeParents 返回通道树中以prefix开头的父节点id，用于补全目录中没有上报的行政区划
+func (c channelStorage) TreeParents(prefix string) ([]string, error) {
+	var ids []string
+	err := c.db.Model(&model.Channel{}).Distinct("treeParentId").
+		Where("? LIKE ?", treeParent, prefix+"%").Pluck("treeParentId", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// WithChildren 返回ids中在通道树中有子节点的id
+func (c channelStorage) WithChildren(ids []string) ([]string, error) {
+	var list []string
+	if len(ids) == 0 {
+		return list, nil
+	}
+	err := c.db.Model(&model.Channel{}).Distinct("treeParentId").
+		Where(map[string]any{"treeParentId": ids}).Pluck("treeParentId", &list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Search 按名称模糊搜索目录项，不区分大小写
+func (c channelStorage) Search(name string) ([]model.Channel, error) {
+	var list []model.Channel
+	err := c.db.Model(&model.Channel{}).Where(treeVisible).
+		Where("LOWER(?) LIKE ?", clause.Column{Name: "name"}, "%"+strings.ToLower(name)+"%").
+		Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c channelStorage) SaveChange(entity model.ChannelChange) error {
+	return c.db.Create(&entity).Error
+}
+
+func (c channelStorage) Changes(q model.ChannelChangeQuery) ([]model.ChannelChange, int64, error) {
+	db := c.db.Model(&model.ChannelChange{})
+	if q.DeviceId != "" {
+		db = db.Where(map[string]any{"deviceId": q.DeviceId})
+	}
+	if q.ChannelId != "" {
+		db = db.Where(map[string]any{"channelId": q.ChannelId})
+	}
+	if q.Event != "" {
+		db = db.Where(map[string]any{"event": q.Event})
+	}
+	if !q.StartTime.IsZero() {
+		db = db.Where(clause.Gte{Column: "time", Value: q.StartTime})
+	}
+	if !q.EndTime.IsZero() {
+		db = db.Where(clause.Lte{Column: "time", Value: q.EndTime})
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var list []model.ChannelChange
+	err := db.Order(clause.OrderByColumn{Column: clause.Column{Name: "time"}, Desc: true}).Offset((q.Page - 1) * q.Size).Limit(q.Size).Find(&list).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
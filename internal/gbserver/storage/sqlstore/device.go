@@ -1,4 +1,4 @@
-package mysql
+package sqlstore
 
 import (
 	"time"
@@ -45,7 +45,7 @@ func (d *devices) GetById(id uint) (model.Device, error) {
 
 func (d *devices) GetByDeviceId(deviceId string) (model.Device, bool) {
 	var device model.Device
-	if d.db.Where("deviceId = ?", deviceId).Find(&device).RowsAffected == 0 {
+	if d.db.Where(map[string]any{"deviceId": deviceId}).Find(&device).RowsAffected == 0 {
 		return device, false
 	}
 	return device, true
@@ -58,7 +58,7 @@ func (d *devices) Keepalive(id uint) error {
 }
 
 func (d *devices) UpdateDeviceInfo(entity model.Device) error {
-	return d.db.Model(&model.Device{}).Where("deviceId = ?", entity.DeviceId).Updates(model.Device{
+	return d.db.Model(&model.Device{}).Where(map[string]any{"deviceId": entity.DeviceId}).Updates(model.Device{
 		Name:         entity.Name,
 		Manufacturer: entity.Manufacturer,
 		Model:        entity.Model,
@@ -67,7 +67,7 @@ func (d *devices) UpdateDeviceInfo(entity model.Device) error {
 }
 
 func (d *devices) UpdateBasicConfig(entity model.Device) error {
-	return d.db.Model(&model.Device{}).Where("deviceId = ?", entity.DeviceId).Updates(model.Device{
+	return d.db.Model(&model.Device{}).Where(map[string]any{"deviceId": entity.DeviceId}).Updates(model.Device{
 		Name:              entity.Name,
 		Expires:           entity.Expires,
 		HeartBeatInterval: entity.HeartBeatInterval,
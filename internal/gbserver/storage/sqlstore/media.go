@@ -1,4 +1,4 @@
-package mysql
+package sqlstore
 
 import (
 	"github.com/inysc/GB28181/internal/pkg/model"
@@ -19,6 +19,6 @@ func (m *mediaStorage) Save(config model.MediaDetail) error {
 
 func (m *mediaStorage) GetMediaByID(id string) (model.MediaDetail, error) {
 	detail := model.MediaDetail{}
-	err := m.db.Where("id = ?", id).First(&detail).Error
+	err := m.db.Where(map[string]any{"id": id}).First(&detail).Error
 	return detail, err
 }
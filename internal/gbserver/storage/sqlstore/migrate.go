@@ -0,0 +1,150 @@
+package sqlstore
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// 记录已执行迁移的表，每执行一个版本插入一行，回滚时删除
+type schemaVersion struct {
+	Version   int       `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:100"`
+	AppliedAt time.Time `gorm:"column:appliedAt"`
+}
+
+func (schemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// 一个版本的表结构变更，up和down与版本记录在同一个事务中执行。
+// 支持事务性DDL的数据库失败时会整体回滚，MySQL的DDL会隐式提交，只有版本记录会回滚，
+// 因此up和down都需要可以重复执行
+type migration struct {
+	version int
+	name    string
+	up      func(tx *gorm.DB) error
+	down    func(tx *gorm.DB) error
+}
+
+// MigrationStatus 迁移版本的执行情况，AppliedAt为空表示尚未执行
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// LatestVersion 程序需要的表结构版本
+func LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// Version 数据库当前的表结构版本，从未迁移过时为0
+func Version(db *gorm.DB) (int, error) {
+	if err := db.AutoMigrate(schemaVersion{}); err != nil {
+		return 0, errors.WithMessage(err, "create schema_version table fail")
+	}
+	var version int
+	err := db.Model(schemaVersion{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, errors.WithMessage(err, "query schema version fail")
+}
+
+// Migrate 将表结构升级到最新版本
+func Migrate(db *gorm.DB) error {
+	return MigrateTo(db, LatestVersion())
+}
+
+// MigrateTo 将表结构升级或回滚到指定版本，0表示回滚全部迁移
+func MigrateTo(db *gorm.DB, target int) error {
+	if target < 0 || target > LatestVersion() {
+		return errors.Errorf("版本%d不存在，可选版本为0-%d", target, LatestVersion())
+	}
+	current, err := Version(db)
+	if err != nil {
+		return err
+	}
+	if current > LatestVersion() {
+		return errors.Errorf("数据库版本%d高于程序支持的版本%d", current, LatestVersion())
+	}
+
+	for _, m := range migrations {
+		if m.version > current && m.version <= target {
+			if err = apply(db, m, true); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version <= current && m.version > target {
+			if err = apply(db, m, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func apply(db *gorm.DB, m migration, up bool) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if !up {
+			if err := m.down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaVersion{Version: m.version}).Error
+		}
+		if err := m.up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaVersion{Version: m.version, Name: m.name, AppliedAt: time.Now()}).Error
+	})
+	if up {
+		return errors.WithMessagef(err, "migrate up to version %d(%s) fail", m.version, m.name)
+	}
+	return errors.WithMessagef(err, "migrate down version %d(%s) fail", m.version, m.name)
+}
+
+// CheckVersion 检查数据库的表结构版本是否与程序一致，不自动迁移时在启动前调用
+func CheckVersion(db *gorm.DB) error {
+	current, err := Version(db)
+	if err != nil {
+		return err
+	}
+	if current != LatestVersion() {
+		return errors.Errorf("数据库版本%d与程序需要的版本%d不一致，请先执行migrate命令", current, LatestVersion())
+	}
+	return nil
+}
+
+// Status 列出所有迁移版本及其执行时间
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	if _, err := Version(db); err != nil {
+		return nil, err
+	}
+	var applied []schemaVersion
+	if err := db.Find(&applied).Error; err != nil {
+		return nil, errors.WithMessage(err, "query schema version fail")
+	}
+	status := make(map[int]MigrationStatus, len(migrations))
+	for _, m := range migrations {
+		status[m.version] = MigrationStatus{Version: m.version, Name: m.name}
+	}
+	// 保留数据库中存在但程序不认识的版本，便于发现用新版本程序迁移过的数据库
+	for i := range applied {
+		s, ok := status[applied[i].Version]
+		if !ok {
+			s = MigrationStatus{Version: applied[i].Version, Name: applied[i].Name}
+		}
+		s.AppliedAt = &applied[i].AppliedAt
+		status[s.Version] = s
+	}
+
+	list := make([]MigrationStatus, 0, len(status))
+	for _, s := range status {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
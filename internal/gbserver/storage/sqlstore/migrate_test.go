@@ -0,0 +1,122 @@
+package sqlstore_test
+
+import (
+	"testing"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage/sqlite"
+	"github.com/inysc/GB28181/internal/gbserver/storage/sqlstore"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/option"
+	"github.com/smartystreets/goconvey/convey"
+	"gorm.io/gorm"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := sqlite.New(&option.SQLiteOptions{Path: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	convey.Convey("TestMigrate", t, func() {
+		db := openDB(t)
+		convey.So(sqlstore.CheckVersion(db), convey.ShouldNotBeNil)
+		convey.So(sqlstore.Migrate(db), convey.ShouldBeNil)
+		convey.So(sqlstore.CheckVersion(db), convey.ShouldBeNil)
+		convey.So(db.Migrator().HasIndex(&model.Channel{}, "ParentID"), convey.ShouldBeTrue)
+
+		list, err := sqlstore.Status(db)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(list), convey.ShouldEqual, sqlstore.LatestVersion())
+		for _, s := range list {
+			convey.So(s.AppliedAt, convey.ShouldNotBeNil)
+		}
+
+		convey.So(sqlstore.MigrateTo(db, 1), convey.ShouldBeNil)
+		convey.So(db.Migrator().HasIndex(&model.Channel{}, "ParentID"), convey.ShouldBeFalse)
+		convey.So(sqlstore.MigrateTo(db, 0), convey.ShouldBeNil)
+		convey.So(db.Migrator().HasTable(&model.Device{}), convey.ShouldBeFalse)
+		version, err := sqlstore.Version(db)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(version, convey.ShouldEqual, 0)
+
+		convey.So(sqlstore.Migrate(db), convey.ShouldBeNil)
+		convey.So(sqlstore.MigrateTo(db, sqlstore.LatestVersion()+1), convey.ShouldNotBeNil)
+	})
+}
+
+// 迁移到最新版本后的表结构要包含模型中所有的列和索引，修改模型时忘记追加迁移会导致失败
+func TestSchemaMatchesModels(t *testing.T) {
+	convey.Convey("TestSchemaMatchesModels", t, func() {
+		db := openDB(t)
+		convey.So(sqlstore.MigrateTo(db, 1), convey.ShouldBeNil)
+		convey.So(db.Migrator().HasIndex(&model.Device{}, "DeviceId"), convey.ShouldBeFalse)
+		convey.So(sqlstore.Migrate(db), convey.ShouldBeNil)
+
+		models := []any{&model.Device{}, &model.MediaDetail{}, &model.Channel{}, &model.Alarm{}, &model.MobilePosition{},
+			&model.ChannelChange{}, &model.Platform{}, &model.PlatformChannel{}}
+		for _, m := range models {
+			stmt := &gorm.Statement{DB: db}
+			convey.So(stmt.Parse(m), convey.ShouldBeNil)
+			for _, column := range stmt.Schema.DBNames {
+				convey.So(db.Migrator().HasColumn(m, column), convey.ShouldBeTrue)
+			}
+			for name := range stmt.Schema.ParseIndexes() {
+				convey.So(db.Migrator().HasIndex(m, name), convey.ShouldBeTrue)
+			}
+		}
+	})
+}
+
+func TestMigrateExisting(t *testing.T) {
+	convey.Convey("TestMigrateExisting", t, func() {
+		// 以前的版本每次启动执行AutoMigrate，没有版本表和设备id索引
+		db := openDB(t)
+		convey.So(db.AutoMigrate(model.Device{}, model.Channel{}), convey.ShouldBeNil)
+		convey.So(db.Migrator().DropIndex(&model.Device{}, "DeviceId"), convey.ShouldBeNil)
+		convey.So(db.Create(&model.Device{DeviceId: "44010200491320000001"}).Error, convey.ShouldBeNil)
+
+		convey.So(sqlstore.Migrate(db), convey.ShouldBeNil)
+		convey.So(db.Migrator().HasIndex(&model.Device{}, "DeviceId"), convey.ShouldBeTrue)
+		_, ok := sqlstore.New(db).Devices().GetByDeviceId("44010200491320000001")
+		convey.So(ok, convey.ShouldBeTrue)
+
+		// 数据库已被更新版本的程序迁移过
+		convey.So(db.Exec("INSERT INTO schema_version (version, name) VALUES (?, ?)", 99, "future").Error, convey.ShouldBeNil)
+		convey.So(sqlstore.Migrate(db), convey.ShouldNotBeNil)
+		convey.So(sqlstore.CheckVersion(db), convey.ShouldNotBeNil)
+	})
+}
+
+func TestMigrateChannelOwner(t *testing.T) {
+	convey.Convey("TestMigrateChannelOwner", t, func() {
+		// 更早的版本没有ownerId列，以parentId作为通道所属的设备，父节点是虚拟组织的通道会被重复保存
+		db := openDB(t)
+		convey.So(db.Exec("CREATE TABLE devices (id integer PRIMARY KEY AUTOINCREMENT, deviceId text)").Error, convey.ShouldBeNil)
+		convey.So(db.Exec("CREATE TABLE channels (id integer PRIMARY KEY AUTOINCREMENT, deviceId text, parentId text, civilCode text)").Error,
+			convey.ShouldBeNil)
+		convey.So(db.Exec("INSERT INTO devices (deviceId) VALUES (?)", "44010200491320000001").Error, convey.ShouldBeNil)
+		convey.So(db.Exec("INSERT INTO channels (deviceId, parentId) VALUES (?, ?), (?, ?), (?, ?), (?, ?), (?, ?)",
+			"44010200492160000001", "44010200491320000001",
+			"44010200491310000001", "44010200492160000001",
+			"44010200491310000002", "44010200491320000001",
+			"44010200491310000001", "44010200492160000001",
+			"44010200491310000003", "44010200492160000009").Error, convey.ShouldBeNil)
+
+		convey.So(sqlstore.Migrate(db), convey.ShouldBeNil)
+		store := sqlstore.New(db)
+		list, err := store.Channel().List("44010200491320000001")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(list), convey.ShouldEqual, 3)
+		channel, ok := store.Channel().Get("44010200491320000001", "44010200491310000001")
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(channel.TreeParentId, convey.ShouldEqual, "44010200492160000001")
+		channel, _ = store.Channel().Get("44010200491320000001", "44010200491310000002")
+		convey.So(channel.TreeParentId, convey.ShouldEqual, "44010200491320000001")
+		// 找不到所属设备的通道保留，设备重新上报目录后可以使用
+		all, _ := store.Channel().ListAll()
+		convey.So(len(all), convey.ShouldEqual, 4)
+	})
+}
@@ -0,0 +1,206 @@
+package sqlstore
+
+import (
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 表结构的全部版本，按版本号递增排列，已发布的版本不能再修改。
+//
+// 迁移只能使用本包中的表结构快照或显式的表名、列名，不能引用模型，否则模型修改后已发布的版本也会随之改变。
+// 修改模型的表结构时要同时追加新的版本，使迁移到最新版本后的表结构与模型一致。
+//
+// MySQL的DDL会隐式提交事务，迁移中途失败时已执行的DDL不会回滚，而版本记录只在迁移成功后写入，
+// 再次执行时会从该版本重新开始，所以每个版本的up和down都要可以重复执行。
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "baseline",
+		up: func(tx *gorm.DB) error {
+			// 以前的版本每次启动都会AutoMigrate，已有的表会被接管，只补充缺少的列和索引
+			if err := tx.AutoMigrate(tablesV1()...); err != nil {
+				return err
+			}
+			// 更早的版本没有通道所属设备和通道树父节点列，新增的列需要根据已有的通道补全
+			if err := fillChannelOwner(tx); err != nil {
+				return err
+			}
+			return fillChannelTreeParent(tx)
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(tablesV1()...)
+		},
+	},
+	{
+		version: 2,
+		name:    "index device id",
+		up: func(tx *gorm.DB) error {
+			return createIndexes(tx, deviceIdIndexes)
+		},
+		down: func(tx *gorm.DB) error {
+			return dropIndexes(tx, deviceIdIndexes)
+		},
+	},
+}
+
+func tablesV1() []any {
+	return []any{deviceV1{}, mediaDetailV1{}, channelV1{}, alarmV1{}, mobilePositionV1{}, channelChangeV1{},
+		platformV1{}, platformChannelV1{}}
+}
+
+// 通道向上查找所属设备的最大层数，防止目录中的父节点成环
+const maxChannelDepth = 16
+
+// 补全更早的版本保存的通道所属的设备。这些版本按parentId区分通道所属的设备，
+// parentId是设备时即为所属设备，是业务分组、虚拟组织等节点时沿着父节点向上查找；
+// 找不到所属设备的通道保留并标记为空，补全后同一设备下重复的通道只保留最新的一条
+func fillChannelOwner(tx *gorm.DB) error {
+	var count int64
+	if err := tx.Model(&channelV1{}).Where("? IS NULL", clause.Column{Name: "ownerId"}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	var devices []string
+	if err := tx.Model(&deviceV1{}).Pluck("deviceId", &devices).Error; err != nil {
+		return err
+	}
+	var channels []channelV1
+	if err := tx.Select("id", "deviceId", "parentId", "ownerId").Find(&channels).Error; err != nil {
+		return err
+	}
+
+	isDevice := make(map[string]bool, len(devices))
+	for _, id := range devices {
+		isDevice[id] = true
+	}
+	parents := make(map[string]string, len(channels))
+	owners := make(map[string]string, len(channels))
+	for _, ch := range channels {
+		if _, ok := parents[ch.DeviceId]; !ok {
+			parents[ch.DeviceId] = ch.ParentID
+		}
+		if ch.OwnerId != "" {
+			owners[ch.DeviceId] = ch.OwnerId
+		}
+	}
+	ownerOf := func(id string) string {
+		for i := 0; i < maxChannelDepth; i++ {
+			if isDevice[id] {
+				return id
+			}
+			if ownerId, ok := owners[id]; ok {
+				return ownerId
+			}
+			parent, ok := parents[id]
+			if !ok || parent == id {
+				break
+			}
+			id = parent
+		}
+		return ""
+	}
+
+	filled := make(map[string][]uint)
+	for i, ch := range channels {
+		if ch.OwnerId != "" {
+			continue
+		}
+		channels[i].OwnerId = ownerOf(ch.ParentID)
+		filled[channels[i].OwnerId] = append(filled[channels[i].OwnerId], ch.ID)
+	}
+	for ownerId, ids := range filled {
+		if err := tx.Model(&channelV1{}).Where(map[string]any{"id": ids}).Update("ownerId", ownerId).Error; err != nil {
+			return err
+		}
+	}
+	if n := len(filled[""]); n > 0 {
+		logger.Warnf("%d个通道找不到所属的设备，设备重新上报目录后才能使用", n)
+	}
+
+	latest := make(map[[2]string]uint, len(channels))
+	var duplicated []uint
+	for _, ch := range channels {
+		if ch.OwnerId == "" {
+			continue
+		}
+		key := [2]string{ch.OwnerId, ch.DeviceId}
+		id, ok := latest[key]
+		switch {
+		case !ok:
+			latest[key] = ch.ID
+		case id < ch.ID:
+			duplicated = append(duplicated, id)
+			latest[key] = ch.ID
+		default:
+			duplicated = append(duplicated, ch.ID)
+		}
+	}
+	if len(duplicated) > 0 {
+		if err := tx.Delete(&channelV1{}, duplicated).Error; err != nil {
+			return err
+		}
+	}
+	logger.Infof("补全了%d个通道所属的设备，删除了%d个重复的通道", int(count)-len(filled[""]), len(duplicated))
+	return nil
+}
+
+// 计算更早的版本保存的通道在通道树中的父节点，之后保存的通道在保存时计算
+func fillChannelTreeParent(tx *gorm.DB) error {
+	var channels []channelV1
+	return tx.Where("? IS NULL", clause.Column{Name: "treeParentId"}).FindInBatches(&channels, 500, func(batch *gorm.DB, _ int) error {
+		for _, ch := range channels {
+			err := tx.Model(&channelV1{}).Where(map[string]any{"id": ch.ID}).Update("treeParentId", ch.treeParent()).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// 按设备id查询设备和通道时使用的索引，索引名由gorm根据快照的index标签生成，与模型一致
+var deviceIdIndexes = []index{
+	{&deviceV2{}, "DeviceId"},
+	{&channelV2{}, "DeviceId"},
+	{&channelV2{}, "ParentID"},
+}
+
+// 快照和需要建立索引的字段
+type index struct {
+	table any
+	field string
+}
+
+func createIndexes(tx *gorm.DB, indexes []index) error {
+	for _, idx := range indexes {
+		if tx.Migrator().HasIndex(idx.table, idx.field) {
+			continue
+		}
+		// MySQL中没有指定长度的字符串列是longtext，不能建立索引，先改为gorm给带索引的字符串列使用的类型
+		if tx.Dialector.Name() == "mysql" {
+			if err := tx.Migrator().AlterColumn(idx.table, idx.field); err != nil {
+				return err
+			}
+		}
+		if err := tx.Migrator().CreateIndex(idx.table, idx.field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropIndexes(tx *gorm.DB, indexes []index) error {
+	for _, idx := range indexes {
+		if !tx.Migrator().HasIndex(idx.table, idx.field) {
+			continue
+		}
+		if err := tx.Migrator().DropIndex(idx.table, idx.field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package sqlstore
+
+import (
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"gorm.io/gorm"
+)
+
+type platformStorage struct {
+	db *gorm.DB
+}
+
+func newPlatformStorage(ds *datastore) *platformStorage {
+	return &platformStorage{db: ds.db}
+}
+
+func (p platformStorage) Save(entity model.Platform) error {
+	return p.db.Create(&entity).Error
+}
+
+// Update 更新平台配置，不修改注册状态
+func (p platformStorage) Update(entity model.Platform) error {
+	return p.db.Model(&model.Platform{}).Where(map[string]any{"id": entity.ID}).
+		Select("name", "serverId", "serverDomain", "serverIp", "serverPort", "transport", "password",
+			"expires", "keepaliveInterval", "keepaliveTimeout", "shareAll", "enable").
+		Updates(&entity).Error
+}
+
+// Delete 删除平台及其共享的通道
+func (p platformStorage) Delete(id uint) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(map[string]any{"platformId": id}).Delete(&model.PlatformChannel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Platform{}, id).Error
+	})
+}
+
+func (p platformStorage) Get(id uint) (model.Platform, error) {
+	var platform model.Platform
+	if err := p.db.First(&platform, id).Error; err != nil {
+		return model.Platform{}, err
+	}
+	return platform, nil
+}
+
+func (p platformStorage) GetByServerId(serverId string) (model.Platform, bool) {
+	var platform model.Platform
+	if p.db.Where(map[string]any{"serverId": serverId}).Find(&platform).RowsAffected == 0 {
+		return platform, false
+	}
+	return platform, true
+}
+
+func (p platformStorage) List() ([]model.Platform, error) {
+	var list []model.Platform
+	if err := p.db.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (p platformStorage) UpdateStatus(id uint, online bool, registerTime *time.Time) error {
+	values := map[string]any{"online": online}
+	if registerTime != nil {
+		values["registerTime"] = registerTime
+	}
+	return p.db.Model(&model.Platform{}).Where(map[string]any{"id": id}).Updates(values).Error
+}
+
+func (p platformStorage) SetChannels(platformId uint, channels []model.PlatformChannel) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(map[string]any{"platformId": platformId}).Delete(&model.PlatformChannel{}).Error; err != nil {
+			return err
+		}
+		if len(channels) == 0 {
+			return nil
+		}
+		for i := range channels {
+			channels[i].ID = 0
+			channels[i].PlatformId = platformId
+		}
+		return tx.Create(&channels).Error
+	})
+}
+
+func (p platformStorage) Channels(platformId uint) ([]model.PlatformChannel, error) {
+	var list []model.PlatformChannel
+	if err := p.db.Where(map[string]any{"platformId": platformId}).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
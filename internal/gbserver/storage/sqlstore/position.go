@@ -0,0 +1,58 @@
+package sqlstore
+
+import (
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type positionStorage struct {
+	db *gorm.DB
+}
+
+func newPositionStorage(ds *datastore) *positionStorage {
+	return &positionStorage{db: ds.db}
+}
+
+// Save 保存位置记录，同时更新设备或通道上的最新位置
+func (p positionStorage) Save(entity model.MobilePosition) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&entity).Error; err != nil {
+			return err
+		}
+
+		latest := map[string]any{
+			"longitude":    entity.Longitude,
+			"latitude":     entity.Latitude,
+			"positionTime": entity.Time,
+		}
+		if entity.ChannelId == "" || entity.ChannelId == entity.DeviceId {
+			return tx.Model(&model.Device{}).Where(map[string]any{"deviceId": entity.DeviceId}).Updates(latest).Error
+		}
+		return tx.Model(&model.Channel{}).
+			Where(map[string]any{"ownerId": entity.DeviceId, "deviceId": entity.ChannelId}).
+			Updates(latest).Error
+	})
+}
+
+func (p positionStorage) List(q model.PositionQuery) ([]model.MobilePosition, error) {
+	db := p.db.Model(&model.MobilePosition{}).Where(map[string]any{"deviceId": q.DeviceId})
+	if q.ChannelId != "" {
+		db = db.Where(map[string]any{"channelId": q.ChannelId})
+	}
+	if !q.StartTime.IsZero() {
+		db = db.Where(clause.Gte{Column: "time", Value: q.StartTime})
+	}
+	if !q.EndTime.IsZero() {
+		db = db.Where(clause.Lte{Column: "time", Value: q.EndTime})
+	}
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+
+	var list []model.MobilePosition
+	if err := db.Order(clause.OrderByColumn{Column: clause.Column{Name: "time"}}).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
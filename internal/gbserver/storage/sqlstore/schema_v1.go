@@ -0,0 +1,213 @@
+package sqlstore
+
+import (
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/gbid"
+)
+
+// 版本1的表结构快照，即引入版本迁移之前每次启动AutoMigrate创建的表。
+// 快照与模型分开定义，之后修改模型不会改变版本1创建的表，已发布的快照不能再修改。
+
+type deviceV1 struct {
+	ID                uint `gorm:"primarykey"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeviceId          string     `gorm:"column:deviceId;comment:设备的sip唯一id"`
+	Domain            string     `gorm:"column:domain;comment:设备的sip域名"`
+	Name              string     `gorm:"column:name;comment:设备名"`
+	Manufacturer      string     `gorm:"column:manufacturer;comment:制造厂商"`
+	Model             string     `gorm:"column:model;comment:设备型号"`
+	Firmware          string     `gorm:"column:firmware;comment:固件版本"`
+	Transport         string     `gorm:"column:transport;comment:传输模式"`
+	Offline           uint8      `gorm:"column:offline;comment:是否在线:0不在线1在线"`
+	Ip                string     `gorm:"column:ip;comment:ip地址"`
+	Port              string     `gorm:"column:port;comment:传输端口"`
+	Expires           string     `gorm:"column:expires;comment:心跳过期时间"`
+	RegisterTime      time.Time  `gorm:"column:register_time;comment:注册时间"`
+	Keepalive         time.Time  `gorm:"column:keepalive;comment:上次心跳时间"`
+	HeartBeatInterval int        `gorm:"column:heartBeatInterval;comment:心跳间隔时间，5-255;default:5"`
+	HeartBeatCount    int        `gorm:"column:heartBeatCount;comment:心跳超时次数，3-255;default:3"`
+	Password          string     `gorm:"column:password;comment:设备认证密码，为空时使用全局密码"`
+	Longitude         float64    `gorm:"column:longitude;comment:最新上报的经度"`
+	Latitude          float64    `gorm:"column:latitude;comment:最新上报的纬度"`
+	PositionTime      *time.Time `gorm:"column:positionTime;comment:最新上报位置的时间"`
+}
+
+func (deviceV1) TableName() string {
+	return "devices"
+}
+
+type mediaDetailV1 struct {
+	ID                string    `gorm:"column:id;primaryKey;unique"`
+	Ip                string    `gorm:"column:ip;size:100"`
+	HookIp            string    `gorm:"column:hookIp"`
+	SdpIp             string    `gorm:"column:sdpIp"`
+	StreamIp          string    `gorm:"column:streamIp"`
+	HttpPort          int       `gorm:"column:httpPort"`
+	HttpSSlPort       int       `gorm:"column:httpSSLPort"`
+	RtmpPort          int       `gorm:"column:rtmpPort"`
+	RtmpSSlPort       int       `gorm:"column:rtmpSSLPort"`
+	RtpProxyPort      int       `gorm:"column:rtpProxyPort"`
+	RtspPort          int       `gorm:"column:rtspPort"`
+	RtspSSLPort       int       `gorm:"column:rtspSSLPort"`
+	RtpEnable         bool      `gorm:"column:rtpEnable"`
+	RtpPortRange      string    `gorm:"column:rtpPortRange"`
+	Secret            string    `gorm:"column:secret"`
+	Default           bool      `gorm:"column:default"`
+	HookAliveInterval int       `gorm:"column:hookAliveInterval"`
+	CreateTime        time.Time `gorm:"column:createTime;autoUpdateTime:milli"`
+	UpdateTime        time.Time `gorm:"column:updateTime;autoUpdateTime:milli"`
+	LastKeepaliveTime time.Time `gorm:"column:lastKeepaliveTime;autoUpdateTime:milli"`
+}
+
+func (mediaDetailV1) TableName() string {
+	return "mediaDetail"
+}
+
+type channelV1 struct {
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeviceId     string     `gorm:"column:deviceId;comment:通道id"`
+	TypeCode     string     `gorm:"column:typeCode;comment:类型编码"`
+	Name         string     `gorm:"column:name;comment:通道名称"`
+	Manufacturer string     `gorm:"column:manufacturer;comment:当为设备时，设备厂商"`
+	Model        string     `gorm:"column:model;comment:当为设备时，设备型号"`
+	Owner        string     `gorm:"column:owner;comment:当为设备时，设备归属"`
+	CivilCode    string     `gorm:"column:civilCode;comment:行政区域"`
+	Address      string     `gorm:"column:address;comment:当为设备时，安装地址"`
+	Parental     string     `gorm:"column:parental;comment:当为设备时，是否有子设备，1有，0没有"`
+	ParentID     string     `gorm:"column:parentId;comment:父设备/区域/系统ID"`
+	OwnerId      string     `gorm:"column:ownerId;index;comment:通道所属的设备id"`
+	TreeParentId string     `gorm:"column:treeParentId;index;comment:通道树中的父节点id"`
+	SafetyWay    string     `gorm:"column:safetyWay;comment:信令安全模式，0不采用、2 S/MIME签名方式、3 S/MIME加密他签名同时采用方式、4 数字摘要方式"`
+	RegisterWay  string     `gorm:"column:registerWay;comment:注册方式，1 标准认证注册模式 、2 基于口令的双向认证模式、3 基于数字证书的双向认证注册模式"`
+	Secrecy      string     `gorm:"column:secrecy;comment:保密属性，0不涉密、1涉密"`
+	Status       string     `gorm:"column:status;comment:设备状态"`
+	Longitude    float64    `gorm:"column:longitude;comment:最新上报的经度"`
+	Latitude     float64    `gorm:"column:latitude;comment:最新上报的纬度"`
+	PositionTime *time.Time `gorm:"column:positionTime;comment:最新上报位置的时间"`
+}
+
+func (channelV1) TableName() string {
+	return "channels"
+}
+
+// 版本1发布时model.Channel.TreeParent的规则，只用于补全已有的通道
+func (c channelV1) treeParent() string {
+	if gbid.IsCivilCode(c.DeviceId) {
+		return c.DeviceId[:len(c.DeviceId)-2]
+	}
+	typeCode := gbid.TypeCode(c.DeviceId)
+	if typeCode == gbid.TypeBusinessGroup || c.DeviceId == c.OwnerId {
+		return ""
+	}
+	if c.ParentID != c.DeviceId && c.ParentID != c.OwnerId {
+		switch parent := gbid.TypeCode(c.ParentID); {
+		case parent == gbid.TypeBusinessGroup || parent == gbid.TypeVirtualOrg:
+			return c.ParentID
+		case typeCode != gbid.TypeVirtualOrg && gbid.Category(parent) == gbid.CategoryPeripheral:
+			return c.ParentID
+		}
+	}
+	if typeCode == gbid.TypeVirtualOrg {
+		return ""
+	}
+	if gbid.IsCivilCode(c.CivilCode) {
+		return c.CivilCode
+	}
+	return c.OwnerId
+}
+
+type alarmV1 struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeviceId      string     `gorm:"column:deviceId;index;comment:上报报警的设备id"`
+	ChannelId     string     `gorm:"column:channelId;comment:报警源id"`
+	AlarmPriority int        `gorm:"column:alarmPriority;comment:报警级别，1-4级警情"`
+	AlarmMethod   int        `gorm:"column:alarmMethod;comment:报警方式"`
+	AlarmType     int        `gorm:"column:alarmType;comment:报警类型"`
+	EventType     int        `gorm:"column:eventType;comment:事件类型"`
+	AlarmTime     time.Time  `gorm:"column:alarmTime;index;comment:报警时间"`
+	Description   string     `gorm:"column:description;comment:报警内容描述"`
+	Longitude     float64    `gorm:"column:longitude;comment:经度"`
+	Latitude      float64    `gorm:"column:latitude;comment:纬度"`
+	Reset         bool       `gorm:"column:reset;comment:是否已复位"`
+	ResetTime     *time.Time `gorm:"column:resetTime;comment:复位时间"`
+}
+
+func (alarmV1) TableName() string {
+	return "alarms"
+}
+
+type mobilePositionV1 struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeviceId  string    `gorm:"column:deviceId;index:idx_position_device_time;comment:上报位置的设备id"`
+	ChannelId string    `gorm:"column:channelId;comment:位置所属的id"`
+	Time      time.Time `gorm:"column:time;index:idx_position_device_time;comment:定位时间"`
+	Longitude float64   `gorm:"column:longitude;comment:经度"`
+	Latitude  float64   `gorm:"column:latitude;comment:纬度"`
+	Speed     float64   `gorm:"column:speed;comment:速度，单位km/h"`
+	Direction float64   `gorm:"column:direction;comment:方向，与正北方的顺时针夹角"`
+	Altitude  float64   `gorm:"column:altitude;comment:海拔高度，单位m"`
+}
+
+func (mobilePositionV1) TableName() string {
+	return "mobile_positions"
+}
+
+type channelChangeV1 struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeviceId  string    `gorm:"column:deviceId;index;comment:上报变化的设备id"`
+	ChannelId string    `gorm:"column:channelId;index;comment:发生变化的通道id"`
+	Name      string    `gorm:"column:name;comment:通道名称"`
+	Event     string    `gorm:"column:event;comment:变化事件"`
+	Time      time.Time `gorm:"column:time;index;comment:收到变化通知的时间"`
+}
+
+func (channelChangeV1) TableName() string {
+	return "channel_changes"
+}
+
+type platformV1 struct {
+	ID                uint `gorm:"primarykey"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Name              string     `gorm:"column:name;comment:平台名称"`
+	ServerId          string     `gorm:"column:serverId;uniqueIndex;size:20;comment:上级平台SIP编码"`
+	ServerDomain      string     `gorm:"column:serverDomain;comment:上级平台SIP域"`
+	ServerIp          string     `gorm:"column:serverIp;comment:上级平台ip地址"`
+	ServerPort        string     `gorm:"column:serverPort;comment:上级平台SIP端口"`
+	Transport         string     `gorm:"column:transport;comment:传输协议"`
+	Password          string     `gorm:"column:password;comment:注册密码"`
+	Expires           int        `gorm:"column:expires;comment:注册有效期"`
+	KeepaliveInterval int        `gorm:"column:keepaliveInterval;comment:心跳周期"`
+	KeepaliveTimeout  int        `gorm:"column:keepaliveTimeout;comment:心跳超时次数"`
+	ShareAll          bool       `gorm:"column:shareAll;comment:是否共享全部通道"`
+	Enable            bool       `gorm:"column:enable;comment:是否启用"`
+	Online            bool       `gorm:"column:online;comment:是否已注册到上级平台"`
+	RegisterTime      *time.Time `gorm:"column:registerTime;comment:最近一次注册成功的时间"`
+}
+
+func (platformV1) TableName() string {
+	return "platforms"
+}
+
+type platformChannelV1 struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	PlatformId uint   `gorm:"column:platformId;index;comment:上级平台id"`
+	DeviceId   string `gorm:"column:deviceId;comment:设备id"`
+	ChannelId  string `gorm:"column:channelId;comment:通道id"`
+}
+
+func (platformChannelV1) TableName() string {
+	return "platform_channels"
+}
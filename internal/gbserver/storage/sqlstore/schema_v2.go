@@ -0,0 +1,20 @@
+package sqlstore
+
+// 版本2建立索引的列，只包含需要修改的字段
+
+type deviceV2 struct {
+	DeviceId string `gorm:"column:deviceId;index;comment:设备的sip唯一id"`
+}
+
+func (deviceV2) TableName() string {
+	return "devices"
+}
+
+type channelV2 struct {
+	DeviceId string `gorm:"column:deviceId;index;comment:通道id"`
+	ParentID string `gorm:"column:parentId;index;comment:父设备/区域/系统ID"`
+}
+
+func (channelV2) TableName() string {
+	return "channels"
+}
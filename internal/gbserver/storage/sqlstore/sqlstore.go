@@ -0,0 +1,78 @@
+// Package sqlstore 基于gorm实现的存储，MySQL、SQLite和PostgreSQL共用同一套表结构和查询。
+//
+// 表的列名使用驼峰命名，PostgreSQL会把没有引号的标识符转成小写，
+// 所以查询条件和排序都通过gorm的map条件或clause表达式生成，由gorm按数据库的方式给列名加引号。
+package sqlstore
+
+import (
+	log2 "log"
+	"os"
+	"time"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type datastore struct {
+	db *gorm.DB
+}
+
+// New 创建存储，表结构需要事先通过Migrate迁移到最新版本
+func New(db *gorm.DB) storage.Factory {
+	return &datastore{db}
+}
+
+// Config 各数据库共用的gorm配置
+func Config() *gorm.Config {
+	c := &gorm.Config{DisableForeignKeyConstraintWhenMigrating: true}
+	_default := logger.New(log2.New(os.Stdout, "\r\n", log2.LstdFlags), logger.Config{
+		SlowThreshold: 200 * time.Millisecond, // 打印慢SQL
+		LogLevel:      logger.Info,            // 打印级别为info
+		Colorful:      true,                   // 是否为彩色输出到控制台
+	})
+	c.Logger = _default.LogMode(logger.Error)
+	return c
+}
+
+// SetPool 设置连接池
+func SetPool(db *gorm.DB, maxOpen, maxIdle int, maxLifeTime time.Duration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	// 设置最多连接数
+	sqlDB.SetMaxOpenConns(maxOpen)
+
+	// 设置最多可重用连接
+	sqlDB.SetConnMaxLifetime(maxLifeTime)
+
+	// 设置最多空闲连接池里的最多连接数
+	sqlDB.SetMaxIdleConns(maxIdle)
+	return nil
+}
+
+func (d *datastore) Devices() storage.DeviceStore {
+	return newDevices(d)
+}
+
+func (d *datastore) Media() storage.MediaStorage {
+	return newMediaStorage(d)
+}
+
+func (d *datastore) Channel() storage.ChannelStore {
+	return newChannelStorage(d)
+}
+
+func (d *datastore) Alarm() storage.AlarmStore {
+	return newAlarmStorage(d)
+}
+
+func (d *datastore) Position() storage.PositionStore {
+	return newPositionStorage(d)
+}
+
+func (d *datastore) Platform() storage.PlatformStore {
+	return newPlatformStorage(d)
+}
@@ -0,0 +1,176 @@
+package sqlstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/gbserver/storage/sqlite"
+	"github.com/inysc/GB28181/internal/gbserver/storage/sqlstore"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/option"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func newStore(t *testing.T) storage.Factory {
+	db, err := sqlite.New(&option.SQLiteOptions{Path: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sqlstore.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return sqlstore.New(db)
+}
+
+func TestDevices(t *testing.T) {
+	convey.Convey("TestDevices", t, func() {
+		store := newStore(t)
+		convey.So(store.Devices().Save(model.Device{DeviceId: "44010200491320000001", Name: "dev"}), convey.ShouldBeNil)
+		convey.So(store.Devices().UpdateDeviceInfo(model.Device{DeviceId: "44010200491320000001", Name: "camera"}), convey.ShouldBeNil)
+
+		device, ok := store.Devices().GetByDeviceId("44010200491320000001")
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(device.Name, convey.ShouldEqual, "camera")
+		convey.So(store.Devices().Keepalive(device.ID), convey.ShouldBeNil)
+
+		_, ok = store.Devices().GetByDeviceId("44010200491320000002")
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
+
+func TestChannels(t *testing.T) {
+	convey.Convey("TestChannels", t, func() {
+		store := newStore(t)
+		channels := []model.Channel{
+			{DeviceId: "44010200491310000001", ParentID: "44010200491320000001", Name: "1"},
+			{DeviceId: "44010200491310000002", ParentID: "44010200491320000001", Name: "2"},
+			// 父节点是虚拟组织的通道同样属于上报目录的设备
+			{DeviceId: "44010200491310000003", ParentID: "44010200492160000001", Name: "3"},
+		}
+		convey.So(store.Channel().SaveBatch(channels, "44010200491320000001"), convey.ShouldBeNil)
+		convey.So(store.Channel().SaveBatch(channels[1:], "44010200491320000001"), convey.ShouldBeNil)
+		convey.So(store.Channel().SaveBatch(channels[1:], "44010200491320000001"), convey.ShouldBeNil)
+
+		list, err := store.Channel().List("44010200491320000001")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(list), convey.ShouldEqual, 2)
+		convey.So(store.Channel().UpdateStatus("44010200491320000001", "44010200491310000002", "OFF"), convey.ShouldBeNil)
+		channel, ok := store.Channel().Get("44010200491320000001", "44010200491310000002")
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(channel.Status, convey.ShouldEqual, "OFF")
+		_, ok = store.Channel().Get("44010200491320000002", "44010200491310000002")
+		convey.So(ok, convey.ShouldBeFalse)
+
+		// 同一个通道可以由多个设备上报，按设备区分
+		convey.So(store.Channel().SaveBatch(channels[1:2], "44010200491320000002"), convey.ShouldBeNil)
+		owners, err := store.Channel().Owners("44010200491310000002")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(owners, convey.ShouldResemble, []string{"44010200491320000001", "44010200491320000002"})
+		convey.So(store.Channel().Delete("44010200491320000001", "44010200491310000003"), convey.ShouldBeNil)
+		list, _ = store.Channel().List("44010200491320000001")
+		convey.So(len(list), convey.ShouldEqual, 1)
+
+		now := time.Now()
+		for i := 0; i < 3; i++ {
+			change := model.ChannelChange{DeviceId: channel.ParentID, ChannelId: channel.DeviceId, Event: model.CatalogEventOff, Time: now.Add(time.Duration(i) * time.Minute)}
+			convey.So(store.Channel().SaveChange(change), convey.ShouldBeNil)
+		}
+		changes, total, err := store.Channel().Changes(model.ChannelChangeQuery{DeviceId: channel.ParentID, StartTime: now.Add(time.Second), Page: 1, Size: 10})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(total, convey.ShouldEqual, 2)
+		convey.So(changes[0].Time.After(changes[1].Time), convey.ShouldBeTrue)
+	})
+}
+
+func TestChannelTree(t *testing.T) {
+	convey.Convey("TestChannelTree", t, func() {
+		store := newStore(t)
+		channels := []model.Channel{
+			// 设备上报的自身
+			{DeviceId: "44010200491320000001", ParentID: "440102"},
+			{DeviceId: "44010200492150000001", Name: "group"},
+			{DeviceId: "44010200492160000001", ParentID: "44010200492150000001", Name: "org"},
+			{DeviceId: "44010200491310000001", ParentID: "44010200492160000001", Name: "Camera1"},
+			{DeviceId: "44010200491310000002", ParentID: "44010200491320000001", CivilCode: "440102", Name: "camera2"},
+			{DeviceId: "44010200491310000003", ParentID: "44010200491320000001", Name: "camera3"},
+		}
+		convey.So(store.Channel().SaveBatch(channels, "44010200491320000001"), convey.ShouldBeNil)
+
+		list, err := store.Channel().Children("")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(list), convey.ShouldEqual, 1)
+		convey.So(list[0].DeviceId, convey.ShouldEqual, "44010200492150000001")
+		list, _ = store.Channel().Children("44010200492160000001")
+		convey.So(len(list), convey.ShouldEqual, 1)
+		convey.So(list[0].DeviceId, convey.ShouldEqual, "44010200491310000001")
+		list, _ = store.Channel().Children("440102")
+		convey.So(len(list), convey.ShouldEqual, 1)
+		list, _ = store.Channel().Children("44010200491320000001")
+		convey.So(len(list), convey.ShouldEqual, 1)
+		convey.So(list[0].DeviceId, convey.ShouldEqual, "44010200491310000003")
+
+		parents, err := store.Channel().TreeParents("44")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(parents, convey.ShouldContain, "440102")
+		convey.So(parents, convey.ShouldNotContain, "44010200491310000001")
+		parents, _ = store.Channel().WithChildren([]string{"44010200492150000001", "44010200491310000001"})
+		convey.So(parents, convey.ShouldResemble, []string{"44010200492150000001"})
+		list, _ = store.Channel().Search("camera")
+		convey.So(len(list), convey.ShouldEqual, 3)
+	})
+}
+
+func TestAlarmsAndPositions(t *testing.T) {
+	convey.Convey("TestAlarmsAndPositions", t, func() {
+		store := newStore(t)
+		now := time.Now()
+		convey.So(store.Alarm().Save(model.Alarm{DeviceId: "44010200491320000001", ChannelId: "44010200491310000001", AlarmPriority: 1, AlarmTime: now}), convey.ShouldBeNil)
+		convey.So(store.Alarm().Reset("44010200491320000001", "", now), convey.ShouldBeNil)
+		alarms, total, err := store.Alarm().List(model.AlarmQuery{DeviceId: "44010200491320000001", AlarmPriority: 1, Page: 1, Size: 10})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(total, convey.ShouldEqual, 1)
+		convey.So(alarms[0].Reset, convey.ShouldBeTrue)
+
+		convey.So(store.Devices().Save(model.Device{DeviceId: "44010200491320000001"}), convey.ShouldBeNil)
+		convey.So(store.Position().Save(model.MobilePosition{DeviceId: "44010200491320000001", Time: now, Longitude: 113.26}), convey.ShouldBeNil)
+		positions, err := store.Position().List(model.PositionQuery{DeviceId: "44010200491320000001", EndTime: now.Add(time.Second)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(positions), convey.ShouldEqual, 1)
+		device, _ := store.Devices().GetByDeviceId("44010200491320000001")
+		convey.So(device.Longitude, convey.ShouldEqual, 113.26)
+
+		convey.So(store.Position().Save(model.MobilePosition{DeviceId: "44010200491320000001", Time: now.Add(-time.Second)}), convey.ShouldBeNil)
+		positions, err = store.Position().List(model.PositionQuery{DeviceId: "44010200491320000001", Limit: 1})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(positions), convey.ShouldEqual, 1)
+		convey.So(positions[0].Time.Before(now), convey.ShouldBeTrue)
+	})
+}
+
+func TestPlatforms(t *testing.T) {
+	convey.Convey("TestPlatforms", t, func() {
+		store := newStore(t)
+		convey.So(store.Platform().Save(model.Platform{Name: "up", ServerId: "44010200492000000001"}), convey.ShouldBeNil)
+		platform, ok := store.Platform().GetByServerId("44010200492000000001")
+		convey.So(ok, convey.ShouldBeTrue)
+
+		platform.Name = "upper"
+		convey.So(store.Platform().Update(platform), convey.ShouldBeNil)
+		now := time.Now()
+		convey.So(store.Platform().UpdateStatus(platform.ID, true, &now), convey.ShouldBeNil)
+		convey.So(store.Platform().SetChannels(platform.ID, []model.PlatformChannel{{DeviceId: "44010200491320000001", ChannelId: "44010200491310000001"}}), convey.ShouldBeNil)
+
+		platform, err := store.Platform().Get(platform.ID)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(platform.Name, convey.ShouldEqual, "upper")
+		convey.So(platform.Online, convey.ShouldBeTrue)
+		channels, err := store.Platform().Channels(platform.ID)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(channels), convey.ShouldEqual, 1)
+
+		convey.So(store.Platform().Delete(platform.ID), convey.ShouldBeNil)
+		channels, _ = store.Platform().Channels(platform.ID)
+		convey.So(len(channels), convey.ShouldEqual, 0)
+	})
+}
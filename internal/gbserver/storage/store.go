@@ -1,12 +1,19 @@
 package storage
 
-import "github.com/inysc/GB28181/internal/pkg/model"
+import (
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/model"
+)
 
 // Factory defines the factory storage interface
 type Factory interface {
 	Devices() DeviceStore
 	Media() MediaStorage
 	Channel() ChannelStore
+	Alarm() AlarmStore
+	Position() PositionStore
+	Platform() PlatformStore
 }
 
 // DeviceStore defines device storage interface
@@ -27,7 +34,51 @@ type MediaStorage interface {
 	Save(config model.MediaDetail) error
 }
 
+// ChannelStore 通道存储，deviceId均指通道所属的设备，即model.Channel.OwnerId
 type ChannelStore interface {
 	SaveBatch(channels []model.Channel, deviceId string) error
 	List(deviceId string) ([]model.Channel, error)
+	ListAll() ([]model.Channel, error)
+	// Get 获取设备下的通道
+	Get(deviceId, channelId string) (model.Channel, bool)
+	// Owners 返回通道id所属的设备id，按通道保存的先后排序
+	Owners(channelId string) ([]string, error)
+	// Save 按所属设备和通道id判断通道是否存在，不存在时新增，存在时更新
+	Save(entity model.Channel) error
+	Delete(deviceId, channelId string) error
+	UpdateStatus(deviceId, channelId, status string) error
+	// Children 返回通道树中父节点为parentId的目录项，parentId为空时返回根节点下的目录项
+	Children(parentId string) ([]model.Channel, error)
+	// TreeParents 返回通道树中以prefix开头的父节点id
+	TreeParents(prefix string) ([]string, error)
+	// WithChildren 返回ids中在通道树中有子节点的id
+	WithChildren(ids []string) ([]string, error)
+	// Search 按名称模糊搜索目录项
+	Search(name string) ([]model.Channel, error)
+	SaveChange(entity model.ChannelChange) error
+	Changes(q model.ChannelChangeQuery) ([]model.ChannelChange, int64, error)
+}
+
+type AlarmStore interface {
+	Save(entity model.Alarm) error
+	List(q model.AlarmQuery) ([]model.Alarm, int64, error)
+	Reset(deviceId, channelId string, resetTime time.Time) error
+}
+
+type PositionStore interface {
+	Save(entity model.MobilePosition) error
+	List(q model.PositionQuery) ([]model.MobilePosition, error)
+}
+
+type PlatformStore interface {
+	Save(entity model.Platform) error
+	Update(entity model.Platform) error
+	Delete(id uint) error
+	Get(id uint) (model.Platform, error)
+	GetByServerId(serverId string) (model.Platform, bool)
+	List() ([]model.Platform, error)
+	UpdateStatus(id uint, online bool, registerTime *time.Time) error
+	// SetChannels 以传入的通道替换平台已共享的通道
+	SetChannels(platformId uint, channels []model.PlatformChannel) error
+	Channels(platformId uint) ([]model.PlatformChannel, error)
 }
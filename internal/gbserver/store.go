@@ -0,0 +1,46 @@
+package gbserver
+
+import (
+	"fmt"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage"
+	"github.com/inysc/GB28181/internal/gbserver/storage/mysql"
+	"github.com/inysc/GB28181/internal/gbserver/storage/postgres"
+	"github.com/inysc/GB28181/internal/gbserver/storage/sqlite"
+	"github.com/inysc/GB28181/internal/gbserver/storage/sqlstore"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/option"
+	"gorm.io/gorm"
+)
+
+// 根据配置的数据库类型连接数据库
+func openDatabase(opt *GbOption) (*gorm.DB, error) {
+	switch opt.DatabaseOption.Type {
+	case option.DatabaseMySQL, "":
+		return mysql.New(opt.MysqlOption)
+	case option.DatabaseSQLite:
+		return sqlite.New(opt.SqliteOption)
+	case option.DatabasePostgres:
+		return postgres.New(opt.PostgresOption)
+	default:
+		return nil, fmt.Errorf("不支持的数据库类型%s", opt.DatabaseOption.Type)
+	}
+}
+
+// 创建配置的数据库存储，失败时无法提供服务，直接退出
+func newStore(opt *GbOption) storage.Factory {
+	db, err := openDatabase(opt)
+	if err != nil {
+		panic(fmt.Errorf("failed to connect %s database, error: %w", opt.DatabaseOption.Type, err))
+	}
+	if opt.DatabaseOption.AutoMigrate {
+		err = sqlstore.Migrate(db)
+	} else {
+		err = sqlstore.CheckVersion(db)
+	}
+	if err != nil {
+		panic(fmt.Errorf("failed to migrate %s database, error: %w", opt.DatabaseOption.Type, err))
+	}
+	logger.Infof("使用%s数据库，表结构版本%d", opt.DatabaseOption.Type, sqlstore.LatestVersion())
+	return sqlstore.New(db)
+}
@@ -25,13 +25,24 @@ type App struct {
 	runFunc RunFunc
 	// app的选项
 	options option.GbOption
-	cmd     *cobra.Command
+	// 子命令
+	commands []Command
+	cmd      *cobra.Command
 }
 
 type Option func(*App)
 
 type RunFunc func(basename string) error
 
+// Command 子命令，与主命令使用同一份配置文件和选项
+type Command struct {
+	Use   string
+	Short string
+	Long  string
+	Args  cobra.PositionalArgs
+	Run   func(args []string) error
+}
+
 func WithRunFunc(run RunFunc) Option {
 	return func(app *App) {
 		app.runFunc = run
@@ -56,6 +67,12 @@ func WithOption(opt option.GbOption) Option {
 	}
 }
 
+func WithCommand(c Command) Option {
+	return func(app *App) {
+		app.commands = append(app.commands, c)
+	}
+}
+
 func NewApp(name, basename string, opts ...Option) *App {
 	a := &App{
 		basename: basename,
@@ -90,20 +107,49 @@ func (a *App) buildCommand() {
 	cmd.Flags().SortFlags = true
 	cmd.Flags().AddGoFlagSet(flag.CommandLine)
 
+	// 选项注册为持久标志，子命令也可以使用
 	if a.options != nil {
-		addConfigFlag(a.basename, cmd.Flags())
+		addConfigFlag(a.basename, cmd.PersistentFlags())
 		fss := a.options.Flags()
-		cmd.Flags().AddFlagSet(fss)
+		cmd.PersistentFlags().AddFlagSet(fss)
 	}
 
 	if a.runFunc != nil {
 		cmd.RunE = a.launch
 	}
 
+	for _, c := range a.commands {
+		run := c.Run
+		cmd.AddCommand(&cobra.Command{
+			Use:   c.Use,
+			Short: c.Short,
+			Long:  c.Long,
+			Args:  c.Args,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				a.parseOptions(cmd)
+				return run(args)
+			},
+		})
+	}
+
 	a.cmd = cmd
 }
 
 func (a *App) launch(cmd *cobra.Command, args []string) error {
+	a.parseOptions(cmd)
+
+	if a.banner != "" {
+		println(a.banner)
+	}
+
+	if a.runFunc != nil {
+		return a.runFunc(a.basename)
+	}
+	return nil
+}
+
+// 将命令行标志和配置文件解析到选项中，失败时直接退出
+func (a *App) parseOptions(cmd *cobra.Command) {
 	if err := viper.BindPFlags(cmd.Flags()); err != nil {
 		_, _ = fmt.Fprint(os.Stderr, "解析标志失败")
 		os.Exit(1)
@@ -116,13 +162,4 @@ func (a *App) launch(cmd *cobra.Command, args []string) error {
 			os.Exit(1)
 		}
 	}
-
-	if a.banner != "" {
-		println(a.banner)
-	}
-
-	if a.runFunc != nil {
-		return a.runFunc(a.basename)
-	}
-	return nil
 }
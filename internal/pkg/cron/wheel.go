@@ -0,0 +1,147 @@
+package cron
+
+import (
+	"sync"
+	"time"
+)
+
+// TimingWheel 时间轮，按key管理定时任务，并发安全
+//
+// 每个刻度推进一个槽位，延迟超过一圈的任务记录剩余圈数，到期的任务在锁外执行
+type TimingWheel struct {
+	tick  time.Duration
+	slots []map[string]*wheelTask
+	pos   int
+	tasks map[string]*wheelTask
+
+	mux    sync.Mutex
+	stop   chan struct{}
+	ticker *time.Ticker
+	once   sync.Once
+}
+
+type wheelTask struct {
+	key    string
+	slot   int
+	rounds int
+	fn     func()
+}
+
+// NewTimingWheel 创建时间轮，tick为刻度精度，slots为一圈的槽位数
+func NewTimingWheel(tick time.Duration, slots int) *TimingWheel {
+	if tick <= 0 {
+		tick = time.Second
+	}
+	if slots <= 0 {
+		slots = 60
+	}
+	w := &TimingWheel{
+		tick:  tick,
+		slots: make([]map[string]*wheelTask, slots),
+		tasks: make(map[string]*wheelTask),
+		stop:  make(chan struct{}),
+	}
+	for i := range w.slots {
+		w.slots[i] = make(map[string]*wheelTask)
+	}
+	return w
+}
+
+// Start 开始推进时间轮
+func (w *TimingWheel) Start() {
+	w.mux.Lock()
+	if w.ticker != nil {
+		w.mux.Unlock()
+		return
+	}
+	w.ticker = time.NewTicker(w.tick)
+	w.mux.Unlock()
+
+	go w.run()
+}
+
+// Stop 停止时间轮，未到期的任务不再执行
+func (w *TimingWheel) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+}
+
+// Add 添加延迟执行的任务，key已存在时替换原任务并重新计时
+func (w *TimingWheel) Add(key string, delay time.Duration, fn func()) {
+	ticks := int((delay + w.tick - 1) / w.tick)
+	if ticks < 1 {
+		ticks = 1
+	}
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.remove(key)
+	n := len(w.slots)
+	t := &wheelTask{
+		key:    key,
+		slot:   (w.pos + ticks) % n,
+		rounds: (ticks - 1) / n,
+		fn:     fn,
+	}
+	w.slots[t.slot][key] = t
+	w.tasks[key] = t
+}
+
+// Remove 移除任务，任务不存在时返回false
+func (w *TimingWheel) Remove(key string) bool {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.remove(key)
+}
+
+// Has 任务是否存在且未执行
+func (w *TimingWheel) Has(key string) bool {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	_, ok := w.tasks[key]
+	return ok
+}
+
+func (w *TimingWheel) remove(key string) bool {
+	t, ok := w.tasks[key]
+	if !ok {
+		return false
+	}
+	delete(w.slots[t.slot], key)
+	delete(w.tasks, key)
+	return true
+}
+
+func (w *TimingWheel) run() {
+	defer w.ticker.Stop()
+	for {
+		select {
+		case <-w.ticker.C:
+			for _, fn := range w.advance() {
+				fn()
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// 推进一个槽位，返回到期的任务
+func (w *TimingWheel) advance() []func() {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.pos = (w.pos + 1) % len(w.slots)
+
+	var expired []func()
+	for key, t := range w.slots[w.pos] {
+		if t.rounds > 0 {
+			t.rounds--
+			continue
+		}
+		delete(w.slots[w.pos], key)
+		delete(w.tasks, key)
+		expired = append(expired, t.fn)
+	}
+	return expired
+}
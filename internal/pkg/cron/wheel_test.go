@@ -0,0 +1,77 @@
+package cron
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestTimingWheel(t *testing.T) {
+	convey.Convey("TestTimingWheel", t, func() {
+		w := NewTimingWheel(10*time.Millisecond, 4)
+		w.Start()
+		defer w.Stop()
+
+		convey.Convey("到期执行，超过一圈的任务按圈数延后", func() {
+			start := time.Now()
+			done := make(chan time.Duration, 1)
+			w.Add("a", 100*time.Millisecond, func() {
+				done <- time.Since(start)
+			})
+			convey.So(w.Has("a"), convey.ShouldBeTrue)
+
+			select {
+			case d := <-done:
+				convey.So(d, convey.ShouldBeGreaterThanOrEqualTo, 90*time.Millisecond)
+			case <-time.After(time.Second):
+				convey.So("timeout", convey.ShouldBeEmpty)
+			}
+			convey.So(w.Has("a"), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("重复添加会重新计时，移除后不再执行", func() {
+			var n int32
+			for i := 0; i < 5; i++ {
+				w.Add("b", 50*time.Millisecond, func() {
+					atomic.AddInt32(&n, 1)
+				})
+				time.Sleep(20 * time.Millisecond)
+			}
+			convey.So(atomic.LoadInt32(&n), convey.ShouldEqual, 0)
+			time.Sleep(100 * time.Millisecond)
+			convey.So(atomic.LoadInt32(&n), convey.ShouldEqual, 1)
+
+			w.Add("c", 30*time.Millisecond, func() {
+				atomic.AddInt32(&n, 1)
+			})
+			convey.So(w.Remove("c"), convey.ShouldBeTrue)
+			convey.So(w.Remove("c"), convey.ShouldBeFalse)
+			time.Sleep(80 * time.Millisecond)
+			convey.So(atomic.LoadInt32(&n), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("并发添加和移除", func() {
+			var wg sync.WaitGroup
+			var n int32
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					key := string(rune('a' + i%26))
+					w.Add(key, 20*time.Millisecond, func() {
+						atomic.AddInt32(&n, 1)
+					})
+					if i%2 == 0 {
+						w.Remove(key)
+					}
+				}(i)
+			}
+			wg.Wait()
+			time.Sleep(100 * time.Millisecond)
+			convey.So(atomic.LoadInt32(&n), convey.ShouldBeLessThanOrEqualTo, 26)
+		})
+	})
+}
@@ -0,0 +1,248 @@
+// Package digest 实现 RFC 2617/3261 中定义的 SIP 摘要认证，
+// 同时支持 GB/T 28181-2022 中新增的 SHA-256 摘要算法
+package digest
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	AlgorithmMD5    = "MD5"
+	AlgorithmSHA256 = "SHA-256"
+
+	QopAuth = "auth"
+)
+
+var (
+	ErrNotDigest            = errors.New("不是Digest认证头")
+	ErrUnsupportedAlgorithm = errors.New("不支持的摘要算法")
+)
+
+// Challenge 服务端返回的 WWW-Authenticate 认证质询
+type Challenge struct {
+	Realm     string
+	Nonce     string
+	Algorithm string
+	Qop       string
+	Opaque    string
+	// 为true时表示nonce已过期，客户端无需重新输入密码，使用新的nonce重新计算即可
+	Stale bool
+}
+
+// String 生成 WWW-Authenticate 头部的值
+func (c Challenge) String() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Digest realm=\"%s\", nonce=\"%s\"", c.Realm, c.Nonce))
+	if c.Opaque != "" {
+		b.WriteString(fmt.Sprintf(", opaque=\"%s\"", c.Opaque))
+	}
+	if c.Stale {
+		b.WriteString(", stale=TRUE")
+	}
+	b.WriteString(", algorithm=" + c.algorithm())
+	if c.Qop != "" {
+		b.WriteString(fmt.Sprintf(", qop=\"%s\"", c.Qop))
+	}
+	return b.String()
+}
+
+func (c Challenge) algorithm() string {
+	if c.Algorithm == "" {
+		return AlgorithmMD5
+	}
+	return c.Algorithm
+}
+
+// Credentials 客户端携带的 Authorization 认证信息
+type Credentials struct {
+	Username  string
+	Realm     string
+	Nonce     string
+	URI       string
+	Response  string
+	Algorithm string
+	Qop       string
+	Nc        string
+	CNonce    string
+	Opaque    string
+}
+
+// String 生成 Authorization 头部的值
+func (c Credentials) String() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Digest username=\"%s\", realm=\"%s\", nonce=\"%s\", uri=\"%s\", response=\"%s\", algorithm=%s",
+		c.Username, c.Realm, c.Nonce, c.URI, c.Response, c.algorithm()))
+	if c.Qop != "" {
+		b.WriteString(fmt.Sprintf(", qop=%s, nc=%s, cnonce=\"%s\"", c.Qop, c.Nc, c.CNonce))
+	}
+	if c.Opaque != "" {
+		b.WriteString(fmt.Sprintf(", opaque=\"%s\"", c.Opaque))
+	}
+	return b.String()
+}
+
+func (c Credentials) algorithm() string {
+	if c.Algorithm == "" {
+		return AlgorithmMD5
+	}
+	return c.Algorithm
+}
+
+// Verify 使用密码和请求方法重新计算摘要，校验客户端给出的response是否正确
+func (c Credentials) Verify(method, password string) bool {
+	expect, err := calcResponse(c, method, password)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(expect, c.Response)
+}
+
+// ParseChallenge 解析 WWW-Authenticate 头部
+func ParseChallenge(value string) (Challenge, error) {
+	params, err := parseParams(value)
+	if err != nil {
+		return Challenge{}, err
+	}
+	return Challenge{
+		Realm:     params["realm"],
+		Nonce:     params["nonce"],
+		Algorithm: params["algorithm"],
+		Qop:       params["qop"],
+		Opaque:    params["opaque"],
+		Stale:     strings.EqualFold(params["stale"], "true"),
+	}, nil
+}
+
+// ParseCredentials 解析 Authorization 头部
+func ParseCredentials(value string) (Credentials, error) {
+	params, err := parseParams(value)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{
+		Username:  params["username"],
+		Realm:     params["realm"],
+		Nonce:     params["nonce"],
+		URI:       params["uri"],
+		Response:  params["response"],
+		Algorithm: params["algorithm"],
+		Qop:       params["qop"],
+		Nc:        params["nc"],
+		CNonce:    params["cnonce"],
+		Opaque:    params["opaque"],
+	}, nil
+}
+
+// Authorize 根据服务端的质询计算出客户端需要携带的认证信息
+func Authorize(ch Challenge, method, uri, username, password string) (Credentials, error) {
+	c := Credentials{
+		Username:  username,
+		Realm:     ch.Realm,
+		Nonce:     ch.Nonce,
+		URI:       uri,
+		Algorithm: ch.Algorithm,
+		Opaque:    ch.Opaque,
+	}
+	// qop可能以逗号分隔给出多个取值，这里只支持auth
+	for _, q := range strings.Split(ch.Qop, ",") {
+		if strings.TrimSpace(q) == QopAuth {
+			c.Qop = QopAuth
+			c.Nc = "00000001"
+			c.CNonce = NewNonce()
+			break
+		}
+	}
+	resp, err := calcResponse(c, method, password)
+	if err != nil {
+		return Credentials{}, err
+	}
+	c.Response = resp
+	return c, nil
+}
+
+// NewNonce 生成一个随机的nonce
+func NewNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// 根据 RFC 2617 3.2.2.1 计算摘要
+func calcResponse(c Credentials, method, password string) (string, error) {
+	newHash, err := hashFunc(c.algorithm())
+	if err != nil {
+		return "", err
+	}
+	h := func(s string) string {
+		w := newHash()
+		w.Write([]byte(s))
+		return hex.EncodeToString(w.Sum(nil))
+	}
+
+	ha1 := h(c.Username + ":" + c.Realm + ":" + password)
+	ha2 := h(method + ":" + c.URI)
+	if c.Qop == "" {
+		return h(ha1 + ":" + c.Nonce + ":" + ha2), nil
+	}
+	return h(ha1 + ":" + c.Nonce + ":" + c.Nc + ":" + c.CNonce + ":" + c.Qop + ":" + ha2), nil
+}
+
+func hashFunc(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToUpper(algorithm) {
+	case AlgorithmMD5:
+		return md5.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	default:
+		return nil, errors.Wrap(ErrUnsupportedAlgorithm, algorithm)
+	}
+}
+
+// 解析形如 Digest k1="v1", k2=v2 的参数列表，值可以带引号也可以不带
+func parseParams(value string) (map[string]string, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 7 || !strings.EqualFold(value[:7], "Digest ") {
+		return nil, ErrNotDigest
+	}
+	value = value[7:]
+
+	params := make(map[string]string)
+	for len(value) > 0 {
+		value = strings.TrimLeft(value, " ,\t")
+		eq := strings.IndexByte(value, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(value[:eq]))
+		value = strings.TrimLeft(value[eq+1:], " \t")
+
+		var val string
+		if strings.HasPrefix(value, "\"") {
+			end := strings.IndexByte(value[1:], '"')
+			if end < 0 {
+				return nil, errors.Errorf("参数%s的引号未闭合", key)
+			}
+			val = value[1 : end+1]
+			value = value[end+2:]
+		} else {
+			end := strings.IndexByte(value, ',')
+			if end < 0 {
+				end = len(value)
+			}
+			val = strings.TrimSpace(value[:end])
+			value = value[end:]
+		}
+		params[key] = val
+	}
+	return params, nil
+}
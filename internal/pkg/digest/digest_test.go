@@ -0,0 +1,68 @@
+package digest
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// RFC 2617 3.5 中给出的示例
+const rfcAuthorization = `Digest username="Mufasa", realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", uri="/dir/index.html", qop=auth, nc=00000001, cnonce="0a4f113b", response="6629fae49393a05397450978507c4ef1", opaque="5ccc069c403ebaf9f0171e9517f40e41"`
+
+func TestParseCredentials(t *testing.T) {
+	convey.Convey("TestParseCredentials", t, func() {
+		convey.Convey("for success", func() {
+			c, err := ParseCredentials(rfcAuthorization)
+			convey.So(err, convey.ShouldEqual, nil)
+			convey.So(c.Username, convey.ShouldEqual, "Mufasa")
+			convey.So(c.Realm, convey.ShouldEqual, "testrealm@host.com")
+			convey.So(c.Qop, convey.ShouldEqual, "auth")
+			convey.So(c.Nc, convey.ShouldEqual, "00000001")
+			convey.So(c.CNonce, convey.ShouldEqual, "0a4f113b")
+		})
+
+		convey.Convey("for not digest", func() {
+			_, err := ParseCredentials(`Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==`)
+			convey.So(err, convey.ShouldEqual, ErrNotDigest)
+		})
+	})
+}
+
+func TestCredentials_Verify(t *testing.T) {
+	convey.Convey("TestCredentials_Verify", t, func() {
+		c, err := ParseCredentials(rfcAuthorization)
+		convey.So(err, convey.ShouldEqual, nil)
+
+		convey.Convey("for success", func() {
+			convey.So(c.Verify("GET", "Circle Of Life"), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("for wrong password", func() {
+			convey.So(c.Verify("GET", "admin123"), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("for unsupported algorithm", func() {
+			c.Algorithm = "SHA-512"
+			convey.So(c.Verify("GET", "Circle Of Life"), convey.ShouldBeFalse)
+		})
+	})
+}
+
+func TestAuthorize(t *testing.T) {
+	convey.Convey("TestAuthorize", t, func() {
+		for _, algorithm := range []string{AlgorithmMD5, AlgorithmSHA256} {
+			ch := Challenge{Realm: "4401020049", Nonce: NewNonce(), Algorithm: algorithm, Qop: QopAuth}
+			parsed, err := ParseChallenge(ch.String())
+			convey.So(err, convey.ShouldEqual, nil)
+			convey.So(parsed, convey.ShouldResemble, ch)
+
+			c, err := Authorize(parsed, "REGISTER", "sip:44010200492000000001@4401020049", "34020000001320000001", "admin123")
+			convey.So(err, convey.ShouldEqual, nil)
+
+			got, err := ParseCredentials(c.String())
+			convey.So(err, convey.ShouldEqual, nil)
+			convey.So(got.Verify("REGISTER", "admin123"), convey.ShouldBeTrue)
+			convey.So(got.Verify("REGISTER", "12345678"), convey.ShouldBeFalse)
+		}
+	})
+}
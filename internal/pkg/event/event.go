@@ -0,0 +1,134 @@
+package event
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/logger"
+)
+
+type Type string
+
+const (
+	// DeviceOnline 设备上线
+	DeviceOnline Type = "device.online"
+	// DeviceOffline 设备离线
+	DeviceOffline Type = "device.offline"
+	// ChannelChange 通道目录变化
+	ChannelChange Type = "channel.change"
+	// Alarm 设备报警
+	Alarm Type = "alarm.notify"
+	// StreamStart 媒体会话建立
+	StreamStart Type = "stream.start"
+	// StreamStop 媒体会话结束
+	StreamStop Type = "stream.stop"
+	// MediaServerStarted 流媒体服务启动
+	MediaServerStarted Type = "media.started"
+	// MediaStreamChanged 流媒体服务上流注册或注销
+	MediaStreamChanged Type = "media.streamChanged"
+)
+
+// Event 平台内部事件
+type Event struct {
+	Type Type `json:"type"`
+	// 事件所属的设备，与设备无关的事件为空
+	DeviceId string      `json:"deviceId,omitempty"`
+	Time     time.Time   `json:"time"`
+	Data     interface{} `json:"data"`
+}
+
+// DeviceStatus 设备上下线事件的内容
+type DeviceStatus struct {
+	DeviceId string `json:"deviceId"`
+	// 上下线原因
+	Reason string `json:"reason"`
+}
+
+// Stream 媒体会话事件的内容
+type Stream struct {
+	StreamId  string `json:"streamId"`
+	DeviceId  string `json:"deviceId"`
+	ChannelId string `json:"channelId"`
+}
+
+// Filter 订阅条件，为空时不过滤
+type Filter struct {
+	DeviceIds []string
+	// 事件类型，也可以只填类别，如device匹配device.online和device.offline
+	Types []string
+}
+
+func (f Filter) match(e Event) bool {
+	if len(f.DeviceIds) > 0 && !contains(f.DeviceIds, e.DeviceId) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if string(e.Type) == t || strings.HasPrefix(string(e.Type), t+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(s []string, v string) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+type subscriber struct {
+	ch     chan Event
+	filter Filter
+}
+
+type bus struct {
+	mux  sync.RWMutex
+	next int
+	subs map[int]*subscriber
+}
+
+var b = &bus{subs: make(map[int]*subscriber)}
+
+// Publish 发布事件，订阅者处理不过来时丢弃该订阅者的事件，不阻塞发布方
+func Publish(t Type, deviceId string, data interface{}) {
+	e := Event{Type: t, DeviceId: deviceId, Time: time.Now(), Data: data}
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	for id, s := range b.subs {
+		if !s.filter.match(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			logger.Warnf("事件订阅者%d处理过慢，已丢弃事件%s", id, t)
+		}
+	}
+}
+
+// Subscribe 订阅符合条件的事件，buffer为缓存的事件数，调用返回的函数取消订阅
+func Subscribe(buffer int, filter Filter) (<-chan Event, func()) {
+	s := &subscriber{ch: make(chan Event, buffer), filter: filter}
+	b.mux.Lock()
+	b.next++
+	id := b.next
+	b.subs[id] = s
+	b.mux.Unlock()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			b.mux.Lock()
+			delete(b.subs, id)
+			b.mux.Unlock()
+			close(s.ch)
+		})
+	}
+}
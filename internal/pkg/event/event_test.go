@@ -0,0 +1,39 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestSubscribe(t *testing.T) {
+	convey.Convey("TestSubscribe", t, func() {
+		convey.Convey("按设备和事件类别过滤", func() {
+			ch, cancel := Subscribe(8, Filter{DeviceIds: []string{"a"}, Types: []string{"device", string(Alarm)}})
+			defer cancel()
+
+			Publish(DeviceOnline, "b", nil)
+			Publish(StreamStart, "a", nil)
+			Publish(DeviceOffline, "a", DeviceStatus{DeviceId: "a", Reason: "心跳超时"})
+			Publish(Alarm, "a", nil)
+
+			e := <-ch
+			convey.So(e.Type, convey.ShouldEqual, DeviceOffline)
+			convey.So(e.Data.(DeviceStatus).Reason, convey.ShouldEqual, "心跳超时")
+			convey.So((<-ch).Type, convey.ShouldEqual, Alarm)
+			convey.So(len(ch), convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("订阅者缓存满时丢弃事件，不阻塞发布", func() {
+			ch, cancel := Subscribe(1, Filter{})
+			Publish(StreamStart, "", nil)
+			Publish(StreamStop, "", nil)
+			convey.So((<-ch).Type, convey.ShouldEqual, StreamStart)
+
+			cancel()
+			cancel()
+			_, ok := <-ch
+			convey.So(ok, convey.ShouldBeFalse)
+		})
+	})
+}
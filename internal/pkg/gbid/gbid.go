@@ -0,0 +1,287 @@
+// Package gbid 解析和校验GB/T 28181中的20位统一编码
+//
+// 编码由中心编码(8位)、行业编码(2位)、类型编码(3位)、网络标识(1位)和序号(6位)组成，
+// 中心编码的前2、4、6、8位分别是省、市、区县和基层单位的行政区划代码。
+package gbid
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// Length 统一编码的长度
+	Length = 20
+	// DomainLength SIP域的长度，即统一编码的前10位
+	DomainLength = 10
+)
+
+// 类型编码
+const (
+	TypeDVR               = "111"
+	TypeVideoServer       = "112"
+	TypeEncoder           = "113"
+	TypeDecoder           = "114"
+	TypeVideoMatrix       = "115"
+	TypeAudioMatrix       = "116"
+	TypeAlarmController   = "117"
+	TypeNVR               = "118"
+	TypeHVR               = "119"
+	TypeCamera            = "131"
+	TypeIPC               = "132"
+	TypeDisplay           = "133"
+	TypeAlarmInput        = "134"
+	TypeAlarmOutput       = "135"
+	TypeVoiceInput        = "136"
+	TypeVoiceOutput       = "137"
+	TypeMobileTransmitter = "138"
+	TypeOtherPeripheral   = "139"
+	TypePlatform          = "200"
+	TypeWebServer         = "201"
+	TypeMediaServer       = "202"
+	TypeProxyServer       = "203"
+	TypeSecurityServer    = "204"
+	TypeAlarmServer       = "205"
+	TypeDatabaseServer    = "206"
+	TypeGISServer         = "207"
+	TypeManageServer      = "208"
+	TypeAccessGateway     = "209"
+	TypeStorageServer     = "210"
+	TypeSecurityGateway   = "211"
+	TypeBusinessGroup     = "215"
+	TypeVirtualOrg        = "216"
+	TypeCenterUser        = "300"
+	TypeTerminalUser      = "400"
+)
+
+// 编码的分类
+const (
+	CategoryDevice        = "device"
+	CategoryPeripheral    = "peripheral"
+	CategoryPlatform      = "platform"
+	CategoryBusinessGroup = "businessGroup"
+	CategoryVirtualOrg    = "virtualOrganization"
+	CategoryUser          = "user"
+	CategoryUnknown       = "unknown"
+)
+
+// 类型编码的中文名称，只用于展示，没有列出的类型编码只要在标准的范围内同样合法
+var typeNames = map[string]string{
+	TypeDVR:               "DVR",
+	TypeVideoServer:       "视频服务器",
+	TypeEncoder:           "编码器",
+	TypeDecoder:           "解码器",
+	TypeVideoMatrix:       "视频切换矩阵",
+	TypeAudioMatrix:       "音频切换矩阵",
+	TypeAlarmController:   "报警控制器",
+	TypeNVR:               "NVR",
+	TypeHVR:               "HVR",
+	TypeCamera:            "摄像机",
+	TypeIPC:               "网络摄像机",
+	TypeDisplay:           "显示器",
+	TypeAlarmInput:        "报警输入设备",
+	TypeAlarmOutput:       "报警输出设备",
+	TypeVoiceInput:        "语音输入设备",
+	TypeVoiceOutput:       "语音输出设备",
+	TypeMobileTransmitter: "移动传输设备",
+	TypeOtherPeripheral:   "其他外围设备",
+	TypePlatform:          "中心信令控制服务器",
+	TypeWebServer:         "Web应用服务器",
+	TypeMediaServer:       "媒体分发服务器",
+	TypeProxyServer:       "代理服务器",
+	TypeSecurityServer:    "安全服务器",
+	TypeAlarmServer:       "报警服务器",
+	TypeDatabaseServer:    "数据库服务器",
+	TypeGISServer:         "GIS服务器",
+	TypeManageServer:      "管理服务器",
+	TypeAccessGateway:     "接入网关",
+	TypeStorageServer:     "媒体存储服务器",
+	TypeSecurityGateway:   "信令安全路由网关",
+	TypeBusinessGroup:     "业务分组",
+	TypeVirtualOrg:        "虚拟组织",
+	TypeCenterUser:        "中心用户",
+	TypeTerminalUser:      "终端用户",
+}
+
+// 省级行政区划代码，中心编码和行政区划代码都以其中之一开头
+var provinces = map[string]bool{
+	"11": true, "12": true, "13": true, "14": true, "15": true,
+	"21": true, "22": true, "23": true,
+	"31": true, "32": true, "33": true, "34": true, "35": true, "36": true, "37": true,
+	"41": true, "42": true, "43": true, "44": true, "45": true, "46": true,
+	"50": true, "51": true, "52": true, "53": true, "54": true,
+	"61": true, "62": true, "63": true, "64": true, "65": true,
+	"71": true, "81": true, "82": true,
+}
+
+var (
+	ErrLength     = errors.New("编码长度不是20位")
+	ErrNotDigit   = errors.New("编码中含有非数字字符")
+	ErrCivilCode  = errors.New("行政区划代码不合法")
+	ErrCenterCode = errors.New("中心编码的省级行政区划代码不合法")
+	ErrTypeCode   = errors.New("类型编码不在标准的范围内")
+	ErrNotDevice  = errors.New("编码不是可以注册的设备或平台类型")
+)
+
+// ID 解析后的统一编码
+type ID struct {
+	Raw string
+	// 中心编码，由行政区划代码和基层单位编号组成
+	CenterCode string
+	// 行业编码
+	IndustryCode string
+	// 类型编码
+	TypeCode string
+	// 网络标识，0-4为监控报警专网，5为公安信息网，6为政务网，7为Internet，8为社会资源接入网
+	NetworkCode string
+	// 设备、用户序号
+	Serial string
+}
+
+// Parse 解析20位统一编码
+func Parse(id string) (ID, error) {
+	if err := Validate(id); err != nil {
+		return ID{}, err
+	}
+	return ID{
+		Raw:          id,
+		CenterCode:   id[0:8],
+		IndustryCode: id[8:10],
+		TypeCode:     id[10:13],
+		NetworkCode:  id[13:14],
+		Serial:       id[14:20],
+	}, nil
+}
+
+// Validate 校验统一编码是否为20位数字，中心编码以省级行政区划代码开头，且类型编码在标准的范围内
+func Validate(id string) error {
+	if len(id) != Length {
+		return errors.Wrapf(ErrLength, "%q", id)
+	}
+	if !isDigits(id) {
+		return errors.Wrapf(ErrNotDigit, "%q", id)
+	}
+	if !provinces[id[:2]] {
+		return errors.Wrapf(ErrCenterCode, "%q", id)
+	}
+	if Category(id[10:13]) == CategoryUnknown {
+		return errors.Wrapf(ErrTypeCode, "%q", id)
+	}
+	return nil
+}
+
+// ValidateRegister 校验注册请求中的编码，只有前端主设备、平台以及直接注册的摄像机可以注册，
+// 业务分组、虚拟组织、用户和其他外围设备的编码会被拒绝
+func ValidateRegister(id string) error {
+	parsed, err := Parse(id)
+	if err != nil {
+		return err
+	}
+	if parsed.IsDevice() || parsed.IsPlatform() || parsed.TypeCode == TypeCamera || parsed.TypeCode == TypeIPC {
+		return nil
+	}
+	return errors.Wrapf(ErrNotDevice, "%q(%s)", id, parsed.TypeName())
+}
+
+// IsValid 统一编码是否合法
+func IsValid(id string) bool {
+	return Validate(id) == nil
+}
+
+// IsCivilCode 是否为行政区划代码，目录中的行政区划节点使用2、4、6或8位的行政区划代码作为id
+func IsCivilCode(code string) bool {
+	switch len(code) {
+	case 2, 4, 6, 8:
+		return isDigits(code) && provinces[code[:2]]
+	}
+	return false
+}
+
+// ValidateCivilCode 校验行政区划代码
+func ValidateCivilCode(code string) error {
+	if !IsCivilCode(code) {
+		return errors.Wrapf(ErrCivilCode, "%q", code)
+	}
+	return nil
+}
+
+// TypeCode 返回编码的类型编码，不是合法的统一编码时返回空
+func TypeCode(id string) string {
+	if !IsValid(id) {
+		return ""
+	}
+	return id[10:13]
+}
+
+// Domain 返回统一编码所属的SIP域
+func (i ID) Domain() string {
+	return i.Raw[:DomainLength]
+}
+
+// CivilCode 返回中心编码中的行政区划代码
+func (i ID) CivilCode() string {
+	return i.CenterCode[:6]
+}
+
+// TypeName 返回类型编码的中文名称
+func (i ID) TypeName() string {
+	if name, ok := typeNames[i.TypeCode]; ok {
+		return name
+	}
+	return "未知类型"
+}
+
+// Category 按类型编码对编码分类
+func (i ID) Category() string {
+	return Category(i.TypeCode)
+}
+
+// Category 按类型编码分类，111-130为前端主设备，131-199为前端外围设备，200-299为平台设备
+func Category(typeCode string) string {
+	switch {
+	case typeCode == TypeBusinessGroup:
+		return CategoryBusinessGroup
+	case typeCode == TypeVirtualOrg:
+		return CategoryVirtualOrg
+	case typeCode >= "111" && typeCode <= "130":
+		return CategoryDevice
+	case typeCode >= "131" && typeCode <= "199":
+		return CategoryPeripheral
+	case typeCode >= "200" && typeCode <= "299":
+		return CategoryPlatform
+	case typeCode >= "300" && typeCode <= "499":
+		return CategoryUser
+	}
+	return CategoryUnknown
+}
+
+// IsDevice 是否为前端主设备，如DVR、NVR
+func (i ID) IsDevice() bool {
+	return i.Category() == CategoryDevice
+}
+
+// IsPeripheral 是否为前端外围设备，如摄像机、报警输入输出设备
+func (i ID) IsPeripheral() bool {
+	return i.Category() == CategoryPeripheral
+}
+
+// IsPlatform 是否为平台设备
+func (i ID) IsPlatform() bool {
+	return i.Category() == CategoryPlatform
+}
+
+// SSRCDomain 返回SSRC中使用的域标识，即SIP域的第4-8位
+func SSRCDomain(domain string) string {
+	if len(domain) < 8 {
+		return "00000"
+	}
+	return domain[3:8]
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
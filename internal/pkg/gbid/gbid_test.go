@@ -0,0 +1,83 @@
+package gbid
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestParse(t *testing.T) {
+	convey.Convey("TestParse", t, func() {
+		convey.Convey("for success", func() {
+			id, err := Parse("34020000001320000001")
+			convey.So(err, convey.ShouldEqual, nil)
+			convey.So(id.CenterCode, convey.ShouldEqual, "34020000")
+			convey.So(id.IndustryCode, convey.ShouldEqual, "00")
+			convey.So(id.TypeCode, convey.ShouldEqual, TypeIPC)
+			convey.So(id.NetworkCode, convey.ShouldEqual, "0")
+			convey.So(id.Serial, convey.ShouldEqual, "000001")
+			convey.So(id.Domain(), convey.ShouldEqual, "3402000000")
+			convey.So(id.CivilCode(), convey.ShouldEqual, "340200")
+			convey.So(id.IsPeripheral(), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("for wrong length", func() {
+			_, err := Parse("3402000000132000001")
+			convey.So(errors.Is(err, ErrLength), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("for not digit", func() {
+			_, err := Parse("3402000000132000000a")
+			convey.So(errors.Is(err, ErrNotDigit), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("for unknown center or type code", func() {
+			_, err := Parse("99020000001320000001")
+			convey.So(errors.Is(err, ErrCenterCode), convey.ShouldBeTrue)
+			_, err = Parse("34020000009990000001")
+			convey.So(errors.Is(err, ErrTypeCode), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("for type code without a name", func() {
+			id, err := Parse("34020000001250000001")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(id.IsDevice(), convey.ShouldBeTrue)
+			convey.So(id.TypeName(), convey.ShouldEqual, "未知类型")
+			convey.So(IsValid("34020000001500000001"), convey.ShouldBeTrue)
+			convey.So(TypeCode("34020000001500000001"), convey.ShouldEqual, "150")
+		})
+	})
+}
+
+func TestValidateRegister(t *testing.T) {
+	convey.Convey("TestValidateRegister", t, func() {
+		convey.So(ValidateRegister("34020000001180000001"), convey.ShouldBeNil)
+		convey.So(ValidateRegister("34020000001320000001"), convey.ShouldBeNil)
+		convey.So(ValidateRegister("34020000002000000001"), convey.ShouldBeNil)
+		convey.So(errors.Is(ValidateRegister("34020000002160000001"), ErrNotDevice), convey.ShouldBeTrue)
+		convey.So(errors.Is(ValidateRegister("34020000001340000001"), ErrNotDevice), convey.ShouldBeTrue)
+		convey.So(errors.Is(ValidateRegister("34020000003000000001"), ErrNotDevice), convey.ShouldBeTrue)
+	})
+}
+
+func TestCategory(t *testing.T) {
+	convey.Convey("TestCategory", t, func() {
+		convey.So(Category(TypeNVR), convey.ShouldEqual, CategoryDevice)
+		convey.So(Category(TypeCamera), convey.ShouldEqual, CategoryPeripheral)
+		convey.So(Category(TypePlatform), convey.ShouldEqual, CategoryPlatform)
+		convey.So(Category(TypeBusinessGroup), convey.ShouldEqual, CategoryBusinessGroup)
+		convey.So(Category(TypeVirtualOrg), convey.ShouldEqual, CategoryVirtualOrg)
+		convey.So(Category("999"), convey.ShouldEqual, CategoryUnknown)
+	})
+}
+
+func TestIsCivilCode(t *testing.T) {
+	convey.Convey("TestIsCivilCode", t, func() {
+		convey.So(IsCivilCode("34"), convey.ShouldBeTrue)
+		convey.So(IsCivilCode("340200"), convey.ShouldBeTrue)
+		convey.So(IsCivilCode("340"), convey.ShouldBeFalse)
+		convey.So(IsCivilCode("34020a"), convey.ShouldBeFalse)
+		convey.So(IsCivilCode("990200"), convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,228 @@
+package gbsip
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/config"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrBroadcastBusy     = errors.New("该通道正在进行语音广播")
+	ErrBroadcastNotFound = errors.New("该通道没有进行语音广播")
+)
+
+// 语音广播会话，设备收到广播通知后作为主叫发起INVITE，本级是被叫
+type broadcastState struct {
+	device  model.Device
+	session model.BroadcastSession
+	// 本级应答INVITE后的会话信息，FromTag是本级的tag，ToTag是设备的tag
+	dialog SipTX
+	// 会话建立或失败时通知等待的请求
+	done chan error
+	once sync.Once
+}
+
+func (s *broadcastState) finish(err error) {
+	s.once.Do(func() {
+		s.done <- err
+		close(s.done)
+	})
+}
+
+// 以语音输出通道id作为key
+type broadcastManager struct {
+	mux sync.Mutex
+	m   map[string]*broadcastState
+}
+
+var broadcasts = &broadcastManager{m: make(map[string]*broadcastState)}
+
+// Broadcast 向设备发送语音广播通知，设备确认后会发起INVITE请求语音流，需要调用WaitBroadcast等待会话建立
+func Broadcast(device model.Device, session model.BroadcastSession) error {
+	broadcasts.mux.Lock()
+	if _, ok := broadcasts.m[session.ChannelId]; ok {
+		broadcasts.mux.Unlock()
+		return ErrBroadcastBusy
+	}
+	session.Status = model.BroadcastStatusWaiting
+	// 设备可能在收到通知的应答前就发起INVITE，需要先记录会话
+	broadcasts.m[session.ChannelId] = &broadcastState{
+		device:  device,
+		session: session,
+		done:    make(chan error, 1),
+	}
+	broadcasts.mux.Unlock()
+
+	if err := sendBroadcastNotify(device, session.ChannelId); err != nil {
+		broadcasts.remove(session.ChannelId)
+		return err
+	}
+	return nil
+}
+
+func sendBroadcastNotify(device model.Device, channelId string) error {
+	body, err := parser.CreateBroadcastXML(config.SIPId(), channelId)
+	if err != nil {
+		return errors.Wrap(err, "创建语音广播通知失败")
+	}
+	request := sipRequestFactory.createMessageRequest(device, body)
+	logger.Debugf("语音广播通知：\n%s", request)
+	tx, err := c.server.sendRequest(request)
+	if err != nil {
+		return errors.Wrap(err, "发送语音广播通知失败")
+	}
+	response := getResponse(tx)
+	if response == nil {
+		return errors.New("接收语音广播通知的确认超时")
+	}
+	if !response.IsSuccess() {
+		return errors.Errorf("设备拒绝了语音广播通知: %d %s", response.StatusCode(), response.Reason())
+	}
+	return nil
+}
+
+// WaitBroadcast 等待设备发起INVITE建立语音广播会话，超时或失败时清理会话
+func WaitBroadcast(channelId string, timeout time.Duration) (model.BroadcastSession, error) {
+	s, ok := broadcasts.get(channelId)
+	if !ok {
+		return model.BroadcastSession{}, ErrBroadcastNotFound
+	}
+	select {
+	case err := <-s.done:
+		if err != nil {
+			broadcasts.remove(channelId)
+			return model.BroadcastSession{}, err
+		}
+	case <-time.After(timeout):
+		broadcasts.remove(channelId)
+		return model.BroadcastSession{}, errors.New("等待设备发起语音广播INVITE超时")
+	}
+	broadcasts.mux.Lock()
+	defer broadcasts.mux.Unlock()
+	if s, ok = broadcasts.m[channelId]; !ok {
+		return model.BroadcastSession{}, ErrBroadcastNotFound
+	}
+	return s.session, nil
+}
+
+// PendingBroadcast 根据INVITE的发起方查找等待建立的语音广播，设备可能以语音输出通道id或设备id发起
+func PendingBroadcast(user string) (model.BroadcastSession, bool) {
+	broadcasts.mux.Lock()
+	defer broadcasts.mux.Unlock()
+	if s, ok := broadcasts.m[user]; ok && s.session.Status == model.BroadcastStatusWaiting {
+		return s.session, true
+	}
+	for _, s := range broadcasts.m {
+		if s.session.DeviceId == user && s.session.Status == model.BroadcastStatusWaiting {
+			return s.session, true
+		}
+	}
+	return model.BroadcastSession{}, false
+}
+
+// BroadcastEstablished 本级应答设备的INVITE后记录会话信息，用于后续发送BYE
+func BroadcastEstablished(session model.BroadcastSession, req sip.Request, resp sip.Response) {
+	s, ok := broadcasts.get(session.ChannelId)
+	if !ok {
+		return
+	}
+	dialog := SipTX{DeviceId: session.DeviceId, ChannelId: session.ChannelId, SSRC: session.SSRC}
+	if callId, ok := req.CallID(); ok {
+		dialog.CallId = callId.Value()
+	}
+	if from, ok := req.From(); ok && from.Params != nil {
+		if tag, ok := from.Params.Get("tag"); ok {
+			dialog.ToTag = tag.String()
+		}
+	}
+	if to, ok := resp.To(); ok && to.Params != nil {
+		if tag, ok := to.Params.Get("tag"); ok {
+			dialog.FromTag = tag.String()
+		}
+	}
+
+	broadcasts.mux.Lock()
+	session.Status = model.BroadcastStatusActive
+	session.CallId = dialog.CallId
+	s.session = session
+	s.dialog = dialog
+	broadcasts.mux.Unlock()
+	s.finish(nil)
+}
+
+// BroadcastFailed 设备拒绝语音广播或本级无法应答设备的INVITE
+func BroadcastFailed(channelId string, err error) {
+	if s, ok := broadcasts.get(channelId); ok {
+		s.finish(err)
+	}
+}
+
+// BroadcastByCallId 根据设备BYE请求中的Call-ID查找语音广播会话
+func BroadcastByCallId(callId string) (model.BroadcastSession, bool) {
+	broadcasts.mux.Lock()
+	defer broadcasts.mux.Unlock()
+	for _, s := range broadcasts.m {
+		if s.dialog.CallId == callId {
+			return s.session, true
+		}
+	}
+	return model.BroadcastSession{}, false
+}
+
+// Broadcasts 返回所有语音广播会话
+func Broadcasts() []model.BroadcastSession {
+	broadcasts.mux.Lock()
+	defer broadcasts.mux.Unlock()
+	list := make([]model.BroadcastSession, 0, len(broadcasts.m))
+	for _, s := range broadcasts.m {
+		list = append(list, s.session)
+	}
+	return list
+}
+
+// EndBroadcast 结束语音广播会话，sendBye为true时向设备发送BYE，设备主动结束时为false
+func EndBroadcast(channelId string, sendBye bool) (model.BroadcastSession, error) {
+	s, ok := broadcasts.get(channelId)
+	if !ok {
+		return model.BroadcastSession{}, ErrBroadcastNotFound
+	}
+	broadcasts.remove(channelId)
+	s.finish(ErrBroadcastNotFound)
+	if !sendBye || s.dialog.CallId == "" {
+		return s.session, nil
+	}
+
+	s.dialog.ViaBranch = sip.GenerateBranch()
+	request, err := sipRequestFactory.createByeRequest(channelId, s.device, s.dialog)
+	if err != nil {
+		return s.session, err
+	}
+	logger.Debugf("结束语音广播：\n%s", request)
+	tx, err := c.server.sendRequest(request)
+	if err != nil {
+		return s.session, errors.WithMessage(err, "send bye request fail")
+	}
+	if response := getResponse(tx); response == nil {
+		logger.Warnf("{%s}接收语音广播BYE的响应超时", channelId)
+	}
+	return s.session, nil
+}
+
+func (m *broadcastManager) get(channelId string) (*broadcastState, bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	s, ok := m.m[channelId]
+	return s, ok
+}
+
+func (m *broadcastManager) remove(channelId string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.m, channelId)
+}
@@ -0,0 +1,257 @@
+package gbsip
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/config"
+	"github.com/inysc/GB28181/internal/pkg/digest"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/pkg/errors"
+)
+
+// 目录应答每个分包携带的通道数，避免UDP报文过大
+const catalogItemsPerPacket = 5
+
+// 向上级平台注册使用的会话信息，同一个平台的注册和刷新使用相同的Call-ID
+type registerDialog struct {
+	callId  string
+	fromTag string
+	seq     uint32
+}
+
+type registerDialogs struct {
+	mux sync.Mutex
+	m   map[uint]*registerDialog
+}
+
+var platformDialogs = &registerDialogs{m: make(map[uint]*registerDialog)}
+
+func (r *registerDialogs) get(platformId uint) *registerDialog {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	d, ok := r.m[platformId]
+	if !ok {
+		d = &registerDialog{callId: randString(32), fromTag: randString(32)}
+		r.m[platformId] = d
+	}
+	return d
+}
+
+func (r *registerDialogs) nextSeq(platformId uint) (registerDialog, uint32) {
+	d := r.get(platformId)
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	d.seq++
+	return *d, d.seq
+}
+
+func (r *registerDialogs) remove(platformId uint) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	delete(r.m, platformId)
+}
+
+// 将上级平台转换为消息的接收方
+func platformTarget(p model.Platform) model.Device {
+	return model.Device{
+		DeviceId:  p.ServerId,
+		Ip:        p.ServerIp,
+		Port:      p.ServerPort,
+		Transport: p.Transport,
+	}
+}
+
+// PlatformRegister 向上级平台注册，expires为0时表示注销，上级平台要求认证时使用平台密码完成摘要认证
+func PlatformRegister(p model.Platform, expires int) error {
+	dialog, seq := platformDialogs.nextSeq(p.ID)
+	request, err := sipRequestFactory.createRegisterRequest(p, expires, dialog.callId, dialog.fromTag, seq, "", "")
+	if err != nil {
+		return err
+	}
+	response, err := sendPlatformRequest(request)
+	if err != nil {
+		return err
+	}
+
+	code := int(response.StatusCode())
+	if code == http.StatusUnauthorized || code == http.StatusProxyAuthRequired {
+		challengeHeader, authHeader := "WWW-Authenticate", "Authorization"
+		if code == http.StatusProxyAuthRequired {
+			challengeHeader, authHeader = "Proxy-Authenticate", "Proxy-Authorization"
+		}
+		h := response.GetHeaders(challengeHeader)
+		if len(h) == 0 {
+			return errors.Errorf("上级平台%s的认证质询中缺少%s头部", p.ServerId, challengeHeader)
+		}
+		challenge, err := digest.ParseChallenge(h[0].Value())
+		if err != nil {
+			return errors.WithMessage(err, "解析上级平台的认证质询失败")
+		}
+		credentials, err := digest.Authorize(challenge, string(sip.REGISTER), request.Recipient().String(), config.SIPId(), p.Password)
+		if err != nil {
+			return errors.WithMessage(err, "计算注册认证信息失败")
+		}
+
+		dialog, seq = platformDialogs.nextSeq(p.ID)
+		request, err = sipRequestFactory.createRegisterRequest(p, expires, dialog.callId, dialog.fromTag, seq, authHeader, credentials.String())
+		if err != nil {
+			return err
+		}
+		if response, err = sendPlatformRequest(request); err != nil {
+			return err
+		}
+	}
+
+	if !response.IsSuccess() {
+		return errors.Errorf("上级平台%s拒绝了注册请求: %d %s", p.ServerId, response.StatusCode(), response.Reason())
+	}
+	if expires == 0 {
+		platformDialogs.remove(p.ID)
+	}
+	return nil
+}
+
+// PlatformKeepalive 向上级平台发送心跳
+func PlatformKeepalive(p model.Platform) error {
+	body, err := parser.CreateNotifyXML(parser.KeepaliveCmdType, config.SIPId(), parser.WithCustomKV("Status", "OK"))
+	if err != nil {
+		return err
+	}
+	return SendToPlatform(p, body)
+}
+
+// SendToPlatform 向上级平台发送MESSAGE请求
+func SendToPlatform(p model.Platform, body string) error {
+	request := sipRequestFactory.createMessageRequest(platformTarget(p), body)
+	response, err := sendPlatformRequest(request)
+	if err != nil {
+		return err
+	}
+	if !response.IsSuccess() {
+		return errors.Errorf("上级平台%s拒绝了消息: %d %s", p.ServerId, response.StatusCode(), response.Reason())
+	}
+	return nil
+}
+
+func sendPlatformRequest(request sip.Request) (sip.Response, error) {
+	logger.Debugf("向上级平台发送请求：\n%s", request)
+	tx, err := c.server.sendRequest(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "向上级平台发送请求失败")
+	}
+	response := getResponse(tx)
+	if response == nil {
+		return nil, errors.New("接收上级平台响应超时")
+	}
+	return response, nil
+}
+
+// CatalogResponse 以分包的方式向上级平台返回共享的通道目录
+func CatalogResponse(p model.Platform, sn string, channels []model.Channel) error {
+	sum := strconv.Itoa(len(channels))
+	for start := 0; start == 0 || start < len(channels); start += catalogItemsPerPacket {
+		end := start + catalogItemsPerPacket
+		if end > len(channels) {
+			end = len(channels)
+		}
+		body, err := parser.CreateResponseXML(parser.CatalogCmdType, sn, config.SIPId(),
+			parser.WithCustomKV("SumNum", sum), withCatalogItems(channels[start:end]))
+		if err != nil {
+			return err
+		}
+		if err = SendToPlatform(p, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func withCatalogItems(channels []model.Channel) parser.WithKeyValue {
+	return func(element *etree.Element) {
+		list := element.CreateElement("DeviceList")
+		list.CreateAttr("Num", strconv.Itoa(len(channels)))
+		for _, ch := range channels {
+			status := ch.Status
+			if status == "" {
+				status = "ON"
+			}
+			item := list.CreateElement("Item")
+			item.CreateElement("DeviceID").CreateText(ch.DeviceId)
+			item.CreateElement("Name").CreateText(ch.Name)
+			item.CreateElement("Manufacturer").CreateText(ch.Manufacturer)
+			item.CreateElement("Model").CreateText(ch.Model)
+			item.CreateElement("Owner").CreateText(ch.Owner)
+			item.CreateElement("CivilCode").CreateText(ch.CivilCode)
+			item.CreateElement("Address").CreateText(ch.Address)
+			item.CreateElement("Parental").CreateText("0")
+			// 共享给上级平台的通道都挂在本平台下
+			item.CreateElement("ParentID").CreateText(config.SIPId())
+			item.CreateElement("SafetyWay").CreateText("0")
+			item.CreateElement("RegisterWay").CreateText("1")
+			item.CreateElement("Secrecy").CreateText("0")
+			item.CreateElement("Status").CreateText(status)
+		}
+	}
+}
+
+// DeviceInfoResponse 向上级平台返回本平台的设备信息
+func DeviceInfoResponse(p model.Platform, sn string, channelNum int) error {
+	body, err := parser.CreateResponseXML(parser.DeviceInfoCmdType, sn, config.SIPId(),
+		parser.WithCustomKV("Result", "OK"),
+		parser.WithCustomKV("DeviceName", config.SIPUserAgent()),
+		parser.WithCustomKV("Manufacturer", "inysc"),
+		parser.WithCustomKV("Model", "GB28181"),
+		parser.WithCustomKV("Firmware", "1.0"),
+		parser.WithCustomKV("Channel", strconv.Itoa(channelNum)))
+	if err != nil {
+		return err
+	}
+	return SendToPlatform(p, body)
+}
+
+// DeviceStatusResponse 向上级平台返回本平台的运行状态
+func DeviceStatusResponse(p model.Platform, sn string) error {
+	body, err := parser.CreateResponseXML(parser.DeviceStatusCmdType, sn, config.SIPId(),
+		parser.WithCustomKV("Result", "OK"),
+		parser.WithCustomKV("Online", "ONLINE"),
+		parser.WithCustomKV("Status", "OK"),
+		parser.WithCustomKV("DeviceTime", time.Now().Format(model.GBTimeLayout)),
+		parser.WithCustomKV("Encode", "ON"),
+		parser.WithCustomKV("Record", "OFF"))
+	if err != nil {
+		return err
+	}
+	return SendToPlatform(p, body)
+}
+
+// CreateAnswerSdp 创建应答上级平台点播的sdp，本级作为发送方
+func CreateAnswerSdp(mediaIp, ssrc string, localPort int) string {
+	return createSdp(sdpSession{
+		name:     SessionPlay,
+		mediaIp:  mediaIp,
+		ssrc:     ssrc,
+		rtpPort:  localPort,
+		sendOnly: true,
+	})
+}
+
+// CreateInviteOkResponse 创建应答上级平台点播的200 OK响应，携带本级的sdp
+func CreateInviteOkResponse(req sip.Request, answerSdp string) sip.Response {
+	resp := sip.NewResponseFromRequest("", req, http.StatusOK, http.StatusText(http.StatusOK), answerSdp)
+	if to, ok := resp.To(); ok && to.Params != nil && !to.Params.Has("tag") {
+		to.Params.Add("tag", sip.String{Str: randString(32)})
+	} else if ok && to.Params == nil {
+		to.Params = newParams(map[string]string{"tag": randString(32)})
+	}
+	contentType := sip.ContentType("APPLICATION/SDP")
+	resp.AppendHeader(&contentType)
+	resp.AppendHeader(&sip.ContactHeader{Address: newTo(config.SIPId(), config.SIPAddress(), config.SIPPort()).Uri})
+	return resp
+}
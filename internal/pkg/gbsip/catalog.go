@@ -0,0 +1,145 @@
+package gbsip
+
+import (
+	"sync"
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/pkg/errors"
+)
+
+// 发送目录查询后超过该时间仍未收到任何应答，认为同步超时
+const catalogResponseTimeout = 30 * time.Second
+
+// ErrCatalogSyncing 设备的目录同步正在进行中
+var ErrCatalogSyncing = errors.New("设备目录正在同步中")
+
+type catalogSyncState struct {
+	sync  model.CatalogSync
+	timer *time.Timer
+}
+
+// 记录每个设备最近一次目录同步的进度
+type catalogSyncManager struct {
+	mux sync.Mutex
+	m   map[string]*catalogSyncState
+}
+
+var catalogSyncs = &catalogSyncManager{m: make(map[string]*catalogSyncState)}
+
+func (c *catalogSyncManager) start(deviceId string) (model.CatalogSync, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if s, ok := c.m[deviceId]; ok && s.sync.Status == model.CatalogSyncing {
+		return s.sync, ErrCatalogSyncing
+	}
+	s := &catalogSyncState{
+		sync: model.CatalogSync{
+			DeviceId:  deviceId,
+			Status:    model.CatalogSyncing,
+			StartTime: time.Now(),
+		},
+	}
+	s.timer = time.AfterFunc(catalogResponseTimeout, func() {
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		// 已经开始接收分包后，超时由分包聚合负责
+		if s.sync.Status == model.CatalogSyncing && s.sync.Received == 0 {
+			s.finish(model.CatalogSyncTimeout, "设备未应答目录查询")
+		}
+	})
+	c.m[deviceId] = s
+	return s.sync, nil
+}
+
+func (s *catalogSyncState) finish(status, message string) {
+	now := time.Now()
+	s.sync.Status = status
+	s.sync.Message = message
+	s.sync.EndTime = &now
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// CatalogSyncProgress 更新设备目录同步的进度，设备主动上报目录时也会开始一次同步
+func CatalogSyncProgress(deviceId, sn string, total, received int) {
+	catalogSyncs.mux.Lock()
+	defer catalogSyncs.mux.Unlock()
+
+	s, ok := catalogSyncs.m[deviceId]
+	if !ok || s.sync.Status != model.CatalogSyncing {
+		s = &catalogSyncState{sync: model.CatalogSync{
+			DeviceId:  deviceId,
+			Status:    model.CatalogSyncing,
+			StartTime: time.Now(),
+		}}
+		catalogSyncs.m[deviceId] = s
+	}
+	s.sync.SN = sn
+	s.sync.Total = total
+	s.sync.Received = received
+}
+
+// FinishCatalogSync 结束设备的目录同步，status为finished、timeout或failed
+func FinishCatalogSync(deviceId, status, message string) {
+	catalogSyncs.mux.Lock()
+	defer catalogSyncs.mux.Unlock()
+
+	if s, ok := catalogSyncs.m[deviceId]; ok {
+		s.finish(status, message)
+	}
+}
+
+// CatalogSyncStatus 获取设备最近一次目录同步的进度
+func CatalogSyncStatus(deviceId string) (model.CatalogSync, bool) {
+	catalogSyncs.mux.Lock()
+	defer catalogSyncs.mux.Unlock()
+
+	s, ok := catalogSyncs.m[deviceId]
+	if !ok {
+		return model.CatalogSync{}, false
+	}
+	return s.sync, true
+}
+
+// SyncCatalog 向设备发送目录查询，开始一次完整的目录同步，同步进度通过 CatalogSyncStatus 获取
+func SyncCatalog(device model.Device) (model.CatalogSync, error) {
+	status, err := catalogSyncs.start(device.DeviceId)
+	if err != nil {
+		return status, err
+	}
+	if err = DeviceCatalogQuery(device); err != nil {
+		FinishCatalogSync(device.DeviceId, model.CatalogSyncFailed, err.Error())
+		status, _ = CatalogSyncStatus(device.DeviceId)
+		return status, err
+	}
+	return status, nil
+}
+
+// DeviceCatalogQuery 查询设备目录，设备会通过一个或多个MESSAGE返回目录
+func DeviceCatalogQuery(device model.Device) error {
+	xml, err := parser.CreateQueryXML(parser.CatalogCmdType, device.DeviceId)
+	if err != nil {
+		return err
+	}
+
+	request := sipRequestFactory.createMessageRequest(device, xml)
+	logger.Debugf("发送设备目录查询信息：\n%s", request)
+	tx, err := c.server.sendRequest(request)
+	if err != nil {
+		logger.Error(err)
+		return errors.Wrap(err, "发送设备目录查询请求失败")
+	}
+	response := getResponse(tx)
+	if response == nil {
+		return errors.New("接收设备目录查询响应超时")
+	}
+	if !response.IsSuccess() {
+		return errors.Errorf("设备拒绝了目录查询请求: %d %s", response.StatusCode(), response.Reason())
+	}
+	return nil
+}
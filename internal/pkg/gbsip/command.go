@@ -1,16 +1,17 @@
 package gbsip
 
 import (
+	"context"
 	"fmt"
-	"strings"
+	"sort"
 
-	"github.com/beevik/etree"
 	"github.com/ghettovoice/gosip/sip"
 	"github.com/inysc/GB28181/internal/gbserver/storage/cache"
 	"github.com/inysc/GB28181/internal/pkg/logger"
 	"github.com/inysc/GB28181/internal/pkg/model"
 	"github.com/inysc/GB28181/internal/pkg/model/constant"
 	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/inysc/GB28181/internal/pkg/syn"
 	"github.com/pkg/errors"
 )
 
@@ -25,161 +26,88 @@ func mustSetupCommand(server *Server) {
 	c = &cmd{server: server}
 }
 
-// DeviceInfoQuery 查询设备信息
-func DeviceInfoQuery(d model.Device) {
-	document := etree.NewDocument()
-	document.CreateProcInst("xml", "version=\"1.0\" encoding=\"GB2312\"")
-	query := document.CreateElement("Query")
-	query.CreateElement("CmdType").CreateText("DeviceInfo")
-	query.CreateElement("SN").CreateText("701385")
-	query.CreateElement("DeviceID").CreateText(d.DeviceId)
-	document.Indent(2)
-	body, _ := document.WriteToString()
-
-	request := sipRequestFactory.createMessageRequest(d, body)
-	logger.Debugf("查询设备信息请求：\n%s", request)
-	resp, _ := c.server.sendRequest(request)
-	if resp != nil {
-		logger.Debugf("收到设备查询响应：\n%s", <-resp.Responses())
-	}
-	DeviceCatalogQuery(d)
+// DeviceInfoQuery 查询设备信息，返回等待设备应答的任务
+func DeviceInfoQuery(d model.Device) (*syn.Entity, error) {
+	return sendQuery(d, parser.DeviceInfoCmdType, d.DeviceId, "查询设备信息")
 }
 
-func DeviceCatalogQuery(device model.Device) {
-	xml, err := parser.CreateQueryXML(parser.CatalogCmdType, "44010200491118000001")
-	if err != nil {
-		return
-	}
-
-	request := sipRequestFactory.createMessageRequest(device, xml)
-	logger.Debugf("发送设备目录查询信息：\n%s", request)
-	resp, err := c.server.sendRequest(request)
-	if err != nil {
-		logger.Error(err)
-	}
-	if resp != nil {
-		logger.Debugf("收到设备目录查询响应：\n%s", <-resp.Responses())
-	}
+func DeviceBasicConfig(req *model.DeviceBasicConfigDto) (*syn.Entity, error) {
+	return sendControlWithResult(req.Device, parser.DeviceConfig, req.DeviceId, "设备配置",
+		parser.WithBasicParams(req.Name, req.Expiration, req.HeartBeatInterval, req.HeartBeatCount))
 }
 
-func DeviceBasicConfig(req *model.DeviceBasicConfigDto) error {
-	xml, err := parser.CreateControlXml(parser.DeviceConfig, req.DeviceId, parser.WithBasicParams(req.Name, req.Expiration, req.HeartBeatInterval, req.HeartBeatCount))
-	if err != nil {
-		return errors.Wrap(err, "创建设备配置请求失败")
-	}
-	request := sipRequestFactory.createMessageRequest(req.Device, xml)
-	logger.Debugf("查询设备基本配置请求：\n%s", request)
-	tx, err := c.server.sendRequest(request)
-	if err != nil {
-		logger.Error(err)
-		return err
-	}
-	response := getResponse(tx)
-	if response.IsSuccess() {
-
-	}
-	return nil
+func DeviceBasicConfigQuery(d model.Device) (*syn.Entity, error) {
+	return DeviceConfigQuery(d, d.DeviceId, ConfigBasicParam)
 }
 
-func DeviceBasicConfigQuery(d model.Device) error {
-	xml, err := parser.CreateQueryXML(parser.ConfigDownloadCmdType, d.DeviceId, parser.WithCustomKV("ConfigType", "BasicParam"))
-	if err != nil {
-		return errors.Wrap(err, "创建查询设备配置请求失败")
-	}
-	request := sipRequestFactory.createMessageRequest(d, xml)
-	_, err = c.server.sendRequest(request)
-	if err != nil {
-		logger.Error(err)
-		return err
-	}
-	return nil
+// DeviceStatusQuery 查询设备状态，返回等待设备应答的任务
+func DeviceStatusQuery(d model.Device) (*syn.Entity, error) {
+	return sendQuery(d, parser.DeviceStatusCmdType, d.DeviceId, "查询设备状态")
 }
 
-func DeviceStatusQuery(d model.Device) error {
-	xml, err := parser.CreateQueryXML(parser.DeviceStatusCmdType, d.DeviceId)
-	if err != nil {
-		return errors.Wrap(err, "创建查询设备状态请求失败")
+// RecordInfoQuery 检索设备通道在指定时间段内的录像文件，设备可能分多个包返回检索结果
+func RecordInfoQuery(d model.Device, q model.RecordQuery) (*syn.Entity, error) {
+	recordType := q.Type
+	if recordType == "" {
+		recordType = "all"
 	}
-	request := sipRequestFactory.createMessageRequest(d, xml)
-	_, err = c.server.sendRequest(request)
-	if err != nil {
-		logger.Error(err)
-		return err
-	}
-	return nil
+	return sendQuery(d, parser.RecordInfoCmdType, q.ChannelId, "录像文件检索",
+		parser.WithRecordQuery(q.StartTime, q.EndTime, q.FilePath, q.Secrecy, recordType))
 }
 
-func AlarmSubscribe(device model.Device) error {
-	xml, err := parser.CreateQueryXML(parser.AlarmCmdType, device.DeviceId, parser.WithAlarmQuery())
-	if err != nil {
-		return errors.Wrap(err, "创建报警订阅请求body失败")
-	}
-	request, err := sipRequestFactory.createSubscribeRequest(device, xml, "presence")
-	if err != nil {
-		return errors.Wrap(err, "创建报警订阅请求失败")
-	}
-	logger.Debug("报警订阅请求：\n", request)
-	tx, err := c.server.sendRequest(request)
+// CollectRecordInfo 接收录像检索的全部分包，合并后按开始时间排序
+func CollectRecordInfo(ctx context.Context, task *syn.Entity) (RecordInfo, error) {
+	var result RecordInfo
+	first := true
+	err := task.Collect(ctx, func(data interface{}) bool {
+		info := data.(RecordInfo)
+		if first {
+			result = info
+			first = false
+		} else {
+			result.RecordList.Items = append(result.RecordList.Items, info.RecordList.Items...)
+		}
+		return len(result.RecordList.Items) >= result.SumNum
+	})
 	if err != nil {
-		logger.Error(err)
-		return errors.Wrap(err, "发送请求失败")
-	}
-	response := getResponse(tx)
-	if response == nil || !response.IsSuccess() {
-		logger.Error("发送请求失败")
-		return errors.New("发送请求失败2")
+		return RecordInfo{}, err
 	}
-	logger.Debug("报警订阅请求响应:\n", response)
-	return nil
+	sort.Slice(result.RecordList.Items, func(i, j int) bool {
+		return result.RecordList.Items[i].StartTime < result.RecordList.Items[j].StartTime
+	})
+	result.RecordList.Num = len(result.RecordList.Items)
+	return result, nil
 }
 
-func CatalogSubscribe(device model.Device) error {
-	xml, err := parser.CreateQueryXML(parser.CatalogCmdType, device.DeviceId)
-	if err != nil {
-		return errors.Wrap(err, "创建目录请订阅求body失败")
-	}
-	request, err := sipRequestFactory.createSubscribeRequest(device, xml, "Catalog")
+// ResetAlarm 报警复位，channelId为报警源的id
+func ResetAlarm(device model.Device, channelId, alarmMethod, alarmType string) error {
+	xml, err := parser.CreateControlXml(parser.DeviceControl, channelId, parser.WithResetAlarm(alarmMethod, alarmType))
 	if err != nil {
-		return errors.Wrap(err, "创建目录订阅请求失败")
+		return errors.Wrap(err, "创建报警复位请求失败")
 	}
+	request := sipRequestFactory.createMessageRequest(device, xml)
+	logger.Debugf("报警复位请求：\n%s", request)
 	tx, err := c.server.sendRequest(request)
 	if err != nil {
 		logger.Error(err)
-		return errors.Wrap(err, "发送目录订阅请求失败")
+		return errors.Wrap(err, "发送报警复位请求失败")
 	}
 	response := getResponse(tx)
 	if response == nil || !response.IsSuccess() {
-		logger.Error("接收目录订阅消息确认超时")
-		return errors.New("接收目录订阅消息确认超时")
+		return errors.New("接收报警复位确认超时")
 	}
 	return nil
 }
 
-func MobilePositionSubscribe(device model.Device) error {
-	xml, err := parser.CreateQueryXML(parser.MobilePositionCmdType, device.DeviceId, parser.WithCustomKV("Interval", "5"))
-	if err != nil {
-		return errors.Wrap(err, "创建设备移动位置订阅请求body失败")
-	}
-	request, err := sipRequestFactory.createSubscribeRequest(device, xml, "presence")
-	if err != nil {
-		return errors.Wrap(err, "创建设备移动位置订阅请求失败")
-	}
-	tx, err := c.server.sendRequest(request)
-	if err != nil {
-		logger.Error(err)
-		return errors.Wrap(err, "发送设备移动位置订阅请求失败")
-	}
-	response := getResponse(tx)
-	if response == nil || !response.IsSuccess() {
-		logger.Error("接收设备移动位置订阅确认超时")
-		return errors.New("接收设备移动位置订阅确认超时")
-	}
-	return nil
+func Play(device model.Device, detail model.MediaDetail, streamId, ssrc string, channelId string, rtpPort int) (model.StreamInfo, error) {
+	logger.Debugf("点播开始，流id: %s, 设备ip: %s, SSRC: %s, rtp端口: %d\n", streamId, device.Ip, ssrc, rtpPort)
+	body := createSdpInfo(detail.Ip, channelId, ssrc, rtpPort)
+	return invite(device, detail, streamId, ssrc, channelId, body)
 }
 
-func Play(device model.Device, detail model.MediaDetail, streamId, ssrc string, channelId string, rtpPort int) (model.StreamInfo, error) {
-	logger.Debugf("点播开始，流id: %c, 设备ip: %c, SSRC: %c, rtp端口: %d\n", streamId, device.Ip, ssrc, rtpPort)
-	request := sipRequestFactory.createInviteRequest(device, detail, channelId, ssrc, rtpPort)
+// 发送invite请求建立媒体会话，成功后保存流信息和会话事务
+func invite(device model.Device, detail model.MediaDetail, streamId, ssrc string, channelId string, body string) (model.StreamInfo, error) {
+	request := sipRequestFactory.createInviteRequest(device, channelId, ssrc, body)
 	logger.Debugf("发送invite请求：\n%s", request)
 	tx, err := c.server.sendRequest(request)
 	if err != nil {
@@ -188,6 +116,12 @@ func Play(device model.Device, detail model.MediaDetail, streamId, ssrc string,
 
 	resp := getResponse(tx)
 	logger.Debugf("收到invite响应：\n%s", resp)
+	if resp == nil {
+		return model.StreamInfo{}, errors.New("接收invite响应超时")
+	}
+	if !resp.IsSuccess() {
+		return model.StreamInfo{}, errors.Errorf("设备拒绝了invite请求: %d %s", resp.StatusCode(), resp.Reason())
+	}
 	logger.Debugf("\ntransaction key: %s", tx.Key().String())
 
 	ackRequest := sip.NewAckRequest("", request, resp, "", nil)
@@ -212,21 +146,14 @@ func Play(device model.Device, detail model.MediaDetail, streamId, ssrc string,
 	if err != nil {
 		return model.StreamInfo{}, err
 	}
-	streamSessionManage.saveStreamSession(device.DeviceId, channelId, ssrc, callId, fromTag, toTag, branch)
+	streamSessionManage.saveStreamSession(streamId, device.DeviceId, channelId, ssrc, callId, fromTag, toTag, branch)
 
 	return info, nil
 }
 
 func StopPlay(streamId, channelId string, device model.Device) error {
-	// delete stream info in cache
-	key := fmt.Sprintf("%s:%s", constant.StreamInfoPrefix, streamId)
-	err := cache.Del(key)
-	if err != nil {
-		return err
-	}
-
 	// get SipOption tx in cache
-	txInfo, err := streamSessionManage.getTx(device.DeviceId, channelId)
+	txInfo, err := streamSessionManage.getTx(streamId)
 	if err != nil {
 		return err
 	}
@@ -238,20 +165,18 @@ func StopPlay(streamId, channelId string, device model.Device) error {
 	}
 
 	logger.Debugf("创建Bye请求：\n%s", byeRequest)
-	key = fmt.Sprintf("%s:%s", constant.StreamTransactionPrefix, streamId)
-	err = cache.Del(key)
-	if err != nil {
-		return errors.WithMessage(err, "delete cache by key fail")
+	// delete stream info and SipOption tx in cache
+	if err := streamSessionManage.clearStreamSession(streamId, txInfo); err != nil {
+		return err
 	}
 
-	//err = s.s.Send(byeRequest)
 	tx, err := c.server.sendRequest(byeRequest)
 	if err != nil {
 		logger.Error("发送请求发生错误,", err)
+		return errors.WithMessage(err, "send bye request fail")
 	}
 
 	response := getResponse(tx)
-
 	if response == nil {
 		logger.Error("response is nil")
 	}
@@ -334,13 +259,11 @@ func ControlPTZ(d model.Device, channelId, command string, params1, params2, com
 // 创建PTZ指令
 // 根据gb28181协议的标准，前端指令中一共包含4个字节
 func createPTZCode(command string, params1, params2, combineCode int) (string, error) {
-	var ptz strings.Builder
 	// gb28181协议中控制指令中的前三个字节
 	// 字节1是A5，字节2是组合码，高4位由版本信息组成，版本信息为0H；低四位是校验位，校验位=(字节1的高4位+字节1的低四位+字节2的高四位) % 16
 	// 所以校验码 = (0xa + 0x5 + 0) % 16 = (1010 + 0101 + 0) % 16 = 15 % 16 = 15；十进制数15转十六进制= F
 	// 所以字节2 = 0F
 	// 字节3是地址的低8位，这里直接设置为01
-	ptz.WriteString("A50F01")
 	var cmd int
 
 	// 指令码以一个字节来表示
@@ -384,25 +307,13 @@ func createPTZCode(command string, params1, params2, combineCode int) (string, e
 		return "", errors.New("不合规的控制字符串")
 	}
 
-	// 根据gb标准，字节4用于表示云台的镜头缩小、镜头放大、上、下、左、右，写入指令码的16进制数
-	ptz.WriteString(fmt.Sprintf("%02X", cmd))
-
-	logger.Debug("合并字节4之后：" + ptz.String())
-
-	// 根据gb标准，字节5用于表示水平控制速度，写入水平控制方向速度的十六进制数
-	ptz.WriteString(fmt.Sprintf("%02X", params1))
-
-	// 根据gb标准，字节6用于表示垂直控制速度，写入垂直控制方向速度的十六进制数
-	ptz.WriteString(fmt.Sprintf("%02X", params2))
-
-	// 最后字节7的高4位用于表示变倍控制速度，后4位不关注
-	// 所以这里直接与0xF0做与操作，保留前4位，后4为置0
-	c := combineCode & 0xF0
-	ptz.WriteString(fmt.Sprintf("%02X", c))
+	if err := checkByte(params1, params2, combineCode); err != nil {
+		return "", err
+	}
 
-	// 字节8用于校验位，根据gb标准，校验位=(字节1+字节2+字节3+字节4+字节5+字节6+字节7) % 256
-	checkCode := (0xA5 + 0x0F + 0x01 + cmd + params1 + params2 + c) % 0x100
-	ptz.WriteString(fmt.Sprintf("%02X", checkCode))
-	logger.Debug("最终生成的PTZCmd: " + ptz.String())
-	return ptz.String(), nil
+	// 字节4为指令码，字节5为水平控制速度，字节6为垂直控制速度，
+	// 字节7的高4位为变倍控制速度，所以只保留变倍速度的高4位
+	code := buildPTZCmd(cmd, params1, params2, (combineCode&0xF0)>>4)
+	logger.Debug("最终生成的PTZCmd: " + code)
+	return code, nil
 }
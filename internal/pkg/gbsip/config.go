@@ -0,0 +1,354 @@
+package gbsip
+
+import (
+	"strings"
+
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/inysc/GB28181/internal/pkg/syn"
+	"github.com/pkg/errors"
+)
+
+// 设备配置类型，见GB/T 28181-2022附录A.2.3.2
+const (
+	ConfigBasicParam          = "BasicParam"
+	ConfigVideoParamOpt       = "VideoParamOpt"
+	ConfigSVACEncode          = "SVACEncodeConfig"
+	ConfigSVACDecode          = "SVACDecodeConfig"
+	ConfigVideoParamAttribute = "VideoParamAttribute"
+	ConfigVideoRecordPlan     = "VideoRecordPlan"
+	ConfigVideoAlarmRecord    = "VideoAlarmRecord"
+	ConfigPictureMask         = "PictureMask"
+	ConfigFrameMirror         = "FrameMirror"
+	ConfigAlarmReport         = "AlarmReport"
+	ConfigOSD                 = "OSDConfig"
+)
+
+// ConfigTypes 除BasicParam外支持查询和修改的配置类型，BasicParam使用单独的接口
+var ConfigTypes = []string{
+	ConfigVideoParamOpt,
+	ConfigSVACEncode,
+	ConfigSVACDecode,
+	ConfigVideoParamAttribute,
+	ConfigVideoRecordPlan,
+	ConfigVideoAlarmRecord,
+	ConfigPictureMask,
+	ConfigFrameMirror,
+	ConfigAlarmReport,
+	ConfigOSD,
+}
+
+var ErrConfigType = errors.New("不支持的设备配置类型")
+
+type (
+	// DeviceConfigParams 设备配置项，查询应答和修改请求中只携带涉及的配置类型
+	DeviceConfigParams struct {
+		VideoParamOpt       *VideoParamOpt       `xml:"VideoParamOpt,omitempty" json:"videoParamOpt,omitempty"`
+		SVACEncodeConfig    *SVACEncodeConfig    `xml:"SVACEncodeConfig,omitempty" json:"svacEncodeConfig,omitempty"`
+		SVACDecodeConfig    *SVACDecodeConfig    `xml:"SVACDecodeConfig,omitempty" json:"svacDecodeConfig,omitempty"`
+		VideoParamAttribute *VideoParamAttribute `xml:"VideoParamAttribute,omitempty" json:"videoParamAttribute,omitempty"`
+		VideoRecordPlan     *VideoRecordPlan     `xml:"VideoRecordPlan,omitempty" json:"videoRecordPlan,omitempty"`
+		VideoAlarmRecord    *VideoAlarmRecord    `xml:"VideoAlarmRecord,omitempty" json:"videoAlarmRecord,omitempty"`
+		PictureMask         *PictureMask         `xml:"PictureMask,omitempty" json:"pictureMask,omitempty"`
+		// 画面翻转，0为不翻转，1为水平翻转，2为垂直翻转，3为旋转180度
+		FrameMirror *int         `xml:"FrameMirror,omitempty" json:"frameMirror,omitempty"`
+		AlarmReport *AlarmReport `xml:"AlarmReport,omitempty" json:"alarmReport,omitempty"`
+		OSDConfig   *OSDConfig   `xml:"OSDConfig,omitempty" json:"osdConfig,omitempty"`
+	}
+
+	// DeviceConfigResp 设备配置查询应答
+	DeviceConfigResp struct {
+		Mata
+		R
+		BasicParam *BasicParam `xml:"BasicParam"`
+		DeviceConfigParams
+	}
+
+	// DeviceConfigRequest 修改设备配置，可同时修改多种配置
+	DeviceConfigRequest struct {
+		DeviceId string `json:"deviceId" binding:"required"`
+		// 配置的通道id，为空时配置设备
+		ChannelId string `json:"channelId,omitempty"`
+		DeviceConfigParams
+	}
+
+	// VideoParamOpt 视频参数范围，多个取值以/分隔
+	VideoParamOpt struct {
+		// 下载倍速
+		DownloadSpeed string `xml:"DownloadSpeed,omitempty" json:"downloadSpeed,omitempty"`
+		// 摄像机支持的分辨率
+		Resolution string `xml:"Resolution,omitempty" json:"resolution,omitempty"`
+	}
+
+	// SVACEncodeConfig SVAC编码配置
+	SVACEncodeConfig struct {
+		ROIParam          *ROIParam             `xml:"ROIParam,omitempty" json:"roiParam,omitempty"`
+		SVCParam          *SVCEncodeParam       `xml:"SVCParam,omitempty" json:"svcParam,omitempty"`
+		SurveillanceParam *SurveillanceParam    `xml:"SurveillanceParam,omitempty" json:"surveillanceParam,omitempty"`
+		AudioParam        *SVACAudioEncodeParam `xml:"AudioParam,omitempty" json:"audioParam,omitempty"`
+	}
+
+	// ROIParam 感兴趣区域参数
+	ROIParam struct {
+		// 感兴趣区域开关，0为关闭，1为打开
+		ROIFlag   int       `xml:"ROIFlag" json:"roiFlag"`
+		ROINumber int       `xml:"ROINumber" json:"roiNumber"`
+		Items     []ROIItem `xml:"Item" json:"items,omitempty"`
+		// 背景区域编码质量等级，0为一般，1为较好，2为好，3为很好
+		BackGroundQP int `xml:"BackGroundQP" json:"backGroundQP"`
+		// 背景跳过开关，0为关闭，1为打开
+		BackGroundSkipFlag int `xml:"BackGroundSkipFlag" json:"backGroundSkipFlag"`
+	}
+
+	ROIItem struct {
+		// 感兴趣区域编号，取值：1-16
+		ROISeq int `xml:"ROISeq" json:"roiSeq"`
+		// 区域左上角和右下角的宏块序号
+		TopLeft     int `xml:"TopLeft" json:"topLeft"`
+		BottomRight int `xml:"BottomRight" json:"bottomRight"`
+		// 区域编码质量等级，0为一般，1为较好，2为好，3为很好
+		ROIQP int `xml:"ROIQP" json:"roiQP"`
+	}
+
+	// SVCEncodeParam SVC编码参数
+	SVCEncodeParam struct {
+		// 空域编码方式，0为基本层，1为1级增强，2为2级增强，3为3级增强
+		SVCSpaceDomainMode int `xml:"SVCSpaceDomainMode" json:"svcSpaceDomainMode"`
+		// 时域编码方式，取值同空域编码方式
+		SVCTimeDomainMode int `xml:"SVCTimeDomainMode" json:"svcTimeDomainMode"`
+		// 空域编码能力，只在查询应答中携带
+		SVCSpaceSupportMode int `xml:"SVCSpaceSupportMode,omitempty" json:"svcSpaceSupportMode,omitempty"`
+		// 时域编码能力，只在查询应答中携带
+		SVCTimeSupportMode int `xml:"SVCTimeSupportMode,omitempty" json:"svcTimeSupportMode,omitempty"`
+	}
+
+	// SurveillanceParam 监控专用信息
+	SurveillanceParam struct {
+		// 绝对时间信息开关，0为关闭，1为打开，下同
+		TimeFlag int `xml:"TimeFlag" json:"timeFlag"`
+		// OSD信息开关
+		OSDFlag int `xml:"OSDFlag" json:"osdFlag"`
+		// 智能分析信息开关
+		AIFlag int `xml:"AIFlag" json:"aiFlag"`
+		// 地理信息开关
+		GISFlag int `xml:"GISFlag" json:"gisFlag"`
+	}
+
+	SVACAudioEncodeParam struct {
+		// 声音识别特征参数开关，0为关闭，1为打开
+		AudioRecognitionFlag int `xml:"AudioRecognitionFlag" json:"audioRecognitionFlag"`
+	}
+
+	// SVACDecodeConfig SVAC解码配置
+	SVACDecodeConfig struct {
+		SVCParam          *SVCDecodeParam          `xml:"SVCParam,omitempty" json:"svcParam,omitempty"`
+		SurveillanceParam *SurveillanceDecodeParam `xml:"SurveillanceParam,omitempty" json:"surveillanceParam,omitempty"`
+	}
+
+	// SVCDecodeParam SVC解码参数
+	SVCDecodeParam struct {
+		// 码流显示模式，0为基本层，1为1级增强，2为2级增强，3为3级增强
+		SVCSTMMode int `xml:"SVCSTMMode" json:"svcSTMMode"`
+		// 空域和时域解码能力，只在查询应答中携带
+		SVCSpaceSupportMode int `xml:"SVCSpaceSupportMode,omitempty" json:"svcSpaceSupportMode,omitempty"`
+		SVCTimeSupportMode  int `xml:"SVCTimeSupportMode,omitempty" json:"svcTimeSupportMode,omitempty"`
+	}
+
+	// SurveillanceDecodeParam 监控专用信息显示
+	SurveillanceDecodeParam struct {
+		// 绝对时间信息显示开关，0为关闭，1为打开，下同
+		TimeShowFlag int `xml:"TimeShowFlag" json:"timeShowFlag"`
+		// 监控事件信息显示开关
+		EventShowFlag int `xml:"EventShowFlag" json:"eventShowFlag"`
+		// 报警信息显示开关
+		AlerShowFlag int `xml:"AlerShowtFlag" json:"alerShowFlag"`
+	}
+
+	// VideoParamAttribute 视频参数属性，每路码流一项
+	VideoParamAttribute struct {
+		Num   int                       `xml:"Num,attr" json:"num"`
+		Items []VideoParamAttributeItem `xml:"Item" json:"items"`
+	}
+
+	VideoParamAttributeItem struct {
+		// 码流名称，如Stream1、Stream2
+		StreamName string `xml:"StreamName" json:"streamName"`
+		// 视频编码格式，1为MPEG-4，2为H.264，3为SVAC，4为3GP，5为H.265
+		VideoFormat string `xml:"VideoFormat" json:"videoFormat"`
+		// 分辨率
+		Resolution string `xml:"Resolution" json:"resolution"`
+		// 帧率，取值：0-99
+		FrameRate string `xml:"FrameRate" json:"frameRate"`
+		// 码率类型，1为固定码率，2为可变码率
+		BitRateType string `xml:"BitRateType" json:"bitRateType"`
+		// 视频码率，单位kbps
+		VideoBitRate string `xml:"VideoBitRate" json:"videoBitRate"`
+	}
+
+	// VideoRecordPlan 录像计划
+	VideoRecordPlan struct {
+		// 是否启用录像计划，0为关闭，1为启用
+		RecordEnable int `xml:"RecordEnable" json:"recordEnable"`
+		// 每周录像计划的天数
+		RecordScheduleSumNum int              `xml:"RecordScheduleSumNum" json:"recordScheduleSumNum"`
+		RecordSchedules      []RecordSchedule `xml:"RecordSchedule" json:"recordSchedules,omitempty"`
+		// 录像使用的码流编号，0为主码流，1为子码流1，以此类推
+		StreamNumber int `xml:"StreamNumber" json:"streamNumber"`
+	}
+
+	RecordSchedule struct {
+		// 周几，1为周一，7为周日
+		WeekDayNum int `xml:"WeekDayNum" json:"weekDayNum"`
+		// 当天的录像时间段数量，最多8段
+		TimeSegmentSumNum int           `xml:"TimeSegmentSumNum" json:"timeSegmentSumNum"`
+		TimeSegments      []TimeSegment `xml:"TimeSegment" json:"timeSegments,omitempty"`
+	}
+
+	TimeSegment struct {
+		StartHour int `xml:"StartHour" json:"startHour"`
+		StartMin  int `xml:"StartMin" json:"startMin"`
+		StartSec  int `xml:"StartSec" json:"startSec"`
+		StopHour  int `xml:"StopHour" json:"stopHour"`
+		StopMin   int `xml:"StopMin" json:"stopMin"`
+		StopSec   int `xml:"StopSec" json:"stopSec"`
+	}
+
+	// VideoAlarmRecord 报警录像
+	VideoAlarmRecord struct {
+		// 是否启用报警录像，0为关闭，1为启用
+		RecordEnable int `xml:"RecordEnable" json:"recordEnable"`
+		// 报警后的录像时长，单位秒
+		RecordTime int `xml:"RecordTime" json:"recordTime"`
+		// 报警前的预录时长，单位秒
+		PreRecordTime int `xml:"PreRecordTime" json:"preRecordTime"`
+		// 录像使用的码流编号，0为主码流，1为子码流1，以此类推
+		StreamNumber int `xml:"StreamNumber" json:"streamNumber"`
+	}
+
+	// PictureMask 视频画面遮挡
+	PictureMask struct {
+		// 是否启用画面遮挡，0为关闭，1为启用
+		On     int         `xml:"On" json:"on"`
+		SumNum int         `xml:"SumNum" json:"sumNum"`
+		Region *RegionList `xml:"RegionList,omitempty" json:"regionList,omitempty"`
+	}
+
+	RegionList struct {
+		Items []MaskRegion `xml:"Item" json:"items"`
+	}
+
+	MaskRegion struct {
+		// 区域编号，取值：1-4
+		Seq int `xml:"Seq" json:"seq"`
+		// 区域左上角和右下角的坐标，格式为x1,y1,x2,y2，单位为像素
+		Point string `xml:"Point" json:"point"`
+	}
+
+	// AlarmReport 报警上报开关
+	AlarmReport struct {
+		// 移动侦测事件上报开关，0为关闭，1为打开
+		MotionDetection int `xml:"MotionDetection" json:"motionDetection"`
+		// 区域入侵事件上报开关，0为关闭，1为打开
+		FieldDetection int `xml:"FieldDetection" json:"fieldDetection"`
+	}
+
+	// OSDConfig 前端OSD设置，坐标以画面左上角为原点，单位为像素
+	OSDConfig struct {
+		// 配置窗口的长度和宽度
+		Length int `xml:"Length" json:"length"`
+		Width  int `xml:"Width" json:"width"`
+		// 时间的显示位置
+		TimeX int `xml:"TimeX" json:"timeX"`
+		TimeY int `xml:"TimeY" json:"timeY"`
+		// 是否显示时间，0为不显示，1为显示
+		TimeEnable int `xml:"TimeEnable" json:"timeEnable"`
+		// 时间的显示格式，0为YYYY-MM-DD HH:MM:SS，1为YYYY年MM月DD日 HH:MM:SS
+		TimeType int `xml:"TimeType" json:"timeType"`
+		// 是否显示文字，0为不显示，1为显示
+		TextEnable int       `xml:"TextEnable" json:"textEnable"`
+		SumNum     int       `xml:"SumNum" json:"sumNum"`
+		Items      []OSDText `xml:"Item" json:"items,omitempty"`
+	}
+
+	OSDText struct {
+		Text string `xml:"Text" json:"text"`
+		X    int    `xml:"X" json:"x"`
+		Y    int    `xml:"Y" json:"y"`
+	}
+)
+
+// Types 返回携带的配置类型
+func (p DeviceConfigParams) Types() []string {
+	var types []string
+	if p.VideoParamOpt != nil {
+		types = append(types, ConfigVideoParamOpt)
+	}
+	if p.SVACEncodeConfig != nil {
+		types = append(types, ConfigSVACEncode)
+	}
+	if p.SVACDecodeConfig != nil {
+		types = append(types, ConfigSVACDecode)
+	}
+	if p.VideoParamAttribute != nil {
+		types = append(types, ConfigVideoParamAttribute)
+	}
+	if p.VideoRecordPlan != nil {
+		types = append(types, ConfigVideoRecordPlan)
+	}
+	if p.VideoAlarmRecord != nil {
+		types = append(types, ConfigVideoAlarmRecord)
+	}
+	if p.PictureMask != nil {
+		types = append(types, ConfigPictureMask)
+	}
+	if p.FrameMirror != nil {
+		types = append(types, ConfigFrameMirror)
+	}
+	if p.AlarmReport != nil {
+		types = append(types, ConfigAlarmReport)
+	}
+	if p.OSDConfig != nil {
+		types = append(types, ConfigOSD)
+	}
+	return types
+}
+
+// CheckConfigTypes 校验配置类型是否支持
+func CheckConfigTypes(types []string) error {
+	if len(types) == 0 {
+		return ErrConfigType
+	}
+	for _, t := range types {
+		supported := t == ConfigBasicParam
+		for _, s := range ConfigTypes {
+			if t == s {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return errors.Wrap(ErrConfigType, t)
+		}
+	}
+	return nil
+}
+
+// DeviceConfig 修改设备配置，返回等待设备通过DeviceConfig应答配置结果的任务
+func DeviceConfig(d model.Device, req DeviceConfigRequest) (*syn.Entity, error) {
+	if len(req.Types()) == 0 {
+		return nil, ErrConfigType
+	}
+	targetId := req.ChannelId
+	if targetId == "" {
+		targetId = d.DeviceId
+	}
+	return sendControlWithResult(d, parser.DeviceConfig, targetId, "设备配置", parser.WithElements(req.DeviceConfigParams))
+}
+
+// DeviceConfigQuery 查询设备或通道的配置，多种配置类型合并在一次查询中，返回等待设备通过ConfigDownload应答的任务
+func DeviceConfigQuery(d model.Device, targetId string, types ...string) (*syn.Entity, error) {
+	if err := CheckConfigTypes(types); err != nil {
+		return nil, err
+	}
+	return sendQuery(d, parser.ConfigDownloadCmdType, targetId, "查询设备配置", parser.WithCustomKV("ConfigType", strings.Join(types, "/")))
+}
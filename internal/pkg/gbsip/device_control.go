@@ -0,0 +1,140 @@
+package gbsip
+
+import (
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/inysc/GB28181/internal/pkg/syn"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrControlFailed 设备应答的执行结果为ERROR
+	ErrControlFailed  = errors.New("设备执行命令失败")
+	errControlCommand = errors.New("不合规的控制命令")
+)
+
+// TeleBoot 远程启动设备，设备收到后直接重启，不会返回控制结果
+func TeleBoot(d model.Device) error {
+	return sendControlCmd(d, d.DeviceId, "远程启动", parser.WithCustomKV("TeleBoot", "Boot"))
+}
+
+// RecordCmd 开始或停止通道的手动录像
+func RecordCmd(d model.Device, ctl model.RecordControl) (*syn.Entity, error) {
+	var cmd string
+	switch ctl.Command {
+	case "start":
+		cmd = "Record"
+	case "stop":
+		cmd = "StopRecord"
+	default:
+		return nil, errControlCommand
+	}
+	return sendControlWithResult(d, parser.DeviceControl, ctl.ChannelId, "录像控制", parser.WithCustomKV("RecordCmd", cmd))
+}
+
+// GuardCmd 布防或撤防，未指定报警通道时对整个设备生效
+func GuardCmd(d model.Device, ctl model.GuardControl) (*syn.Entity, error) {
+	var cmd string
+	switch ctl.Command {
+	case "set":
+		cmd = "SetGuard"
+	case "reset":
+		cmd = "ResetGuard"
+	default:
+		return nil, errControlCommand
+	}
+	targetId := ctl.ChannelId
+	if targetId == "" {
+		targetId = d.DeviceId
+	}
+	return sendControlWithResult(d, parser.DeviceControl, targetId, "布撤防", parser.WithCustomKV("GuardCmd", cmd))
+}
+
+// IFrameCmd 要求通道立即发送关键帧
+func IFrameCmd(d model.Device, ctl model.IFrameControl) error {
+	// 标准中该字段的名称就是IFameCmd
+	return sendControlCmd(d, ctl.ChannelId, "强制关键帧", parser.WithCustomKV("IFameCmd", "Send"))
+}
+
+// DragZoomCmd 拉框放大或缩小
+func DragZoomCmd(d model.Device, ctl model.DragZoomControl) error {
+	var zoomIn bool
+	switch ctl.Command {
+	case "in":
+		zoomIn = true
+	case "out":
+	default:
+		return errControlCommand
+	}
+	return sendControlCmd(d, ctl.ChannelId, "拉框缩放", parser.WithDragZoom(zoomIn, ctl.DragZoom))
+}
+
+// HomePosition 设置看守位，启用时通道在空闲ResetTime秒后自动调用PresetIndex预置位
+func HomePosition(d model.Device, ctl model.HomePositionControl) (*syn.Entity, error) {
+	if ctl.Enabled {
+		if ctl.PresetIndex < 1 || ctl.PresetIndex > 0xFF {
+			return nil, errors.Wrapf(ErrPTZParam, "预置位号%d", ctl.PresetIndex)
+		}
+		if ctl.ResetTime <= 0 {
+			return nil, errors.Wrapf(ErrPTZParam, "自动归位时间%d", ctl.ResetTime)
+		}
+	}
+	return sendControlWithResult(d, parser.DeviceControl, ctl.ChannelId, "看守位控制", parser.WithHomePosition(ctl.Enabled, ctl.ResetTime, ctl.PresetIndex))
+}
+
+// 发送设备控制命令并等待设备确认收到，不需要等待执行结果
+func sendControlCmd(d model.Device, targetId, name string, kv parser.WithKeyValue) error {
+	return sendControl(d, parser.DeviceControl, targetId, name, kv)
+}
+
+// 发送设备控制或设备配置命令，返回等待设备应答执行结果的任务
+func sendControlWithResult(d model.Device, cmd parser.ControlType, targetId, name string, kv parser.WithKeyValue) (*syn.Entity, error) {
+	task := syn.NewTask(string(cmd))
+	if err := sendControl(d, cmd, targetId, name, kv, parser.WithSN(task.SN())); err != nil {
+		task.Cancel()
+		return nil, err
+	}
+	return task, nil
+}
+
+func sendControl(d model.Device, cmd parser.ControlType, targetId, name string, kvs ...parser.WithKeyValue) error {
+	xml, err := parser.CreateControlXml(cmd, targetId, kvs...)
+	if err != nil {
+		return errors.Wrapf(err, "创建%s请求失败", name)
+	}
+	return sendMessage(d, xml, name)
+}
+
+// 发送查询请求，返回等待设备应答查询结果的任务
+func sendQuery(d model.Device, cmd parser.QueryType, targetId, name string, kvs ...parser.WithKeyValue) (*syn.Entity, error) {
+	task := syn.NewTask(string(cmd))
+	xml, err := parser.CreateQueryXML(cmd, targetId, append(kvs, parser.WithSN(task.SN()))...)
+	if err != nil {
+		task.Cancel()
+		return nil, errors.Wrapf(err, "创建%s请求失败", name)
+	}
+	if err = sendMessage(d, xml, name); err != nil {
+		task.Cancel()
+		return nil, err
+	}
+	return task, nil
+}
+
+// 发送MESSAGE请求并等待设备确认收到
+func sendMessage(d model.Device, body, name string) error {
+	request := sipRequestFactory.createMessageRequest(d, body)
+	logger.Debugf("%s请求：\n%s", name, request)
+	tx, err := c.server.sendRequest(request)
+	if err != nil {
+		return errors.Wrapf(err, "发送%s请求失败", name)
+	}
+	response := getResponse(tx)
+	if response == nil {
+		return errors.Errorf("接收%s确认超时", name)
+	}
+	if !response.IsSuccess() {
+		return errors.Errorf("设备拒绝了%s请求: %d %s", name, response.StatusCode(), response.Reason())
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package gbsip
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage/cache"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/model/constant"
+	"github.com/pkg/errors"
+)
+
+// 下载完成后保留下载信息的时间，供查询下载结果
+const downloadRetention = 24 * time.Hour
+
+// Download 录像文件下载，向设备发送 s=Download 的invite请求，设备会按照指定的倍速推送录像
+func Download(device model.Device, detail model.MediaDetail, streamId, ssrc string, channelId string, rtpPort int, start, end time.Time, speed int) (model.DownloadInfo, error) {
+	logger.Debugf("下载开始，流id: %s, 设备ip: %s, SSRC: %s, rtp端口: %d, 时间段: %s - %s, 倍速: %d\n",
+		streamId, device.Ip, ssrc, rtpPort, start.Format(model.GBTimeLayout), end.Format(model.GBTimeLayout), speed)
+
+	// 先保存下载信息，流媒体的推流鉴权事件需要据此开启录制
+	info := model.DownloadInfo{
+		StreamInfo: model.MustNewStreamInfo(detail.ID, detail.Ip, streamId, ssrc),
+		StartTime:  start.Format(model.GBTimeLayout),
+		EndTime:    end.Format(model.GBTimeLayout),
+		Speed:      speed,
+		Status:     model.DownloadStatusDownloading,
+	}
+	SaveDownloadInfo(info)
+
+	body := createDownloadSdpInfo(detail.Ip, channelId, ssrc, rtpPort, start, end, speed)
+	if _, err := invite(device, detail, streamId, ssrc, channelId, body); err != nil {
+		_ = cache.Del(fmt.Sprintf("%s:%s", constant.StreamDownloadPrefix, streamId))
+		return model.DownloadInfo{}, err
+	}
+	return info, nil
+}
+
+// SaveDownloadInfo 保存录像下载信息
+func SaveDownloadInfo(info model.DownloadInfo) {
+	key := fmt.Sprintf("%s:%s", constant.StreamDownloadPrefix, info.Stream)
+	cache.Set(key, info)
+}
+
+// GetDownloadInfo 根据流id获取录像下载信息
+func GetDownloadInfo(streamId string) (model.DownloadInfo, error) {
+	key := fmt.Sprintf("%s:%s", constant.StreamDownloadPrefix, streamId)
+	j, err := cache.Get(key)
+	if err != nil {
+		return model.DownloadInfo{}, errors.WithMessage(err, "get download info fail")
+	}
+
+	var info model.DownloadInfo
+	err = json.Unmarshal([]byte(j.(string)), &info)
+	if err != nil {
+		return model.DownloadInfo{}, errors.WithMessage(err, "unmarshal json data to struct fail")
+	}
+	return info, nil
+}
+
+// FinishDownload 设备发送完录像文件后，将下载状态置为已完成
+func FinishDownload(streamId string) error {
+	info, err := GetDownloadInfo(streamId)
+	if err != nil {
+		return err
+	}
+	info.Status = model.DownloadStatusFinished
+	info.Progress = 1
+	cache.SetEx(fmt.Sprintf("%s:%s", constant.StreamDownloadPrefix, streamId), info, downloadRetention)
+	return nil
+}
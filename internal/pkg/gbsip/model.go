@@ -74,6 +74,23 @@ type (
 		//(！一报警类型扩展参数。在人侵检测报警时可携带EventType〉事件类型(/Even- tType〉，事件类型取值：1-进入区域；2-离开区域。-〉
 		EventType string `xml:"EventType"`
 	}
+
+	// MobilePositionNotify 移动设备位置数据通知
+	MobilePositionNotify struct {
+		Mata
+		// 产生通知时间
+		Time string `xml:"Time"`
+		// 经度
+		Longitude string `xml:"Longitude"`
+		// 纬度
+		Latitude string `xml:"Latitude"`
+		// 速度，单位：km/h
+		Speed string `xml:"Speed"`
+		// 方向，取值为当前摄像头方向与正北方的顺时针夹角，取值范围0°~360°，单位：°
+		Direction string `xml:"Direction"`
+		// 海拔高度，单位：m
+		Altitude string `xml:"Altitude"`
+	}
 )
 
 // message消息
@@ -132,6 +149,12 @@ type (
 		BasicParam BasicParam `xml:"BasicParam"`
 	}
 
+	// ControlResult 设备控制和设备配置的应答
+	ControlResult struct {
+		Mata
+		R
+	}
+
 	// BasicParam 设备基本配置Basic配置项结构体
 	BasicParam struct {
 		Name string `xml:"Name"`
@@ -166,4 +189,50 @@ type (
 		DeviceID   string `xml:"DeviceID"`
 		DutyStatus string `xml:"DutyStatus"`
 	}
+
+	// PresetInfo 设备预置位查询响应
+	PresetInfo struct {
+		Mata
+		PresetList PresetList `xml:"PresetList"`
+	}
+
+	PresetList struct {
+		Num   int          `xml:"Num,attr"`
+		Items []PresetItem `xml:"Item"`
+	}
+
+	PresetItem struct {
+		PresetID   string `xml:"PresetID" json:"presetId"`
+		PresetName string `xml:"PresetName" json:"presetName"`
+	}
+
+	// RecordInfo 设备录像文件检索响应，文件较多时设备会分多个包返回
+	RecordInfo struct {
+		Mata
+		Name       string     `xml:"Name"`
+		SumNum     int        `xml:"SumNum"`
+		RecordList RecordList `xml:"RecordList"`
+	}
+
+	// RecordList 录像文件列表
+	RecordList struct {
+		Num   int          `xml:"Num,attr"`
+		Items []RecordItem `xml:"Item"`
+	}
+
+	// RecordItem 录像文件信息
+	RecordItem struct {
+		DeviceID  string `xml:"DeviceID"`
+		Name      string `xml:"Name"`
+		FilePath  string `xml:"FilePath"`
+		Address   string `xml:"Address"`
+		StartTime string `xml:"StartTime"`
+		EndTime   string `xml:"EndTime"`
+		// 保密属性，0不涉密、1涉密
+		Secrecy string `xml:"Secrecy"`
+		// 录像产生类型，time、alarm、manual
+		Type       string `xml:"Type"`
+		RecorderID string `xml:"RecorderID"`
+		FileSize   string `xml:"FileSize"`
+	}
 )
@@ -0,0 +1,85 @@
+package gbsip
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/pkg/errors"
+)
+
+// MANSRTSP协议中的CSeq
+var rtspSeq uint32
+
+// Playback 历史回放，向设备发送 s=Playback 的invite请求
+func Playback(device model.Device, detail model.MediaDetail, streamId, ssrc string, channelId string, rtpPort int, start, end time.Time) (model.StreamInfo, error) {
+	logger.Debugf("回放开始，流id: %s, 设备ip: %s, SSRC: %s, rtp端口: %d, 时间段: %s - %s\n",
+		streamId, device.Ip, ssrc, rtpPort, start.Format(model.GBTimeLayout), end.Format(model.GBTimeLayout))
+	body := createPlaybackSdpInfo(detail.Ip, channelId, ssrc, rtpPort, start, end)
+	return invite(device, detail, streamId, ssrc, channelId, body)
+}
+
+// PlaybackControl 在回放会话中发送INFO请求，控制回放的播放、暂停、倍速和拖动
+func PlaybackControl(device model.Device, tx SipTX, ctl model.PlaybackControl) error {
+	body, err := createMansrtspBody(ctl, atomic.AddUint32(&rtspSeq, 1))
+	if err != nil {
+		return err
+	}
+
+	request, err := sipRequestFactory.createInfoRequest(device, tx, body)
+	if err != nil {
+		return err
+	}
+	logger.Debugf("发送回放控制请求：\n%s", request)
+	t, err := c.server.sendRequest(request)
+	if err != nil {
+		logger.Error(err)
+		return errors.Wrap(err, "发送回放控制请求失败")
+	}
+
+	response := getResponse(t)
+	if response == nil {
+		return errors.New("接收回放控制响应超时")
+	}
+	if !response.IsSuccess() {
+		return errors.Errorf("设备拒绝了回放控制请求: %d %s", response.StatusCode(), response.Reason())
+	}
+	return nil
+}
+
+// 创建MANSRTSP协议的消息体
+func createMansrtspBody(ctl model.PlaybackControl, seq uint32) (string, error) {
+	var b strings.Builder
+	switch ctl.Command {
+	case model.PlaybackPlay:
+		b.WriteString("PLAY RTSP/1.0\r\n")
+		b.WriteString(fmt.Sprintf("CSeq: %d\r\n", seq))
+		b.WriteString("Range: npt=now-\r\n")
+	case model.PlaybackPause:
+		b.WriteString("PAUSE RTSP/1.0\r\n")
+		b.WriteString(fmt.Sprintf("CSeq: %d\r\n", seq))
+		b.WriteString("PauseTime: now\r\n")
+	case model.PlaybackScale:
+		switch ctl.Scale {
+		case 0.25, 0.5, 1, 2, 4:
+		default:
+			return "", errors.Errorf("不支持的播放倍速: %v", ctl.Scale)
+		}
+		b.WriteString("PLAY RTSP/1.0\r\n")
+		b.WriteString(fmt.Sprintf("CSeq: %d\r\n", seq))
+		b.WriteString(fmt.Sprintf("Scale: %v\r\n", ctl.Scale))
+	case model.PlaybackSeek:
+		if ctl.Range < 0 {
+			return "", errors.Errorf("拖动的偏移时间不能为负数: %d", ctl.Range)
+		}
+		b.WriteString("PLAY RTSP/1.0\r\n")
+		b.WriteString(fmt.Sprintf("CSeq: %d\r\n", seq))
+		b.WriteString(fmt.Sprintf("Range: npt=%d-\r\n", ctl.Range))
+	default:
+		return "", errors.Errorf("不支持的回放控制命令: %s", ctl.Command)
+	}
+	return b.String(), nil
+}
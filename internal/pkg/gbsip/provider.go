@@ -21,9 +21,10 @@ type (
 )
 
 const (
-	letterBytes    = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	contentTypeXML = "Application/MANSCDP+xml"
-	contentTypeSDP = "APPLICATION/SDP"
+	letterBytes     = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	contentTypeXML  = "Application/MANSCDP+xml"
+	contentTypeSDP  = "APPLICATION/SDP"
+	contentTypeRTSP = "Application/MANSRTSP"
 )
 
 var (
@@ -56,10 +57,8 @@ func (f sipFactory) createMessageRequest(d model.Device, body string) sip.Reques
 	return req
 }
 
-// createInviteRequest 创建invite请求
-func (f sipFactory) createInviteRequest(device model.Device, detail model.MediaDetail, channelId string, ssrc string, rtpPort int) sip.Request {
-	body := createSdpInfo(detail.Ip, channelId, ssrc, rtpPort)
-
+// createInviteRequest 创建invite请求，body为点播、回放或下载的sdp信息
+func (f sipFactory) createInviteRequest(device model.Device, channelId string, ssrc string, body string) sip.Request {
 	requestBuilder := sip.NewRequestBuilder()
 	to := newTo(channelId, device.Ip, device.Port)
 	requestBuilder.SetMethod(sip.INVITE)
@@ -97,15 +96,25 @@ func (f sipFactory) createInviteRequest(device model.Device, detail model.MediaD
 	return request
 }
 
-func (f sipFactory) createSubscribeRequest(device model.Device, body, event string) (sip.Request, error) {
+// createSubscribeRequest 创建订阅请求，dialog不为空时在已有的订阅会话中发送，用于刷新和取消订阅
+func (f sipFactory) createSubscribeRequest(device model.Device, body, event string, expires int, dialog *Subscription) (sip.Request, error) {
 	builder := sip.NewRequestBuilder()
 	to := newTo(device.DeviceId, device.Ip, device.Port)
+	fromTag := randString(32)
+	callID := sip.CallID(randString(32))
+	if dialog != nil {
+		if dialog.ToTag != "" {
+			to.Params = newParams(map[string]string{"tag": dialog.ToTag})
+		}
+		fromTag = dialog.FromTag
+		callID = sip.CallID(dialog.CallId)
+	}
 	// method
 	builder.SetMethod(sip.SUBSCRIBE)
 	// to
 	builder.SetTo(to)
 	// from
-	builder.SetFrom(newFromAddress(newParams(map[string]string{"tag": randString(32)})))
+	builder.SetFrom(newFromAddress(newParams(map[string]string{"tag": fromTag})))
 
 	sipUri := &sip.SipUri{
 		FUser: sip.String{Str: device.DeviceId},
@@ -130,12 +139,11 @@ func (f sipFactory) createSubscribeRequest(device model.Device, body, event stri
 		builder.SetSeqNo(cast.ToUint(ceq))
 	}
 	// callID
-	callID := sip.CallID(fmt.Sprintf("%s", randString(32)))
 	builder.SetCallID(&callID)
 
-	// expires
-	expires := sip.Expires(3600)
-	builder.SetExpires(&expires)
+	// expires，为0时表示取消订阅
+	e := sip.Expires(expires)
+	builder.SetExpires(&e)
 
 	eventHeader := &sip.GenericHeader{
 		HeaderName: "Event",
@@ -150,6 +158,46 @@ func (f sipFactory) createSubscribeRequest(device model.Device, body, event stri
 	return request, nil
 }
 
+// createRegisterRequest 创建向上级平台注册的请求，authHeader不为空时携带认证信息
+func (f sipFactory) createRegisterRequest(p model.Platform, expires int, callId, fromTag string, seq uint32, authHeader, authorization string) (sip.Request, error) {
+	port := sip.Port(cast.ToUint16(p.ServerPort))
+	// 注册请求的From和To都是本平台在上级平台域中的地址
+	aor := &sip.SipUri{
+		FUser: sip.String{Str: config.SIPId()},
+		FHost: p.ServerDomain,
+	}
+	from := &sip.Address{Uri: aor, Params: newParams(map[string]string{"tag": fromTag})}
+	to := &sip.Address{Uri: aor.Clone()}
+
+	callID := sip.CallID(callId)
+	e := sip.Expires(expires)
+	userAgent := sip.UserAgentHeader(config.SIPUserAgent())
+	builder := sip.NewRequestBuilder().
+		SetMethod(sip.REGISTER).
+		SetRecipient(&sip.SipUri{
+			FUser: sip.String{Str: p.ServerId},
+			FHost: p.ServerIp,
+			FPort: &port,
+		}).
+		SetFrom(from).
+		SetTo(to).
+		AddVia(newVia(p.Transport)).
+		SetContact(newTo(config.SIPId(), config.SIPAddress(), config.SIPPort())).
+		SetCallID(&callID).
+		SetSeqNo(uint(seq)).
+		SetExpires(&e).
+		SetUserAgent(&userAgent)
+	if authHeader != "" {
+		builder.AddHeader(&sip.GenericHeader{HeaderName: authHeader, Contents: authorization})
+	}
+
+	request, err := builder.Build()
+	if err != nil {
+		return nil, errors.WithMessage(err, "generate register request fail")
+	}
+	return request, nil
+}
+
 // create bye request
 func (f sipFactory) createByeRequest(channelId string, device model.Device, tx SipTX) (sip.Request, error) {
 
@@ -187,6 +235,39 @@ func (f sipFactory) createByeRequest(channelId string, device model.Device, tx S
 	return request, nil
 }
 
+// createInfoRequest 在已建立的会话中创建INFO请求，用于回放控制
+func (f sipFactory) createInfoRequest(device model.Device, tx SipTX, body string) (sip.Request, error) {
+	fromAddress := newFromAddress(newParams(map[string]string{"tag": tx.FromTag}))
+
+	toAddress := newTo(tx.ChannelId, device.Ip, device.Port)
+	toAddress.Params = newParams(map[string]string{"tag": tx.ToTag})
+
+	callID := sip.CallID(tx.CallId)
+	ceq, err := cache.GetCeq()
+	if err != nil {
+		logger.Error("get ceq in cache fail,", err)
+		ceq = 0
+	}
+	contentType := sip.ContentType(contentTypeRTSP)
+
+	request, err := sip.NewRequestBuilder().
+		SetFrom(fromAddress).
+		SetTo(toAddress).
+		SetMethod(sip.INFO).
+		AddVia(newVia(device.Transport)).
+		SetContact(newTo(config.SIPId(), config.SIPAddress(), config.SIPPort())).
+		SetCallID(&callID).
+		SetSeqNo(cast.ToUint(ceq)).
+		SetContentType(&contentType).
+		SetBody(body).
+		SetRecipient(toAddress.Uri).Build()
+
+	if err != nil {
+		return nil, errors.WithMessage(err, "generate info request fail")
+	}
+	return request, nil
+}
+
 // 从自身SIP服务获取地址返回FromHeader
 func newFromAddress(params sip.Params) *sip.Address {
 	return &sip.Address{
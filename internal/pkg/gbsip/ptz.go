@@ -0,0 +1,203 @@
+package gbsip
+
+import (
+	"fmt"
+
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/inysc/GB28181/internal/pkg/syn"
+	"github.com/pkg/errors"
+)
+
+// 前端设备控制指令的字节4，见GB/T 28181-2016附录A.3
+const (
+	// FI指令，高4位为0100，低4位从高到低分别是光圈缩小、光圈放大、聚焦近、聚焦远
+	fiCmd        = 0x40
+	fiFocusFar   = 0x41
+	fiFocusNear  = 0x42
+	fiIrisOpen   = 0x44
+	fiIrisClose  = 0x48
+	presetSet    = 0x81
+	presetCall   = 0x82
+	presetDelete = 0x83
+	cruiseAdd    = 0x84
+	cruiseRemove = 0x85
+	cruiseSpeed  = 0x86
+	cruiseDwell  = 0x87
+	cruiseStart  = 0x88
+	scanCmd      = 0x89
+	scanSpeed    = 0x8A
+)
+
+// 自动扫描指令的字节6
+const (
+	scanStart         = 0x00
+	scanLeftBoundary  = 0x01
+	scanRightBoundary = 0x02
+)
+
+// 巡航速度、停留时间和扫描速度由字节6和字节7的高4位组成，最大为12位
+const maxPTZValue = 0xFFF
+
+var ErrPTZParam = errors.New("云台控制参数超出范围")
+
+// 生成8字节的前端设备控制指令
+// 字节1固定为A5，字节2为版本和校验位，字节3为地址的低8位，字节4为指令码，
+// 字节5、6为数据1、2，字节7的高4位为数据3，低4位为地址的高4位，字节8为前7个字节之和对256取模
+func buildPTZCmd(code, data1, data2, data3 int) string {
+	data3 = (data3 & 0x0F) << 4
+	checkCode := (0xA5 + 0x0F + 0x01 + code + data1 + data2 + data3) % 0x100
+	return fmt.Sprintf("A50F01%02X%02X%02X%02X%02X", code, data1, data2, data3, checkCode)
+}
+
+func checkByte(values ...int) error {
+	for _, v := range values {
+		if v < 0 || v > 0xFF {
+			return errors.Wrapf(ErrPTZParam, "%d", v)
+		}
+	}
+	return nil
+}
+
+// FI指令，command取值为focusnear、focusfar、irisopen、irisclose、stop
+func createFICode(command string, focusSpeed, irisSpeed int) (string, error) {
+	if err := checkByte(focusSpeed, irisSpeed); err != nil {
+		return "", err
+	}
+	var code int
+	switch command {
+	case "focusfar":
+		code = fiFocusFar
+	case "focusnear":
+		code = fiFocusNear
+	case "irisopen":
+		code = fiIrisOpen
+	case "irisclose":
+		code = fiIrisClose
+	case "stop":
+		code = fiCmd
+	default:
+		return "", errors.New("不合规的控制字符串")
+	}
+	return buildPTZCmd(code, focusSpeed, irisSpeed, 0), nil
+}
+
+// 预置位指令，command取值为set、call、delete，预置位号为1-255
+func createPresetCode(command string, presetId int) (string, error) {
+	if presetId < 1 || presetId > 0xFF {
+		return "", errors.Wrapf(ErrPTZParam, "预置位号%d", presetId)
+	}
+	var code int
+	switch command {
+	case "set":
+		code = presetSet
+	case "call":
+		code = presetCall
+	case "delete":
+		code = presetDelete
+	default:
+		return "", errors.New("不合规的控制字符串")
+	}
+	return buildPTZCmd(code, 0, presetId, 0), nil
+}
+
+// 巡航指令，command取值为add、remove、speed、dwell、start、stop
+// remove的预置位号为0时删除整条巡航，speed和dwell的取值为0-4095
+func createCruiseCode(command string, cruiseId, presetId, value int) (string, error) {
+	if err := checkByte(cruiseId, presetId); err != nil {
+		return "", err
+	}
+	switch command {
+	case "add":
+		return buildPTZCmd(cruiseAdd, cruiseId, presetId, 0), nil
+	case "remove":
+		return buildPTZCmd(cruiseRemove, cruiseId, presetId, 0), nil
+	case "speed", "dwell":
+		if value < 0 || value > maxPTZValue {
+			return "", errors.Wrapf(ErrPTZParam, "%d", value)
+		}
+		code := cruiseSpeed
+		if command == "dwell" {
+			code = cruiseDwell
+		}
+		return buildPTZCmd(code, cruiseId, value&0xFF, value>>8), nil
+	case "start":
+		return buildPTZCmd(cruiseStart, cruiseId, 0, 0), nil
+	case "stop":
+		// 任何云台动作都会停止巡航，使用停止指令
+		return buildPTZCmd(0, 0, 0, 0), nil
+	}
+	return "", errors.New("不合规的控制字符串")
+}
+
+// 自动扫描指令，command取值为start、left、right、speed、stop，speed的取值为0-4095
+func createScanCode(command string, scanId, speed int) (string, error) {
+	if err := checkByte(scanId); err != nil {
+		return "", err
+	}
+	switch command {
+	case "start":
+		return buildPTZCmd(scanCmd, scanId, scanStart, 0), nil
+	case "left":
+		return buildPTZCmd(scanCmd, scanId, scanLeftBoundary, 0), nil
+	case "right":
+		return buildPTZCmd(scanCmd, scanId, scanRightBoundary, 0), nil
+	case "speed":
+		if speed < 0 || speed > maxPTZValue {
+			return "", errors.Wrapf(ErrPTZParam, "%d", speed)
+		}
+		return buildPTZCmd(scanSpeed, scanId, speed&0xFF, speed>>8), nil
+	case "stop":
+		return buildPTZCmd(0, 0, 0, 0), nil
+	}
+	return "", errors.New("不合规的控制字符串")
+}
+
+// ControlFI 控制摄像机的聚焦和光圈
+func ControlFI(d model.Device, ctl model.FIControl) error {
+	cmd, err := createFICode(ctl.Command, ctl.FocusSpeed, ctl.IrisSpeed)
+	if err != nil {
+		return err
+	}
+	return sendPTZCmd(d, ctl.ChannelId, cmd)
+}
+
+// ControlPreset 设置、调用和删除预置位
+func ControlPreset(d model.Device, ctl model.PresetControl) error {
+	cmd, err := createPresetCode(ctl.Command, ctl.PresetId)
+	if err != nil {
+		return err
+	}
+	return sendPTZCmd(d, ctl.ChannelId, cmd)
+}
+
+// ControlCruise 设置和启停巡航
+func ControlCruise(d model.Device, ctl model.CruiseControl) error {
+	value := ctl.Speed
+	if ctl.Command == "dwell" {
+		value = ctl.DwellTime
+	}
+	cmd, err := createCruiseCode(ctl.Command, ctl.CruiseId, ctl.PresetId, value)
+	if err != nil {
+		return err
+	}
+	return sendPTZCmd(d, ctl.ChannelId, cmd)
+}
+
+// ControlScan 设置和启停自动扫描
+func ControlScan(d model.Device, ctl model.ScanControl) error {
+	cmd, err := createScanCode(ctl.Command, ctl.ScanId, ctl.Speed)
+	if err != nil {
+		return err
+	}
+	return sendPTZCmd(d, ctl.ChannelId, cmd)
+}
+
+func sendPTZCmd(d model.Device, channelId, cmd string) error {
+	return sendControlCmd(d, channelId, "云台控制", parser.WithPTZCmd(cmd))
+}
+
+// PresetQuery 查询通道的预置位，返回等待设备应答的任务
+func PresetQuery(d model.Device, channelId string) (*syn.Entity, error) {
+	return sendQuery(d, parser.PresetQueryCmdType, channelId, "预置位查询")
+}
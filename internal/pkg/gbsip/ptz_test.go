@@ -0,0 +1,57 @@
+package gbsip
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestCreatePTZCode(t *testing.T) {
+	convey.Convey("TestCreatePTZCode", t, func() {
+		convey.Convey("for pan tilt zoom", func() {
+			code, err := createPTZCode("up", 0, 0x80, 0x50)
+			convey.So(err, convey.ShouldEqual, nil)
+			convey.So(code, convey.ShouldEqual, "A50F01080080508D")
+		})
+
+		convey.Convey("for speed out of range", func() {
+			_, err := createPTZCode("up", 0, 256, 0)
+			convey.So(errors.Is(err, ErrPTZParam), convey.ShouldBeTrue)
+		})
+	})
+}
+
+func TestCreatePresetCode(t *testing.T) {
+	convey.Convey("TestCreatePresetCode", t, func() {
+		code, err := createPresetCode("call", 1)
+		convey.So(err, convey.ShouldEqual, nil)
+		convey.So(code, convey.ShouldEqual, "A50F018200010038")
+
+		_, err = createPresetCode("set", 0)
+		convey.So(errors.Is(err, ErrPTZParam), convey.ShouldBeTrue)
+	})
+}
+
+func TestCreateCruiseCode(t *testing.T) {
+	convey.Convey("TestCreateCruiseCode", t, func() {
+		convey.Convey("speed uses byte 6 and high 4 bits of byte 7", func() {
+			code, err := createCruiseCode("speed", 1, 0, 0x123)
+			convey.So(err, convey.ShouldEqual, nil)
+			convey.So(code, convey.ShouldEqual, "A50F01860123106F")
+		})
+
+		convey.Convey("for value out of range", func() {
+			_, err := createCruiseCode("dwell", 1, 0, 0x1000)
+			convey.So(errors.Is(err, ErrPTZParam), convey.ShouldBeTrue)
+		})
+	})
+}
+
+func TestCreateFICode(t *testing.T) {
+	convey.Convey("TestCreateFICode", t, func() {
+		code, err := createFICode("irisopen", 0, 0x10)
+		convey.So(err, convey.ShouldEqual, nil)
+		convey.So(code, convey.ShouldEqual, "A50F014400100009")
+	})
+}
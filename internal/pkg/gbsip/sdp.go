@@ -1,52 +1,346 @@
 package gbsip
 
 import (
+	"fmt"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/inysc/GB28181/internal/config"
+	"github.com/inysc/GB28181/internal/pkg/model"
 	sdp "github.com/panjjo/gosdp"
+	"github.com/pkg/errors"
 )
 
+// sdp中的会话名称，用于区分实时点播、历史回放和文件下载
+const (
+	SessionPlay     = "Play"
+	SessionPlayback = "Playback"
+	SessionDownload = "Download"
+)
+
+// sdp会话参数
+type sdpSession struct {
+	name      string
+	mediaIp   string
+	channelId string
+	ssrc      string
+	rtpPort   int
+	// 回放和下载的起止时间，实时点播时为零值
+	start time.Time
+	end   time.Time
+	// 下载倍速，仅在文件下载时有效
+	downloadSpeed int
+	// 为true时本级是发送方，用于应答上级平台的点播
+	sendOnly bool
+	// 语音广播使用的编码，不为空时创建音频的媒体描述
+	audioCodec string
+	// 语音的rtp负载类型，与设备的sdp保持一致
+	audioPayload int
+	// 对讲时为true，双方互相发送语音
+	talk bool
+	// tcp传输时本级的连接方式，active或passive，为空时使用udp
+	setup string
+}
+
+// 语音编码的rtp负载类型和rtpmap
+type audioCodec struct {
+	payload int
+	rtpmap  string
+}
+
+var audioCodecs = map[string]audioCodec{
+	model.AudioCodecPCMA: {payload: 8, rtpmap: "PCMA/8000"},
+	model.AudioCodecPCMU: {payload: 0, rtpmap: "PCMU/8000"},
+	model.AudioCodecAAC:  {payload: 97, rtpmap: "MPEG4-GENERIC/8000"},
+}
+
 func createSdpInfo(mediaIp, channelId, ssrc string, rtpPort int) string {
+	return createSdp(sdpSession{
+		name:      SessionPlay,
+		mediaIp:   mediaIp,
+		channelId: channelId,
+		ssrc:      ssrc,
+		rtpPort:   rtpPort,
+	})
+}
+
+func createPlaybackSdpInfo(mediaIp, channelId, ssrc string, rtpPort int, start, end time.Time) string {
+	return createSdp(sdpSession{
+		name:      SessionPlayback,
+		mediaIp:   mediaIp,
+		channelId: channelId,
+		ssrc:      ssrc,
+		rtpPort:   rtpPort,
+		start:     start,
+		end:       end,
+	})
+}
+
+func createDownloadSdpInfo(mediaIp, channelId, ssrc string, rtpPort int, start, end time.Time, speed int) string {
+	return createSdp(sdpSession{
+		name:          SessionDownload,
+		mediaIp:       mediaIp,
+		channelId:     channelId,
+		ssrc:          ssrc,
+		rtpPort:       rtpPort,
+		start:         start,
+		end:           end,
+		downloadSpeed: speed,
+	})
+}
+
+func createSdp(s sdpSession) string {
 	origin := sdp.Origin{
-		Username:       channelId,
+		Username:       s.originUser(),
 		SessionID:      0,
 		SessionVersion: 0,
 		// Internet
 		NetworkType: "IN",
 		// ipv4
 		AddressType: "IP4",
-		Address:     mediaIp,
+		Address:     s.mediaIp,
+	}
+
+	if s.audioCodec != "" {
+		return createAudioSdp(s, origin)
 	}
 
 	video := sdp.Media{
 		Description: sdp.MediaDescription{
 			Type:     "video",
-			Port:     rtpPort,
+			Port:     s.rtpPort,
 			Protocol: "RTP/RTCP",
 			Formats:  []string{"96", "98", "97"},
 		},
 		Connection: sdp.ConnectionData{
 			NetworkType: "IN",
 			AddressType: "IP4",
-			IP:          net.ParseIP(mediaIp),
+			IP:          net.ParseIP(s.mediaIp),
 			TTL:         0,
 		},
 	}
-	video.AddAttribute("recvonly")
-	video.AddAttribute("rtpmap", "96", "PS/90000")
-	video.AddAttribute("rtpmap", "98", "H264/90000")
-	video.AddAttribute("rtpmap", "97", "MPEG4/90000")
+	if s.sendOnly {
+		video.Description.Formats = []string{"96"}
+		video.AddAttribute("sendonly")
+		video.AddAttribute("rtpmap", "96", "PS/90000")
+	} else {
+		video.AddAttribute("recvonly")
+		video.AddAttribute("rtpmap", "96", "PS/90000")
+		video.AddAttribute("rtpmap", "98", "H264/90000")
+		video.AddAttribute("rtpmap", "97", "MPEG4/90000")
+	}
+	if s.downloadSpeed > 0 {
+		video.AddAttribute("downloadspeed", strconv.Itoa(s.downloadSpeed))
+	}
 
 	msg := sdp.Message{
 		Version: 0,
 		Origin:  origin,
-		Name:    "Play",
+		Name:    s.name,
 		Medias:  sdp.Medias{video},
-		Timing:  []sdp.Timing{{Start: time.Time{}, End: time.Time{}}},
-		SSRC:    ssrc,
+		Timing:  []sdp.Timing{{Start: s.start, End: s.end}},
+		SSRC:    s.ssrc,
+	}
+	// 回放和下载需要通过u字段指定录像的通道
+	if s.name != SessionPlay {
+		msg.URI = fmt.Sprintf("%s:0", s.channelId)
 	}
 	session := msg.Append(sdp.Session{})
 	bytes := session.AppendTo([]byte{})
 	return string(bytes)
 }
+
+// 创建语音广播的sdp，本级是语音的发送方，对讲时同时接收设备的语音
+func createAudioSdp(s sdpSession, origin sdp.Origin) string {
+	codec := audioCodecs[s.audioCodec]
+	payload := strconv.Itoa(s.audioPayload)
+	audio := sdp.Media{
+		Description: sdp.MediaDescription{
+			Type:     "audio",
+			Port:     s.rtpPort,
+			Protocol: "RTP/AVP",
+			Formats:  []string{payload},
+		},
+		Connection: sdp.ConnectionData{
+			NetworkType: "IN",
+			AddressType: "IP4",
+			IP:          net.ParseIP(s.mediaIp),
+		},
+	}
+	if s.setup != "" {
+		audio.Description.Protocol = "TCP/RTP/AVP"
+		audio.AddAttribute("setup", s.setup)
+		audio.AddAttribute("connection", "new")
+	}
+	if s.talk {
+		audio.AddAttribute("sendrecv")
+	} else {
+		audio.AddAttribute("sendonly")
+	}
+	audio.AddAttribute("rtpmap", payload, codec.rtpmap)
+
+	msg := sdp.Message{
+		Version: 0,
+		Origin:  origin,
+		Name:    s.name,
+		Medias:  sdp.Medias{audio},
+		Timing:  []sdp.Timing{{}},
+		SSRC:    s.ssrc,
+	}
+	session := msg.Append(sdp.Session{})
+	return string(session.AppendTo([]byte{}))
+}
+
+// CreateBroadcastAnswerSdp 创建应答设备语音广播INVITE的sdp
+func CreateBroadcastAnswerSdp(mediaIp, ssrc, codec string, localPort int, talk bool, inv model.BroadcastInvite) string {
+	s := sdpSession{
+		name:         SessionPlay,
+		mediaIp:      mediaIp,
+		ssrc:         ssrc,
+		rtpPort:      localPort,
+		audioCodec:   codec,
+		audioPayload: BroadcastPayloadType(codec, inv),
+		talk:         talk,
+	}
+	if !inv.UDP {
+		s.setup = "active"
+		if inv.Active {
+			s.setup = "passive"
+		}
+	}
+	return createSdp(s)
+}
+
+// BroadcastPayloadType 返回语音编码的rtp负载类型，优先使用设备sdp中的负载类型
+func BroadcastPayloadType(codec string, inv model.BroadcastInvite) int {
+	if pt, ok := inv.Payloads[codec]; ok {
+		return pt
+	}
+	return audioCodecs[codec].payload
+}
+
+func (s sdpSession) originUser() string {
+	if s.channelId == "" {
+		return config.SIPId()
+	}
+	return s.channelId
+}
+
+// ParseCascadeInvite 解析上级平台点播请求中的sdp，获取收流地址和ssrc，目前只支持实时点播
+func ParseCascadeInvite(body string) (model.CascadeInvite, error) {
+	msg, err := sdp.Decode([]byte(body))
+	if err != nil {
+		return model.CascadeInvite{}, errors.WithMessage(err, "解析sdp失败")
+	}
+	if msg.Name != SessionPlay {
+		return model.CascadeInvite{}, errors.Errorf("不支持的会话类型: %s", msg.Name)
+	}
+	if len(msg.Medias) == 0 {
+		return model.CascadeInvite{}, errors.New("sdp中没有媒体描述")
+	}
+
+	media := msg.Medias[0]
+	inv := model.CascadeInvite{
+		Port: media.Description.Port,
+		UDP:  !strings.HasPrefix(strings.ToUpper(media.Description.Protocol), "TCP"),
+		SSRC: sdpSSRC(body),
+	}
+	// 上级平台以tcp主动连接时需要本级被动监听，暂不支持
+	if !inv.UDP && media.Attributes.Value("setup") == "active" {
+		return model.CascadeInvite{}, errors.New("不支持tcp被动推流")
+	}
+	ip := media.Connection.IP
+	if ip == nil {
+		ip = msg.Connection.IP
+	}
+	if ip == nil {
+		return model.CascadeInvite{}, errors.New("sdp中没有收流地址")
+	}
+	inv.Ip = ip.String()
+	return inv, nil
+}
+
+// ParseBroadcastInvite 解析设备收到语音广播通知后发起的INVITE中的sdp，获取设备的收流地址和支持的语音编码
+func ParseBroadcastInvite(body string) (model.BroadcastInvite, error) {
+	msg, err := sdp.Decode([]byte(body))
+	if err != nil {
+		return model.BroadcastInvite{}, errors.WithMessage(err, "解析sdp失败")
+	}
+	var audio *sdp.Media
+	for i := range msg.Medias {
+		if msg.Medias[i].Description.Type == "audio" {
+			audio = &msg.Medias[i]
+			break
+		}
+	}
+	if audio == nil {
+		return model.BroadcastInvite{}, errors.New("sdp中没有音频的媒体描述")
+	}
+
+	inv := model.BroadcastInvite{
+		Port: audio.Description.Port,
+		UDP:  !strings.HasPrefix(strings.ToUpper(audio.Description.Protocol), "TCP"),
+		SSRC: sdpSSRC(body),
+	}
+	if !inv.UDP {
+		inv.Active = audio.Attributes.Value("setup") == "active"
+	}
+	ip := audio.Connection.IP
+	if ip == nil {
+		ip = msg.Connection.IP
+	}
+	if ip == nil {
+		return model.BroadcastInvite{}, errors.New("sdp中没有收流地址")
+	}
+	inv.Ip = ip.String()
+
+	// 动态负载类型需要通过rtpmap确定编码
+	rtpmap := make(map[string]string)
+	for _, v := range audio.Attributes.Values("rtpmap") {
+		if f := strings.Fields(v); len(f) == 2 {
+			rtpmap[f[0]] = strings.ToUpper(f[1])
+		}
+	}
+	inv.Payloads = make(map[string]int)
+	for _, format := range audio.Description.Formats {
+		codec := audioCodecName(format, rtpmap[format])
+		if _, ok := inv.Payloads[codec]; codec == "" || ok {
+			continue
+		}
+		inv.Codecs = append(inv.Codecs, codec)
+		inv.Payloads[codec], _ = strconv.Atoi(format)
+	}
+	if len(inv.Codecs) == 0 {
+		return model.BroadcastInvite{}, errors.Errorf("设备不支持PCMA、PCMU和AAC编码: %v", audio.Description.Formats)
+	}
+	return inv, nil
+}
+
+func audioCodecName(payload, rtpmap string) string {
+	switch {
+	case strings.HasPrefix(rtpmap, "PCMA"):
+		return model.AudioCodecPCMA
+	case strings.HasPrefix(rtpmap, "PCMU"):
+		return model.AudioCodecPCMU
+	case strings.HasPrefix(rtpmap, "MPEG4-GENERIC"), strings.HasPrefix(rtpmap, "AAC"):
+		return model.AudioCodecAAC
+	case rtpmap == "" && payload == "8":
+		return model.AudioCodecPCMA
+	case rtpmap == "" && payload == "0":
+		return model.AudioCodecPCMU
+	}
+	return ""
+}
+
+// sdp解析库不解析GB28181扩展的y字段，需要单独获取ssrc
+func sdpSSRC(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "y=") {
+			return strings.TrimPrefix(line, "y=")
+		}
+	}
+	return ""
+}
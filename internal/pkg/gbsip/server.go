@@ -18,9 +18,8 @@ type Server struct {
 type RequestHandlerMap map[sip.RequestMethod]func(req sip.Request, tx sip.ServerTransaction)
 
 type SipConfig struct {
-	SipOption   *option.SIPOptions
-	MysqlOption *option.MySQLOptions
-	HandlerMap  RequestHandlerMap
+	SipOption  *option.SIPOptions
+	HandlerMap RequestHandlerMap
 }
 
 func NewServer(c *SipConfig) *Server {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/inysc/GB28181/internal/gbserver/storage/cache"
+	"github.com/inysc/GB28181/internal/pkg/event"
 	"github.com/inysc/GB28181/internal/pkg/model/constant"
 	"github.com/pkg/errors"
 )
@@ -24,8 +25,8 @@ type txManage struct{}
 
 var streamSessionManage txManage
 
-// 保存sip事务信息
-func (s txManage) saveStreamSession(deviceId string, channelId string, ssrc string, callId string, fromTag string, toTag string, viaBranch string) {
+// 保存sip事务信息，以流id作为key，实时点播的流id为 deviceId_channelId
+func (s txManage) saveStreamSession(streamId string, deviceId string, channelId string, ssrc string, callId string, fromTag string, toTag string, viaBranch string) {
 	tx := SipTX{
 		DeviceId:  deviceId,
 		ChannelId: channelId,
@@ -36,12 +37,15 @@ func (s txManage) saveStreamSession(deviceId string, channelId string, ssrc stri
 		ViaBranch: viaBranch,
 	}
 
-	key := fmt.Sprintf("%s:%s", constant.StreamTransactionPrefix, deviceId+"_"+channelId)
+	key := fmt.Sprintf("%s:%s", constant.StreamTransactionPrefix, streamId)
 	cache.Set(key, tx)
+	// 设备在会话内发送的消息只携带Call-ID，需要能据此找到对应的流
+	cache.Set(fmt.Sprintf("%s:%s", constant.StreamCallIdPrefix, callId), streamId)
+	event.Publish(event.StreamStart, deviceId, event.Stream{StreamId: streamId, DeviceId: deviceId, ChannelId: channelId})
 }
 
-func (s txManage) getTx(deviceId, channelId string) (SipTX, error) {
-	key := fmt.Sprintf("%s:%s", constant.StreamTransactionPrefix, deviceId+"_"+channelId)
+func (s txManage) getTx(streamId string) (SipTX, error) {
+	key := fmt.Sprintf("%s:%s", constant.StreamTransactionPrefix, streamId)
 	j, err := cache.Get(key)
 
 	if err != nil {
@@ -57,3 +61,49 @@ func (s txManage) getTx(deviceId, channelId string) (SipTX, error) {
 
 	return tx, nil
 }
+
+// 删除缓存中的流信息和sip会话事务
+func (s txManage) clearStreamSession(streamId string, tx SipTX) error {
+	keys := []string{
+		fmt.Sprintf("%s:%s", constant.StreamInfoPrefix, streamId),
+		fmt.Sprintf("%s:%s", constant.StreamTransactionPrefix, streamId),
+		fmt.Sprintf("%s:%s", constant.StreamCallIdPrefix, tx.CallId),
+	}
+	for _, key := range keys {
+		if err := cache.Del(key); err != nil {
+			return errors.WithMessage(err, "delete cache by key fail")
+		}
+	}
+	event.Publish(event.StreamStop, tx.DeviceId, event.Stream{StreamId: streamId, DeviceId: tx.DeviceId, ChannelId: tx.ChannelId})
+	return nil
+}
+
+// ClearStreamSession 清理流信息和sip会话事务，用于会话已经由设备结束，无需再发送BYE的场景
+func ClearStreamSession(streamId string) error {
+	tx, err := streamSessionManage.getTx(streamId)
+	if err != nil {
+		return err
+	}
+	return streamSessionManage.clearStreamSession(streamId, tx)
+}
+
+// StreamSession 根据流id获取对应的sip会话事务
+func StreamSession(streamId string) (SipTX, error) {
+	return streamSessionManage.getTx(streamId)
+}
+
+// StreamIdByCallId 根据会话的Call-ID获取对应的流id
+func StreamIdByCallId(callId string) (string, error) {
+	key := fmt.Sprintf("%s:%s", constant.StreamCallIdPrefix, callId)
+	j, err := cache.Get(key)
+	if err != nil {
+		return "", errors.WithMessage(err, "get stream id by call id fail")
+	}
+
+	var streamId string
+	err = json.Unmarshal([]byte(j.(string)), &streamId)
+	if err != nil {
+		return "", errors.WithMessage(err, "unmarshal json data to string fail")
+	}
+	return streamId, nil
+}
@@ -0,0 +1,424 @@
+package gbsip
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ghettovoice/gosip/sip"
+	"github.com/inysc/GB28181/internal/gbserver/storage/cache"
+	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/model/constant"
+	"github.com/inysc/GB28181/internal/pkg/parser"
+	"github.com/pkg/errors"
+)
+
+// 订阅类型，对应订阅请求消息体中的CmdType
+const (
+	SubscribeCatalog        = "Catalog"
+	SubscribeAlarm          = "Alarm"
+	SubscribeMobilePosition = "MobilePosition"
+)
+
+const (
+	// DefaultSubscribeExpires 默认的订阅有效期，单位秒
+	DefaultSubscribeExpires = 3600
+
+	// DefaultPositionInterval 默认的移动设备位置上报间隔，单位秒
+	DefaultPositionInterval = 5
+
+	// 在订阅过期前提前刷新的时间
+	subscribeRefreshAhead = 60 * time.Second
+)
+
+var (
+	ErrUnsupportedSubscribe = errors.New("不支持的订阅类型")
+	ErrSubscriptionNotFound = errors.New("订阅不存在")
+)
+
+// Subscription 订阅会话
+type Subscription struct {
+	DeviceId string `json:"deviceId"`
+
+	// 订阅类型，取值为：Catalog、Alarm、MobilePosition
+	CmdType string `json:"cmdType"`
+
+	// 订阅有效期，单位秒
+	Expires int `json:"expires"`
+
+	// 移动设备位置上报间隔，单位秒，仅MobilePosition订阅有效
+	Interval int `json:"interval,omitempty"`
+
+	// 订阅是否有效，刷新失败或设备终止订阅后为false，设备重新上线时会重新订阅
+	Active bool `json:"active"`
+
+	// 订阅过期时间
+	ExpireAt time.Time `json:"expireAt"`
+
+	// 订阅会话的Call-ID
+	CallId  string `json:"callId"`
+	FromTag string `json:"-"`
+	ToTag   string `json:"-"`
+
+	device model.Device
+	timer  *time.Timer
+}
+
+// 订阅表保存在内存中，每个设备的订阅同时保存到缓存，服务重启后据此重新订阅
+type subscriptionManager struct {
+	mux sync.Mutex
+	// key: deviceId_cmdType
+	subs map[string]*Subscription
+	// 每个设备一把锁，同一设备的订阅、刷新和取消订阅依次执行
+	devices map[string]*sync.Mutex
+}
+
+var subscriptions = &subscriptionManager{
+	subs:    make(map[string]*Subscription),
+	devices: make(map[string]*sync.Mutex),
+}
+
+func subscriptionKey(deviceId, cmdType string) string {
+	return deviceId + "_" + cmdType
+}
+
+// Subscribe 向设备发起订阅，已有订阅时在原会话中刷新，并在过期前自动刷新
+func Subscribe(device model.Device, cmdType string, expires, interval int) error {
+	switch cmdType {
+	case SubscribeCatalog, SubscribeAlarm, SubscribeMobilePosition:
+	default:
+		return errors.Wrap(ErrUnsupportedSubscribe, cmdType)
+	}
+	if expires <= 0 {
+		expires = DefaultSubscribeExpires
+	}
+	if cmdType == SubscribeMobilePosition && interval <= 0 {
+		interval = DefaultPositionInterval
+	}
+	unlock := subscriptions.lock(device.DeviceId)
+	defer unlock()
+	return subscriptions.subscribe(device, cmdType, expires, interval)
+}
+
+// Unsubscribe 取消订阅，向设备发送Expires为0的订阅请求
+func Unsubscribe(deviceId, cmdType string) error {
+	return subscriptions.unsubscribe(deviceId, cmdType)
+}
+
+// Subscriptions 返回设备的所有订阅，deviceId为空时返回全部订阅
+func Subscriptions(deviceId string) []Subscription {
+	return subscriptions.list(deviceId)
+}
+
+// Resubscribe 设备重新上线后，之前建立的订阅会话已经失效，需要重新订阅。
+// 服务重启后内存中没有该设备的订阅时，从缓存中恢复
+func Resubscribe(device model.Device) {
+	subscriptions.resubscribe(device)
+}
+
+// SubscriptionTerminated 设备通知订阅已终止
+func SubscriptionTerminated(callId string) {
+	subscriptions.terminated(callId)
+}
+
+// 锁定设备的订阅，返回解锁函数
+func (m *subscriptionManager) lock(deviceId string) func() {
+	m.mux.Lock()
+	l, ok := m.devices[deviceId]
+	if !ok {
+		l = new(sync.Mutex)
+		m.devices[deviceId] = l
+	}
+	m.mux.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// 发起或刷新订阅，调用方需要先锁定设备
+func (m *subscriptionManager) subscribe(device model.Device, cmdType string, expires, interval int) error {
+	key := subscriptionKey(device.DeviceId, cmdType)
+
+	m.mux.Lock()
+	var dialog *Subscription
+	if old, ok := m.subs[key]; ok && old.Active {
+		d := *old
+		dialog = &d
+	}
+	m.mux.Unlock()
+
+	sub, err := sendSubscribe(device, cmdType, expires, interval, dialog)
+	if err != nil && dialog != nil {
+		// 原订阅会话可能已经被设备丢弃，重新建立会话
+		logger.Warnf("{%s}刷新%s订阅失败，重新订阅：%v", device.DeviceId, cmdType, err)
+		sub, err = sendSubscribe(device, cmdType, expires, interval, nil)
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if old, ok := m.subs[key]; ok && old.timer != nil {
+		old.timer.Stop()
+	}
+	defer m.save(device.DeviceId)
+	if err != nil {
+		// 保留订阅意图，设备重新上线时重新订阅
+		m.subs[key] = &Subscription{
+			DeviceId: device.DeviceId,
+			CmdType:  cmdType,
+			Expires:  expires,
+			Interval: interval,
+			device:   device,
+		}
+		return err
+	}
+	sub.timer = time.AfterFunc(refreshAfter(sub.Expires), func() {
+		m.refresh(device.DeviceId, cmdType)
+	})
+	m.subs[key] = sub
+	return nil
+}
+
+// 订阅到期前刷新，等待锁期间订阅可能已被取消
+func (m *subscriptionManager) refresh(deviceId, cmdType string) {
+	unlock := m.lock(deviceId)
+	defer unlock()
+
+	m.mux.Lock()
+	sub, ok := m.subs[subscriptionKey(deviceId, cmdType)]
+	if !ok || !sub.Active {
+		m.mux.Unlock()
+		return
+	}
+	device, expires, interval := sub.device, sub.Expires, sub.Interval
+	m.mux.Unlock()
+
+	if err := m.subscribe(device, cmdType, expires, interval); err != nil {
+		logger.Errorf("{%s}刷新%s订阅失败：%v", deviceId, cmdType, err)
+	}
+}
+
+func (m *subscriptionManager) unsubscribe(deviceId, cmdType string) error {
+	unlock := m.lock(deviceId)
+	defer unlock()
+	key := subscriptionKey(deviceId, cmdType)
+
+	m.mux.Lock()
+	sub, ok := m.subs[key]
+	if !ok {
+		m.mux.Unlock()
+		return ErrSubscriptionNotFound
+	}
+	delete(m.subs, key)
+	if sub.timer != nil {
+		sub.timer.Stop()
+	}
+	m.save(deviceId)
+	m.mux.Unlock()
+
+	if !sub.Active {
+		return nil
+	}
+	_, err := sendSubscribe(sub.device, sub.CmdType, 0, sub.Interval, sub)
+	return err
+}
+
+func (m *subscriptionManager) list(deviceId string) []Subscription {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	list := make([]Subscription, 0, len(m.subs))
+	for _, sub := range m.subs {
+		if deviceId == "" || sub.DeviceId == deviceId {
+			list = append(list, *sub)
+		}
+	}
+	return list
+}
+
+func (m *subscriptionManager) resubscribe(device model.Device) {
+	unlock := m.lock(device.DeviceId)
+	defer unlock()
+
+	m.mux.Lock()
+	m.restore(device)
+	var subs []Subscription
+	for _, sub := range m.subs {
+		if sub.DeviceId == device.DeviceId {
+			// 使旧的会话失效，重新建立会话
+			sub.Active = false
+			subs = append(subs, *sub)
+		}
+	}
+	m.mux.Unlock()
+
+	for _, sub := range subs {
+		if err := m.subscribe(device, sub.CmdType, sub.Expires, sub.Interval); err != nil {
+			logger.Errorf("{%s}重新订阅%s失败：%v", device.DeviceId, sub.CmdType, err)
+		} else {
+			logger.Infof("{%s}设备重新上线，已重新订阅%s", device.DeviceId, sub.CmdType)
+		}
+	}
+}
+
+func (m *subscriptionManager) terminated(callId string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for _, sub := range m.subs {
+		if sub.CallId == callId {
+			logger.Infof("{%s}设备终止了%s订阅", sub.DeviceId, sub.CmdType)
+			sub.Active = false
+			if sub.timer != nil {
+				sub.timer.Stop()
+			}
+			return
+		}
+	}
+}
+
+func subscriptionCacheKey(deviceId string) string {
+	return fmt.Sprintf("%s:%s", constant.SubscriptionPrefix, deviceId)
+}
+
+// 把设备的订阅保存到缓存，调用方需要持有m.mux
+func (m *subscriptionManager) save(deviceId string) {
+	var list []Subscription
+	for _, sub := range m.subs {
+		if sub.DeviceId == deviceId {
+			list = append(list, Subscription{DeviceId: deviceId, CmdType: sub.CmdType, Expires: sub.Expires, Interval: sub.Interval})
+		}
+	}
+	if len(list) == 0 {
+		if err := cache.Del(subscriptionCacheKey(deviceId)); err != nil {
+			logger.Errorf("{%s}删除缓存中的订阅失败：%v", deviceId, err)
+		}
+		return
+	}
+	cache.Set(subscriptionCacheKey(deviceId), list)
+}
+
+// 内存中没有设备的订阅时从缓存中恢复，恢复的订阅需要重新建立会话，调用方需要持有m.mux
+func (m *subscriptionManager) restore(device model.Device) {
+	for _, sub := range m.subs {
+		if sub.DeviceId == device.DeviceId {
+			return
+		}
+	}
+	j, err := cache.Get(subscriptionCacheKey(device.DeviceId))
+	if err != nil {
+		return
+	}
+	var list []Subscription
+	if err = json.Unmarshal([]byte(j.(string)), &list); err != nil {
+		logger.Errorf("{%s}解析缓存中的订阅失败：%v", device.DeviceId, err)
+		return
+	}
+	for i := range list {
+		sub := list[i]
+		sub.device = device
+		m.subs[subscriptionKey(device.DeviceId, sub.CmdType)] = &sub
+	}
+}
+
+// 发送订阅请求，expires为0时表示取消订阅
+func sendSubscribe(device model.Device, cmdType string, expires, interval int, dialog *Subscription) (*Subscription, error) {
+	body, event, err := subscribeBody(device.DeviceId, cmdType, interval)
+	if err != nil {
+		return nil, err
+	}
+	request, err := sipRequestFactory.createSubscribeRequest(device, body, event, expires, dialog)
+	if err != nil {
+		return nil, errors.Wrapf(err, "创建%s订阅请求失败", cmdType)
+	}
+	logger.Debugf("%s订阅请求：\n%s", cmdType, request)
+	tx, err := c.server.sendRequest(request)
+	if err != nil {
+		logger.Error(err)
+		return nil, errors.Wrapf(err, "发送%s订阅请求失败", cmdType)
+	}
+	response := getResponse(tx)
+	if response == nil {
+		return nil, errors.Errorf("接收%s订阅确认超时", cmdType)
+	}
+	if !response.IsSuccess() {
+		return nil, errors.Errorf("设备拒绝了%s订阅请求: %d %s", cmdType, response.StatusCode(), response.Reason())
+	}
+
+	// 设备可能会调整订阅的有效期
+	if h := response.GetHeaders("Expires"); len(h) > 0 {
+		if e, err := strconv.Atoi(h[0].Value()); err == nil {
+			expires = e
+		}
+	}
+	callId, fromTag, toTag, err := getDialogField(request, response)
+	if err != nil {
+		return nil, err
+	}
+	return &Subscription{
+		DeviceId: device.DeviceId,
+		CmdType:  cmdType,
+		Expires:  expires,
+		Interval: interval,
+		Active:   expires > 0,
+		ExpireAt: time.Now().Add(time.Duration(expires) * time.Second),
+		CallId:   callId,
+		FromTag:  fromTag,
+		ToTag:    toTag,
+		device:   device,
+	}, nil
+}
+
+// 生成订阅请求的消息体和Event头部
+func subscribeBody(deviceId, cmdType string, interval int) (body, event string, err error) {
+	switch cmdType {
+	case SubscribeCatalog:
+		body, err = parser.CreateQueryXML(parser.CatalogCmdType, deviceId)
+		event = "Catalog"
+	case SubscribeAlarm:
+		body, err = parser.CreateQueryXML(parser.AlarmCmdType, deviceId, parser.WithAlarmQuery())
+		event = "presence"
+	case SubscribeMobilePosition:
+		body, err = parser.CreateQueryXML(parser.MobilePositionCmdType, deviceId, parser.WithCustomKV("Interval", strconv.Itoa(interval)))
+		event = "presence"
+	default:
+		return "", "", errors.Wrap(ErrUnsupportedSubscribe, cmdType)
+	}
+	if err != nil {
+		return "", "", errors.Wrapf(err, "创建%s订阅请求body失败", cmdType)
+	}
+	return body, event, nil
+}
+
+// 从订阅请求和响应中获取会话标识
+func getDialogField(request sip.Request, response sip.Response) (callId, fromTag, toTag string, err error) {
+	callID, ok := request.CallID()
+	if !ok {
+		return "", "", "", errors.New("get CallId header in request fail")
+	}
+	fromHeader, ok := request.From()
+	if !ok {
+		return "", "", "", errors.New("get from header in request fail")
+	}
+	ft, ok := fromHeader.Params.Get("tag")
+	if !ok {
+		return "", "", "", errors.New("get tag field in 'from' header fail")
+	}
+	toHeader, ok := response.To()
+	if !ok {
+		return "", "", "", errors.New("get to header in response fail")
+	}
+	// 部分设备的响应中没有To tag
+	if tg, ok := toHeader.Params.Get("tag"); ok {
+		toTag = tg.String()
+	}
+	return callID.Value(), ft.String(), toTag, nil
+}
+
+// 计算刷新订阅的时间，有效期较短时在有效期过半时刷新
+func refreshAfter(expires int) time.Duration {
+	d := time.Duration(expires) * time.Second
+	if d-subscribeRefreshAhead < d/2 {
+		return d / 2
+	}
+	return d - subscribeRefreshAhead
+}
@@ -0,0 +1,35 @@
+package gbsip
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/inysc/GB28181/internal/gbserver/storage/cache"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/option"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestSubscriptionRestore(t *testing.T) {
+	convey.Convey("TestSubscriptionRestore", t, func() {
+		cache.InitCache(&option.CacheOptions{Type: option.CacheMemory}, nil)
+		device := model.Device{DeviceId: "44010200491320000001"}
+		m := &subscriptionManager{subs: make(map[string]*Subscription), devices: make(map[string]*sync.Mutex)}
+		m.subs[subscriptionKey(device.DeviceId, SubscribeAlarm)] = &Subscription{
+			DeviceId: device.DeviceId, CmdType: SubscribeAlarm, Expires: 600, Active: true, CallId: "1",
+		}
+		m.save(device.DeviceId)
+
+		// 服务重启后内存中的订阅丢失，从缓存中恢复的订阅需要重新建立会话
+		restored := &subscriptionManager{subs: make(map[string]*Subscription), devices: make(map[string]*sync.Mutex)}
+		restored.restore(device)
+		list := restored.list(device.DeviceId)
+		convey.So(len(list), convey.ShouldEqual, 1)
+		convey.So(list[0].Expires, convey.ShouldEqual, 600)
+		convey.So(list[0].Active, convey.ShouldBeFalse)
+
+		convey.So(restored.unsubscribe(device.DeviceId, SubscribeAlarm), convey.ShouldBeNil)
+		_, err := cache.Get(subscriptionCacheKey(device.DeviceId))
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
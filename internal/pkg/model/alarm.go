@@ -0,0 +1,75 @@
+package model
+
+import "time"
+
+// Alarm 报警记录表entity
+type Alarm struct {
+	Meta
+	// 上报报警的设备id
+	DeviceId string `json:"deviceId" gorm:"column:deviceId;index;comment:上报报警的设备id"`
+
+	// 报警源的id，可能是设备本身，也可能是设备下的通道
+	ChannelId string `json:"channelId" gorm:"column:channelId;comment:报警源id"`
+
+	// 报警级别，1为一级警情、2为二级警情、3为三级警情、4为四级警情
+	AlarmPriority int `json:"alarmPriority" gorm:"column:alarmPriority;comment:报警级别，1-4级警情"`
+
+	// 报警方式，1为电话报警，2为设备报警，3为短信报警，4为GPS报警，5为视频报警，6为设备故障报警，7为其他报警
+	AlarmMethod int `json:"alarmMethod" gorm:"column:alarmMethod;comment:报警方式"`
+
+	// 报警类型，含义由报警方式决定
+	AlarmType int `json:"alarmType" gorm:"column:alarmType;comment:报警类型"`
+
+	// 事件类型，入侵检测报警时有效，1为进入区域，2为离开区域
+	EventType int `json:"eventType" gorm:"column:eventType;comment:事件类型"`
+
+	// 报警时间
+	AlarmTime time.Time `json:"alarmTime" gorm:"column:alarmTime;index;comment:报警时间"`
+
+	// 报警内容描述
+	Description string `json:"description" gorm:"column:description;comment:报警内容描述"`
+
+	// 经度
+	Longitude float64 `json:"longitude" gorm:"column:longitude;comment:经度"`
+
+	// 纬度
+	Latitude float64 `json:"latitude" gorm:"column:latitude;comment:纬度"`
+
+	// 报警是否已复位
+	Reset bool `json:"reset" gorm:"column:reset;comment:是否已复位"`
+
+	// 复位时间
+	ResetTime *time.Time `json:"resetTime,omitempty" gorm:"column:resetTime;comment:复位时间"`
+}
+
+// AlarmQuery 报警记录查询条件，值为零时不作为查询条件
+type AlarmQuery struct {
+	DeviceId      string `form:"deviceId"`
+	ChannelId     string `form:"channelId"`
+	AlarmPriority int    `form:"priority"`
+	AlarmMethod   int    `form:"method"`
+	AlarmType     int    `form:"type"`
+
+	// 报警时间的范围
+	StartTime time.Time `form:"-"`
+	EndTime   time.Time `form:"-"`
+
+	// 分页参数，页码从1开始
+	Page int `form:"page"`
+	Size int `form:"size"`
+}
+
+// AlarmReset 报警复位请求
+type AlarmReset struct {
+	// 设备id
+	DeviceId string `json:"deviceId" binding:"required"`
+
+	// 报警源的id，为空时复位整个设备
+	ChannelId string `json:"channelId"`
+
+	// 复位的报警方式，为空时复位所有报警方式
+	AlarmMethod string `json:"alarmMethod"`
+
+	// 复位的报警类型，为空时复位所有报警类型
+	AlarmType string `json:"alarmType"`
+}
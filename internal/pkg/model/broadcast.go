@@ -0,0 +1,83 @@
+package model
+
+import (
+	"time"
+)
+
+// 语音广播模式
+const (
+	// BroadcastModeBroadcast 广播，只向设备发送语音
+	BroadcastModeBroadcast = "broadcast"
+	// BroadcastModeTalk 对讲，同时接收设备的语音
+	BroadcastModeTalk = "talk"
+)
+
+// 语音编码
+const (
+	AudioCodecPCMA = "PCMA"
+	AudioCodecPCMU = "PCMU"
+	AudioCodecAAC  = "AAC"
+)
+
+// 语音广播会话的状态
+const (
+	// BroadcastStatusWaiting 已通知设备，等待设备发起INVITE
+	BroadcastStatusWaiting = "waiting"
+	// BroadcastStatusActive 会话已建立，正在向设备推送语音
+	BroadcastStatusActive = "active"
+)
+
+// BroadcastApp 语音广播的流在流媒体中使用的app
+const BroadcastApp = "broadcast"
+
+// BroadcastRequest 开始语音广播或对讲的请求
+type BroadcastRequest struct {
+	// 设备id
+	DeviceId string `json:"deviceId" binding:"required"`
+	// 语音输出通道id
+	ChannelId string `json:"channelId" binding:"required"`
+	// broadcast或talk，默认为broadcast
+	Mode string `json:"mode"`
+	// 期望使用的语音编码，PCMA、PCMU或AAC，设备不支持时使用设备支持的编码，默认为PCMA
+	Codec string `json:"codec"`
+	// 推送到流媒体的语音流，默认为broadcast/{deviceId}_{channelId}
+	App    string `json:"app"`
+	Stream string `json:"stream"`
+}
+
+// BroadcastStop 停止语音广播的请求
+type BroadcastStop struct {
+	DeviceId  string `json:"deviceId" binding:"required"`
+	ChannelId string `json:"channelId" binding:"required"`
+}
+
+// BroadcastSession 语音广播会话
+type BroadcastSession struct {
+	DeviceId      string `json:"deviceId"`
+	ChannelId     string `json:"channelId"`
+	Mode          string `json:"mode"`
+	Codec         string `json:"codec"`
+	Status        string `json:"status"`
+	MediaServerId string `json:"mediaServerId"`
+	App           string `json:"app"`
+	Stream        string `json:"stream"`
+	// 对讲时设备语音流的流id，与App组成设备语音的播放地址
+	TalkStream string    `json:"talkStream,omitempty"`
+	SSRC       string    `json:"ssrc"`
+	CallId     string    `json:"callId,omitempty"`
+	StartTime  time.Time `json:"startTime"`
+}
+
+// BroadcastInvite 设备收到广播通知后发起的INVITE中的语音参数
+type BroadcastInvite struct {
+	Ip   string
+	Port int
+	UDP  bool
+	// tcp传输时设备主动连接本级
+	Active bool
+	SSRC   string
+	// 设备支持的语音编码，按设备的偏好排序
+	Codecs []string
+	// 语音编码在设备sdp中的rtp负载类型
+	Payloads map[string]int
+}
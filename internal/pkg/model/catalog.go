@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// 目录同步状态
+const (
+	CatalogSyncing      = "syncing"
+	CatalogSyncFinished = "finished"
+	CatalogSyncTimeout  = "timeout"
+	CatalogSyncFailed   = "failed"
+)
+
+// CatalogSync 设备目录同步进度
+type CatalogSync struct {
+	// 设备id
+	DeviceId string `json:"deviceId"`
+
+	// 设备应答的SN，收到第一个分包后才有值
+	SN string `json:"sn,omitempty"`
+
+	// 同步状态，syncing、finished、timeout、failed
+	Status string `json:"status"`
+
+	// 设备上报的通道总数
+	Total int `json:"total"`
+
+	// 已经收到的通道数
+	Received int `json:"received"`
+
+	// 同步开始时间
+	StartTime time.Time `json:"startTime"`
+
+	// 同步结束时间
+	EndTime *time.Time `json:"endTime,omitempty"`
+
+	// 同步失败或超时的原因
+	Message string `json:"message,omitempty"`
+}
@@ -1,9 +1,14 @@
 package model
 
+import "time"
+
 type Channel struct {
 	Meta
 	// 设备唯一sipid
-	DeviceId string `json:"DeviceId,omitempty" gorm:"column:deviceId;comment:通道id"`
+	DeviceId string `json:"DeviceId,omitempty" gorm:"column:deviceId;index;comment:通道id"`
+
+	// 类型编码，国标编码的第11-13位，行政区划节点为空
+	TypeCode string `json:"TypeCode,omitempty" gorm:"column:typeCode;comment:类型编码"`
 
 	// 通道名称
 	Name string `json:"Name,omitempty" gorm:"column:name;comment:通道名称"`
@@ -27,7 +32,13 @@ type Channel struct {
 	Parental string `json:"Parental,omitempty" gorm:"column:parental;comment:当为设备时，是否有子设备，1有，0没有"`
 
 	// 父设备/区域/系统ID
-	ParentID string `json:"ParentID,omitempty" gorm:"column:parentId;comment:父设备/区域/系统ID"`
+	ParentID string `json:"ParentID,omitempty" gorm:"column:parentId;index;comment:父设备/区域/系统ID"`
+
+	// 通道所属的设备id，即上报该通道目录的设备，ParentID只表示目录中的层级关系
+	OwnerId string `json:"OwnerId,omitempty" gorm:"column:ownerId;index;comment:通道所属的设备id"`
+
+	// 通道树中的父节点id，保存时由TreeParent计算，为空时是根节点
+	TreeParentId string `json:"-" gorm:"column:treeParentId;index;comment:通道树中的父节点id"`
 
 	// 信令安全模式，0不采用、2 S/MIME签名方式、3 S/MIME加密他签名同时采用方式、4 数字摘要方式
 	SafetyWay string `json:"SafetyWay,omitempty" gorm:"column:safetyWay;comment:信令安全模式，0不采用、2 S/MIME签名方式、3 S/MIME加密他签名同时采用方式、4 数字摘要方式"`
@@ -40,6 +51,15 @@ type Channel struct {
 
 	// 设备状态
 	Status string `json:"Status,omitempty" gorm:"column:status;comment:设备状态"`
+
+	// 最新上报的经度
+	Longitude float64 `json:"Longitude,omitempty" gorm:"column:longitude;comment:最新上报的经度"`
+
+	// 最新上报的纬度
+	Latitude float64 `json:"Latitude,omitempty" gorm:"column:latitude;comment:最新上报的纬度"`
+
+	// 最新上报位置的时间
+	PositionTime *time.Time `json:"PositionTime,omitempty" gorm:"column:positionTime;comment:最新上报位置的时间"`
 }
 
 type CameraExpand struct {
@@ -50,3 +70,48 @@ func NewChannelMust(deviceId string) Channel {
 		DeviceId: deviceId,
 	}
 }
+
+// 目录变化通知中的事件类型
+const (
+	CatalogEventAdd    = "ADD"
+	CatalogEventDel    = "DEL"
+	CatalogEventUpdate = "UPDATE"
+	CatalogEventOn     = "ON"
+	CatalogEventOff    = "OFF"
+	CatalogEventVLost  = "VLOST"
+	CatalogEventDefect = "DEFECT"
+)
+
+// ChannelChange 通道变更记录表entity，记录设备通过目录订阅上报的每一次通道变化
+type ChannelChange struct {
+	Meta
+	// 上报变化的设备id
+	DeviceId string `json:"deviceId" gorm:"column:deviceId;index;comment:上报变化的设备id"`
+
+	// 发生变化的通道id
+	ChannelId string `json:"channelId" gorm:"column:channelId;index;comment:发生变化的通道id"`
+
+	// 通道名称，便于通道删除后仍能辨认
+	Name string `json:"name" gorm:"column:name;comment:通道名称"`
+
+	// 变化事件，ADD、DEL、UPDATE、ON、OFF、VLOST、DEFECT
+	Event string `json:"event" gorm:"column:event;comment:变化事件"`
+
+	// 收到变化通知的时间
+	Time time.Time `json:"time" gorm:"column:time;index;comment:收到变化通知的时间"`
+}
+
+// ChannelChangeQuery 通道变更记录查询条件，值为零时不作为查询条件
+type ChannelChangeQuery struct {
+	DeviceId  string `form:"deviceId"`
+	ChannelId string `form:"channelId"`
+	Event     string `form:"event"`
+
+	// 变化时间的范围
+	StartTime time.Time `form:"-"`
+	EndTime   time.Time `form:"-"`
+
+	// 分页参数，页码从1开始
+	Page int `form:"page"`
+	Size int `form:"size"`
+}
@@ -4,7 +4,11 @@ const (
 	MediaServerPrefix       = "GB:MEDIA:SERVER"
 	StreamInfoPrefix        = "GB:MEDIA:STREAM:INFO"
 	StreamTransactionPrefix = "GB:MEDIA:STREAM:TRANSACTION"
+	StreamCallIdPrefix      = "GB:MEDIA:STREAM:CALLID"
+	StreamDownloadPrefix    = "GB:MEDIA:STREAM:DOWNLOAD"
 	CeqPrefix               = "GB:MEDIA:CEQ"
+	CascadeSessionPrefix    = "GB:CASCADE:SESSION"
+	SubscriptionPrefix      = "GB:SUBSCRIPTION"
 )
 
 const (
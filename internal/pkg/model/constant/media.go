@@ -3,4 +3,10 @@ package constant
 const (
 	MediaGetRtpInfoApiUrl = "http://%s:%d/index/api/getRtpInfo"
 	MediaCreateRtpApiUrl  = "http://%s:%d/index/api/openRtpServer"
+	MediaCloseRtpApiUrl   = "http://%s:%d/index/api/closeRtpServer"
+	MediaGetMediaInfoUrl  = "http://%s:%d/index/api/getMediaInfo"
+	MediaStartSendRtpUrl  = "http://%s:%d/index/api/startSendRtp"
+	MediaStopSendRtpUrl   = "http://%s:%d/index/api/stopSendRtp"
+
+	MediaStartSendRtpPassiveUrl = "http://%s:%d/index/api/startSendRtpPassive"
 )
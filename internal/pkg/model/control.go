@@ -15,6 +15,121 @@ type DeviceControl struct {
 	ZoomSpeed int `json:"zoomSpeed,omitempty"`
 }
 
+// FIControl 聚焦和光圈控制
+type FIControl struct {
+	DeviceId  string `json:"deviceId" binding:"required"`
+	ChannelId string `json:"channelId" binding:"required"`
+	// 控制的命令，取值为：focusnear、focusfar、irisopen、irisclose、stop
+	Command string `json:"command" binding:"required"`
+	// 聚焦速度，取值：0-255
+	FocusSpeed int `json:"focusSpeed,omitempty"`
+	// 光圈速度，取值：0-255
+	IrisSpeed int `json:"irisSpeed,omitempty"`
+}
+
+// PresetControl 预置位控制
+type PresetControl struct {
+	DeviceId  string `json:"deviceId" binding:"required"`
+	ChannelId string `json:"channelId" binding:"required"`
+	// 控制的命令，取值为：set、call、delete
+	Command string `json:"command" binding:"required"`
+	// 预置位号，取值：1-255
+	PresetId int `json:"presetId"`
+}
+
+// CruiseControl 巡航控制
+type CruiseControl struct {
+	DeviceId  string `json:"deviceId" binding:"required"`
+	ChannelId string `json:"channelId" binding:"required"`
+	// 控制的命令，取值为：add（加入巡航点）、remove（删除巡航点）、speed（设置巡航速度）、dwell（设置停留时间）、start、stop
+	Command string `json:"command" binding:"required"`
+	// 巡航组号，取值：0-255
+	CruiseId int `json:"cruiseId"`
+	// 预置位号，remove时为0表示删除整条巡航
+	PresetId int `json:"presetId,omitempty"`
+	// 巡航速度，取值：0-4095
+	Speed int `json:"speed,omitempty"`
+	// 巡航点停留时间，单位秒，取值：0-4095
+	DwellTime int `json:"dwellTime,omitempty"`
+}
+
+// ScanControl 自动扫描控制
+type ScanControl struct {
+	DeviceId  string `json:"deviceId" binding:"required"`
+	ChannelId string `json:"channelId" binding:"required"`
+	// 控制的命令，取值为：start、left（设置左边界）、right（设置右边界）、speed（设置扫描速度）、stop
+	Command string `json:"command" binding:"required"`
+	// 扫描组号，取值：0-255
+	ScanId int `json:"scanId"`
+	// 扫描速度，取值：0-4095
+	Speed int `json:"speed,omitempty"`
+}
+
+// TeleBootControl 远程启动
+type TeleBootControl struct {
+	DeviceId string `json:"deviceId" binding:"required"`
+}
+
+// RecordControl 手动录像控制
+type RecordControl struct {
+	DeviceId  string `json:"deviceId" binding:"required"`
+	ChannelId string `json:"channelId" binding:"required"`
+	// 控制的命令，取值为：start（开始录像）、stop（停止录像）
+	Command string `json:"command" binding:"required"`
+}
+
+// GuardControl 布防和撤防
+type GuardControl struct {
+	DeviceId string `json:"deviceId" binding:"required"`
+	// 报警通道id，为空时对整个设备布防或撤防
+	ChannelId string `json:"channelId,omitempty"`
+	// 控制的命令，取值为：set（布防）、reset（撤防）
+	Command string `json:"command" binding:"required"`
+}
+
+// IFrameControl 强制关键帧
+type IFrameControl struct {
+	DeviceId  string `json:"deviceId" binding:"required"`
+	ChannelId string `json:"channelId" binding:"required"`
+}
+
+// DragZoom 拉框的位置和大小，单位为像素
+type DragZoom struct {
+	// 播放窗口的长度
+	Length int `json:"length" binding:"required"`
+	// 播放窗口的宽度
+	Width int `json:"width" binding:"required"`
+	// 拉框中心的横轴坐标
+	MidPointX int `json:"midPointX"`
+	// 拉框中心的纵轴坐标
+	MidPointY int `json:"midPointY"`
+	// 拉框的长度
+	LengthX int `json:"lengthX" binding:"required"`
+	// 拉框的宽度
+	LengthY int `json:"lengthY" binding:"required"`
+}
+
+// DragZoomControl 拉框放大和缩小
+type DragZoomControl struct {
+	DeviceId  string `json:"deviceId" binding:"required"`
+	ChannelId string `json:"channelId" binding:"required"`
+	// 控制的命令，取值为：in（拉框放大）、out（拉框缩小）
+	Command string `json:"command" binding:"required"`
+	DragZoom
+}
+
+// HomePositionControl 看守位控制
+type HomePositionControl struct {
+	DeviceId  string `json:"deviceId" binding:"required"`
+	ChannelId string `json:"channelId" binding:"required"`
+	// 是否启用看守位
+	Enabled bool `json:"enabled"`
+	// 自动归位时间，单位秒
+	ResetTime int `json:"resetTime,omitempty"`
+	// 调用的预置位号，取值：1-255
+	PresetIndex int `json:"presetIndex,omitempty"`
+}
+
 // DeviceBasicConfigReq 设备基本配置Request对象
 type DeviceBasicConfigReq struct {
 	// 设备国标id
@@ -15,7 +15,7 @@ type Meta struct {
 type Device struct {
 	Meta
 	// 设备的sip唯一id
-	DeviceId string `json:"deviceId" gorm:"column:deviceId;comment:设备的sip唯一id"`
+	DeviceId string `json:"deviceId" gorm:"column:deviceId;index;comment:设备的sip唯一id"`
 
 	// 设备的sip域名
 	Domain string `json:"domain" gorm:"column:domain;comment:设备的sip域名"`
@@ -58,4 +58,16 @@ type Device struct {
 
 	// 心跳超时次数，范围值：3-255
 	HeartBeatCount int `json:"heartBeatCount" gorm:"column:heartBeatCount;comment:心跳超时次数，3-255;default:3"`
+
+	// 设备单独的认证密码，为空时使用sip.password
+	Password string `json:"-" gorm:"column:password;comment:设备认证密码，为空时使用全局密码"`
+
+	// 最新上报的经度
+	Longitude float64 `json:"longitude" gorm:"column:longitude;comment:最新上报的经度"`
+
+	// 最新上报的纬度
+	Latitude float64 `json:"latitude" gorm:"column:latitude;comment:最新上报的纬度"`
+
+	// 最新上报位置的时间
+	PositionTime *time.Time `json:"positionTime,omitempty" gorm:"column:positionTime;comment:最新上报位置的时间"`
 }
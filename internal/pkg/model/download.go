@@ -0,0 +1,28 @@
+package model
+
+// 录像下载状态
+const (
+	DownloadStatusDownloading = "downloading"
+	DownloadStatusFinished    = "finished"
+	DownloadStatusStopped     = "stopped"
+)
+
+// DownloadInfo 录像下载会话信息
+type DownloadInfo struct {
+	StreamInfo
+
+	// 录像起始时间，格式为2006-01-02T15:04:05
+	StartTime string `json:"startTime"`
+
+	// 录像终止时间，格式为2006-01-02T15:04:05
+	EndTime string `json:"endTime"`
+
+	// 下载倍速
+	Speed int `json:"speed"`
+
+	// 下载状态，取值为：downloading、finished、stopped
+	Status string `json:"status"`
+
+	// 下载进度，取值范围为0到1
+	Progress float64 `json:"progress"`
+}
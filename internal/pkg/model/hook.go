@@ -227,6 +227,9 @@ type (
 
 		// 音频采样率
 		SampleRate int `json:"sample_rate,omitempty"`
+
+		// 轨道时长，单位毫秒
+		Duration int64 `json:"duration,omitempty"`
 	}
 )
 
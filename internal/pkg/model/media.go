@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/inysc/GB28181/internal/pkg/gbid"
 	"github.com/inysc/GB28181/internal/pkg/model/constant"
 	"github.com/spf13/cast"
 )
@@ -87,7 +88,7 @@ func NewSsrcConfig(mediaServerId, domain string) SsrcConfig {
 	}
 	return SsrcConfig{
 		MediaServerId: mediaServerId,
-		SsrcPrefix:    domain[3:8],
+		SsrcPrefix:    gbid.SSRCDomain(domain),
 		NotUsed:       noUsed,
 		IsUsed:        make([]string, 0),
 	}
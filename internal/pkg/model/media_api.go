@@ -28,3 +28,38 @@ type CreateRtpServerResp struct {
 	Message
 	Port int `json:"port"`
 }
+
+type GetMediaInfoResp struct {
+	C
+	Message
+	Online      bool    `json:"online,omitempty"`
+	AliveSecond uint    `json:"aliveSecond,omitempty"`
+	TotalBytes  uint64  `json:"totalBytes,omitempty"`
+	Tracks      []Track `json:"tracks,omitempty"`
+}
+
+// SendRtpParam 让流媒体以rtp的方式推送流的参数
+type SendRtpParam struct {
+	App    string
+	Stream string
+	SSRC   string
+	// 目标地址，被动推流时为空
+	DstIp   string
+	DstPort int
+	UDP     bool
+	// 为true时由流媒体监听端口，等待对方以tcp连接
+	Passive bool
+	// 只推送音频，并且不封装为PS，用于语音广播和对讲
+	OnlyAudio bool
+	// rtp负载类型，为0时使用流媒体的默认值
+	PayloadType int
+	// 对讲时在推流的端口上接收对方的流，以该流id发布
+	RecvStream string
+}
+
+// StartSendRtpResp 流媒体开始推送rtp的应答
+type StartSendRtpResp struct {
+	C
+	Message
+	LocalPort int `json:"local_port"`
+}
@@ -0,0 +1,112 @@
+package model
+
+import "time"
+
+// 上级平台的默认注册有效期和心跳参数
+const (
+	DefaultPlatformExpires           = 3600
+	DefaultPlatformKeepaliveInterval = 60
+	DefaultPlatformKeepaliveTimeout  = 3
+)
+
+// Platform 上级平台表entity，本服务作为下级平台向其注册并共享通道
+type Platform struct {
+	Meta
+	// 平台名称
+	Name string `json:"name" gorm:"column:name;comment:平台名称"`
+
+	// 上级平台的SIP编码
+	ServerId string `json:"serverId" binding:"required" gorm:"column:serverId;uniqueIndex;size:20;comment:上级平台SIP编码"`
+
+	// 上级平台的SIP域
+	ServerDomain string `json:"serverDomain" binding:"required" gorm:"column:serverDomain;comment:上级平台SIP域"`
+
+	// 上级平台的ip地址
+	ServerIp string `json:"serverIp" binding:"required" gorm:"column:serverIp;comment:上级平台ip地址"`
+
+	// 上级平台的SIP端口
+	ServerPort string `json:"serverPort" binding:"required" gorm:"column:serverPort;comment:上级平台SIP端口"`
+
+	// 传输协议，UDP或TCP
+	Transport string `json:"transport" gorm:"column:transport;comment:传输协议"`
+
+	// 注册密码
+	Password string `json:"password,omitempty" gorm:"column:password;comment:注册密码"`
+
+	// 注册有效期，单位秒
+	Expires int `json:"expires" gorm:"column:expires;comment:注册有效期"`
+
+	// 心跳周期，单位秒
+	KeepaliveInterval int `json:"keepaliveInterval" gorm:"column:keepaliveInterval;comment:心跳周期"`
+
+	// 心跳连续超时该次数后重新注册
+	KeepaliveTimeout int `json:"keepaliveTimeout" gorm:"column:keepaliveTimeout;comment:心跳超时次数"`
+
+	// 是否共享全部通道，为false时只共享选择的通道
+	ShareAll bool `json:"shareAll" gorm:"column:shareAll;comment:是否共享全部通道"`
+
+	// 是否启用
+	Enable bool `json:"enable" gorm:"column:enable;comment:是否启用"`
+
+	// 是否已注册到上级平台
+	Online bool `json:"online" gorm:"column:online;comment:是否已注册到上级平台"`
+
+	// 最近一次注册成功的时间
+	RegisterTime *time.Time `json:"registerTime,omitempty" gorm:"column:registerTime;comment:最近一次注册成功的时间"`
+}
+
+// WithDefault 补全未设置的注册和心跳参数
+func (p Platform) WithDefault() Platform {
+	if p.Transport == "" {
+		p.Transport = "UDP"
+	}
+	if p.Expires <= 0 {
+		p.Expires = DefaultPlatformExpires
+	}
+	if p.KeepaliveInterval <= 0 {
+		p.KeepaliveInterval = DefaultPlatformKeepaliveInterval
+	}
+	if p.KeepaliveTimeout <= 0 {
+		p.KeepaliveTimeout = DefaultPlatformKeepaliveTimeout
+	}
+	return p
+}
+
+// PlatformChannel 上级平台共享通道表entity
+type PlatformChannel struct {
+	Meta
+	// 上级平台的主键id
+	PlatformId uint `json:"platformId" gorm:"column:platformId;index;comment:上级平台id"`
+
+	// 通道所属的设备id
+	DeviceId string `json:"deviceId" binding:"required" gorm:"column:deviceId;comment:设备id"`
+
+	// 共享的通道id
+	ChannelId string `json:"channelId" binding:"required" gorm:"column:channelId;comment:通道id"`
+}
+
+// CascadeSession 上级平台点播本级通道的会话，以上级平台INVITE的Call-ID作为key
+type CascadeSession struct {
+	PlatformId uint   `json:"platformId"`
+	DeviceId   string `json:"deviceId"`
+	ChannelId  string `json:"channelId"`
+	// 本级点播的流id
+	StreamId string `json:"streamId"`
+	// 本级点播使用的流媒体id，在该流媒体上向上级平台推流
+	MediaServerId string `json:"mediaServerId"`
+	// 上级平台指定的ssrc
+	SSRC string `json:"ssrc"`
+	// 向上级平台推流时使用的本地端口
+	LocalPort int `json:"localPort"`
+}
+
+// CascadeInvite 上级平台点播请求中解析出的参数
+type CascadeInvite struct {
+	ChannelId string
+	// 上级平台的收流地址
+	Ip   string
+	Port int
+	// 是否使用UDP推流，为false时主动以TCP连接上级平台
+	UDP  bool
+	SSRC string
+}
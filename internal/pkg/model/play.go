@@ -68,9 +68,11 @@ const (
 )
 
 func MustNewStreamInfo(mediaId, mediaIp, stream, ssrc string) StreamInfo {
-	index := strings.Index(stream, "_")
-	deviceId := stream[0:index]
-	channelID := stream[index:]
+	// 流id的格式为 deviceId_channelId，回放等会话会在后面追加其他标识
+	var deviceId, channelID string
+	if s := strings.Split(stream, "_"); len(s) > 1 {
+		deviceId, channelID = s[0], s[1]
+	}
 	return StreamInfo{
 		MediaServerId: mediaId,
 		App:           "rtp",
@@ -91,3 +93,26 @@ func MustNewStreamInfo(mediaId, mediaIp, stream, ssrc string) StreamInfo {
 // SipTransaction transaction info of sip request
 type SipTransaction struct {
 }
+
+// 回放控制命令
+const (
+	PlaybackPlay  = "play"
+	PlaybackPause = "pause"
+	PlaybackScale = "scale"
+	PlaybackSeek  = "seek"
+)
+
+// PlaybackControl 历史回放控制请求
+type PlaybackControl struct {
+	// 回放的流id
+	StreamId string `json:"streamId"`
+
+	// 控制命令，取值为：play、pause、scale、seek
+	Command string `json:"command"`
+
+	// 播放倍速，command为scale时有效，取值为：0.25、0.5、1、2、4
+	Scale float64 `json:"scale,omitempty"`
+
+	// 相对于回放起始时间的偏移秒数，command为seek时有效
+	Range int `json:"range,omitempty"`
+}
@@ -0,0 +1,109 @@
+package model
+
+import "time"
+
+// MobilePosition 移动设备位置记录表entity
+type MobilePosition struct {
+	Meta
+	// 上报位置的设备id
+	DeviceId string `json:"deviceId" gorm:"column:deviceId;index:idx_position_device_time;comment:上报位置的设备id"`
+
+	// 位置所属的id，可能是设备本身，也可能是设备下的通道
+	ChannelId string `json:"channelId" gorm:"column:channelId;comment:位置所属的id"`
+
+	// 定位时间
+	Time time.Time `json:"time" gorm:"column:time;index:idx_position_device_time;comment:定位时间"`
+
+	// 经度
+	Longitude float64 `json:"longitude" gorm:"column:longitude;comment:经度"`
+
+	// 纬度
+	Latitude float64 `json:"latitude" gorm:"column:latitude;comment:纬度"`
+
+	// 速度，单位：km/h
+	Speed float64 `json:"speed" gorm:"column:speed;comment:速度，单位km/h"`
+
+	// 方向，与正北方的顺时针夹角，单位：°
+	Direction float64 `json:"direction" gorm:"column:direction;comment:方向，与正北方的顺时针夹角"`
+
+	// 海拔高度，单位：m
+	Altitude float64 `json:"altitude" gorm:"column:altitude;comment:海拔高度，单位m"`
+}
+
+// PositionQuery 轨迹查询条件
+type PositionQuery struct {
+	DeviceId string
+
+	// 为空时查询设备下所有的位置记录
+	ChannelId string
+
+	StartTime time.Time
+	EndTime   time.Time
+
+	// 最多返回的记录数，为0时不限制
+	Limit int
+}
+
+// GeoJSON 对象，参考 RFC 7946
+type (
+	FeatureCollection struct {
+		Type     string    `json:"type"`
+		Features []Feature `json:"features"`
+	}
+
+	Feature struct {
+		Type       string         `json:"type"`
+		Geometry   Geometry       `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	}
+
+	Geometry struct {
+		Type        string `json:"type"`
+		Coordinates any    `json:"coordinates"`
+	}
+)
+
+// NewTrackGeoJSON 将位置记录转换成GeoJSON，包含一条轨迹线以及每个定位点
+// 位置记录需要按时间升序排列，不同的位置所属id会生成不同的轨迹线
+func NewTrackGeoJSON(positions []MobilePosition) FeatureCollection {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+
+	var (
+		order  []string
+		tracks = make(map[string][][]float64)
+	)
+	for _, p := range positions {
+		if _, ok := tracks[p.ChannelId]; !ok {
+			order = append(order, p.ChannelId)
+		}
+		tracks[p.ChannelId] = append(tracks[p.ChannelId], []float64{p.Longitude, p.Latitude, p.Altitude})
+	}
+	for _, id := range order {
+		// LineString至少需要两个点
+		if len(tracks[id]) < 2 {
+			continue
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:     "Feature",
+			Geometry: Geometry{Type: "LineString", Coordinates: tracks[id]},
+			Properties: map[string]any{
+				"channelId": id,
+			},
+		})
+	}
+
+	for _, p := range positions {
+		fc.Features = append(fc.Features, Feature{
+			Type:     "Feature",
+			Geometry: Geometry{Type: "Point", Coordinates: []float64{p.Longitude, p.Latitude, p.Altitude}},
+			Properties: map[string]any{
+				"deviceId":  p.DeviceId,
+				"channelId": p.ChannelId,
+				"time":      p.Time.Format(GBTimeLayout),
+				"speed":     p.Speed,
+				"direction": p.Direction,
+			},
+		})
+	}
+	return fc
+}
@@ -0,0 +1,28 @@
+package model
+
+// GBTimeLayout 国标协议中时间字段的格式
+const GBTimeLayout = "2006-01-02T15:04:05"
+
+// RecordQuery 录像文件检索请求
+type RecordQuery struct {
+	// 设备国标id
+	DeviceId string `json:"deviceId"`
+
+	// 通道id
+	ChannelId string `json:"channelId"`
+
+	// 录像起始时间，格式为 2006-01-02T15:04:05
+	StartTime string `json:"startTime"`
+
+	// 录像终止时间，格式为 2006-01-02T15:04:05
+	EndTime string `json:"endTime"`
+
+	// 文件路径名，可选
+	FilePath string `json:"filePath,omitempty"`
+
+	// 保密属性，0不涉密、1涉密
+	Secrecy int `json:"secrecy"`
+
+	// 录像产生类型，取值为：time、alarm、manual、all
+	Type string `json:"type,omitempty"`
+}
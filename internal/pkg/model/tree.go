@@ -0,0 +1,67 @@
+package model
+
+import "github.com/inysc/GB28181/internal/pkg/gbid"
+
+// 通道树的节点类型
+const (
+	TreeNodeRegion        = "region"
+	TreeNodeBusinessGroup = "businessGroup"
+	TreeNodeVirtualOrg    = "virtualOrganization"
+	TreeNodeDevice        = "device"
+	TreeNodeChannel       = "channel"
+)
+
+// TreeNode 通道树节点，由行政区划、业务分组、虚拟组织、设备和通道组成
+type TreeNode struct {
+	// 节点id，行政区划为行政区划代码，其余为国标编码
+	Id string `json:"id"`
+
+	// 节点名称
+	Name string `json:"name"`
+
+	// 节点类型，region、businessGroup、virtualOrganization、device、channel
+	Type string `json:"type"`
+
+	// 父节点id，为空时是根节点
+	ParentId string `json:"parentId,omitempty"`
+
+	// 通道所属的设备id，点播时使用，只有通道节点有值
+	DeviceId string `json:"deviceId,omitempty"`
+
+	// 设备或通道的在线状态，ON或OFF
+	Status string `json:"status,omitempty"`
+
+	// 是否有子节点，用于懒加载
+	HasChildren bool `json:"hasChildren"`
+
+	// 从根节点到该节点的父节点路径，只在搜索结果中返回
+	Path []string `json:"path,omitempty"`
+}
+
+// TreeParent 计算目录项在通道树中的父节点。行政区划挂在上一级行政区划下，业务分组是根节点，
+// 虚拟组织挂在业务分组或上级虚拟组织下；通道优先挂在业务分组、虚拟组织或上级通道下，
+// 其次是所在的行政区划，最后是所属设备。设备把自身上报在目录中时返回空，以设备节点为准
+func (c Channel) TreeParent() string {
+	if gbid.IsCivilCode(c.DeviceId) {
+		return c.DeviceId[:len(c.DeviceId)-2]
+	}
+	typeCode := gbid.TypeCode(c.DeviceId)
+	if typeCode == gbid.TypeBusinessGroup || c.DeviceId == c.OwnerId {
+		return ""
+	}
+	if c.ParentID != c.DeviceId && c.ParentID != c.OwnerId {
+		switch parent := gbid.TypeCode(c.ParentID); {
+		case parent == gbid.TypeBusinessGroup || parent == gbid.TypeVirtualOrg:
+			return c.ParentID
+		case typeCode != gbid.TypeVirtualOrg && gbid.Category(parent) == gbid.CategoryPeripheral:
+			return c.ParentID
+		}
+	}
+	if typeCode == gbid.TypeVirtualOrg {
+		return ""
+	}
+	if gbid.IsCivilCode(c.CivilCode) {
+		return c.CivilCode
+	}
+	return c.OwnerId
+}
@@ -0,0 +1,26 @@
+package option
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// 支持的缓存类型
+const (
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+)
+
+// CacheOptions 选择使用的缓存，单机部署可以使用进程内缓存，集群部署需要使用redis共享会话
+type CacheOptions struct {
+	Type string `json:"type,omitempty" mapstructure:"type"`
+}
+
+func NewCacheOptions() *CacheOptions {
+	return &CacheOptions{
+		Type: CacheRedis,
+	}
+}
+
+func (c *CacheOptions) AddFlags(fss *pflag.FlagSet) {
+	fss.StringVar(&c.Type, "cache.type", c.Type, "使用的缓存，取值memory、redis")
+}
@@ -0,0 +1,31 @@
+package option
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// 支持的数据库类型
+const (
+	DatabaseMySQL    = "mysql"
+	DatabaseSQLite   = "sqlite"
+	DatabasePostgres = "postgres"
+)
+
+// DatabaseOptions 选择使用的数据库，各数据库的连接配置在对应的配置项中
+type DatabaseOptions struct {
+	Type string `json:"type,omitempty" mapstructure:"type"`
+	// 启动时是否自动迁移表结构，关闭后需要先执行migrate命令，版本不一致时拒绝启动
+	AutoMigrate bool `json:"auto-migrate" mapstructure:"auto-migrate"`
+}
+
+func NewDatabaseOptions() *DatabaseOptions {
+	return &DatabaseOptions{
+		Type:        DatabaseMySQL,
+		AutoMigrate: true,
+	}
+}
+
+func (d *DatabaseOptions) AddFlags(fss *pflag.FlagSet) {
+	fss.StringVar(&d.Type, "database.type", d.Type, "使用的数据库，取值mysql、sqlite、postgres")
+	fss.BoolVar(&d.AutoMigrate, "database.auto-migrate", d.AutoMigrate, "启动时自动迁移表结构")
+}
@@ -0,0 +1,38 @@
+package option
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// PostgresOptions 定义PostgreSQL数据库的配置选项
+type PostgresOptions struct {
+	Host                  string `json:"host,omitempty" mapstructure:"host"`
+	Port                  string `json:"port" mapstructure:"port"`
+	Username              string `json:"username,omitempty" mapstructure:"username"`
+	Password              string `json:"password,omitempty" mapstructure:"password"`
+	Database              string `json:"database,omitempty" mapstructure:"database"`
+	SSLMode               string `json:"sslmode,omitempty" mapstructure:"sslmode"`
+	MaxIdleConnections    int    `json:"max-idle-connections,omitempty" mapstructure:"max-idle-connections"`
+	MaxOpenConnections    int    `json:"max-open-connections,omitempty" mapstructure:"max-open-connections"`
+	MaxConnectionLifeTime int64  `json:"max-connection-life-time,omitempty" mapstructure:"max-connection-life-time"`
+}
+
+func NewPostgresOptions() *PostgresOptions {
+	return &PostgresOptions{
+		Host:                  "127.0.0.1",
+		Port:                  "5432",
+		SSLMode:               "disable",
+		MaxIdleConnections:    100,
+		MaxOpenConnections:    100,
+		MaxConnectionLifeTime: 10,
+	}
+}
+
+func (p *PostgresOptions) AddFlags(fss *pflag.FlagSet) {
+	fss.StringVar(&p.Host, "postgres.host", p.Host, "postgres数据库的ip地址")
+	fss.StringVar(&p.Port, "postgres.port", p.Port, "postgres数据库的端口")
+	fss.StringVar(&p.SSLMode, "postgres.sslmode", p.SSLMode, "postgres连接的sslmode")
+	fss.IntVar(&p.MaxIdleConnections, "postgres.max-idle-connections", p.MaxIdleConnections, "postgres数据库的最大空闲连接数")
+	fss.IntVar(&p.MaxOpenConnections, "postgres.max-open-connections", p.MaxOpenConnections, "postgres数据库的最大连接数")
+	fss.Int64Var(&p.MaxConnectionLifeTime, "postgres.max-connection-life-time", p.MaxConnectionLifeTime, "postgres数据库连接的最大可重用时间，单位秒")
+}
@@ -11,14 +11,16 @@ type SIPOptions struct {
 	Id        string `json:"id" mapstructure:"id"`
 	Password  string `json:"password,omitempty" mapstructure:"password"`
 	UserAgent string `json:"user-agent" mapstructure:"user-agent"`
+	Algorithm string `json:"algorithm,omitempty" mapstructure:"algorithm"`
 }
 
 func NewSIPOptions() *SIPOptions {
 	return &SIPOptions{
-		Ip:     "127.0.0.1",
-		Port:   "5060",
-		Domain: "4401020049",
-		Id:     "44010200492000000001",
+		Ip:        "127.0.0.1",
+		Port:      "5060",
+		Domain:    "4401020049",
+		Id:        "44010200492000000001",
+		Algorithm: "MD5",
 	}
 
 }
@@ -28,4 +30,6 @@ func (s *SIPOptions) AddFlags(fss *pflag.FlagSet) {
 	fss.StringVar(&s.Port, "sip.port", s.Port, "sip服务监听的端口")
 	fss.StringVar(&s.Domain, "sip.domain", s.Domain, "sip服务器国标域编码")
 	fss.StringVar(&s.Id, "sip.id", s.Id, "sip服务器国标唯一编码")
+	fss.StringVar(&s.Password, "sip.password", s.Password, "设备注册的默认认证密码，为空则不进行校验")
+	fss.StringVar(&s.Algorithm, "sip.algorithm", s.Algorithm, "注册认证使用的摘要算法，取值MD5、SHA-256")
 }
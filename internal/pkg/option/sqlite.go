@@ -0,0 +1,21 @@
+package option
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// SQLiteOptions 定义SQLite数据库的配置选项
+type SQLiteOptions struct {
+	// 数据库文件路径，为:memory:时使用内存数据库
+	Path string `json:"path,omitempty" mapstructure:"path"`
+}
+
+func NewSQLiteOptions() *SQLiteOptions {
+	return &SQLiteOptions{
+		Path: "gb.db",
+	}
+}
+
+func (s *SQLiteOptions) AddFlags(fss *pflag.FlagSet) {
+	fss.StringVar(&s.Path, "sqlite.path", s.Path, "sqlite数据库文件的路径")
+}
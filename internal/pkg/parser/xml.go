@@ -1,16 +1,15 @@
 package parser
 
 import (
-	"crypto/rand"
 	"encoding/xml"
 	"fmt"
 	"io"
-	"math/big"
-	"strconv"
 	"strings"
 
 	"github.com/beevik/etree"
 	"github.com/inysc/GB28181/internal/pkg/logger"
+	"github.com/inysc/GB28181/internal/pkg/model"
+	"github.com/inysc/GB28181/internal/pkg/syn"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
 	"golang.org/x/text/encoding/simplifiedchinese"
@@ -46,6 +45,8 @@ const (
 	ConfigDownloadCmdType QueryType = "ConfigDownload"
 	PresetQueryCmdType    QueryType = "PresetQuery"
 	MobilePositionCmdType QueryType = "MobilePosition"
+	KeepaliveCmdType      QueryType = "Keepalive"
+	BroadcastCmdType      QueryType = "Broadcast"
 )
 
 // CreateQueryXML create catalog query request xml of sip message and return
@@ -92,6 +93,56 @@ func CreateControlXml(cmd ControlType, deviceId string, kvs ...WithKeyValue) (st
 
 }
 
+// CreateNotifyXML create notify xml of sip message, such as keepalive sent to upper platform
+func CreateNotifyXML(cmd QueryType, deviceId string, kvs ...WithKeyValue) (string, error) {
+	return createXML("Notify", string(cmd), getSN(), deviceId, kvs...)
+}
+
+// CreateResponseXML create response xml answering the query of upper platform, sn must be the same as the query
+func CreateResponseXML(cmd QueryType, sn, deviceId string, kvs ...WithKeyValue) (string, error) {
+	return createXML("Response", string(cmd), sn, deviceId, kvs...)
+}
+
+// CreateBroadcastXML create voice broadcast notify xml, sourceId is the id of audio source and targetId is the audio output channel of device
+func CreateBroadcastXML(sourceId, targetId string) (string, error) {
+	document := etree.NewDocument()
+	document.CreateProcInst("xml", "version=\"1.0\" encoding=\"GB2312\"")
+	notify := document.CreateElement("Notify")
+	notify.CreateElement("CmdType").CreateText(string(BroadcastCmdType))
+	notify.CreateElement("SN").CreateText(getSN())
+	notify.CreateElement("SourceID").CreateText(sourceId)
+	notify.CreateElement("TargetID").CreateText(targetId)
+
+	document.Indent(2)
+	body, err := document.WriteToString()
+	if err != nil {
+		logger.Error(err)
+		return "", errors.Wrap(err, "encoding broadcast notify xml fail")
+	}
+	return body, nil
+}
+
+func createXML(root, cmd, sn, deviceId string, kvs ...WithKeyValue) (string, error) {
+	document := etree.NewDocument()
+	document.CreateProcInst("xml", "version=\"1.0\" encoding=\"GB2312\"")
+	element := document.CreateElement(root)
+	element.CreateElement("CmdType").CreateText(cmd)
+	element.CreateElement("SN").CreateText(sn)
+	element.CreateElement("DeviceID").CreateText(deviceId)
+
+	for _, kv := range kvs {
+		kv(element)
+	}
+
+	document.Indent(2)
+	body, err := document.WriteToString()
+	if err != nil {
+		logger.Error(err)
+		return "", errors.Wrapf(err, "encoding %s xml fail", strings.ToLower(root))
+	}
+	return body, nil
+}
+
 // WithFilePath create 'FilePath' item of xml by value
 func WithFilePath(value string) WithKeyValue {
 	return func(element *etree.Element) {
@@ -112,8 +163,8 @@ func WithBasicParams(name string, expiration, heartBeatInterval, heartBeatCount
 		p := element.CreateElement("BasicParam")
 		p.CreateElement("Name").CreateText(name)
 		p.CreateElement("Expiration").CreateText(cast.ToString(expiration))
-		p.CreateElement("HeartBeatInterval").CreateText(cast.ToString(heartBeatCount))
-		p.CreateElement("HeartBeatCount").CreateText(cast.ToString(heartBeatInterval))
+		p.CreateElement("HeartBeatInterval").CreateText(cast.ToString(heartBeatInterval))
+		p.CreateElement("HeartBeatCount").CreateText(cast.ToString(heartBeatCount))
 	}
 }
 
@@ -125,6 +176,89 @@ func WithAlarmQuery() WithKeyValue {
 	}
 }
 
+// WithRecordQuery create items of record info query xml
+func WithRecordQuery(startTime, endTime, filePath string, secrecy int, recordType string) WithKeyValue {
+	return func(element *etree.Element) {
+		element.CreateElement("StartTime").CreateText(startTime)
+		element.CreateElement("EndTime").CreateText(endTime)
+		if filePath != "" {
+			element.CreateElement("FilePath").CreateText(filePath)
+		}
+		element.CreateElement("Secrecy").CreateText(cast.ToString(secrecy))
+		element.CreateElement("Type").CreateText(recordType)
+	}
+}
+
+// WithResetAlarm create 'AlarmCmd' and 'Info' items of reset alarm control xml
+func WithResetAlarm(alarmMethod, alarmType string) WithKeyValue {
+	return func(element *etree.Element) {
+		element.CreateElement("AlarmCmd").CreateText("ResetAlarm")
+		if alarmMethod == "" && alarmType == "" {
+			return
+		}
+		info := element.CreateElement("Info")
+		if alarmMethod != "" {
+			info.CreateElement("AlarmMethod").CreateText(alarmMethod)
+		}
+		if alarmType != "" {
+			info.CreateElement("AlarmType").CreateText(alarmType)
+		}
+	}
+}
+
+// WithDragZoom create 'DragZoomIn' or 'DragZoomOut' item of device control xml
+func WithDragZoom(zoomIn bool, z model.DragZoom) WithKeyValue {
+	return func(element *etree.Element) {
+		name := "DragZoomOut"
+		if zoomIn {
+			name = "DragZoomIn"
+		}
+		p := element.CreateElement(name)
+		p.CreateElement("Length").CreateText(cast.ToString(z.Length))
+		p.CreateElement("Width").CreateText(cast.ToString(z.Width))
+		p.CreateElement("MidPointX").CreateText(cast.ToString(z.MidPointX))
+		p.CreateElement("MidPointY").CreateText(cast.ToString(z.MidPointY))
+		p.CreateElement("LengthX").CreateText(cast.ToString(z.LengthX))
+		p.CreateElement("LengthY").CreateText(cast.ToString(z.LengthY))
+	}
+}
+
+// WithHomePosition create 'HomePosition' item of device control xml
+func WithHomePosition(enabled bool, resetTime, presetIndex int) WithKeyValue {
+	return func(element *etree.Element) {
+		p := element.CreateElement("HomePosition")
+		if !enabled {
+			p.CreateElement("Enabled").CreateText("0")
+			return
+		}
+		p.CreateElement("Enabled").CreateText("1")
+		p.CreateElement("ResetTime").CreateText(cast.ToString(resetTime))
+		p.CreateElement("PresetIndex").CreateText(cast.ToString(presetIndex))
+	}
+}
+
+// WithElements encode v by encoding/xml and append the child items of its root to xml,
+// v is usually a struct whose fields are the config items of device config xml
+func WithElements(v interface{}) WithKeyValue {
+	return func(element *etree.Element) {
+		b, err := xml.Marshal(v)
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		doc := etree.NewDocument()
+		if err = doc.ReadFromBytes(b); err != nil {
+			logger.Error(err)
+			return
+		}
+		if root := doc.Root(); root != nil {
+			for _, child := range root.ChildElements() {
+				element.AddChild(child)
+			}
+		}
+	}
+}
+
 // WithCustomKV create 'k' item of xml by 'v'
 func WithCustomKV(k, v string) WithKeyValue {
 	return func(element *etree.Element) {
@@ -132,12 +266,17 @@ func WithCustomKV(k, v string) WithKeyValue {
 	}
 }
 
-func getSN() string {
-	i, err := rand.Int(rand.Reader, big.NewInt(10))
-	if err != nil {
-		return "9876"
+// WithSN replace the generated SN of xml by sn, the response of device carries the same SN
+func WithSN(sn int) WithKeyValue {
+	return func(element *etree.Element) {
+		if e := element.SelectElement("SN"); e != nil {
+			e.SetText(syn.SNString(sn))
+		}
 	}
-	return strconv.FormatInt((i.Int64()+1)*9876, 10)
+}
+
+func getSN() string {
+	return syn.SNString(syn.NextSN())
 }
 
 // GetCmdTypeFromXML 根据body获取XML配置文件中的根元素
@@ -1,7 +1,11 @@
 package syn
 
 import (
+	"math"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,29 +19,65 @@ var d = &deferredSync{
 	d:   make(map[string]*Entity),
 }
 
-func NewDelayTask(key string, timeout time.Duration) *Entity {
+// 以启动时间作为SN的初始值，重启后不会马上复用上次运行时的SN
+var sn = uint32(time.Now().Unix() % 1000000)
+
+// NextSN 生成单调递增的命令序列号，超过int32范围后从1开始
+func NextSN() int {
+	for {
+		old := atomic.LoadUint32(&sn)
+		next := old + 1
+		if next > math.MaxInt32 {
+			next = 1
+		}
+		if atomic.CompareAndSwapUint32(&sn, old, next) {
+			return int(next)
+		}
+	}
+}
+
+func taskKey(cmdType, sn string) string {
+	return cmdType + "_" + strings.TrimSpace(sn)
+}
+
+// NewTask 创建等待应答的任务，cmdType为应答的CmdType，请求中需要携带任务的SN
+func NewTask(cmdType string) *Entity {
+	e := newEntity(cmdType, NextSN())
 	d.mux.Lock()
 	defer d.mux.Unlock()
-	e := &Entity{
-		key:    key,
-		err:    make(chan error, 1),
-		data:   make(chan interface{}, 1),
-		ticker: time.NewTimer(timeout),
-	}
-	d.d[key] = e
+	d.d[e.key] = e
 	return e
 }
 
-type CallBackFunc func(e *Entity)
+// Deliver 将应答交给对应SN的任务，多包应答的每个分包都需要交付，没有等待的任务时返回false
+func Deliver(cmdType, sn string, data interface{}) bool {
+	return deliver(taskKey(cmdType, sn), packet{data: data})
+}
+
+// Fail 通知对应SN的任务请求失败
+func Fail(cmdType, sn string, err error) bool {
+	return deliver(taskKey(cmdType, sn), packet{err: err})
+}
 
-func HasSyncTask(key string, successCall ...CallBackFunc) bool {
+func deliver(key string, p packet) bool {
 	d.mux.RLock()
-	defer d.mux.RUnlock()
 	e, ok := d.d[key]
-	if len(successCall) > 0 && ok {
-		for _, callBackFunc := range successCall {
-			callBackFunc(e)
-		}
+	d.mux.RUnlock()
+	if !ok {
+		return false
+	}
+	return e.push(p)
+}
+
+func remove(e *Entity) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.d[e.key] == e {
+		delete(d.d, e.key)
 	}
-	return ok
+}
+
+// SNString 将SN转换为请求中携带的格式
+func SNString(sn int) string {
+	return strconv.Itoa(sn)
 }
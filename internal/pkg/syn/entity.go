@@ -1,55 +1,101 @@
 package syn
 
 import (
-	"time"
+	"context"
+	"sync"
 
 	"github.com/inysc/GB28181/internal/pkg/logger"
 	"github.com/pkg/errors"
 )
 
-// todo 等待重构
-type Entity struct {
-	key    string
-	err    chan error
-	data   chan interface{}
-	ticker *time.Timer
-}
+// 一个任务最多缓存的未处理分包数
+const packetBuffer = 64
 
 var (
 	ErrTimeOut = errors.New("time out")
 )
 
-func (e *Entity) Wait() (interface{}, error) {
-	defer e.destroy()
-	select {
-	case err := <-e.err:
-		logger.Error(err)
-		return nil, err
-	case d := <-e.data:
-		return d, nil
-	case <-e.ticker.C:
-		return nil, ErrTimeOut
+type packet struct {
+	data interface{}
+	err  error
+}
+
+// Entity 一次请求的等待任务，以应答的CmdType和请求的SN关联应答
+//
+// 任务结束后只从任务表中移除并关闭done，不会关闭接收应答的通道，迟到的应答会被丢弃
+type Entity struct {
+	key     string
+	sn      int
+	packets chan packet
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newEntity(cmdType string, sn int) *Entity {
+	return &Entity{
+		key:     taskKey(cmdType, SNString(sn)),
+		sn:      sn,
+		packets: make(chan packet, packetBuffer),
+		done:    make(chan struct{}),
 	}
 }
 
-func (e *Entity) destroy() {
-	d.mux.Lock()
-	defer d.mux.Unlock()
-	delete(d.d, e.key)
-	_ = e.Close()
+// SN 请求需要携带的序列号
+func (e *Entity) SN() int {
+	return e.sn
 }
 
-func (e *Entity) Close() error {
-	close(e.data)
-	e.ticker.Stop()
-	close(e.err)
-	return nil
+// Wait 等待单个应答，ctx超时返回ErrTimeOut
+func (e *Entity) Wait(ctx context.Context) (interface{}, error) {
+	var data interface{}
+	err := e.Collect(ctx, func(d interface{}) bool {
+		data = d
+		return true
+	})
+	return data, err
 }
 
-func (e *Entity) Ok(data interface{}) {
-	e.data <- data
+// Collect 逐个接收多包应答，fn返回true表示应答已接收完整
+func (e *Entity) Collect(ctx context.Context, fn func(data interface{}) bool) error {
+	defer e.Cancel()
+	for {
+		select {
+		case p := <-e.packets:
+			if p.err != nil {
+				logger.Error(p.err)
+				return p.err
+			}
+			if fn(p.data) {
+				return nil
+			}
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ErrTimeOut
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// Cancel 结束任务，请求未能发出或不再等待应答时调用，可以重复调用
+func (e *Entity) Cancel() {
+	e.once.Do(func() {
+		remove(e)
+		close(e.done)
+	})
 }
 
-func (e *Entity) Err(err error) {
-	e.err <- err
+func (e *Entity) push(p packet) bool {
+	select {
+	case <-e.done:
+		return false
+	default:
+	}
+	select {
+	case e.packets <- p:
+		return true
+	default:
+		logger.Warnf("任务%s未处理的应答过多，已丢弃", e.key)
+		return false
+	}
 }
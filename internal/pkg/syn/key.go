@@ -1,9 +0,0 @@
-package syn
-
-const (
-	KeyControlDeviceConfigQuery = "CallBack_Control_DeviceConfig_Query"
-)
-
-const (
-	KeyQueryDeviceStatus = "CallBack_Qeury_DeviceStatus"
-)
@@ -0,0 +1,76 @@
+package syn
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestNextSN(t *testing.T) {
+	convey.Convey("TestNextSN", t, func() {
+		a, b := NextSN(), NextSN()
+		convey.So(b, convey.ShouldEqual, a+1)
+	})
+}
+
+func TestTask(t *testing.T) {
+	convey.Convey("TestTask", t, func() {
+		convey.Convey("concurrent tasks of the same command are separated by SN", func() {
+			t1, t2 := NewTask("DeviceStatus"), NewTask("DeviceStatus")
+			convey.So(Deliver("DeviceStatus", strconv.Itoa(t2.SN()), "second"), convey.ShouldBeTrue)
+			convey.So(Deliver("DeviceStatus", strconv.Itoa(t1.SN()), "first"), convey.ShouldBeTrue)
+
+			d1, err := t1.Wait(context.Background())
+			convey.So(err, convey.ShouldEqual, nil)
+			convey.So(d1, convey.ShouldEqual, "first")
+			d2, err := t2.Wait(context.Background())
+			convey.So(err, convey.ShouldEqual, nil)
+			convey.So(d2, convey.ShouldEqual, "second")
+		})
+
+		convey.Convey("multi-packet response", func() {
+			task := NewTask("RecordInfo")
+			sn := " " + strconv.Itoa(task.SN()) + " "
+			for i := 0; i < 3; i++ {
+				Deliver("RecordInfo", sn, i)
+			}
+			var sum int
+			err := task.Collect(context.Background(), func(data interface{}) bool {
+				sum += data.(int)
+				return data.(int) == 2
+			})
+			convey.So(err, convey.ShouldEqual, nil)
+			convey.So(sum, convey.ShouldEqual, 3)
+		})
+
+		convey.Convey("failed response", func() {
+			task := NewTask("DeviceControl")
+			failed := errors.New("failed")
+			Fail("DeviceControl", strconv.Itoa(task.SN()), failed)
+			_, err := task.Wait(context.Background())
+			convey.So(err, convey.ShouldEqual, failed)
+		})
+
+		convey.Convey("timeout and late response", func() {
+			task := NewTask("PresetQuery")
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, err := task.Wait(ctx)
+			convey.So(errors.Is(err, ErrTimeOut), convey.ShouldBeTrue)
+			convey.So(Deliver("PresetQuery", strconv.Itoa(task.SN()), "late"), convey.ShouldBeFalse)
+			task.Cancel()
+		})
+
+		convey.Convey("canceled context", func() {
+			task := NewTask("PresetQuery")
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := task.Wait(ctx)
+			convey.So(errors.Is(err, context.Canceled), convey.ShouldBeTrue)
+		})
+	})
+}
@@ -5,23 +5,44 @@ import (
 	"sync"
 
 	"github.com/inysc/GB28181/internal/config"
+	"github.com/inysc/GB28181/internal/pkg/gbid"
 	"github.com/inysc/GB28181/internal/pkg/model/constant"
+	"github.com/pkg/errors"
 )
 
+// ErrSSRCExhausted 同时进行的会话数达到上限，没有可用的ssrc
+var ErrSSRCExhausted = errors.New("没有可用的ssrc，同时进行的会话数已达到上限")
+
 type ssrc struct {
-	m         sync.Mutex
-	isUsed    []string
+	m sync.Mutex
+	// 已分配的完整ssrc到流水号的映射
+	isUsed    map[string]string
 	isNotUsed []string
 }
 
-func (s *ssrc) getSSRC(t SsrcPrefix) string {
+func (s *ssrc) getSSRC(t SsrcPrefix) (string, error) {
 	s.m.Lock()
 	defer s.m.Unlock()
-	ssrc := s.isNotUsed[0]
-	s.isUsed = append(s.isUsed, ssrc)
+	if len(s.isNotUsed) == 0 {
+		return "", ErrSSRCExhausted
+	}
+	serial := s.isNotUsed[0]
 	s.isNotUsed = s.isNotUsed[1:]
-	key := fmt.Sprintf("%d%s%s", t, config.SIPDomain()[3:8], ssrc)
-	return key
+	key := fmt.Sprintf("%d%s%s", t, gbid.SSRCDomain(config.SIPDomain()), serial)
+	s.isUsed[key] = serial
+	return key, nil
+}
+
+// 归还ssrc的流水号，不是由本服务分配的ssrc（例如设备在sdp中指定的）会被忽略
+func (s *ssrc) releaseSSRC(key string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	serial, ok := s.isUsed[key]
+	if !ok {
+		return
+	}
+	delete(s.isUsed, key)
+	s.isNotUsed = append(s.isNotUsed, serial)
 }
 
 type SsrcPrefix int
@@ -41,11 +62,16 @@ func initSSRC() *ssrc {
 	}
 	return &ssrc{
 		m:         sync.Mutex{},
-		isUsed:    make([]string, 0, constant.MaxStreamCount),
+		isUsed:    make(map[string]string, constant.MaxStreamCount),
 		isNotUsed: noUsed,
 	}
 }
 
-func GetSSRC(t SsrcPrefix) string {
+func GetSSRC(t SsrcPrefix) (string, error) {
 	return ssrcInfo.getSSRC(t)
 }
+
+// ReleaseSSRC 会话结束后归还ssrc，可以重复调用
+func ReleaseSSRC(ssrc string) {
+	ssrcInfo.releaseSSRC(ssrc)
+}
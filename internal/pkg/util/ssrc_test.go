@@ -0,0 +1,29 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestSSRC(t *testing.T) {
+	convey.Convey("TestSSRC", t, func() {
+		s := &ssrc{isUsed: map[string]string{}, isNotUsed: []string{"0001", "0002"}}
+		a, err := s.getSSRC(RealTime)
+		convey.So(err, convey.ShouldBeNil)
+		b, _ := s.getSSRC(History)
+		convey.So(a[0], convey.ShouldEqual, '0')
+		convey.So(b[0], convey.ShouldEqual, '1')
+		_, err = s.getSSRC(RealTime)
+		convey.So(err, convey.ShouldEqual, ErrSSRCExhausted)
+
+		// 重复归还和归还不是本服务分配的ssrc都不会影响ssrc池
+		s.releaseSSRC(a)
+		s.releaseSSRC(a)
+		s.releaseSSRC("0440102999")
+		convey.So(s.isNotUsed, convey.ShouldResemble, []string{"0001"})
+		c, err := s.getSSRC(RealTime)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(c, convey.ShouldEqual, a)
+	})
+}